@@ -0,0 +1,286 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"nofx/config"
+	"nofx/decision"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxExperimentVariants 单个实验最多的变体数
+const maxExperimentVariants = 5
+
+// CreateExperimentRequest 创建A/B实验请求
+type CreateExperimentRequest struct {
+	Name           string                     `json:"name" binding:"required"`
+	BaseTraderID   string                     `json:"base_trader_id" binding:"required"`
+	InitialBalance float64                    `json:"initial_balance"` // 0=沿用基础交易员的初始资金
+	Variants       []config.ExperimentVariant `json:"variants" binding:"required"`
+}
+
+// handleGetExperiments 获取当前用户的实验列表
+func (s *Server) handleGetExperiments(c *gin.Context) {
+	experiments, err := s.database.GetExperiments(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取实验列表失败: %v", err)})
+		return
+	}
+	if experiments == nil {
+		experiments = []*config.Experiment{}
+	}
+	c.JSON(http.StatusOK, experiments)
+}
+
+// handleCreateExperiment 从基础交易员克隆出各变体交易员（相同币种范围、杠杆和初始资金），并保存实验
+func (s *Server) handleCreateExperiment(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req CreateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Variants) < 2 || len(req.Variants) > maxExperimentVariants {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("实验需要 2-%d 个变体", maxExperimentVariants)})
+		return
+	}
+	if req.InitialBalance < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "初始资金不能为负数"})
+		return
+	}
+
+	base, _, _, err := s.database.GetTraderConfig(userID, req.BaseTraderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "基础交易员不存在或无访问权限"})
+		return
+	}
+	initialBalance := base.InitialBalance
+	if req.InitialBalance > 0 {
+		initialBalance = req.InitialBalance
+	}
+
+	// 补全变体配置并校验（未指定的项沿用基础交易员）
+	models, _ := s.database.GetAIModels(userID)
+	exchanges, _ := s.database.GetExchanges(userID)
+	now := time.Now()
+	expID := fmt.Sprintf("exp_%d", now.Unix())
+	labels := make(map[string]bool)
+	exchangeOwners := make(map[string]string)
+	for i := range req.Variants {
+		v := &req.Variants[i]
+		if v.Label = strings.TrimSpace(v.Label); v.Label == "" {
+			v.Label = string(rune('A' + i))
+		}
+		if labels[v.Label] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("变体名称重复: %s", v.Label)})
+			return
+		}
+		labels[v.Label] = true
+
+		if v.SystemPromptTemplate == "" {
+			v.SystemPromptTemplate = base.SystemPromptTemplate
+		} else if _, err := decision.GetPromptTemplate(v.SystemPromptTemplate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("变体 %s: %v", v.Label, err)})
+			return
+		}
+		if v.AIModelID == "" {
+			v.AIModelID = base.AIModelID
+		} else if !hasAIModel(models, v.AIModelID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("变体 %s: AI模型 %s 未配置", v.Label, v.AIModelID)})
+			return
+		}
+		if v.ExchangeID == "" {
+			v.ExchangeID = base.ExchangeID
+		} else if !hasExchange(exchanges, v.ExchangeID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("变体 %s: 交易所 %s 未配置", v.Label, v.ExchangeID)})
+			return
+		}
+		// 同一交易所账户上的变体会共享持仓和余额，结果无法比较
+		if other, shared := exchangeOwners[v.ExchangeID]; shared {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("变体 %s 与 %s 使用同一交易所账户 %s，请为每个变体配置独立的交易所账户", v.Label, other, v.ExchangeID)})
+			return
+		}
+		exchangeOwners[v.ExchangeID] = v.Label
+		if v.CustomPrompt == "" {
+			v.CustomPrompt = base.CustomPrompt
+		}
+		v.TraderID = fmt.Sprintf("%s_%s_%d_exp%d", v.ExchangeID, v.AIModelID, now.Unix(), i+1)
+	}
+
+	// 创建变体交易员，任一步骤失败时删除已创建的变体，避免残留孤立的交易员
+	var created []string
+	rollback := func() {
+		for _, id := range created {
+			if err := s.database.DeleteTrader(userID, id); err != nil {
+				log.Printf("⚠️ 回滚删除变体交易员 %s 失败: %v", id, err)
+			}
+		}
+	}
+	for _, v := range req.Variants {
+		variant := *base
+		variant.ID = v.TraderID
+		variant.Name = fmt.Sprintf("%s [%s]", req.Name, v.Label)
+		variant.AIModelID = v.AIModelID
+		variant.ExchangeID = v.ExchangeID
+		variant.SystemPromptTemplate = v.SystemPromptTemplate
+		variant.CustomPrompt = v.CustomPrompt
+		variant.InitialBalance = initialBalance
+		variant.IsRunning = false
+		if err := s.database.CreateTrader(&variant); err != nil {
+			rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建变体交易员 %s 失败: %v", v.Label, err)})
+			return
+		}
+		created = append(created, variant.ID)
+	}
+
+	exp := &config.Experiment{
+		ID:             expID,
+		UserID:         userID,
+		Name:           req.Name,
+		BaseTraderID:   base.ID,
+		InitialBalance: initialBalance,
+		Variants:       req.Variants,
+		Status:         config.ExperimentCreated,
+		CreatedAt:      now,
+	}
+	if err := s.database.CreateExperiment(exp); err != nil {
+		rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 全部落库成功后再加载到内存
+	for _, id := range created {
+		if err := s.traderManager.LoadTraderByID(s.database, userID, id); err != nil {
+			log.Printf("⚠️ 加载变体交易员到内存失败: %v", err)
+		}
+	}
+
+	log.Printf("🧪 创建实验 %s (%s): %d 个变体，基础交易员 %s", exp.Name, exp.ID, len(exp.Variants), base.Name)
+	c.JSON(http.StatusCreated, exp)
+}
+
+// handleStartExperiment 同时启动所有变体交易员，并记录实验开始时间
+func (s *Server) handleStartExperiment(c *gin.Context) {
+	userID := c.GetString("user_id")
+	exp, err := s.database.GetExperiment(userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "实验不存在或无访问权限"})
+		return
+	}
+	if exp.Status == config.ExperimentRunning {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "实验已在运行中"})
+		return
+	}
+	if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+		log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", userID, err)
+	}
+
+	// 先确认所有变体都可启动，避免部分变体先跑导致起点不一致
+	for _, v := range exp.Variants {
+		trader, err := s.traderManager.GetTrader(v.TraderID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("变体 %s 的交易员不存在", v.Label)})
+			return
+		}
+		if isRunning, ok := trader.GetStatus()["is_running"].(bool); ok && isRunning {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("变体 %s 的交易员已在运行中", v.Label)})
+			return
+		}
+	}
+
+	s.reloadPromptTemplatesWithLog("")
+	startedAt := time.Now()
+	for _, v := range exp.Variants {
+		trader, _ := s.traderManager.GetTrader(v.TraderID)
+		go func(label string) {
+			log.Printf("▶️  启动实验 %s 变体 %s (%s)", exp.Name, label, trader.GetName())
+			if err := trader.Run(); err != nil {
+				log.Printf("❌ 实验 %s 变体 %s 运行错误: %v", exp.Name, label, err)
+			}
+		}(v.Label)
+		if err := s.database.UpdateTraderStatus(userID, v.TraderID, true); err != nil {
+			log.Printf("⚠️  更新交易员状态失败: %v", err)
+		}
+	}
+
+	if err := s.database.UpdateExperimentStatus(userID, exp.ID, config.ExperimentRunning, startedAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("🧪 实验 %s 已启动 (%d 个变体)", exp.Name, len(exp.Variants))
+	c.JSON(http.StatusOK, gin.H{"message": "实验已启动", "started_at": startedAt})
+}
+
+// handleStopExperiment 停止所有变体交易员，并记录实验停止时间
+func (s *Server) handleStopExperiment(c *gin.Context) {
+	userID := c.GetString("user_id")
+	exp, err := s.database.GetExperiment(userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "实验不存在或无访问权限"})
+		return
+	}
+	if exp.Status != config.ExperimentRunning {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "实验未在运行"})
+		return
+	}
+
+	for _, v := range exp.Variants {
+		if trader, err := s.traderManager.GetTrader(v.TraderID); err == nil {
+			trader.Stop()
+		}
+		if err := s.database.UpdateTraderStatus(userID, v.TraderID, false); err != nil {
+			log.Printf("⚠️  更新交易员状态失败: %v", err)
+		}
+	}
+
+	if err := s.database.UpdateExperimentStatus(userID, exp.ID, config.ExperimentStopped, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("⏹  实验 %s 已停止", exp.Name)
+	c.JSON(http.StatusOK, gin.H{"message": "实验已停止"})
+}
+
+// handleExperimentReport 实验对比报告（各变体自启动以来的表现及与对照组的显著性检验）
+func (s *Server) handleExperimentReport(c *gin.Context) {
+	userID := c.GetString("user_id")
+	exp, err := s.database.GetExperiment(userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "实验不存在或无访问权限"})
+		return
+	}
+	if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+		log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", userID, err)
+	}
+
+	report, err := s.traderManager.BuildExperimentReport(exp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("生成实验报告失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func hasAIModel(models []*config.AIModelConfig, id string) bool {
+	for _, m := range models {
+		if m.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func hasExchange(exchanges []*config.ExchangeConfig, id string) bool {
+	for _, ex := range exchanges {
+		if ex.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"nofx/config"
+	"nofx/decision"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LessonRequest 手动添加或编辑交易教训
+type LessonRequest struct {
+	Symbol  string `json:"symbol"` // 空表示通用教训
+	Content string `json:"content" binding:"required"`
+}
+
+// normalize 规范化币种并截断过长的内容
+func (r *LessonRequest) normalize() error {
+	r.Symbol = strings.ToUpper(strings.TrimSpace(r.Symbol))
+	r.Content = decision.TruncateLesson(r.Content)
+	if r.Content == "" {
+		return fmt.Errorf("教训内容不能为空")
+	}
+	return nil
+}
+
+// traderForLessons 校验交易员归属，返回交易员配置
+func (s *Server) traderForLessons(c *gin.Context) (*config.TraderRecord, bool) {
+	traderCfg, _, _, err := s.database.GetTraderConfig(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return nil, false
+	}
+	return traderCfg, true
+}
+
+// handleGetLessons 获取交易员的教训列表（从新到旧）
+func (s *Server) handleGetLessons(c *gin.Context) {
+	traderCfg, ok := s.traderForLessons(c)
+	if !ok {
+		return
+	}
+	lessons, err := s.database.GetTraderLessons(traderCfg.UserID, traderCfg.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if lessons == nil {
+		lessons = []*config.TraderLesson{}
+	}
+	c.JSON(http.StatusOK, lessons)
+}
+
+// handleCreateLesson 手动添加交易教训（下次反思保存时一并计入数量上限）
+func (s *Server) handleCreateLesson(c *gin.Context) {
+	traderCfg, ok := s.traderForLessons(c)
+	if !ok {
+		return
+	}
+	var req LessonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lesson := &config.TraderLesson{Symbol: req.Symbol, Content: req.Content}
+	if err := s.database.AddTraderLessons(traderCfg.UserID, traderCfg.ID, []*config.TraderLesson{lesson}, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "教训已添加"})
+}
+
+// handleUpdateLesson 编辑交易教训
+func (s *Server) handleUpdateLesson(c *gin.Context) {
+	traderCfg, ok := s.traderForLessons(c)
+	if !ok {
+		return
+	}
+	lessonID, err := strconv.ParseInt(c.Param("lessonId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的教训ID"})
+		return
+	}
+	var req LessonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.database.UpdateTraderLesson(traderCfg.UserID, traderCfg.ID, lessonID, req.Symbol, req.Content); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "教训已更新"})
+}
+
+// handleDeleteLesson 删除交易教训
+func (s *Server) handleDeleteLesson(c *gin.Context) {
+	traderCfg, ok := s.traderForLessons(c)
+	if !ok {
+		return
+	}
+	lessonID, err := strconv.ParseInt(c.Param("lessonId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的教训ID"})
+		return
+	}
+	if err := s.database.DeleteTraderLesson(traderCfg.UserID, traderCfg.ID, lessonID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "教训已删除"})
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"nofx/config"
+	"nofx/decision"
+	"nofx/logger"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordPromptTemplateVersions 为当前已加载的系统提示词模板记录版本（内容未变化的模板不会产生新版本）
+func RecordPromptTemplateVersions(database *config.Database) {
+	for _, template := range decision.GetAllPromptTemplates() {
+		version, created, err := database.AddPromptVersion(config.PromptKindTemplate, template.Name, template.Source, template.Hash, "file", "")
+		if err != nil {
+			log.Printf("⚠️  记录提示词模板 %s 版本失败: %v", template.Name, err)
+			continue
+		}
+		if created {
+			log.Printf("📝 提示词模板 %s 新版本 v%d (%s)", template.Name, version.Version, version.Hash[:12])
+		}
+	}
+}
+
+// recordCustomPromptVersion 记录交易员自定义prompt的新版本（作者为当前登录用户）
+func (s *Server) recordCustomPromptVersion(c *gin.Context, traderID, customPrompt, note string) {
+	if _, _, _, err := s.database.GetTraderConfig(c.GetString("user_id"), traderID); err != nil {
+		return
+	}
+	if _, _, err := s.database.AddPromptVersion(config.PromptKindCustom, traderID, customPrompt, "", c.GetString("email"), note); err != nil {
+		log.Printf("⚠️  记录交易员 %s 自定义prompt版本失败: %v", traderID, err)
+	}
+}
+
+// getAccessiblePromptVersion 按路径参数获取提示词版本，自定义prompt校验交易员归属
+func (s *Server) getAccessiblePromptVersion(c *gin.Context) (*config.PromptVersion, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的版本ID"})
+		return nil, false
+	}
+	version, err := s.database.GetPromptVersion(id)
+	if err == nil && version.Kind == config.PromptKindCustom {
+		_, _, _, err = s.database.GetTraderConfig(c.GetString("user_id"), version.Name)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "提示词版本不存在或无访问权限"})
+		return nil, false
+	}
+	return version, true
+}
+
+// handleGetPromptVersions 列出提示词的版本历史（?kind=template|custom&name=模板名或交易员ID）
+func (s *Server) handleGetPromptVersions(c *gin.Context) {
+	kind := c.DefaultQuery("kind", config.PromptKindTemplate)
+	name := c.Query("name")
+	if name == "" || (kind != config.PromptKindTemplate && kind != config.PromptKindCustom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "需要指定 kind（template/custom）和 name"})
+		return
+	}
+	if kind == config.PromptKindCustom {
+		if _, _, _, err := s.database.GetTraderConfig(c.GetString("user_id"), name); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+			return
+		}
+	}
+
+	versions, err := s.database.GetPromptVersions(kind, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取提示词版本失败: %v", err)})
+		return
+	}
+	if versions == nil {
+		versions = []*config.PromptVersion{}
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// handlePromptVersionDiff 比较两个版本（默认与上一个版本比较，?against=版本ID 指定对比版本）
+func (s *Server) handlePromptVersionDiff(c *gin.Context) {
+	version, ok := s.getAccessiblePromptVersion(c)
+	if !ok {
+		return
+	}
+
+	var base *config.PromptVersion
+	if against := c.Query("against"); against != "" {
+		id, err := strconv.ParseInt(against, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的对比版本ID"})
+			return
+		}
+		base, err = s.database.GetPromptVersion(id)
+		if err != nil || base.Kind != version.Kind || base.Name != version.Name {
+			c.JSON(http.StatusNotFound, gin.H{"error": "对比版本不存在或不属于同一提示词"})
+			return
+		}
+	} else {
+		var err error
+		base, err = s.database.GetPreviousPromptVersion(version)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取上一个版本失败: %v", err)})
+			return
+		}
+	}
+
+	baseContent := ""
+	baseVersion := 0
+	if base != nil {
+		baseContent = base.Content
+		baseVersion = base.Version
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"kind":         version.Kind,
+		"name":         version.Name,
+		"from_version": baseVersion, // 0 表示与空内容比较（第一个版本）
+		"to_version":   version.Version,
+		"diff":         config.DiffPromptContent(baseContent, version.Content),
+	})
+}
+
+// handleRollbackPromptVersion 回滚到指定版本（以该版本内容创建新版本，模板回滚需要管理员模式）
+func (s *Server) handleRollbackPromptVersion(c *gin.Context) {
+	version, ok := s.getAccessiblePromptVersion(c)
+	if !ok {
+		return
+	}
+	note := fmt.Sprintf("回滚到 v%d", version.Version)
+
+	switch version.Kind {
+	case config.PromptKindCustom:
+		userID := c.GetString("user_id")
+		traderRecord, _, _, err := s.database.GetTraderConfig(userID, version.Name)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+			return
+		}
+		if err := s.database.UpdateTraderCustomPrompt(userID, version.Name, version.Content, traderRecord.OverrideBasePrompt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新自定义prompt失败: %v", err)})
+			return
+		}
+		if trader, err := s.traderManager.GetTrader(version.Name); err == nil {
+			trader.SetCustomPrompt(version.Content)
+		}
+		s.recordCustomPromptVersion(c, version.Name, version.Content, note)
+
+	case config.PromptKindTemplate:
+		adminMode, _ := s.database.GetSystemConfig("admin_mode")
+		if adminMode != "true" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "仅管理员模式下可回滚系统提示词模板"})
+			return
+		}
+		if err := decision.SavePromptTemplate(version.Name, version.Content); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("回滚提示词模板失败: %v", err)})
+			return
+		}
+		hash := ""
+		if template, err := decision.GetPromptTemplate(version.Name); err == nil {
+			hash = template.Hash
+		}
+		if _, _, err := s.database.AddPromptVersion(config.PromptKindTemplate, version.Name, version.Content, hash, c.GetString("email"), note); err != nil {
+			log.Printf("⚠️  记录提示词模板 %s 版本失败: %v", version.Name, err)
+		}
+	}
+
+	log.Printf("↩️  提示词 %s/%s 已回滚到 v%d", version.Kind, version.Name, version.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "已回滚到 v" + strconv.Itoa(version.Version)})
+}
+
+// handlePromptVersionPerformance 统计使用该版本的决策周期表现（模板版本汇总当前用户的所有交易员）
+func (s *Server) handlePromptVersionPerformance(c *gin.Context) {
+	version, ok := s.getAccessiblePromptVersion(c)
+	if !ok {
+		return
+	}
+	userID := c.GetString("user_id")
+	if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+		log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", userID, err)
+	}
+
+	traderIDs := []string{version.Name}
+	if version.Kind == config.PromptKindTemplate {
+		traders, err := s.database.GetTraders(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取交易员列表失败: %v", err)})
+			return
+		}
+		traderIDs = traderIDs[:0]
+		for _, t := range traders {
+			traderIDs = append(traderIDs, t.ID)
+		}
+	}
+
+	total := &logger.PromptVersionPerformance{Hash: version.Hash}
+	for _, traderID := range traderIDs {
+		trader, err := s.traderManager.GetTrader(traderID)
+		if err != nil {
+			continue
+		}
+		perf, err := trader.GetDecisionLogger().AnalyzePromptVersion(version.Hash)
+		if err != nil {
+			log.Printf("⚠️  分析交易员 %s 的提示词版本表现失败: %v", traderID, err)
+			continue
+		}
+		total.Add(perf)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"version":     version,
+		"performance": total,
+	})
+}
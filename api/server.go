@@ -13,6 +13,7 @@ import (
 	"nofx/decision"
 	"nofx/hook"
 	"nofx/manager"
+	"nofx/market"
 	"nofx/trader"
 	"strconv"
 	"strings"
@@ -118,6 +119,7 @@ func (s *Server) setupRoutes() {
 		// 系统提示词模板管理（无需认证）
 		api.GET("/prompt-templates", s.handleGetPromptTemplates)
 		api.GET("/prompt-templates/:name", s.handleGetPromptTemplate)
+		api.GET("/user-prompt-layouts", s.handleGetUserPromptLayouts)
 
 		// 公开的竞赛数据（无需认证）
 		api.GET("/traders", s.handlePublicTraderList)
@@ -161,6 +163,9 @@ func (s *Server) setupRoutes() {
 			// 服务器IP查询（需要认证，用于白名单配置）
 			protected.GET("/server-ip", s.handleGetServerIP)
 
+			// 行情流健康详情（列出流和币种，需要认证）
+			protected.GET("/health/market", s.handleMarketHealth)
+
 			// AI交易员管理
 			protected.GET("/my-traders", s.handleTraderList)
 			protected.GET("/traders/:id/config", s.handleGetTraderConfig)
@@ -172,6 +177,12 @@ func (s *Server) setupRoutes() {
 			protected.PUT("/traders/:id/prompt", s.handleUpdateTraderPrompt)
 			protected.POST("/traders/:id/sync-balance", s.handleSyncBalance)
 
+			// 交易教训（反思生成，可手动添加、编辑和删除）
+			protected.GET("/traders/:id/lessons", s.handleGetLessons)
+			protected.POST("/traders/:id/lessons", s.handleCreateLesson)
+			protected.PUT("/traders/:id/lessons/:lessonId", s.handleUpdateLesson)
+			protected.DELETE("/traders/:id/lessons/:lessonId", s.handleDeleteLesson)
+
 			// AI模型配置
 			protected.GET("/models", s.handleGetModelConfigs)
 			protected.PUT("/models", s.handleUpdateModelConfigs)
@@ -188,22 +199,54 @@ func (s *Server) setupRoutes() {
 			protected.GET("/status", s.handleStatus)
 			protected.GET("/account", s.handleAccount)
 			protected.GET("/positions", s.handlePositions)
+			protected.GET("/exposure", s.handleExposure)
+			protected.GET("/alerts", s.handleAlerts)
 			protected.GET("/decisions", s.handleDecisions)
 			protected.GET("/decisions/latest", s.handleLatestDecisions)
+			protected.GET("/decisions/market-snapshot", s.handleMarketSnapshot)
 			protected.GET("/statistics", s.handleStatistics)
 			protected.GET("/performance", s.handlePerformance)
+
+			// 提示词版本历史（?kind=template|custom&name=xxx）
+			protected.GET("/prompt-versions", s.handleGetPromptVersions)
+			protected.GET("/prompt-versions/:id/diff", s.handlePromptVersionDiff)
+			protected.POST("/prompt-versions/:id/rollback", s.handleRollbackPromptVersion)
+			protected.GET("/prompt-versions/:id/performance", s.handlePromptVersionPerformance)
+
+			// A/B实验
+			protected.GET("/experiments", s.handleGetExperiments)
+			protected.POST("/experiments", s.handleCreateExperiment)
+			protected.POST("/experiments/:id/start", s.handleStartExperiment)
+			protected.POST("/experiments/:id/stop", s.handleStopExperiment)
+			protected.GET("/experiments/:id/report", s.handleExperimentReport)
 		}
 	}
 }
 
-// handleHealth 健康检查
+// handleHealth 健康检查（公开），附带行情WebSocket连接状态摘要，不列出具体的流
 func (s *Server) handleHealth(c *gin.Context) {
+	status := "ok"
+	marketHealth := market.Health(false)
+	if marketHealth != nil && marketHealth.Status != "ok" {
+		status = marketHealth.Status
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
+		"status": status,
 		"time":   c.Request.Context().Value("time"),
+		"market": marketHealth.Summary(),
 	})
 }
 
+// handleMarketHealth 行情流健康详情（verbose=1 时列出所有流，否则只列出过期的流）
+func (s *Server) handleMarketHealth(c *gin.Context) {
+	marketHealth := market.Health(c.Query("verbose") == "1")
+	if marketHealth == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "行情监控器未启动"})
+		return
+	}
+	c.JSON(http.StatusOK, marketHealth)
+}
+
 // handleGetSystemConfig 获取系统配置（客户端需要知道的配置）
 func (s *Server) handleGetSystemConfig(c *gin.Context) {
 	// 获取默认币种
@@ -418,20 +461,32 @@ func (s *Server) getTraderFromQuery(c *gin.Context) (*manager.TraderManager, str
 
 // AI交易员管理相关结构体
 type CreateTraderRequest struct {
-	Name                 string  `json:"name" binding:"required"`
-	AIModelID            string  `json:"ai_model_id" binding:"required"`
-	ExchangeID           string  `json:"exchange_id" binding:"required"`
-	InitialBalance       float64 `json:"initial_balance"`
-	ScanIntervalMinutes  int     `json:"scan_interval_minutes"`
-	BTCETHLeverage       int     `json:"btc_eth_leverage"`
-	AltcoinLeverage      int     `json:"altcoin_leverage"`
-	TradingSymbols       string  `json:"trading_symbols"`
-	CustomPrompt         string  `json:"custom_prompt"`
-	OverrideBasePrompt   bool    `json:"override_base_prompt"`
-	SystemPromptTemplate string  `json:"system_prompt_template"` // 系统提示词模板名称
-	IsCrossMargin        *bool   `json:"is_cross_margin"`        // 指针类型，nil表示使用默认值true
-	UseCoinPool          bool    `json:"use_coin_pool"`
-	UseOITop             bool    `json:"use_oi_top"`
+	Name                 string                         `json:"name" binding:"required"`
+	AIModelID            string                         `json:"ai_model_id" binding:"required"`
+	ExchangeID           string                         `json:"exchange_id" binding:"required"`
+	InitialBalance       float64                        `json:"initial_balance"`
+	ScanIntervalMinutes  int                            `json:"scan_interval_minutes"`
+	BTCETHLeverage       int                            `json:"btc_eth_leverage"`
+	AltcoinLeverage      int                            `json:"altcoin_leverage"`
+	TradingSymbols       string                         `json:"trading_symbols"`
+	CustomPrompt         string                         `json:"custom_prompt"`
+	OverrideBasePrompt   bool                           `json:"override_base_prompt"`
+	SystemPromptTemplate string                         `json:"system_prompt_template"` // 系统提示词模板名称
+	IsCrossMargin        *bool                          `json:"is_cross_margin"`        // 指针类型，nil表示使用默认值true
+	UseCoinPool          bool                           `json:"use_coin_pool"`
+	UseOITop             bool                           `json:"use_oi_top"`
+	ExitPolicies         []trader.ExitPolicy            `json:"exit_policies"`      // 利润保护策略，为空使用默认回撤平仓
+	LiquidationGuard     *trader.LiquidationGuardConfig `json:"liquidation_guard"`  // 强平距离保护，nil使用默认配置（默认不启用）
+	FundingGuard         *trader.FundingGuardConfig     `json:"funding_guard"`      // 开仓资金费检查，nil使用默认配置
+	Timeframes           string                         `json:"timeframes"`         // K线周期，逗号分隔（如 1m,15m,1h,1d），为空使用默认3m/4h
+	MaxDepthPct          *float64                       `json:"max_depth_pct"`      // 开仓金额占对手盘±1%深度上限，nil或0表示不检查
+	AlertTriggers        bool                           `json:"alert_triggers"`     // 关注币种出现市场警报时触发额外决策
+	EventTriggers        *trader.EventTriggerConfig     `json:"event_triggers"`     // 持仓事件触发决策，nil使用默认配置（不启用）
+	PromptVariables      map[string]string              `json:"prompt_variables"`   // 系统提示词模板自定义变量（模板中以 {{.Vars.name}} 引用）
+	UserPromptLayout     string                         `json:"user_prompt_layout"` // User Prompt 布局（name 或 name@vN），为空使用默认布局
+	DecisionPipeline     *decision.PipelineConfig       `json:"decision_pipeline"`  // 多阶段决策流水线（初筛→分析→风控复核），nil不启用
+	Reflection           *trader.ReflectionConfig       `json:"reflection"`         // 交易反思，nil使用默认配置（不启用）
+	ValidationProfile    *decision.ValidationProfile    `json:"validation_profile"` // 决策校验规则，nil使用默认规则
 }
 
 type ModelConfig struct {
@@ -521,8 +576,11 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		symbols := strings.Split(req.TradingSymbols, ",")
 		for _, symbol := range symbols {
 			symbol = strings.TrimSpace(symbol)
-			if symbol != "" && !strings.HasSuffix(strings.ToUpper(symbol), "USDT") {
-				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无效的币种格式: %s，必须以USDT结尾", symbol)})
+			if symbol == "" {
+				continue
+			}
+			if sym, err := market.ParseSymbol(symbol, market.VenueBinance); err != nil || !strings.HasSuffix(strings.ToUpper(symbol), sym.Quote) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无效的币种格式: %s，必须以USDT或USDC结尾", symbol)})
 				return
 			}
 		}
@@ -567,6 +625,85 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		systemPromptTemplate = req.SystemPromptTemplate
 	}
 
+	// 校验利润保护策略
+	exitPolicies, err := encodeExitPolicies(req.ExitPolicies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验强平距离保护配置
+	liquidationGuard, err := encodeLiquidationGuard(req.LiquidationGuard)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验开仓资金费检查配置
+	fundingGuard, err := encodeFundingGuard(req.FundingGuard)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验持仓事件触发配置
+	eventTriggers, err := encodeEventTriggers(req.EventTriggers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验系统提示词模板变量
+	promptVariables, err := encodePromptVariables(req.PromptVariables)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验 User Prompt 布局
+	if err := validateUserPromptLayout(req.UserPromptLayout); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验多阶段决策流水线
+	decisionPipeline, err := s.encodeDecisionPipeline(userID, req.DecisionPipeline)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验交易反思配置
+	reflection, err := encodeReflection(req.Reflection)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验决策校验规则
+	validationProfile, err := encodeValidationProfile(req.ValidationProfile)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验K线周期
+	timeframes, err := normalizeTimeframes(req.Timeframes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验深度检查上限
+	maxDepthPct := trader.DefaultMaxDepthPct
+	if req.MaxDepthPct != nil {
+		if err := trader.ValidateMaxDepthPct(*req.MaxDepthPct); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		maxDepthPct = *req.MaxDepthPct
+	}
+
 	// 设置扫描间隔默认值
 	scanIntervalMinutes := req.ScanIntervalMinutes
 	if scanIntervalMinutes < 3 {
@@ -657,6 +794,18 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		OverrideBasePrompt:   req.OverrideBasePrompt,
 		SystemPromptTemplate: systemPromptTemplate,
 		IsCrossMargin:        isCrossMargin,
+		ExitPolicies:         exitPolicies,
+		LiquidationGuard:     liquidationGuard,
+		FundingGuard:         fundingGuard,
+		Timeframes:           timeframes,
+		MaxDepthPct:          maxDepthPct,
+		AlertTriggers:        req.AlertTriggers,
+		EventTriggers:        eventTriggers,
+		PromptVariables:      promptVariables,
+		UserPromptLayout:     req.UserPromptLayout,
+		DecisionPipeline:     decisionPipeline,
+		Reflection:           reflection,
+		ValidationProfile:    validationProfile,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            false,
 	}
@@ -668,6 +817,10 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		return
 	}
 
+	if req.CustomPrompt != "" {
+		s.recordCustomPromptVersion(c, traderID, req.CustomPrompt, "")
+	}
+
 	// 立即将新交易员加载到TraderManager中
 	err = s.traderManager.LoadTraderByID(s.database, userID, traderID)
 	if err != nil {
@@ -687,18 +840,177 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 
 // UpdateTraderRequest 更新交易员请求
 type UpdateTraderRequest struct {
-	Name                 string  `json:"name" binding:"required"`
-	AIModelID            string  `json:"ai_model_id" binding:"required"`
-	ExchangeID           string  `json:"exchange_id" binding:"required"`
-	InitialBalance       float64 `json:"initial_balance"`
-	ScanIntervalMinutes  int     `json:"scan_interval_minutes"`
-	BTCETHLeverage       int     `json:"btc_eth_leverage"`
-	AltcoinLeverage      int     `json:"altcoin_leverage"`
-	TradingSymbols       string  `json:"trading_symbols"`
-	CustomPrompt         string  `json:"custom_prompt"`
-	OverrideBasePrompt   bool    `json:"override_base_prompt"`
-	SystemPromptTemplate string  `json:"system_prompt_template"`
-	IsCrossMargin        *bool   `json:"is_cross_margin"`
+	Name                 string                         `json:"name" binding:"required"`
+	AIModelID            string                         `json:"ai_model_id" binding:"required"`
+	ExchangeID           string                         `json:"exchange_id" binding:"required"`
+	InitialBalance       float64                        `json:"initial_balance"`
+	ScanIntervalMinutes  int                            `json:"scan_interval_minutes"`
+	BTCETHLeverage       int                            `json:"btc_eth_leverage"`
+	AltcoinLeverage      int                            `json:"altcoin_leverage"`
+	TradingSymbols       string                         `json:"trading_symbols"`
+	CustomPrompt         string                         `json:"custom_prompt"`
+	OverrideBasePrompt   bool                           `json:"override_base_prompt"`
+	SystemPromptTemplate string                         `json:"system_prompt_template"`
+	IsCrossMargin        *bool                          `json:"is_cross_margin"`
+	ExitPolicies         []trader.ExitPolicy            `json:"exit_policies"`      // nil表示保持原值，空数组表示恢复默认
+	LiquidationGuard     *trader.LiquidationGuardConfig `json:"liquidation_guard"`  // nil表示保持原值
+	FundingGuard         *trader.FundingGuardConfig     `json:"funding_guard"`      // nil表示保持原值
+	Timeframes           *string                        `json:"timeframes"`         // nil表示保持原值，空串表示恢复默认
+	MaxDepthPct          *float64                       `json:"max_depth_pct"`      // nil表示保持原值
+	AlertTriggers        *bool                          `json:"alert_triggers"`     // nil表示保持原值
+	EventTriggers        *trader.EventTriggerConfig     `json:"event_triggers"`     // nil表示保持原值
+	PromptVariables      map[string]string              `json:"prompt_variables"`   // nil表示保持原值，空对象表示清空
+	UserPromptLayout     *string                        `json:"user_prompt_layout"` // nil表示保持原值，空串表示恢复默认
+	DecisionPipeline     *decision.PipelineConfig       `json:"decision_pipeline"`  // nil表示保持原值
+	Reflection           *trader.ReflectionConfig       `json:"reflection"`         // nil表示保持原值
+	ValidationProfile    *decision.ValidationProfile    `json:"validation_profile"` // nil表示保持原值
+}
+
+// encodeExitPolicies 校验利润保护策略并序列化为数据库存储格式（空列表存为空串，表示使用默认策略）
+func encodeExitPolicies(policies []trader.ExitPolicy) (string, error) {
+	if len(policies) == 0 {
+		return "", nil
+	}
+	if err := trader.ValidateExitPolicies(policies); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(policies)
+	if err != nil {
+		return "", fmt.Errorf("序列化利润保护策略失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeLiquidationGuard 校验强平距离保护配置并序列化（nil存为空串，表示使用默认配置）
+func encodeLiquidationGuard(cfg *trader.LiquidationGuardConfig) (string, error) {
+	if cfg == nil {
+		return "", nil
+	}
+	if err := trader.ValidateLiquidationGuard(cfg); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("序列化强平保护配置失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeFundingGuard 校验开仓资金费检查配置并序列化（nil存为空串，表示使用默认配置）
+func encodeFundingGuard(cfg *trader.FundingGuardConfig) (string, error) {
+	if cfg == nil {
+		return "", nil
+	}
+	if err := trader.ValidateFundingGuard(cfg); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("序列化资金费检查配置失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeEventTriggers 校验事件触发配置并序列化（nil存为空串，表示使用默认配置）
+func encodeEventTriggers(cfg *trader.EventTriggerConfig) (string, error) {
+	if cfg == nil {
+		return "", nil
+	}
+	if err := trader.ValidateEventTriggers(cfg); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("序列化事件触发配置失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeReflection 校验交易反思配置并序列化（nil存为空串，表示使用默认配置）
+func encodeReflection(cfg *trader.ReflectionConfig) (string, error) {
+	if cfg == nil {
+		return "", nil
+	}
+	if err := trader.ValidateReflection(cfg); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("序列化反思配置失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeValidationProfile 校验决策校验规则并序列化（nil存为空串，表示使用默认规则）
+func encodeValidationProfile(profile *decision.ValidationProfile) (string, error) {
+	if profile == nil {
+		return "", nil
+	}
+	if err := decision.ValidateValidationProfile(profile); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return "", fmt.Errorf("序列化校验配置失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodePromptVariables 校验系统提示词模板变量并序列化（空存为空串）
+func encodePromptVariables(vars map[string]string) (string, error) {
+	if len(vars) == 0 {
+		return "", nil
+	}
+	if err := decision.ValidatePromptVariables(vars); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return "", fmt.Errorf("序列化模板变量失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// validateUserPromptLayout 校验 User Prompt 布局是否存在（空串表示默认布局）
+func validateUserPromptLayout(ref string) error {
+	if ref == "" {
+		return nil
+	}
+	_, err := decision.GetUserPromptLayout(ref)
+	return err
+}
+
+// encodeDecisionPipeline 校验决策流水线配置（阶段模型须为用户已配置的AI模型）并序列化
+func (s *Server) encodeDecisionPipeline(userID string, cfg *decision.PipelineConfig) (string, error) {
+	if cfg == nil {
+		return "", nil
+	}
+	if err := decision.ValidatePipelineConfig(cfg); err != nil {
+		return "", err
+	}
+	models, err := s.database.GetAIModels(userID)
+	if err != nil {
+		return "", fmt.Errorf("获取AI模型配置失败: %w", err)
+	}
+	for _, id := range []string{cfg.ScreenerModelID, cfg.ReviewerModelID} {
+		if id != "" && !hasAIModel(models, id) {
+			return "", fmt.Errorf("AI模型 %s 未配置", id)
+		}
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("序列化决策流水线配置失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// normalizeTimeframes 校验K线周期配置并规范化为从短到长的逗号分隔串（空串表示使用默认周期）
+func normalizeTimeframes(raw string) (string, error) {
+	timeframes, err := market.ParseTimeframes(raw)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(timeframes, ","), nil
 }
 
 // handleUpdateTrader 更新交易员配置
@@ -762,6 +1074,122 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		systemPromptTemplate = existingTrader.SystemPromptTemplate // 如果请求中没有提供，保持原值
 	}
 
+	// 设置利润保护策略，未提供时保持原值
+	exitPolicies := existingTrader.ExitPolicies
+	if req.ExitPolicies != nil {
+		exitPolicies, err = encodeExitPolicies(req.ExitPolicies)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 设置强平距离保护，未提供时保持原值
+	liquidationGuard := existingTrader.LiquidationGuard
+	if req.LiquidationGuard != nil {
+		liquidationGuard, err = encodeLiquidationGuard(req.LiquidationGuard)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 设置开仓资金费检查，未提供时保持原值
+	fundingGuard := existingTrader.FundingGuard
+	if req.FundingGuard != nil {
+		fundingGuard, err = encodeFundingGuard(req.FundingGuard)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 设置K线周期，未提供时保持原值
+	timeframes := existingTrader.Timeframes
+	if req.Timeframes != nil {
+		timeframes, err = normalizeTimeframes(*req.Timeframes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 设置深度检查上限，未提供时保持原值
+	maxDepthPct := existingTrader.MaxDepthPct
+	if req.MaxDepthPct != nil {
+		if err := trader.ValidateMaxDepthPct(*req.MaxDepthPct); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		maxDepthPct = *req.MaxDepthPct
+	}
+
+	// 设置警报触发，未提供时保持原值
+	alertTriggers := existingTrader.AlertTriggers
+	if req.AlertTriggers != nil {
+		alertTriggers = *req.AlertTriggers
+	}
+
+	// 设置持仓事件触发，未提供时保持原值
+	eventTriggers := existingTrader.EventTriggers
+	if req.EventTriggers != nil {
+		eventTriggers, err = encodeEventTriggers(req.EventTriggers)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 设置系统提示词模板变量，未提供时保持原值
+	promptVariables := existingTrader.PromptVariables
+	if req.PromptVariables != nil {
+		promptVariables, err = encodePromptVariables(req.PromptVariables)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 设置 User Prompt 布局，未提供时保持原值
+	userPromptLayout := existingTrader.UserPromptLayout
+	if req.UserPromptLayout != nil {
+		if err := validateUserPromptLayout(*req.UserPromptLayout); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userPromptLayout = *req.UserPromptLayout
+	}
+
+	// 设置多阶段决策流水线，未提供时保持原值
+	decisionPipeline := existingTrader.DecisionPipeline
+	if req.DecisionPipeline != nil {
+		decisionPipeline, err = s.encodeDecisionPipeline(userID, req.DecisionPipeline)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 设置交易反思，未提供时保持原值
+	reflection := existingTrader.Reflection
+	if req.Reflection != nil {
+		reflection, err = encodeReflection(req.Reflection)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 设置决策校验规则，未提供时保持原值
+	validationProfile := existingTrader.ValidationProfile
+	if req.ValidationProfile != nil {
+		validationProfile, err = encodeValidationProfile(req.ValidationProfile)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// 更新交易员配置
 	trader := &config.TraderRecord{
 		ID:                   traderID,
@@ -777,6 +1205,18 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		OverrideBasePrompt:   req.OverrideBasePrompt,
 		SystemPromptTemplate: systemPromptTemplate,
 		IsCrossMargin:        isCrossMargin,
+		ExitPolicies:         exitPolicies,
+		LiquidationGuard:     liquidationGuard,
+		FundingGuard:         fundingGuard,
+		Timeframes:           timeframes,
+		MaxDepthPct:          maxDepthPct,
+		AlertTriggers:        alertTriggers,
+		EventTriggers:        eventTriggers,
+		PromptVariables:      promptVariables,
+		UserPromptLayout:     userPromptLayout,
+		DecisionPipeline:     decisionPipeline,
+		Reflection:           reflection,
+		ValidationProfile:    validationProfile,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            existingTrader.IsRunning, // 保持原值
 	}
@@ -788,6 +1228,10 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		return
 	}
 
+	if req.CustomPrompt != "" || existingTrader.CustomPrompt != "" {
+		s.recordCustomPromptVersion(c, traderID, req.CustomPrompt, "")
+	}
+
 	// 重新加载交易员到内存
 	err = s.traderManager.LoadTraderByID(s.database, userID, traderID)
 	if err != nil {
@@ -937,6 +1381,7 @@ func (s *Server) handleUpdateTraderPrompt(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新自定义prompt失败: %v", err)})
 		return
 	}
+	s.recordCustomPromptVersion(c, traderID, req.CustomPrompt, "")
 
 	// 如果trader在内存中，更新其custom prompt和override设置
 	trader, err := s.traderManager.GetTrader(traderID)
@@ -1353,6 +1798,44 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 	// 返回完整的模型ID，不做转换，保持与前端模型列表一致
 	aiModelID := traderConfig.AIModelID
 
+	// 解析利润保护策略，未配置时返回默认策略
+	exitPolicies, err := trader.ParseExitPolicies(traderConfig.ExitPolicies)
+	if err != nil {
+		exitPolicies = trader.DefaultExitPolicies()
+	}
+	liquidationGuard, err := trader.ParseLiquidationGuard(traderConfig.LiquidationGuard)
+	if err != nil {
+		liquidationGuard = trader.DefaultLiquidationGuard()
+	}
+	fundingGuard, err := trader.ParseFundingGuard(traderConfig.FundingGuard)
+	if err != nil {
+		fundingGuard = trader.DefaultFundingGuard()
+	}
+	eventTriggers, err := trader.ParseEventTriggers(traderConfig.EventTriggers)
+	if err != nil {
+		eventTriggers = trader.DefaultEventTriggers()
+	}
+	timeframes, err := market.ParseTimeframes(traderConfig.Timeframes)
+	if err != nil || len(timeframes) == 0 {
+		timeframes = market.DefaultTimeframes
+	}
+	promptVariables, err := decision.ParsePromptVariables(traderConfig.PromptVariables)
+	if err != nil {
+		promptVariables = map[string]string{}
+	}
+	decisionPipeline, err := decision.ParsePipelineConfig(traderConfig.DecisionPipeline)
+	if err != nil || decisionPipeline == nil {
+		decisionPipeline = &decision.PipelineConfig{}
+	}
+	reflection, err := trader.ParseReflection(traderConfig.Reflection)
+	if err != nil {
+		reflection = trader.DefaultReflection()
+	}
+	validationProfile, err := decision.ParseValidationProfile(traderConfig.ValidationProfile)
+	if err != nil {
+		validationProfile = decision.DefaultValidationProfile()
+	}
+
 	result := map[string]interface{}{
 		"trader_id":              traderConfig.ID,
 		"trader_name":            traderConfig.Name,
@@ -1367,6 +1850,18 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 		"override_base_prompt":   traderConfig.OverrideBasePrompt,
 		"system_prompt_template": traderConfig.SystemPromptTemplate,
 		"is_cross_margin":        traderConfig.IsCrossMargin,
+		"exit_policies":          exitPolicies,
+		"liquidation_guard":      liquidationGuard,
+		"funding_guard":          fundingGuard,
+		"timeframes":             timeframes,
+		"max_depth_pct":          traderConfig.MaxDepthPct,
+		"alert_triggers":         traderConfig.AlertTriggers,
+		"event_triggers":         eventTriggers,
+		"prompt_variables":       promptVariables,
+		"user_prompt_layout":     traderConfig.UserPromptLayout,
+		"decision_pipeline":      decisionPipeline,
+		"reflection":             reflection,
+		"validation_profile":     validationProfile,
 		"use_coin_pool":          traderConfig.UseCoinPool,
 		"use_oi_top":             traderConfig.UseOITop,
 		"is_running":             isRunning,
@@ -1451,6 +1946,58 @@ func (s *Server) handlePositions(c *gin.Context) {
 	c.JSON(http.StatusOK, positions)
 }
 
+// handleExposure 组合敞口与相关性分析
+func (s *Server) handleExposure(c *gin.Context) {
+	_, traderID, err := s.getTraderFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	exposure, err := trader.GetExposure()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("获取组合敞口失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, exposure)
+}
+
+// handleAlerts 市场警报列表（可按币种过滤，指定币种时附带最新特征）
+func (s *Server) handleAlerts(c *gin.Context) {
+	symbol := strings.ToUpper(c.Query("symbol"))
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须为正整数"})
+			return
+		}
+		if n > 500 {
+			n = 500
+		}
+		limit = n
+	}
+
+	alerts := market.RecentAlerts(symbol, limit)
+	if alerts == nil {
+		alerts = []market.Alert{}
+	}
+	resp := gin.H{"alerts": alerts}
+	if symbol != "" && market.WSMonitorCli != nil {
+		resp["features"] = market.WSMonitorCli.GetFeatures(symbol)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
 // handleDecisions 决策日志列表
 func (s *Server) handleDecisions(c *gin.Context) {
 	_, traderID, err := s.getTraderFromQuery(c)
@@ -1508,6 +2055,35 @@ func (s *Server) handleLatestDecisions(c *gin.Context) {
 	c.JSON(http.StatusOK, records)
 }
 
+// handleMarketSnapshot 决策周期的市场数据快照（file 为决策记录中的 market_snapshot_file）
+func (s *Server) handleMarketSnapshot(c *gin.Context) {
+	_, traderID, err := s.getTraderFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	file := c.Query("file")
+	if file == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 file 参数"})
+		return
+	}
+
+	snapshot, err := trader.GetDecisionLogger().LoadMarketSnapshot(file)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
 // handleStatistics 统计信息
 func (s *Server) handleStatistics(c *gin.Context) {
 	_, traderID, err := s.getTraderFromQuery(c)
@@ -2142,6 +2718,7 @@ func (s *Server) Start() error {
 	log.Printf("🌐 API服务器启动在 http://localhost%s", addr)
 	log.Printf("📊 API文档:")
 	log.Printf("  • GET  /api/health           - 健康检查")
+	log.Printf("  • GET  /api/health/market    - 行情流健康详情（需认证，verbose=1 列出所有流）")
 	log.Printf("  • GET  /api/traders          - 公开的AI交易员排行榜前50名（无需认证）")
 	log.Printf("  • GET  /api/competition      - 公开的竞赛数据（无需认证）")
 	log.Printf("  • GET  /api/top-traders      - 前5名交易员数据（无需认证，表现对比用）")
@@ -2159,10 +2736,20 @@ func (s *Server) Start() error {
 	log.Printf("  • GET  /api/status?trader_id=xxx     - 指定trader的系统状态")
 	log.Printf("  • GET  /api/account?trader_id=xxx    - 指定trader的账户信息")
 	log.Printf("  • GET  /api/positions?trader_id=xxx  - 指定trader的持仓列表")
+	log.Printf("  • GET  /api/exposure?trader_id=xxx   - 指定trader的组合敞口与相关性")
 	log.Printf("  • GET  /api/decisions?trader_id=xxx  - 指定trader的决策日志")
 	log.Printf("  • GET  /api/decisions/latest?trader_id=xxx - 指定trader的最新决策")
+	log.Printf("  • GET  /api/decisions/market-snapshot?trader_id=xxx&file=xxx - 决策周期的市场数据快照")
 	log.Printf("  • GET  /api/statistics?trader_id=xxx - 指定trader的统计信息")
 	log.Printf("  • GET  /api/performance?trader_id=xxx - 指定trader的AI学习表现分析")
+	log.Printf("  • GET  /api/traders/:id/lessons - 交易员的交易教训（POST/PUT/DELETE 手动维护）")
+	log.Printf("  • GET  /api/prompt-versions?kind=xxx&name=xxx - 提示词版本历史")
+	log.Printf("  • GET  /api/prompt-versions/:id/diff - 提示词版本对比")
+	log.Printf("  • POST /api/prompt-versions/:id/rollback - 回滚提示词版本")
+	log.Printf("  • GET  /api/prompt-versions/:id/performance - 提示词版本表现")
+	log.Printf("  • POST /api/experiments      - 创建A/B实验（从基础交易员克隆变体）")
+	log.Printf("  • POST /api/experiments/:id/start - 同时启动实验的所有变体")
+	log.Printf("  • GET  /api/experiments/:id/report - 实验对比报告（含显著性检验）")
 	log.Printf("  • GET  /api/version/current  - 获取当前版本")
 	log.Printf("  • GET  /api/version/check    - 检查更新")
 	log.Printf("  • POST /api/version/download - 下载更新")
@@ -2230,6 +2817,13 @@ func (s *Server) handleGetPromptTemplates(c *gin.Context) {
 	})
 }
 
+// handleGetUserPromptLayouts 获取所有 User Prompt 布局（含各版本）
+func (s *Server) handleGetUserPromptLayouts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"layouts": decision.GetAllUserPromptLayouts(),
+	})
+}
+
 // handleGetPromptTemplate 获取指定名称的提示词模板内容
 func (s *Server) handleGetPromptTemplate(c *gin.Context) {
 	templateName := c.Param("name")
@@ -2240,9 +2834,15 @@ func (s *Server) handleGetPromptTemplate(c *gin.Context) {
 		return
 	}
 
+	indicators := make([]string, 0, len(template.Indicators))
+	for _, spec := range template.Indicators {
+		indicators = append(indicators, spec.String())
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"name":    template.Name,
-		"content": template.Content,
+		"name":       template.Name,
+		"content":    template.Content,
+		"indicators": indicators,
 	})
 }
 
@@ -2454,9 +3054,11 @@ func (s *Server) handleGetPublicTraderConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-// reloadPromptTemplatesWithLog 重新加载提示词模板并记录日志
+// reloadPromptTemplatesWithLog 重新加载提示词模板并记录日志（模板内容变化时记录新版本）
 func (s *Server) reloadPromptTemplatesWithLog(templateName string) {
-	if err := decision.ReloadPromptTemplates(); err != nil {
+	err := decision.ReloadPromptTemplates()
+	RecordPromptTemplateVersions(s.database)
+	if err != nil {
 		log.Printf("⚠️  重新加载提示词模板失败: %v", err)
 		return
 	}
@@ -212,6 +212,46 @@ func (d *Database) createTables() error {
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
 
+		// 提示词版本表（系统提示词模板和交易员自定义提示词的不可变历史）
+		`CREATE TABLE IF NOT EXISTS prompt_versions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			name TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			hash TEXT NOT NULL,
+			author TEXT DEFAULT '',
+			note TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(kind, name, version)
+		)`,
+
+		// A/B实验表（同一基础配置的多个交易员变体，variants 为JSON数组）
+		`CREATE TABLE IF NOT EXISTS experiments (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			base_trader_id TEXT NOT NULL,
+			initial_balance REAL NOT NULL,
+			variants TEXT NOT NULL,
+			status TEXT DEFAULT 'created',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			started_at DATETIME,
+			stopped_at DATETIME
+		)`,
+
+		// 交易教训表（反思从已平仓交易中总结，trades_until 为覆盖到的最后平仓时间）
+		`CREATE TABLE IF NOT EXISTS trader_lessons (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			trader_id TEXT NOT NULL,
+			symbol TEXT DEFAULT '',
+			content TEXT NOT NULL,
+			trades_until DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
 		// 触发器：自动更新 updated_at
 		`CREATE TRIGGER IF NOT EXISTS update_users_updated_at
 			AFTER UPDATE ON users
@@ -273,6 +313,18 @@ func (d *Database) createTables() error {
 		`ALTER TABLE traders ADD COLUMN use_coin_pool BOOLEAN DEFAULT 0`,               // 是否使用COIN POOL信号源
 		`ALTER TABLE traders ADD COLUMN use_oi_top BOOLEAN DEFAULT 0`,                  // 是否使用OI TOP信号源
 		`ALTER TABLE traders ADD COLUMN system_prompt_template TEXT DEFAULT 'default'`, // 系统提示词模板名称
+		`ALTER TABLE traders ADD COLUMN exit_policies TEXT DEFAULT ''`,                 // 利润保护策略（JSON数组）
+		`ALTER TABLE traders ADD COLUMN liquidation_guard TEXT DEFAULT ''`,             // 强平距离保护配置（JSON）
+		`ALTER TABLE traders ADD COLUMN funding_guard TEXT DEFAULT ''`,                 // 开仓资金费检查配置（JSON）
+		`ALTER TABLE traders ADD COLUMN timeframes TEXT DEFAULT ''`,                    // 市场数据K线周期，逗号分隔
+		`ALTER TABLE traders ADD COLUMN max_depth_pct REAL DEFAULT 0`,                  // 开仓金额占对手盘±1%深度上限（0=不检查，默认不检查）
+		`ALTER TABLE traders ADD COLUMN alert_triggers BOOLEAN DEFAULT 0`,              // 市场警报触发额外决策
+		`ALTER TABLE traders ADD COLUMN event_triggers TEXT DEFAULT ''`,                // 持仓事件触发决策配置（JSON）
+		`ALTER TABLE traders ADD COLUMN prompt_variables TEXT DEFAULT ''`,              // 系统提示词模板自定义变量（JSON）
+		`ALTER TABLE traders ADD COLUMN user_prompt_layout TEXT DEFAULT ''`,            // User Prompt 布局（空=默认布局）
+		`ALTER TABLE traders ADD COLUMN decision_pipeline TEXT DEFAULT ''`,             // 多阶段决策流水线配置（JSON，空=未启用）
+		`ALTER TABLE traders ADD COLUMN reflection TEXT DEFAULT ''`,                    // 交易反思配置（JSON，空=默认配置）
+		`ALTER TABLE traders ADD COLUMN validation_profile TEXT DEFAULT ''`,            // 决策校验规则（JSON，空=默认规则）
 		`ALTER TABLE ai_models ADD COLUMN custom_api_url TEXT DEFAULT ''`,              // 自定义API地址
 		`ALTER TABLE ai_models ADD COLUMN custom_model_name TEXT DEFAULT ''`,           // 自定义模型名称
 	}
@@ -504,6 +556,18 @@ type TraderRecord struct {
 	OverrideBasePrompt   bool      `json:"override_base_prompt"`   // 是否覆盖基础prompt
 	SystemPromptTemplate string    `json:"system_prompt_template"` // 系统提示词模板名称
 	IsCrossMargin        bool      `json:"is_cross_margin"`        // 是否为全仓模式（true=全仓，false=逐仓）
+	ExitPolicies         string    `json:"exit_policies"`          // 利润保护策略（JSON数组，空=默认回撤平仓）
+	LiquidationGuard     string    `json:"liquidation_guard"`      // 强平距离保护配置（JSON，空=默认配置，默认不启用）
+	FundingGuard         string    `json:"funding_guard"`          // 开仓资金费检查配置（JSON，空=默认配置）
+	Timeframes           string    `json:"timeframes"`             // 市场数据K线周期，逗号分隔（空=默认3m,4h）
+	MaxDepthPct          float64   `json:"max_depth_pct"`          // 开仓金额占对手盘±1%深度的上限百分比（0=不检查）
+	AlertTriggers        bool      `json:"alert_triggers"`         // 关注币种出现市场警报时触发额外决策
+	EventTriggers        string    `json:"event_triggers"`         // 持仓事件触发决策配置（JSON，空=默认配置）
+	PromptVariables      string    `json:"prompt_variables"`       // 系统提示词模板自定义变量（JSON对象，空=无）
+	UserPromptLayout     string    `json:"user_prompt_layout"`     // User Prompt 布局（name 或 name@vN，空=默认布局）
+	DecisionPipeline     string    `json:"decision_pipeline"`      // 多阶段决策流水线配置（JSON，空=未启用）
+	Reflection           string    `json:"reflection"`             // 交易反思配置（JSON，空=默认配置）
+	ValidationProfile    string    `json:"validation_profile"`     // 决策校验规则（JSON，空=默认规则）
 	CreatedAt            time.Time `json:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at"`
 }
@@ -918,9 +982,9 @@ func (d *Database) CreateExchange(userID, id, name, typ string, enabled bool, ap
 // CreateTrader 创建交易员
 func (d *Database) CreateTrader(trader *TraderRecord) error {
 	_, err := d.db.Exec(`
-		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin)
+		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin, exit_policies, liquidation_guard, funding_guard, timeframes, max_depth_pct, alert_triggers, event_triggers, prompt_variables, user_prompt_layout, decision_pipeline, reflection, validation_profile)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin, trader.ExitPolicies, trader.LiquidationGuard, trader.FundingGuard, trader.Timeframes, trader.MaxDepthPct, trader.AlertTriggers, trader.EventTriggers, trader.PromptVariables, trader.UserPromptLayout, trader.DecisionPipeline, trader.Reflection, trader.ValidationProfile)
 	return err
 }
 
@@ -933,7 +997,13 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 		       COALESCE(use_coin_pool, 0) as use_coin_pool, COALESCE(use_oi_top, 0) as use_oi_top,
 		       COALESCE(custom_prompt, '') as custom_prompt, COALESCE(override_base_prompt, 0) as override_base_prompt,
 		       COALESCE(system_prompt_template, 'default') as system_prompt_template,
-		       COALESCE(is_cross_margin, 1) as is_cross_margin, created_at, updated_at
+		       COALESCE(is_cross_margin, 1) as is_cross_margin,
+		       COALESCE(exit_policies, '') as exit_policies,
+		       COALESCE(liquidation_guard, '') as liquidation_guard,
+		       COALESCE(funding_guard, '') as funding_guard,
+		       COALESCE(timeframes, '') as timeframes,
+		       COALESCE(max_depth_pct, 0) as max_depth_pct, COALESCE(alert_triggers, 0) as alert_triggers,
+		       COALESCE(event_triggers, '') as event_triggers, COALESCE(prompt_variables, '') as prompt_variables, COALESCE(user_prompt_layout, '') as user_prompt_layout, COALESCE(decision_pipeline, '') as decision_pipeline, COALESCE(reflection, '') as reflection, COALESCE(validation_profile, '') as validation_profile, created_at, updated_at
 		FROM traders WHERE user_id = ? ORDER BY created_at DESC
 	`, userID)
 	if err != nil {
@@ -950,8 +1020,8 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 			&trader.BTCETHLeverage, &trader.AltcoinLeverage, &trader.TradingSymbols,
 			&trader.UseCoinPool, &trader.UseOITop,
 			&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
-			&trader.IsCrossMargin,
-			&trader.CreatedAt, &trader.UpdatedAt,
+			&trader.IsCrossMargin, &trader.ExitPolicies, &trader.LiquidationGuard, &trader.FundingGuard,
+			&trader.Timeframes, &trader.MaxDepthPct, &trader.AlertTriggers, &trader.EventTriggers, &trader.PromptVariables, &trader.UserPromptLayout, &trader.DecisionPipeline, &trader.Reflection, &trader.ValidationProfile, &trader.CreatedAt, &trader.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -975,12 +1045,12 @@ func (d *Database) UpdateTrader(trader *TraderRecord) error {
 			name = ?, ai_model_id = ?, exchange_id = ?, initial_balance = ?,
 			scan_interval_minutes = ?, btc_eth_leverage = ?, altcoin_leverage = ?,
 			trading_symbols = ?, custom_prompt = ?, override_base_prompt = ?,
-			system_prompt_template = ?, is_cross_margin = ?, updated_at = CURRENT_TIMESTAMP
+			system_prompt_template = ?, is_cross_margin = ?, exit_policies = ?, liquidation_guard = ?, funding_guard = ?, timeframes = ?, max_depth_pct = ?, alert_triggers = ?, event_triggers = ?, prompt_variables = ?, user_prompt_layout = ?, decision_pipeline = ?, reflection = ?, validation_profile = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND user_id = ?
 	`, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance,
 		trader.ScanIntervalMinutes, trader.BTCETHLeverage, trader.AltcoinLeverage,
 		trader.TradingSymbols, trader.CustomPrompt, trader.OverrideBasePrompt,
-		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.ID, trader.UserID)
+		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.ExitPolicies, trader.LiquidationGuard, trader.FundingGuard, trader.Timeframes, trader.MaxDepthPct, trader.AlertTriggers, trader.EventTriggers, trader.PromptVariables, trader.UserPromptLayout, trader.DecisionPipeline, trader.Reflection, trader.ValidationProfile, trader.ID, trader.UserID)
 	return err
 }
 
@@ -1020,6 +1090,18 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 			COALESCE(t.override_base_prompt, 0) as override_base_prompt,
 			COALESCE(t.system_prompt_template, 'default') as system_prompt_template,
 			COALESCE(t.is_cross_margin, 1) as is_cross_margin,
+			COALESCE(t.exit_policies, '') as exit_policies,
+			COALESCE(t.liquidation_guard, '') as liquidation_guard,
+			COALESCE(t.funding_guard, '') as funding_guard,
+			COALESCE(t.timeframes, '') as timeframes,
+			COALESCE(t.max_depth_pct, 0) as max_depth_pct,
+			COALESCE(t.alert_triggers, 0) as alert_triggers,
+			COALESCE(t.event_triggers, '') as event_triggers,
+			COALESCE(t.prompt_variables, '') as prompt_variables,
+			COALESCE(t.user_prompt_layout, '') as user_prompt_layout,
+			COALESCE(t.decision_pipeline, '') as decision_pipeline,
+			COALESCE(t.reflection, '') as reflection,
+			COALESCE(t.validation_profile, '') as validation_profile,
 			t.created_at, t.updated_at,
 			a.id, a.user_id, a.name, a.provider, a.enabled, a.api_key,
 			COALESCE(a.custom_api_url, '') as custom_api_url,
@@ -1041,8 +1123,8 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 		&trader.BTCETHLeverage, &trader.AltcoinLeverage, &trader.TradingSymbols,
 		&trader.UseCoinPool, &trader.UseOITop,
 		&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
-		&trader.IsCrossMargin,
-		&trader.CreatedAt, &trader.UpdatedAt,
+		&trader.IsCrossMargin, &trader.ExitPolicies, &trader.LiquidationGuard, &trader.FundingGuard,
+		&trader.Timeframes, &trader.MaxDepthPct, &trader.AlertTriggers, &trader.EventTriggers, &trader.PromptVariables, &trader.UserPromptLayout, &trader.DecisionPipeline, &trader.Reflection, &trader.ValidationProfile, &trader.CreatedAt, &trader.UpdatedAt,
 		&aiModel.ID, &aiModel.UserID, &aiModel.Name, &aiModel.Provider, &aiModel.Enabled, &aiModel.APIKey,
 		&aiModel.CustomAPIURL, &aiModel.CustomModelName,
 		&aiModel.CreatedAt, &aiModel.UpdatedAt,
@@ -797,3 +797,144 @@ func TestConcurrentWritesWithWAL(t *testing.T) {
 		t.Errorf("并发写入失败次数过多: %d", errorCount)
 	}
 }
+
+// TestPromptVersions 测试提示词版本递增、相同内容不重复创建以及按版本查询
+func TestPromptVersions(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	v1, created, err := db.AddPromptVersion(PromptKindTemplate, "default", "规则A\n规则B", "", "file", "")
+	if err != nil || !created || v1.Version != 1 {
+		t.Fatalf("创建第一个版本失败: %+v created=%v err=%v", v1, created, err)
+	}
+	if v1.Hash != PromptHash("规则A\n规则B") {
+		t.Errorf("版本哈希不一致: %s", v1.Hash)
+	}
+
+	same, created, err := db.AddPromptVersion(PromptKindTemplate, "default", "规则A\n规则B", "", "file", "")
+	if err != nil || created || same.ID != v1.ID {
+		t.Fatalf("相同内容不应创建新版本: %+v created=%v err=%v", same, created, err)
+	}
+
+	v2, created, err := db.AddPromptVersion(PromptKindTemplate, "default", "规则A\n规则C", "", "user@test.com", "调整规则")
+	if err != nil || !created || v2.Version != 2 {
+		t.Fatalf("创建第二个版本失败: %+v created=%v err=%v", v2, created, err)
+	}
+
+	// 不同类型或名称的版本号独立计数
+	if other, _, err := db.AddPromptVersion(PromptKindCustom, "default", "自定义", "", "u", ""); err != nil || other.Version != 1 {
+		t.Fatalf("自定义提示词版本应从1开始: %+v err=%v", other, err)
+	}
+
+	versions, err := db.GetPromptVersions(PromptKindTemplate, "default")
+	if err != nil || len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 1 {
+		t.Fatalf("GetPromptVersions() = %+v, err=%v", versions, err)
+	}
+
+	got, err := db.GetPromptVersion(v2.ID)
+	if err != nil || got.Content != "规则A\n规则C" || got.Author != "user@test.com" || got.Note != "调整规则" {
+		t.Fatalf("GetPromptVersion() = %+v, err=%v", got, err)
+	}
+	prev, err := db.GetPreviousPromptVersion(got)
+	if err != nil || prev.ID != v1.ID {
+		t.Fatalf("GetPreviousPromptVersion() = %+v, err=%v", prev, err)
+	}
+	if _, err := db.GetPreviousPromptVersion(prev); err == nil {
+		t.Error("第一个版本不应有上一个版本")
+	}
+}
+
+// TestDiffPromptContent 测试按行diff
+func TestDiffPromptContent(t *testing.T) {
+	diff := DiffPromptContent("a\nb\nc", "a\nc\nd")
+	want := "  a\n- b\n  c\n+ d\n"
+	if diff != want {
+		t.Errorf("DiffPromptContent() =\n%s\nwant\n%s", diff, want)
+	}
+}
+
+// TestExperiments 测试实验的保存、读取和状态流转
+func TestExperiments(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	exp := &Experiment{
+		ID: "exp_1", UserID: "user1", Name: "模板对比", BaseTraderID: "base", InitialBalance: 500,
+		Variants: []ExperimentVariant{
+			{Label: "A", TraderID: "exp_1_a", SystemPromptTemplate: "default"},
+			{Label: "B", TraderID: "exp_1_b", SystemPromptTemplate: "aggressive", AIModelID: "qwen"},
+		},
+	}
+	if err := db.CreateExperiment(exp); err != nil {
+		t.Fatalf("CreateExperiment: %v", err)
+	}
+
+	got, err := db.GetExperiment("user1", "exp_1")
+	if err != nil {
+		t.Fatalf("GetExperiment: %v", err)
+	}
+	if got.Status != ExperimentCreated || got.StartedAt != nil || len(got.Variants) != 2 || got.Variants[1].AIModelID != "qwen" {
+		t.Fatalf("GetExperiment() = %+v", got)
+	}
+	if _, err := db.GetExperiment("user2", "exp_1"); err == nil {
+		t.Error("其他用户不应读取到实验")
+	}
+
+	startedAt := time.Now().Truncate(time.Second)
+	if err := db.UpdateExperimentStatus("user1", "exp_1", ExperimentRunning, startedAt); err != nil {
+		t.Fatalf("UpdateExperimentStatus: %v", err)
+	}
+	if err := db.UpdateExperimentStatus("user1", "exp_1", ExperimentStopped, startedAt.Add(time.Hour)); err != nil {
+		t.Fatalf("UpdateExperimentStatus: %v", err)
+	}
+
+	list, err := db.GetExperiments("user1")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("GetExperiments() = %v, err=%v", list, err)
+	}
+	if list[0].Status != ExperimentStopped || list[0].StartedAt == nil || !list[0].StartedAt.Equal(startedAt) ||
+		list[0].StoppedAt == nil || !list[0].StoppedAt.Equal(startedAt.Add(time.Hour)) {
+		t.Errorf("实验状态 = %s, 开始 %v, 停止 %v", list[0].Status, list[0].StartedAt, list[0].StoppedAt)
+	}
+}
+
+func TestTraderLessons(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	until := time.Now().Truncate(time.Second)
+	lessons := []*TraderLesson{
+		{Symbol: "SOLUSDT", Content: "追高开多容易被插针止损", TradesUntil: &until},
+		{Content: "亏损单不要拖过4小时"},
+		{Symbol: "ETHUSDT", Content: "资金费率过高时避免开多"},
+	}
+	if err := db.AddTraderLessons("user1", "trader1", lessons, 2); err != nil {
+		t.Fatalf("AddTraderLessons: %v", err)
+	}
+
+	got, err := db.GetTraderLessons("user1", "trader1")
+	if err != nil {
+		t.Fatalf("GetTraderLessons: %v", err)
+	}
+	if len(got) != 2 || got[0].Symbol != "ETHUSDT" || got[1].Content != "亏损单不要拖过4小时" {
+		t.Fatalf("超出上限时应只保留最新的2条, got %+v", got)
+	}
+	if other, _ := db.GetTraderLessons("user2", "trader1"); len(other) != 0 {
+		t.Error("其他用户不应读取到交易教训")
+	}
+
+	if err := db.UpdateTraderLesson("user1", "trader1", got[1].ID, "BTCUSDT", "突破失败后立即止损"); err != nil {
+		t.Fatalf("UpdateTraderLesson: %v", err)
+	}
+	if err := db.DeleteTraderLesson("user1", "trader1", got[0].ID); err != nil {
+		t.Fatalf("DeleteTraderLesson: %v", err)
+	}
+	if err := db.DeleteTraderLesson("user2", "trader1", got[1].ID); err == nil {
+		t.Error("不应删除其他用户的交易教训")
+	}
+
+	got, _ = db.GetTraderLessons("user1", "trader1")
+	if len(got) != 1 || got[0].Symbol != "BTCUSDT" || got[0].Content != "突破失败后立即止损" {
+		t.Errorf("编辑和删除后 = %+v", got)
+	}
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 实验状态
+const (
+	ExperimentCreated = "created" // 已创建，变体交易员未启动
+	ExperimentRunning = "running" // 运行中（所有变体同时启动）
+	ExperimentStopped = "stopped" // 已停止
+)
+
+// ExperimentVariant 实验变体（一个独立的交易员，只覆盖被比较的配置项）
+type ExperimentVariant struct {
+	Label                string `json:"label"`
+	TraderID             string `json:"trader_id"`
+	SystemPromptTemplate string `json:"system_prompt_template,omitempty"` // 空=沿用基础交易员
+	AIModelID            string `json:"ai_model_id,omitempty"`            // 空=沿用基础交易员
+	ExchangeID           string `json:"exchange_id,omitempty"`            // 空=沿用基础交易员
+	CustomPrompt         string `json:"custom_prompt,omitempty"`          // 空=沿用基础交易员
+}
+
+// Experiment A/B实验：从同一基础交易员克隆出多个变体，使用相同的币种范围、初始资金和启动时间
+type Experiment struct {
+	ID             string              `json:"id"`
+	UserID         string              `json:"user_id"`
+	Name           string              `json:"name"`
+	BaseTraderID   string              `json:"base_trader_id"`
+	InitialBalance float64             `json:"initial_balance"`
+	Variants       []ExperimentVariant `json:"variants"` // 第一个变体为对照组
+	Status         string              `json:"status"`
+	CreatedAt      time.Time           `json:"created_at"`
+	StartedAt      *time.Time          `json:"started_at,omitempty"`
+	StoppedAt      *time.Time          `json:"stopped_at,omitempty"`
+}
+
+// CreateExperiment 保存实验
+func (d *Database) CreateExperiment(exp *Experiment) error {
+	variants, err := json.Marshal(exp.Variants)
+	if err != nil {
+		return fmt.Errorf("序列化实验变体失败: %w", err)
+	}
+	_, err = d.db.Exec(`
+		INSERT INTO experiments (id, user_id, name, base_trader_id, initial_balance, variants, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, exp.ID, exp.UserID, exp.Name, exp.BaseTraderID, exp.InitialBalance, string(variants), ExperimentCreated)
+	if err != nil {
+		return fmt.Errorf("保存实验失败: %w", err)
+	}
+	return nil
+}
+
+// GetExperiments 获取用户的所有实验（从新到旧）
+func (d *Database) GetExperiments(userID string) ([]*Experiment, error) {
+	rows, err := d.db.Query(`
+		SELECT id, user_id, name, base_trader_id, initial_balance, variants, status, created_at, started_at, stopped_at
+		FROM experiments WHERE user_id = ? ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询实验失败: %w", err)
+	}
+	defer rows.Close()
+
+	var experiments []*Experiment
+	for rows.Next() {
+		exp, err := scanExperiment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("读取实验失败: %w", err)
+		}
+		experiments = append(experiments, exp)
+	}
+	return experiments, rows.Err()
+}
+
+// GetExperiment 获取用户的指定实验
+func (d *Database) GetExperiment(userID, id string) (*Experiment, error) {
+	exp, err := scanExperiment(d.db.QueryRow(`
+		SELECT id, user_id, name, base_trader_id, initial_balance, variants, status, created_at, started_at, stopped_at
+		FROM experiments WHERE user_id = ? AND id = ?
+	`, userID, id))
+	if err != nil {
+		return nil, fmt.Errorf("实验不存在: %w", err)
+	}
+	return exp, nil
+}
+
+// UpdateExperimentStatus 更新实验状态（启动时记录开始时间，停止时记录停止时间）
+func (d *Database) UpdateExperimentStatus(userID, id, status string, at time.Time) error {
+	var err error
+	switch status {
+	case ExperimentRunning:
+		_, err = d.db.Exec(`UPDATE experiments SET status = ?, started_at = ?, stopped_at = NULL WHERE id = ? AND user_id = ?`, status, at, id, userID)
+	case ExperimentStopped:
+		_, err = d.db.Exec(`UPDATE experiments SET status = ?, stopped_at = ? WHERE id = ? AND user_id = ?`, status, at, id, userID)
+	default:
+		_, err = d.db.Exec(`UPDATE experiments SET status = ? WHERE id = ? AND user_id = ?`, status, id, userID)
+	}
+	if err != nil {
+		return fmt.Errorf("更新实验状态失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteExperiment 删除实验记录（变体交易员由调用方处理）
+func (d *Database) DeleteExperiment(userID, id string) error {
+	_, err := d.db.Exec(`DELETE FROM experiments WHERE id = ? AND user_id = ?`, id, userID)
+	return err
+}
+
+func scanExperiment(row interface{ Scan(...interface{}) error }) (*Experiment, error) {
+	var exp Experiment
+	var variants string
+	var startedAt, stoppedAt sql.NullTime
+	if err := row.Scan(&exp.ID, &exp.UserID, &exp.Name, &exp.BaseTraderID, &exp.InitialBalance, &variants,
+		&exp.Status, &exp.CreatedAt, &startedAt, &stoppedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(variants), &exp.Variants); err != nil {
+		return nil, fmt.Errorf("解析实验变体失败: %w", err)
+	}
+	if startedAt.Valid {
+		exp.StartedAt = &startedAt.Time
+	}
+	if stoppedAt.Valid {
+		exp.StoppedAt = &stoppedAt.Time
+	}
+	return &exp, nil
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TraderLesson 交易员的一条交易教训（由反思生成，可通过API手动编辑或删除）
+type TraderLesson struct {
+	ID          int64      `json:"id"`
+	UserID      string     `json:"user_id"`
+	TraderID    string     `json:"trader_id"`
+	Symbol      string     `json:"symbol"` // 空表示通用教训
+	Content     string     `json:"content"`
+	TradesUntil *time.Time `json:"trades_until,omitempty"` // 生成时覆盖到的最后平仓时间（手动添加时为空）
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// AddTraderLessons 保存交易教训，超出 maxLessons 条时删除最旧的（maxLessons ≤ 0 不限制）
+func (d *Database) AddTraderLessons(userID, traderID string, lessons []*TraderLesson, maxLessons int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, lesson := range lessons {
+		if _, err := tx.Exec(`
+			INSERT INTO trader_lessons (user_id, trader_id, symbol, content, trades_until)
+			VALUES (?, ?, ?, ?, ?)
+		`, userID, traderID, lesson.Symbol, lesson.Content, lesson.TradesUntil); err != nil {
+			return fmt.Errorf("保存交易教训失败: %w", err)
+		}
+	}
+	if maxLessons > 0 {
+		if _, err := tx.Exec(`
+			DELETE FROM trader_lessons WHERE user_id = ? AND trader_id = ? AND id NOT IN (
+				SELECT id FROM trader_lessons WHERE user_id = ? AND trader_id = ? ORDER BY id DESC LIMIT ?
+			)
+		`, userID, traderID, userID, traderID, maxLessons); err != nil {
+			return fmt.Errorf("清理旧的交易教训失败: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交交易教训失败: %w", err)
+	}
+	return nil
+}
+
+// GetTraderLessons 获取交易员的所有教训（从新到旧）
+func (d *Database) GetTraderLessons(userID, traderID string) ([]*TraderLesson, error) {
+	rows, err := d.db.Query(`
+		SELECT id, user_id, trader_id, symbol, content, trades_until, created_at, updated_at
+		FROM trader_lessons WHERE user_id = ? AND trader_id = ? ORDER BY id DESC
+	`, userID, traderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询交易教训失败: %w", err)
+	}
+	defer rows.Close()
+
+	var lessons []*TraderLesson
+	for rows.Next() {
+		var lesson TraderLesson
+		var tradesUntil sql.NullTime
+		if err := rows.Scan(&lesson.ID, &lesson.UserID, &lesson.TraderID, &lesson.Symbol, &lesson.Content,
+			&tradesUntil, &lesson.CreatedAt, &lesson.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("读取交易教训失败: %w", err)
+		}
+		if tradesUntil.Valid {
+			lesson.TradesUntil = &tradesUntil.Time
+		}
+		lessons = append(lessons, &lesson)
+	}
+	return lessons, rows.Err()
+}
+
+// UpdateTraderLesson 修改交易教训的币种和内容
+func (d *Database) UpdateTraderLesson(userID, traderID string, id int64, symbol, content string) error {
+	result, err := d.db.Exec(`
+		UPDATE trader_lessons SET symbol = ?, content = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ? AND trader_id = ?
+	`, symbol, content, id, userID, traderID)
+	if err != nil {
+		return fmt.Errorf("更新交易教训失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("交易教训不存在: %d", id)
+	}
+	return nil
+}
+
+// DeleteTraderLesson 删除交易教训
+func (d *Database) DeleteTraderLesson(userID, traderID string, id int64) error {
+	result, err := d.db.Exec(`DELETE FROM trader_lessons WHERE id = ? AND user_id = ? AND trader_id = ?`, id, userID, traderID)
+	if err != nil {
+		return fmt.Errorf("删除交易教训失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("交易教训不存在: %d", id)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 提示词版本类型
+const (
+	PromptKindTemplate = "template" // 系统提示词模板（name 为模板名称）
+	PromptKindCustom   = "custom"   // 交易员自定义提示词（name 为交易员ID）
+)
+
+// PromptVersion 提示词版本（不可变，回滚会以旧内容创建一个新版本）
+type PromptVersion struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	Content   string    `json:"content"`
+	Hash      string    `json:"hash"` // 版本哈希（决策记录中引用）：自定义prompt为内容的 SHA-256，模板还包含其解析到的共享片段
+	Author    string    `json:"author"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PromptHash 提示词内容的 SHA-256（十六进制），提示词版本和决策记录共用
+func PromptHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// AddPromptVersion 记录提示词的新版本，哈希与最新版本相同时不创建，返回最新版本和 false
+// hash 为空时使用内容的 SHA-256（模板传入包含共享片段的哈希，片段变化时即使内容相同也会产生新版本）
+func (d *Database) AddPromptVersion(kind, name, content, hash, author, note string) (*PromptVersion, bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if hash == "" {
+		hash = PromptHash(content)
+	}
+	latest, err := scanPromptVersion(tx.QueryRow(`
+		SELECT id, kind, name, version, content, hash, author, note, created_at
+		FROM prompt_versions WHERE kind = ? AND name = ? ORDER BY version DESC LIMIT 1
+	`, kind, name))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("查询最新提示词版本失败: %w", err)
+	}
+	if latest != nil && latest.Hash == hash {
+		return latest, false, nil
+	}
+
+	version := 1
+	if latest != nil {
+		version = latest.Version + 1
+	}
+	result, err := tx.Exec(`
+		INSERT INTO prompt_versions (kind, name, version, content, hash, author, note)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, kind, name, version, content, hash, author, note)
+	if err != nil {
+		return nil, false, fmt.Errorf("保存提示词版本失败: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, false, fmt.Errorf("获取提示词版本ID失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("提交提示词版本失败: %w", err)
+	}
+
+	return &PromptVersion{
+		ID: id, Kind: kind, Name: name, Version: version, Content: content,
+		Hash: hash, Author: author, Note: note, CreatedAt: time.Now(),
+	}, true, nil
+}
+
+// GetPromptVersions 获取提示词的所有版本（从新到旧）
+func (d *Database) GetPromptVersions(kind, name string) ([]*PromptVersion, error) {
+	rows, err := d.db.Query(`
+		SELECT id, kind, name, version, content, hash, author, note, created_at
+		FROM prompt_versions WHERE kind = ? AND name = ? ORDER BY version DESC
+	`, kind, name)
+	if err != nil {
+		return nil, fmt.Errorf("查询提示词版本失败: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*PromptVersion
+	for rows.Next() {
+		v, err := scanPromptVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("读取提示词版本失败: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// GetPromptVersion 按ID获取提示词版本
+func (d *Database) GetPromptVersion(id int64) (*PromptVersion, error) {
+	v, err := scanPromptVersion(d.db.QueryRow(`
+		SELECT id, kind, name, version, content, hash, author, note, created_at
+		FROM prompt_versions WHERE id = ?
+	`, id))
+	if err != nil {
+		return nil, fmt.Errorf("提示词版本不存在: %w", err)
+	}
+	return v, nil
+}
+
+// GetPreviousPromptVersion 获取同一提示词的上一个版本（已是第一个版本时返回 sql.ErrNoRows）
+func (d *Database) GetPreviousPromptVersion(v *PromptVersion) (*PromptVersion, error) {
+	return scanPromptVersion(d.db.QueryRow(`
+		SELECT id, kind, name, version, content, hash, author, note, created_at
+		FROM prompt_versions WHERE kind = ? AND name = ? AND version < ? ORDER BY version DESC LIMIT 1
+	`, v.Kind, v.Name, v.Version))
+}
+
+func scanPromptVersion(row interface{ Scan(...interface{}) error }) (*PromptVersion, error) {
+	var v PromptVersion
+	if err := row.Scan(&v.ID, &v.Kind, &v.Name, &v.Version, &v.Content, &v.Hash, &v.Author, &v.Note, &v.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DiffPromptContent 按行比较两个版本的内容，输出统一diff格式的行（" " 未变，"-" 删除，"+" 新增）
+func DiffPromptContent(oldContent, newContent string) string {
+	a := strings.Split(oldContent, "\n")
+	b := strings.Split(newContent, "\n")
+
+	// 最长公共子序列
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			sb.WriteString("+ " + b[j] + "\n")
+			j++
+		default:
+			sb.WriteString("- " + a[i] + "\n")
+			i++
+		}
+	}
+	return sb.String()
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"nofx/config"
 	"nofx/market"
 	"nofx/mcp"
 	"nofx/pool"
@@ -40,6 +41,7 @@ type PositionInfo struct {
 	PeakPnLPct       float64 `json:"peak_pnl_pct"` // 历史最高收益率（百分比）
 	LiquidationPrice float64 `json:"liquidation_price"`
 	MarginUsed       float64 `json:"margin_used"`
+	FundingFee       float64 `json:"funding_fee"` // 持仓期间已结算的资金费（正数为收取，负数为支付）
 	UpdateTime       int64   `json:"update_time"` // 持仓更新时间戳（毫秒）
 }
 
@@ -72,38 +74,52 @@ type OITopData struct {
 
 // Context 交易上下文（传递给AI的完整信息）
 type Context struct {
-	CurrentTime     string                  `json:"current_time"`
-	RuntimeMinutes  int                     `json:"runtime_minutes"`
-	CallCount       int                     `json:"call_count"`
-	Account         AccountInfo             `json:"account"`
-	Positions       []PositionInfo          `json:"positions"`
-	CandidateCoins  []CandidateCoin         `json:"candidate_coins"`
-	MarketDataMap   map[string]*market.Data `json:"-"` // 不序列化，但内部使用
-	OITopDataMap    map[string]*OITopData   `json:"-"` // OI Top数据映射
-	Performance     interface{}             `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
-	BTCETHLeverage  int                     `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
-	AltcoinLeverage int                     `json:"-"` // 山寨币杠杆倍数（从配置读取）
+	CurrentTime      string                    `json:"current_time"`
+	RuntimeMinutes   int                       `json:"runtime_minutes"`
+	CallCount        int                       `json:"call_count"`
+	Account          AccountInfo               `json:"account"`
+	Positions        []PositionInfo            `json:"positions"`
+	CandidateCoins   []CandidateCoin           `json:"candidate_coins"`
+	MarketDataMap    map[string]*market.Data   `json:"-"` // 不序列化，但内部使用
+	OITopDataMap     map[string]*OITopData     `json:"-"` // OI Top数据映射
+	Performance      interface{}               `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
+	Exposure         *ExposureReport           `json:"-"` // 组合敞口与相关性分析
+	BTCETHLeverage   int                       `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
+	AltcoinLeverage  int                       `json:"-"` // 山寨币杠杆倍数（从配置读取）
+	Timeframes       []string                  `json:"-"` // 市场数据使用的K线周期（为空使用默认3m/4h）
+	Indicators       []market.IndicatorSpec    `json:"-"` // 写入市场数据的技术指标（为空时取模板声明）
+	MarketData       market.MarketDataProvider `json:"-"` // 行情数据源（与交易员执行的交易所一致，nil 使用币安）
+	Alerts           []market.Alert            `json:"-"` // 上次决策以来关注币种的市场警报
+	Trigger          string                    `json:"-"` // 本次决策的触发事件（为空表示定时周期）
+	TraderName       string                    `json:"-"` // 交易员名称（系统提示词模板变量）
+	PromptVars       map[string]string         `json:"-"` // 交易员自定义的系统提示词模板变量
+	UserPromptLayout string                    `json:"-"` // User Prompt 布局（name 或 name@vN，为空使用默认布局）
+	News             []string                  `json:"-"` // 新闻摘要（news 区块；由 GET_NEWS 扩展点提供，未注册时为空）
+	Lessons          []Lesson                  `json:"-"` // 与当前币种相关的历史交易教训（lessons 区块）
+	Validation       *ValidationProfile        `json:"-"` // 决策校验规则（nil 使用默认配置）
+	Rejections       []string                  `json:"-"` // 上个周期未通过校验的决策及原因
 }
 
 // Decision AI的交易决策
 type Decision struct {
 	Symbol string `json:"symbol"`
-	Action string `json:"action"` // "open_long", "open_short", "close_long", "close_short", "update_stop_loss", "update_take_profit", "partial_close", "hold", "wait"
+	Action string `json:"action"` // "open_long", "open_short", "close_long", "close_short", "update_stop_loss", "update_take_profit", "update_sl_tp", "partial_close", "add_to_position", "reverse", "hold", "wait"
 
-	// 开仓参数
+	// 开仓参数（add_to_position 和 reverse 同样使用；加仓时为加仓金额，止损止盈作用于加仓后的整个仓位）
 	Leverage        int     `json:"leverage,omitempty"`
 	PositionSizeUSD float64 `json:"position_size_usd,omitempty"`
 	StopLoss        float64 `json:"stop_loss,omitempty"`
 	TakeProfit      float64 `json:"take_profit,omitempty"`
 
 	// 调整参数（新增）
-	NewStopLoss     float64 `json:"new_stop_loss,omitempty"`    // 用于 update_stop_loss
-	NewTakeProfit   float64 `json:"new_take_profit,omitempty"`  // 用于 update_take_profit
+	NewStopLoss     float64 `json:"new_stop_loss,omitempty"`    // 用于 update_stop_loss、update_sl_tp
+	NewTakeProfit   float64 `json:"new_take_profit,omitempty"`  // 用于 update_take_profit、update_sl_tp
 	ClosePercentage float64 `json:"close_percentage,omitempty"` // 用于 partial_close (0-100)
 
 	// 通用参数
 	Confidence int     `json:"confidence,omitempty"` // 信心度 (0-100)
 	RiskUSD    float64 `json:"risk_usd,omitempty"`   // 最大美元风险
+	HoldHours  float64 `json:"hold_hours,omitempty"` // 预期持仓时长（小时），用于估算资金费成本
 	Reasoning  string  `json:"reasoning"`
 }
 
@@ -116,6 +132,27 @@ type FullDecision struct {
 	Timestamp    time.Time  `json:"timestamp"`
 	// AIRequestDurationMs 记录 AI API 调用耗时（毫秒）方便排查延迟问题
 	AIRequestDurationMs int64 `json:"ai_request_duration_ms,omitempty"`
+	// UserPromptLayout 生成 User Prompt 使用的布局（name@vN）
+	UserPromptLayout string `json:"user_prompt_layout,omitempty"`
+	// PromptTemplate / PromptTemplateHash 生成 System Prompt 使用的模板及其版本哈希（完全使用自定义prompt时为空）
+	PromptTemplate     string `json:"prompt_template,omitempty"`
+	PromptTemplateHash string `json:"prompt_template_hash,omitempty"`
+	// CustomPromptHash 交易员自定义prompt的版本哈希（未设置时为空）
+	CustomPromptHash string `json:"custom_prompt_hash,omitempty"`
+	// PromptTokens / PromptTokenBudget 估算的输入token数（system + user）和本次的输入预算
+	PromptTokens      int `json:"prompt_tokens,omitempty"`
+	PromptTokenBudget int `json:"prompt_token_budget,omitempty"`
+	// PromptCuts 为满足预算对 User Prompt 做的裁剪（未裁剪时为空）
+	PromptCuts []string `json:"prompt_cuts,omitempty"`
+	// MarketData / CandidateCoins AI实际看到的市场数据和候选币种（裁剪后，用于写入市场数据快照）
+	MarketData     map[string]*market.Data `json:"-"`
+	CandidateCoins []CandidateCoin         `json:"-"`
+	// Stages 多阶段流水线各阶段的提示词、输出和耗时（单次调用时为空）
+	Stages []StageTrace `json:"stages,omitempty"`
+	// Rejections 修正后仍未通过校验的决策及原因（不执行，下个周期反馈给AI）
+	Rejections []string `json:"rejections,omitempty"`
+	// Repairs 输出未通过解析或校验时让模型自我修正的记录（无需修正时为空）
+	Repairs []RepairAttempt `json:"repairs,omitempty"`
 }
 
 // GetFullDecision 获取AI的完整交易决策（批量分析所有币种和持仓）
@@ -125,14 +162,40 @@ func GetFullDecision(ctx *Context, mcpClient *mcp.Client) (*FullDecision, error)
 
 // GetFullDecisionWithCustomPrompt 获取AI的完整交易决策（支持自定义prompt和模板选择）
 func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
-	// 1. 为所有币种获取市场数据
+	// 1. 为所有币种获取市场数据（含模板声明的技术指标）
+	if err := prepareMarketData(ctx, templateName); err != nil {
+		return nil, err
+	}
+	return analyzeWithAI(ctx, mcpClient, customPrompt, overrideBase, templateName)
+}
+
+// prepareMarketData 获取上下文中所有币种的市场数据（含模板声明的技术指标）
+func prepareMarketData(ctx *Context, templateName string) error {
+	if ctx.Indicators == nil {
+		ctx.Indicators = GetPromptTemplateIndicators(templateName)
+	}
 	if err := fetchMarketDataForContext(ctx); err != nil {
-		return nil, fmt.Errorf("获取市场数据失败: %w", err)
+		return fmt.Errorf("获取市场数据失败: %w", err)
 	}
+	return nil
+}
 
+// analyzeWithAI 基于已获取的市场数据构建提示词并调用AI得到决策
+func analyzeWithAI(ctx *Context, mcpClient *mcp.Client, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
 	// 2. 构建 System Prompt（固定规则）和 User Prompt（动态数据）
-	systemPrompt := buildSystemPromptWithCustom(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, customPrompt, overrideBase, templateName)
-	userPrompt := buildUserPrompt(ctx)
+	systemPrompt := buildSystemPromptWithCustom(newPromptData(ctx), customPrompt, overrideBase, templateName)
+	// User Prompt 按模型上下文预算组装（超出时裁剪候选币种和K线序列）
+	layout := resolveUserPromptLayout(ctx.UserPromptLayout)
+	budget, err := promptBudgetFor(mcpClient)
+	if err != nil {
+		return nil, fmt.Errorf("计算提示词预算失败: %w", err)
+	}
+	systemTokens := budget.Tokenizer.CountTokens(systemPrompt)
+	assembled := assembleUserPrompt(ctx, layout, budget.Limit-systemTokens, budget.Tokenizer)
+	userPrompt := assembled.Prompt
+	if len(assembled.Cuts) > 0 {
+		log.Printf("✂️  Prompt 超出预算 %d tokens，已裁剪: %s", budget.Limit, strings.Join(assembled.Cuts, "; "))
+	}
 
 	// 3. 调用AI API（使用 system + user prompt）
 	aiCallStart := time.Now()
@@ -142,8 +205,8 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, custom
 		return nil, fmt.Errorf("调用AI API失败: %w", err)
 	}
 
-	// 4. 解析AI响应
-	decision, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+	// 4. 解析AI响应（未通过解析或校验时把错误发回给模型修正）
+	decision, repairs, err := parseWithRepair(ctx, mcpClient, systemPrompt, userPrompt, aiResponse, layout.Language)
 
 	// 无论是否有错误，都要保存 SystemPrompt 和 UserPrompt（用于调试和决策未执行后的问题定位）
 	if decision != nil {
@@ -151,6 +214,13 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, custom
 		decision.SystemPrompt = systemPrompt // 保存系统prompt
 		decision.UserPrompt = userPrompt     // 保存输入prompt
 		decision.AIRequestDurationMs = aiCallDuration.Milliseconds()
+		decision.UserPromptLayout = layout.ID()
+		decision.PromptTokens = systemTokens + assembled.Tokens
+		decision.PromptTokenBudget = budget.Limit
+		decision.PromptCuts = assembled.Cuts
+		decision.MarketData, decision.CandidateCoins = assembled.MarketDataMap, assembled.CandidateCoins
+		decision.Repairs = repairs
+		decision.PromptTemplate, decision.PromptTemplateHash, decision.CustomPromptHash = promptVersions(templateName, customPrompt, overrideBase)
 	}
 
 	if err != nil {
@@ -176,10 +246,10 @@ func fetchMarketDataForContext(ctx *Context) error {
 		symbolSet[pos.Symbol] = true
 	}
 
-	// 2. 候选币种数量根据账户状态动态调整
-	maxCandidates := calculateMaxCandidates(ctx)
+	// 2. 候选币种按来源强度排序，最多获取 maxCandidateFetch 个（写入Prompt的数量由token预算决定）
+	ctx.CandidateCoins = rankCandidates(ctx.CandidateCoins)
 	for i, coin := range ctx.CandidateCoins {
-		if i >= maxCandidates {
+		if i >= maxCandidateFetch {
 			break
 		}
 		symbolSet[coin.Symbol] = true
@@ -192,8 +262,13 @@ func fetchMarketDataForContext(ctx *Context) error {
 		positionSymbols[pos.Symbol] = true
 	}
 
+	primaryInterval := market.DefaultTimeframes[0]
+	if len(ctx.Timeframes) > 0 {
+		primaryInterval = ctx.Timeframes[0]
+	}
+
 	for symbol := range symbolSet {
-		data, err := market.Get(symbol)
+		data, err := market.FetchData(ctx.MarketData, symbol, ctx.Timeframes)
 		if err != nil {
 			// 单个币种失败不影响整体，只记录错误
 			continue
@@ -217,6 +292,8 @@ func fetchMarketDataForContext(ctx *Context) error {
 			}
 		}
 
+		// 技术指标默认计算在主周期（最短周期）上
+		data.Indicators = market.ComputeIndicators(ctx.MarketData, symbol, ctx.Indicators, primaryInterval)
 		ctx.MarketDataMap[symbol] = data
 	}
 
@@ -237,47 +314,30 @@ func fetchMarketDataForContext(ctx *Context) error {
 		}
 	}
 
+	// 组合敞口与相关性（候选币种仅取通过过滤、有市场数据的未持仓币种）
+	var exposureCandidates []string
+	for _, coin := range ctx.CandidateCoins {
+		if _, ok := ctx.MarketDataMap[coin.Symbol]; ok && !positionSymbols[coin.Symbol] {
+			exposureCandidates = append(exposureCandidates, coin.Symbol)
+		}
+	}
+	ctx.Exposure = BuildExposureReport(ctx.MarketData, ctx.Positions, exposureCandidates, ctx.Account.TotalEquity)
+
 	return nil
 }
 
-// calculateMaxCandidates 根据账户状态计算需要分析的候选币种数量
-func calculateMaxCandidates(ctx *Context) int {
-	// ⚠️ 重要：限制候选币种数量，避免 Prompt 过大
-	// 根据持仓数量动态调整：持仓越少，可以分析更多候选币
-	const (
-		maxCandidatesWhenEmpty    = 30 // 无持仓时最多分析30个候选币
-		maxCandidatesWhenHolding1 = 25 // 持仓1个时最多分析25个候选币
-		maxCandidatesWhenHolding2 = 20 // 持仓2个时最多分析20个候选币
-		maxCandidatesWhenHolding3 = 15 // 持仓3个时最多分析15个候选币（避免 Prompt 过大）
-	)
-
-	positionCount := len(ctx.Positions)
-	var maxCandidates int
-
-	switch positionCount {
-	case 0:
-		maxCandidates = maxCandidatesWhenEmpty
-	case 1:
-		maxCandidates = maxCandidatesWhenHolding1
-	case 2:
-		maxCandidates = maxCandidatesWhenHolding2
-	default: // 3+ 持仓
-		maxCandidates = maxCandidatesWhenHolding3
-	}
-
-	// 返回实际候选币数量和上限中的较小值
-	return min(len(ctx.CandidateCoins), maxCandidates)
-}
+// maxCandidateFetch 每个周期最多获取行情的候选币种数量（限制行情请求量）
+const maxCandidateFetch = 30
 
 // buildSystemPromptWithCustom 构建包含自定义内容的 System Prompt
-func buildSystemPromptWithCustom(accountEquity float64, btcEthLeverage, altcoinLeverage int, customPrompt string, overrideBase bool, templateName string) string {
+func buildSystemPromptWithCustom(data *PromptData, customPrompt string, overrideBase bool, templateName string) string {
 	// 如果覆盖基础prompt且有自定义prompt，只使用自定义prompt
 	if overrideBase && customPrompt != "" {
 		return customPrompt
 	}
 
 	// 获取基础prompt（使用指定的模板）
-	basePrompt := buildSystemPrompt(accountEquity, btcEthLeverage, altcoinLeverage, templateName)
+	basePrompt := buildSystemPrompt(data, templateName)
 
 	// 如果没有自定义prompt，直接返回基础prompt
 	if customPrompt == "" {
@@ -296,10 +356,31 @@ func buildSystemPromptWithCustom(accountEquity float64, btcEthLeverage, altcoinL
 	return sb.String()
 }
 
-// buildSystemPrompt 构建 System Prompt（使用模板+动态部分）
-func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage int, templateName string) string {
-	var sb strings.Builder
+// promptVersions 本次 System Prompt 使用的模板名称、模板版本哈希和自定义prompt哈希（与 buildSystemPrompt 的模板选择一致）
+func promptVersions(templateName, customPrompt string, overrideBase bool) (name, templateHash, customHash string) {
+	if customPrompt != "" {
+		customHash = config.PromptHash(customPrompt)
+	}
+	if overrideBase && customPrompt != "" {
+		return "", "", customHash
+	}
+	if templateName == "" {
+		templateName = "default"
+	}
+	template, err := GetPromptTemplate(templateName)
+	if err != nil {
+		if template, err = GetPromptTemplate("default"); err != nil {
+			return fallbackPromptTemplate.Name, "", customHash
+		}
+	}
+	return template.Name, template.Hash, customHash
+}
 
+// fallbackPromptTemplate 无法加载任何模板时使用的内置简化版本
+var fallbackPromptTemplate = &PromptTemplate{Name: "fallback", Content: "你是专业的加密货币交易AI。请根据市场数据做出交易决策。"}
+
+// buildSystemPrompt 构建 System Prompt（模板内容 + 硬约束、输出格式片段，通过 text/template 渲染）
+func buildSystemPrompt(data *PromptData, templateName string) string {
 	// 1. 加载提示词模板（核心交易策略部分）
 	if templateName == "" {
 		templateName = "default" // 默认使用 default 模板
@@ -313,151 +394,34 @@ func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage in
 		if err != nil {
 			// 如果连 default 都不存在，使用内置的简化版本
 			log.Printf("❌ 无法加载任何提示词模板，使用内置简化版本")
-			sb.WriteString("你是专业的加密货币交易AI。请根据市场数据做出交易决策。\n\n")
-		} else {
-			sb.WriteString(template.Content)
-			sb.WriteString("\n\n")
-		}
-	} else {
-		sb.WriteString(template.Content)
-		sb.WriteString("\n\n")
-	}
-
-	// 2. 硬约束（风险控制）- 动态生成
-	sb.WriteString("# 硬约束（风险控制）\n\n")
-	sb.WriteString("1. 风险回报比: 必须 ≥ 1:3（冒1%风险，赚3%+收益）\n")
-	sb.WriteString("2. 最多持仓: 3个币种（质量>数量）\n")
-	sb.WriteString(fmt.Sprintf("3. 单币仓位: 山寨%.0f-%.0f U | BTC/ETH %.0f-%.0f U\n",
-		accountEquity*0.8, accountEquity*1.5, accountEquity*5, accountEquity*10))
-	sb.WriteString(fmt.Sprintf("4. 杠杆限制: **山寨币最大%dx杠杆** | **BTC/ETH最大%dx杠杆** (⚠️ 严格执行，不可超过)\n", altcoinLeverage, btcEthLeverage))
-	sb.WriteString("5. 保证金: 总使用率 ≤ 90%\n")
-	sb.WriteString("6. 开仓金额: 建议 **≥12 USDT** (交易所最小名义价值 10 USDT + 安全边际)\n\n")
-
-	// 3. 输出格式 - 动态生成
-	sb.WriteString("# 输出格式 (严格遵守)\n\n")
-	sb.WriteString("**必须使用XML标签 <reasoning> 和 <decision> 标签分隔思维链和决策JSON，避免解析错误**\n\n")
-	sb.WriteString("## 格式要求\n\n")
-	sb.WriteString("<reasoning>\n")
-	sb.WriteString("你的思维链分析...\n")
-	sb.WriteString("- 简洁分析你的思考过程 \n")
-	sb.WriteString("</reasoning>\n\n")
-	sb.WriteString("<decision>\n")
-	sb.WriteString("```json\n[\n")
-	sb.WriteString(fmt.Sprintf("  {\"symbol\": \"BTCUSDT\", \"action\": \"open_short\", \"leverage\": %d, \"position_size_usd\": %.0f, \"stop_loss\": 97000, \"take_profit\": 91000, \"confidence\": 85, \"risk_usd\": 300, \"reasoning\": \"下跌趋势+MACD死叉\"},\n", btcEthLeverage, accountEquity*5))
-	sb.WriteString("  {\"symbol\": \"ETHUSDT\", \"action\": \"close_long\", \"reasoning\": \"止盈离场\"}\n")
-	sb.WriteString("]\n```\n")
-	sb.WriteString("</decision>\n\n")
-	sb.WriteString("## 字段说明\n\n")
-	sb.WriteString("- `action`: open_long | open_short | close_long | close_short | hold | wait\n")
-	sb.WriteString("- `confidence`: 0-100（开仓建议≥75）\n")
-	sb.WriteString("- 开仓时必填: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd, reasoning\n\n")
-
-	return sb.String()
-}
-
-// buildUserPrompt 构建 User Prompt（动态数据）
-func buildUserPrompt(ctx *Context) string {
-	var sb strings.Builder
-
-	// 系统状态
-	sb.WriteString(fmt.Sprintf("时间: %s | 周期: #%d | 运行: %d分钟\n\n",
-		ctx.CurrentTime, ctx.CallCount, ctx.RuntimeMinutes))
-
-	// BTC 市场
-	if btcData, hasBTC := ctx.MarketDataMap["BTCUSDT"]; hasBTC {
-		sb.WriteString(fmt.Sprintf("BTC: %.2f (1h: %+.2f%%, 4h: %+.2f%%) | MACD: %.4f | RSI: %.2f\n\n",
-			btcData.CurrentPrice, btcData.PriceChange1h, btcData.PriceChange4h,
-			btcData.CurrentMACD, btcData.CurrentRSI7))
-	}
-
-	// 账户
-	sb.WriteString(fmt.Sprintf("账户: 净值%.2f | 余额%.2f (%.1f%%) | 盈亏%+.2f%% | 保证金%.1f%% | 持仓%d个\n\n",
-		ctx.Account.TotalEquity,
-		ctx.Account.AvailableBalance,
-		(ctx.Account.AvailableBalance/ctx.Account.TotalEquity)*100,
-		ctx.Account.TotalPnLPct,
-		ctx.Account.MarginUsedPct,
-		ctx.Account.PositionCount))
-
-	// 持仓（完整市场数据）
-	if len(ctx.Positions) > 0 {
-		sb.WriteString("## 当前持仓\n")
-		for i, pos := range ctx.Positions {
-			// 计算持仓时长
-			holdingDuration := ""
-			if pos.UpdateTime > 0 {
-				durationMs := time.Now().UnixMilli() - pos.UpdateTime
-				durationMin := durationMs / (1000 * 60) // 转换为分钟
-				if durationMin < 60 {
-					holdingDuration = fmt.Sprintf(" | 持仓时长%d分钟", durationMin)
-				} else {
-					durationHour := durationMin / 60
-					durationMinRemainder := durationMin % 60
-					holdingDuration = fmt.Sprintf(" | 持仓时长%d小时%d分钟", durationHour, durationMinRemainder)
-				}
-			}
-
-			sb.WriteString(fmt.Sprintf("%d. %s %s | 入场价%.4f 当前价%.4f | 盈亏%+.2f%% | 盈亏金额%+.2f USDT | 最高收益率%.2f%% | 杠杆%dx | 保证金%.0f | 强平价%.4f%s\n\n",
-				i+1, pos.Symbol, strings.ToUpper(pos.Side),
-				pos.EntryPrice, pos.MarkPrice, pos.UnrealizedPnLPct, pos.UnrealizedPnL, pos.PeakPnLPct,
-				pos.Leverage, pos.MarginUsed, pos.LiquidationPrice, holdingDuration))
-
-			// 使用FormatMarketData输出完整市场数据
-			if marketData, ok := ctx.MarketDataMap[pos.Symbol]; ok {
-				sb.WriteString(market.Format(marketData))
-				sb.WriteString("\n")
-			}
+			template = fallbackPromptTemplate
 		}
-	} else {
-		sb.WriteString("当前持仓: 无\n\n")
 	}
 
-	// 候选币种（完整市场数据）
-	sb.WriteString(fmt.Sprintf("## 候选币种 (%d个)\n\n", len(ctx.MarketDataMap)))
-	displayedCount := 0
-	for _, coin := range ctx.CandidateCoins {
-		marketData, hasData := ctx.MarketDataMap[coin.Symbol]
-		if !hasData {
-			continue
-		}
-		displayedCount++
-
-		sourceTags := ""
-		if len(coin.Sources) > 1 {
-			sourceTags = " (AI500+OI_Top双重信号)"
-		} else if len(coin.Sources) == 1 && coin.Sources[0] == "oi_top" {
-			sourceTags = " (OI_Top持仓增长)"
-		}
-
-		// 使用FormatMarketData输出完整市场数据
-		sb.WriteString(fmt.Sprintf("### %d. %s%s\n\n", displayedCount, coin.Symbol, sourceTags))
-		sb.WriteString(market.Format(marketData))
-		sb.WriteString("\n")
-	}
-	sb.WriteString("\n")
-
-	// 夏普比率（直接传值，不要复杂格式化）
-	if ctx.Performance != nil {
-		// 直接从interface{}中提取SharpeRatio
-		type PerformanceData struct {
-			SharpeRatio float64 `json:"sharpe_ratio"`
-		}
-		var perfData PerformanceData
-		if jsonData, err := json.Marshal(ctx.Performance); err == nil {
-			if err := json.Unmarshal(jsonData, &perfData); err == nil {
-				sb.WriteString(fmt.Sprintf("## 📊 夏普比率: %.2f\n\n", perfData.SharpeRatio))
-			}
-		}
+	// 2. 渲染模板变量，未引用的硬约束（风险控制）和输出格式片段追加在末尾
+	prompt, err := template.Render(data)
+	if err != nil {
+		// 模板已在加载时校验，这里失败说明运行时数据异常，回退到内置简化版本
+		log.Printf("❌ 渲染提示词模板 '%s' 失败，使用内置简化版本: %v", template.Name, err)
+		prompt, _ = fallbackPromptTemplate.Render(data)
 	}
+	return prompt
+}
 
-	sb.WriteString("---\n\n")
-	sb.WriteString("现在请分析并输出决策（思维链 + JSON）\n")
+// RenderUserPrompt 基于已保存的市场数据快照重新渲染 User Prompt（审计或对比不同模板时使用，不会重新获取行情）
+func RenderUserPrompt(ctx *Context, marketData map[string]*market.Data) string {
+	replay := *ctx
+	replay.MarketDataMap = marketData
+	return buildUserPrompt(&replay)
+}
 
-	return sb.String()
+// buildUserPrompt 构建 User Prompt（动态数据，按交易员选择的布局组合区块）
+func buildUserPrompt(ctx *Context) string {
+	return renderUserPrompt(ctx, resolveUserPromptLayout(ctx.UserPromptLayout))
 }
 
-// parseFullDecisionResponse 解析AI的完整决策响应
-func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthLeverage, altcoinLeverage int) (*FullDecision, error) {
+// parseFullDecisionResponse 解析AI的完整决策响应（无法提取决策时返回错误，未通过校验的决策记入 Rejections）
+func parseFullDecisionResponse(aiResponse string, ctx *Context) (*FullDecision, error) {
 	// 1. 提取思维链
 	cotTrace := extractCoTTrace(aiResponse)
 
@@ -470,17 +434,12 @@ func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthL
 		}, fmt.Errorf("提取决策失败: %w", err)
 	}
 
-	// 3. 验证决策
-	if err := validateDecisions(decisions, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
-		return &FullDecision{
-			CoTTrace:  cotTrace,
-			Decisions: decisions,
-		}, fmt.Errorf("决策验证失败: %w", err)
-	}
-
+	// 3. 按交易员的校验配置逐条验证决策（只保留通过校验的决策，拒绝原因见 Rejections）
+	valid, rejections := newDecisionValidator(ctx).validateAll(decisions)
 	return &FullDecision{
-		CoTTrace:  cotTrace,
-		Decisions: decisions,
+		CoTTrace:   cotTrace,
+		Decisions:  valid,
+		Rejections: rejections,
 	}, nil
 }
 
@@ -669,16 +628,6 @@ func compactArrayOpen(s string) string {
 	return reArrayOpenSpace.ReplaceAllString(strings.TrimSpace(s), "[{")
 }
 
-// validateDecisions 验证所有决策（需要账户信息和杠杆配置）
-func validateDecisions(decisions []Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
-	for i, decision := range decisions {
-		if err := validateDecision(&decision, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
-			return fmt.Errorf("决策 #%d 验证失败: %w", i+1, err)
-		}
-	}
-	return nil
-}
-
 // findMatchingBracket 查找匹配的右括号
 func findMatchingBracket(s string, start int) int {
 	if start >= len(s) || s[start] != '[' {
@@ -700,141 +649,3 @@ func findMatchingBracket(s string, start int) int {
 
 	return -1
 }
-
-// validateDecision 验证单个决策的有效性
-func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
-	// 验证action
-	validActions := map[string]bool{
-		"open_long":          true,
-		"open_short":         true,
-		"close_long":         true,
-		"close_short":        true,
-		"update_stop_loss":   true,
-		"update_take_profit": true,
-		"partial_close":      true,
-		"hold":               true,
-		"wait":               true,
-	}
-
-	if !validActions[d.Action] {
-		return fmt.Errorf("无效的action: %s", d.Action)
-	}
-
-	// 开仓操作必须提供完整参数
-	if d.Action == "open_long" || d.Action == "open_short" {
-		// 根据币种使用配置的杠杆上限
-		maxLeverage := altcoinLeverage          // 山寨币使用配置的杠杆
-		maxPositionValue := accountEquity * 1.5 // 山寨币最多1.5倍账户净值
-		if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
-			maxLeverage = btcEthLeverage          // BTC和ETH使用配置的杠杆
-			maxPositionValue = accountEquity * 10 // BTC/ETH最多10倍账户净值
-		}
-
-		// ✅ Fallback 机制：杠杆超限时自动修正为上限值（而不是直接拒绝决策）
-		if d.Leverage <= 0 {
-			return fmt.Errorf("杠杆必须大于0: %d", d.Leverage)
-		}
-		if d.Leverage > maxLeverage {
-			log.Printf("⚠️  [Leverage Fallback] %s 杠杆超限 (%dx > %dx)，自动调整为上限值 %dx",
-				d.Symbol, d.Leverage, maxLeverage, maxLeverage)
-			d.Leverage = maxLeverage // 自动修正为上限值
-		}
-		if d.PositionSizeUSD <= 0 {
-			return fmt.Errorf("仓位大小必须大于0: %.2f", d.PositionSizeUSD)
-		}
-
-		// ✅ 验证最小开仓金额（防止数量格式化为 0 的错误）
-		// Binance 最小名义价值 10 USDT + 安全边际
-		const minPositionSizeGeneral = 12.0 // 10 + 20% 安全边际
-		const minPositionSizeBTCETH = 60.0  // BTC/ETH 因价格高和精度限制需要更大金额（更灵活）
-
-		if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
-			if d.PositionSizeUSD < minPositionSizeBTCETH {
-				return fmt.Errorf("%s 开仓金额过小(%.2f USDT)，必须≥%.2f USDT（因价格高且精度限制，避免数量四舍五入为0）", d.Symbol, d.PositionSizeUSD, minPositionSizeBTCETH)
-			}
-		} else {
-			if d.PositionSizeUSD < minPositionSizeGeneral {
-				return fmt.Errorf("开仓金额过小(%.2f USDT)，必须≥%.2f USDT（Binance 最小名义价值要求）", d.PositionSizeUSD, minPositionSizeGeneral)
-			}
-		}
-
-		// 验证仓位价值上限（加1%容差以避免浮点数精度问题）
-		tolerance := maxPositionValue * 0.01 // 1%容差
-		if d.PositionSizeUSD > maxPositionValue+tolerance {
-			if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
-				return fmt.Errorf("BTC/ETH单币种仓位价值不能超过%.0f USDT（10倍账户净值），实际: %.0f", maxPositionValue, d.PositionSizeUSD)
-			} else {
-				return fmt.Errorf("山寨币单币种仓位价值不能超过%.0f USDT（1.5倍账户净值），实际: %.0f", maxPositionValue, d.PositionSizeUSD)
-			}
-		}
-		if d.StopLoss <= 0 || d.TakeProfit <= 0 {
-			return fmt.Errorf("止损和止盈必须大于0")
-		}
-
-		// 验证止损止盈的合理性
-		if d.Action == "open_long" {
-			if d.StopLoss >= d.TakeProfit {
-				return fmt.Errorf("做多时止损价必须小于止盈价")
-			}
-		} else {
-			if d.StopLoss <= d.TakeProfit {
-				return fmt.Errorf("做空时止损价必须大于止盈价")
-			}
-		}
-
-		// 验证风险回报比（必须≥1:3）
-		// 计算入场价（假设当前市价）
-		var entryPrice float64
-		if d.Action == "open_long" {
-			// 做多：入场价在止损和止盈之间
-			entryPrice = d.StopLoss + (d.TakeProfit-d.StopLoss)*0.2 // 假设在20%位置入场
-		} else {
-			// 做空：入场价在止损和止盈之间
-			entryPrice = d.StopLoss - (d.StopLoss-d.TakeProfit)*0.2 // 假设在20%位置入场
-		}
-
-		var riskPercent, rewardPercent, riskRewardRatio float64
-		if d.Action == "open_long" {
-			riskPercent = (entryPrice - d.StopLoss) / entryPrice * 100
-			rewardPercent = (d.TakeProfit - entryPrice) / entryPrice * 100
-			if riskPercent > 0 {
-				riskRewardRatio = rewardPercent / riskPercent
-			}
-		} else {
-			riskPercent = (d.StopLoss - entryPrice) / entryPrice * 100
-			rewardPercent = (entryPrice - d.TakeProfit) / entryPrice * 100
-			if riskPercent > 0 {
-				riskRewardRatio = rewardPercent / riskPercent
-			}
-		}
-
-		// 硬约束：风险回报比必须≥3.0
-		if riskRewardRatio < 3.0 {
-			return fmt.Errorf("风险回报比过低(%.2f:1)，必须≥3.0:1 [风险:%.2f%% 收益:%.2f%%] [止损:%.2f 止盈:%.2f]",
-				riskRewardRatio, riskPercent, rewardPercent, d.StopLoss, d.TakeProfit)
-		}
-	}
-
-	// 动态调整止损验证
-	if d.Action == "update_stop_loss" {
-		if d.NewStopLoss <= 0 {
-			return fmt.Errorf("新止损价格必须大于0: %.2f", d.NewStopLoss)
-		}
-	}
-
-	// 动态调整止盈验证
-	if d.Action == "update_take_profit" {
-		if d.NewTakeProfit <= 0 {
-			return fmt.Errorf("新止盈价格必须大于0: %.2f", d.NewTakeProfit)
-		}
-	}
-
-	// 部分平仓验证
-	if d.Action == "partial_close" {
-		if d.ClosePercentage <= 0 || d.ClosePercentage > 100 {
-			return fmt.Errorf("平仓百分比必须在0-100之间: %.1f", d.ClosePercentage)
-		}
-	}
-
-	return nil
-}
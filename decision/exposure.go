@@ -0,0 +1,342 @@
+package decision
+
+import (
+	"fmt"
+	"math"
+	"nofx/market"
+	"sort"
+	"strings"
+)
+
+const (
+	// exposureInterval 计算收益率相关性使用的K线周期
+	exposureInterval = "4h"
+	// exposureLookbackBars 参与计算的最近K线数量（4h×60 ≈ 10天）
+	exposureLookbackBars = 60
+	// minOverlapReturns 两个币种至少需要的重叠收益率样本数
+	minOverlapReturns = 20
+	// highCorrelationThreshold 视为"同一押注"的相关系数阈值
+	highCorrelationThreshold = 0.8
+	// maxCorrelatedPairsInPrompt 提示词中最多列出的高相关币对数量
+	maxCorrelatedPairsInPrompt = 8
+	// exposureBenchmark 计算Beta的基准币种
+	exposureBenchmark = "BTCUSDT"
+)
+
+// PositionExposure 单个持仓的敞口
+type PositionExposure struct {
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"`
+	Notional      float64 `json:"notional"`       // 带方向的名义价值（多为正，空为负）
+	BetaToBTC     float64 `json:"beta_to_btc"`    // 相对BTC的Beta（收益率回归斜率）
+	HasBeta       bool    `json:"has_beta"`       // 样本不足时为 false
+	BTCEquivalent float64 `json:"btc_equivalent"` // Beta调整后的等效BTC敞口（USDT）
+}
+
+// CorrelatedPair 高相关币对
+type CorrelatedPair struct {
+	SymbolA     string  `json:"symbol_a"`
+	SymbolB     string  `json:"symbol_b"`
+	Correlation float64 `json:"correlation"`
+	BothHeld    bool    `json:"both_held"`    // 两个币种均有持仓
+	SameBet     bool    `json:"same_bet"`     // 均有持仓且方向使风险叠加（正相关同向或负相关反向）
+	HeldSymbol  string  `json:"held_symbol"`  // 仅一个有持仓时，持仓的币种
+	HeldSide    string  `json:"held_side"`    // 仅一个有持仓时，持仓的方向
+	IsCandidate bool    `json:"is_candidate"` // 是否涉及候选币种（未持仓）
+}
+
+// ExposureReport 组合敞口与相关性分析
+type ExposureReport struct {
+	Interval         string             `json:"interval"`
+	Equity           float64            `json:"equity"`
+	LongNotional     float64            `json:"long_notional"`
+	ShortNotional    float64            `json:"short_notional"`
+	NetNotional      float64            `json:"net_notional"`
+	GrossNotional    float64            `json:"gross_notional"`
+	NetExposurePct   float64            `json:"net_exposure_pct"`   // 净敞口 / 净值
+	GrossExposurePct float64            `json:"gross_exposure_pct"` // 总敞口 / 净值
+	BetaAdjustedNet  float64            `json:"beta_adjusted_net"`  // Beta调整后的净BTC敞口（USDT）
+	Positions        []PositionExposure `json:"positions"`
+	Symbols          []string           `json:"symbols"`     // 相关性矩阵的币种顺序
+	Correlation      [][]float64        `json:"correlation"` // 相关系数矩阵（样本不足时为 0）
+	CorrelatedPairs  []CorrelatedPair   `json:"correlated_pairs"`
+}
+
+// exposureKlineFetcher 从行情数据源获取计算相关性用的K线（p 为nil时使用币安，测试中可替换）
+var exposureKlineFetcher = func(p market.MarketDataProvider, symbol string) ([]market.Kline, error) {
+	if p == nil {
+		p = market.Binance
+	}
+	if !market.Available(p) {
+		return nil, fmt.Errorf("行情数据源 %s 不可用", p.Name())
+	}
+	return p.GetKlines(market.Normalize(symbol), exposureInterval)
+}
+
+// BuildExposureReport 根据持仓和候选币种计算组合敞口、BTC Beta和相关性矩阵（K线来自交易员的行情数据源）
+func BuildExposureReport(p market.MarketDataProvider, positions []PositionInfo, candidates []string, equity float64) *ExposureReport {
+	// 币种顺序：BTC、持仓、候选（去重）
+	seen := make(map[string]bool)
+	var symbols []string
+	addSymbol := func(symbol string) {
+		if symbol != "" && !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	addSymbol(exposureBenchmark)
+	for _, pos := range positions {
+		addSymbol(pos.Symbol)
+	}
+	for _, symbol := range candidates {
+		addSymbol(symbol)
+	}
+
+	returns := make(map[string]map[int64]float64, len(symbols))
+	for _, symbol := range symbols {
+		klines, err := exposureKlineFetcher(p, symbol)
+		if err != nil {
+			continue
+		}
+		returns[symbol] = klineReturns(klines, exposureLookbackBars)
+	}
+
+	return computeExposure(positions, symbols, returns, equity)
+}
+
+// computeExposure 计算敞口报告（纯计算，便于测试）
+func computeExposure(positions []PositionInfo, symbols []string, returns map[string]map[int64]float64, equity float64) *ExposureReport {
+	report := &ExposureReport{
+		Interval: exposureInterval,
+		Equity:   equity,
+		Symbols:  symbols,
+	}
+
+	held := make(map[string]string) // symbol -> side
+	btcReturns := returns[exposureBenchmark]
+
+	for _, pos := range positions {
+		notional := pos.Quantity * pos.MarkPrice
+		if pos.Side == "short" {
+			report.ShortNotional += notional
+			notional = -notional
+		} else {
+			report.LongNotional += notional
+		}
+		held[pos.Symbol] = pos.Side
+
+		exposure := PositionExposure{Symbol: pos.Symbol, Side: pos.Side, Notional: notional}
+		if pos.Symbol == exposureBenchmark {
+			exposure.BetaToBTC, exposure.HasBeta = 1, true
+		} else if xs, ys := alignReturns(returns[pos.Symbol], btcReturns); len(xs) >= minOverlapReturns {
+			if beta, ok := regressionBeta(xs, ys); ok {
+				exposure.BetaToBTC, exposure.HasBeta = beta, true
+			}
+		}
+		if exposure.HasBeta {
+			exposure.BTCEquivalent = exposure.BetaToBTC * notional
+		} else {
+			// 无法计算Beta时按1处理，避免低估敞口
+			exposure.BTCEquivalent = notional
+		}
+		report.BetaAdjustedNet += exposure.BTCEquivalent
+		report.Positions = append(report.Positions, exposure)
+	}
+
+	report.NetNotional = report.LongNotional - report.ShortNotional
+	report.GrossNotional = report.LongNotional + report.ShortNotional
+	if equity > 0 {
+		report.NetExposurePct = report.NetNotional / equity * 100
+		report.GrossExposurePct = report.GrossNotional / equity * 100
+	}
+
+	// 相关性矩阵
+	n := len(symbols)
+	report.Correlation = make([][]float64, n)
+	for i := range report.Correlation {
+		report.Correlation[i] = make([]float64, n)
+		report.Correlation[i][i] = 1
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			xs, ys := alignReturns(returns[symbols[i]], returns[symbols[j]])
+			if len(xs) < minOverlapReturns {
+				continue
+			}
+			corr, ok := pearson(xs, ys)
+			if !ok {
+				continue
+			}
+			report.Correlation[i][j] = corr
+			report.Correlation[j][i] = corr
+
+			// 基准币种与其他币种的相关性不作为"同一押注"提示（几乎所有币都与BTC高相关）
+			if symbols[i] == exposureBenchmark && held[exposureBenchmark] == "" {
+				continue
+			}
+			if math.Abs(corr) < highCorrelationThreshold {
+				continue
+			}
+			sideA, heldA := held[symbols[i]]
+			sideB, heldB := held[symbols[j]]
+			if !heldA && !heldB {
+				continue
+			}
+			pair := CorrelatedPair{SymbolA: symbols[i], SymbolB: symbols[j], Correlation: corr}
+			switch {
+			case heldA && heldB:
+				pair.BothHeld = true
+				pair.SameBet = (sideA == sideB) == (corr > 0)
+			case heldA:
+				pair.HeldSymbol, pair.HeldSide, pair.IsCandidate = symbols[i], sideA, true
+			default:
+				pair.HeldSymbol, pair.HeldSide, pair.IsCandidate = symbols[j], sideB, true
+			}
+			report.CorrelatedPairs = append(report.CorrelatedPairs, pair)
+		}
+	}
+
+	// 持仓之间的配对优先，其次按相关性绝对值排序
+	sort.SliceStable(report.CorrelatedPairs, func(a, b int) bool {
+		pa, pb := report.CorrelatedPairs[a], report.CorrelatedPairs[b]
+		if pa.BothHeld != pb.BothHeld {
+			return pa.BothHeld
+		}
+		return math.Abs(pa.Correlation) > math.Abs(pb.Correlation)
+	})
+
+	return report
+}
+
+// klineReturns 计算最近 lookback 根K线的对数收益率（key 为K线开盘时间，用于跨币种对齐）
+func klineReturns(klines []market.Kline, lookback int) map[int64]float64 {
+	if len(klines) > lookback+1 {
+		klines = klines[len(klines)-lookback-1:]
+	}
+	result := make(map[int64]float64, len(klines))
+	for i := 1; i < len(klines); i++ {
+		prev, cur := klines[i-1].Close, klines[i].Close
+		if prev <= 0 || cur <= 0 {
+			continue
+		}
+		result[klines[i].OpenTime] = math.Log(cur / prev)
+	}
+	return result
+}
+
+// alignReturns 按时间对齐两个收益率序列
+func alignReturns(a, b map[int64]float64) ([]float64, []float64) {
+	if len(a) == 0 || len(b) == 0 {
+		return nil, nil
+	}
+	keys := make([]int64, 0, len(a))
+	for t := range a {
+		if _, ok := b[t]; ok {
+			keys = append(keys, t)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	xs := make([]float64, len(keys))
+	ys := make([]float64, len(keys))
+	for i, t := range keys {
+		xs[i], ys[i] = a[t], b[t]
+	}
+	return xs, ys
+}
+
+// covariance 计算样本协方差和两个序列的方差
+func covariance(xs, ys []float64) (cov, varX, varY float64) {
+	n := float64(len(xs))
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	return cov / (n - 1), varX / (n - 1), varY / (n - 1)
+}
+
+// pearson 皮尔逊相关系数
+func pearson(xs, ys []float64) (float64, bool) {
+	if len(xs) < 2 || len(xs) != len(ys) {
+		return 0, false
+	}
+	cov, varX, varY := covariance(xs, ys)
+	if varX == 0 || varY == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(varX*varY), true
+}
+
+// regressionBeta 资产收益率 xs 对基准收益率 ys 的Beta
+func regressionBeta(xs, ys []float64) (float64, bool) {
+	if len(xs) < 2 || len(xs) != len(ys) {
+		return 0, false
+	}
+	cov, _, varY := covariance(xs, ys)
+	if varY == 0 {
+		return 0, false
+	}
+	return cov / varY, true
+}
+
+// formatExposure 生成提示词中的组合敞口部分
+func formatExposure(report *ExposureReport, lang string) string {
+	if report == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	if len(report.Positions) > 0 {
+		sb.WriteString(promptText(lang, "exposure_header"))
+		sb.WriteString(fmt.Sprintf(promptText(lang, "exposure_summary"),
+			report.LongNotional, report.ShortNotional, report.NetNotional, report.NetExposurePct,
+			report.GrossExposurePct, report.BetaAdjustedNet))
+		for _, pos := range report.Positions {
+			beta := "β=N/A"
+			if pos.HasBeta {
+				beta = fmt.Sprintf("β=%.2f", pos.BetaToBTC)
+			}
+			sb.WriteString(fmt.Sprintf(promptText(lang, "exposure_position"),
+				pos.Symbol, strings.ToUpper(pos.Side), pos.Notional, beta, pos.BTCEquivalent))
+		}
+	}
+
+	if len(report.CorrelatedPairs) > 0 {
+		if sb.Len() == 0 {
+			sb.WriteString(promptText(lang, "correlation_header"))
+		}
+		sb.WriteString(fmt.Sprintf(promptText(lang, "correlated_pairs"), report.Interval, highCorrelationThreshold))
+		for i, pair := range report.CorrelatedPairs {
+			if i >= maxCorrelatedPairsInPrompt {
+				break
+			}
+			switch {
+			case pair.BothHeld && pair.SameBet:
+				sb.WriteString(fmt.Sprintf(promptText(lang, "pair_same_bet"), pair.SymbolA, pair.SymbolB, pair.Correlation))
+			case pair.BothHeld:
+				sb.WriteString(fmt.Sprintf(promptText(lang, "pair_hedged"), pair.SymbolA, pair.SymbolB, pair.Correlation))
+			default:
+				candidate := pair.SymbolA
+				if candidate == pair.HeldSymbol {
+					candidate = pair.SymbolB
+				}
+				sb.WriteString(fmt.Sprintf(promptText(lang, "pair_candidate"),
+					candidate, pair.HeldSymbol, strings.ToUpper(pair.HeldSide), pair.Correlation))
+			}
+		}
+	}
+
+	if sb.Len() > 0 {
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
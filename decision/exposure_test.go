@@ -0,0 +1,168 @@
+package decision
+
+import (
+	"fmt"
+	"math"
+	"nofx/market"
+	"strings"
+	"testing"
+)
+
+// makeKlines 根据对数收益率序列生成4h K线（开盘时间对齐）
+func makeKlines(returns []float64) []market.Kline {
+	const step = int64(4 * 60 * 60 * 1000)
+	price := 100.0
+	klines := []market.Kline{{OpenTime: 0, Close: price}}
+	for i, r := range returns {
+		price *= math.Exp(r)
+		klines = append(klines, market.Kline{OpenTime: int64(i+1) * step, Close: price})
+	}
+	return klines
+}
+
+// stubExposureKlines 替换K线数据源，测试结束后恢复
+func stubExposureKlines(t *testing.T, data map[string][]market.Kline) {
+	original := exposureKlineFetcher
+	exposureKlineFetcher = func(_ market.MarketDataProvider, symbol string) ([]market.Kline, error) {
+		klines, ok := data[symbol]
+		if !ok {
+			return nil, fmt.Errorf("no klines for %s", symbol)
+		}
+		return klines, nil
+	}
+	t.Cleanup(func() { exposureKlineFetcher = original })
+}
+
+// TestPearsonAndBeta 测试相关系数与Beta的计算结果
+func TestPearsonAndBeta(t *testing.T) {
+	xs := []float64{1, 2, 3, 4, 5}
+	ys := []float64{2, 4, 5, 4, 5}
+
+	corr, ok := pearson(xs, ys)
+	if !ok || math.Abs(corr-6/math.Sqrt(60)) > 1e-12 {
+		t.Errorf("pearson = %v, %v; want %v", corr, ok, 6/math.Sqrt(60))
+	}
+
+	beta, ok := regressionBeta(ys, xs)
+	if !ok || math.Abs(beta-0.6) > 1e-12 {
+		t.Errorf("beta = %v, %v; want 0.6", beta, ok)
+	}
+
+	if _, ok := pearson([]float64{1, 1, 1}, []float64{1, 2, 3}); ok {
+		t.Error("零方差序列不应计算出相关系数")
+	}
+}
+
+// TestBuildExposureReport 测试组合敞口、Beta和高相关币对识别
+func TestBuildExposureReport(t *testing.T) {
+	btc := make([]float64, 40)
+	noise := make([]float64, 40)
+	for i := range btc {
+		btc[i] = 0.01 * math.Sin(float64(i)*0.7)
+		noise[i] = 0.01 * math.Cos(float64(i)*2.3+1)
+	}
+	scale := func(rs []float64, k float64) []float64 {
+		out := make([]float64, len(rs))
+		for i, r := range rs {
+			out[i] = r * k
+		}
+		return out
+	}
+
+	stubExposureKlines(t, map[string][]market.Kline{
+		"BTCUSDT":  makeKlines(btc),
+		"ETHUSDT":  makeKlines(scale(btc, 2)),   // Beta=2，与BTC完全正相关
+		"SOLUSDT":  makeKlines(scale(btc, 1.5)), // 与ETH完全正相关
+		"DOGEUSDT": makeKlines(noise),
+		"XRPUSDT":  makeKlines(btc[:10]), // 样本不足
+	})
+
+	positions := []PositionInfo{
+		{Symbol: "ETHUSDT", Side: "long", MarkPrice: 2000, Quantity: 1},
+		{Symbol: "SOLUSDT", Side: "long", MarkPrice: 100, Quantity: 10},
+		{Symbol: "XRPUSDT", Side: "short", MarkPrice: 1, Quantity: 500},
+	}
+	report := BuildExposureReport(nil, positions, []string{"DOGEUSDT"}, 1000)
+
+	if report.LongNotional != 3000 || report.ShortNotional != 500 {
+		t.Errorf("long/short = %v/%v; want 3000/500", report.LongNotional, report.ShortNotional)
+	}
+	if report.NetNotional != 2500 || report.GrossNotional != 3500 {
+		t.Errorf("net/gross = %v/%v; want 2500/3500", report.NetNotional, report.GrossNotional)
+	}
+	if math.Abs(report.NetExposurePct-250) > 1e-9 || math.Abs(report.GrossExposurePct-350) > 1e-9 {
+		t.Errorf("net/gross pct = %v/%v; want 250/350", report.NetExposurePct, report.GrossExposurePct)
+	}
+
+	eth := report.Positions[0]
+	if !eth.HasBeta || math.Abs(eth.BetaToBTC-2) > 1e-6 {
+		t.Errorf("ETH beta = %v (%v); want 2", eth.BetaToBTC, eth.HasBeta)
+	}
+	xrp := report.Positions[2]
+	if xrp.HasBeta || xrp.BTCEquivalent != -500 {
+		t.Errorf("XRP 样本不足时应按Beta=1处理，got %+v", xrp)
+	}
+	// 2×2000 + 1.5×1000 - 500
+	if math.Abs(report.BetaAdjustedNet-5000) > 1e-3 {
+		t.Errorf("beta adjusted net = %v; want 5000", report.BetaAdjustedNet)
+	}
+
+	if got := strings.Join(report.Symbols, ","); got != "BTCUSDT,ETHUSDT,SOLUSDT,XRPUSDT,DOGEUSDT" {
+		t.Errorf("symbols = %s", got)
+	}
+	if len(report.CorrelatedPairs) == 0 {
+		t.Fatal("应识别出高相关币对")
+	}
+	first := report.CorrelatedPairs[0]
+	if first.SymbolA != "ETHUSDT" || first.SymbolB != "SOLUSDT" || !first.BothHeld || !first.SameBet {
+		t.Errorf("first pair = %+v; want ETH/SOL 同向持仓", first)
+	}
+	for _, pair := range report.CorrelatedPairs {
+		if pair.SymbolA == "BTCUSDT" {
+			t.Errorf("未持有BTC时不应提示BTC相关币对: %+v", pair)
+		}
+		if pair.SymbolA == "XRPUSDT" || pair.SymbolB == "XRPUSDT" {
+			t.Errorf("样本不足的币种不应参与相关性: %+v", pair)
+		}
+	}
+
+	section := formatExposure(report, LanguageZH)
+	for _, want := range []string{"组合敞口", "β=2.00", "β=N/A", "ETHUSDT / SOLUSDT"} {
+		if !strings.Contains(section, want) {
+			t.Errorf("提示词缺少 %q:\n%s", want, section)
+		}
+	}
+}
+
+// TestExposureCandidatePairs 测试候选币种与持仓高相关时的提示
+func TestExposureCandidatePairs(t *testing.T) {
+	btc := make([]float64, 30)
+	for i := range btc {
+		btc[i] = 0.02 * math.Sin(float64(i))
+	}
+	inverse := make([]float64, len(btc))
+	for i, r := range btc {
+		inverse[i] = -r
+	}
+	stubExposureKlines(t, map[string][]market.Kline{
+		"BTCUSDT": makeKlines(btc),
+		"ETHUSDT": makeKlines(btc),
+		"BNBUSDT": makeKlines(inverse),
+	})
+
+	report := BuildExposureReport(nil, []PositionInfo{{Symbol: "ETHUSDT", Side: "short", MarkPrice: 2000, Quantity: 1}}, []string{"BNBUSDT"}, 1000)
+	if len(report.CorrelatedPairs) != 1 {
+		t.Fatalf("pairs = %+v; want 1", report.CorrelatedPairs)
+	}
+	pair := report.CorrelatedPairs[0]
+	if !pair.IsCandidate || pair.HeldSymbol != "ETHUSDT" || pair.HeldSide != "short" || pair.Correlation > -0.99 {
+		t.Errorf("pair = %+v", pair)
+	}
+	if section := formatExposure(report, LanguageZH); !strings.Contains(section, "BNBUSDT ~ 持仓ETHUSDT(SHORT)") {
+		t.Errorf("提示词缺少候选币种相关性:\n%s", section)
+	}
+
+	if formatExposure(&ExposureReport{}, LanguageZH) != "" {
+		t.Error("无持仓且无高相关币对时不应输出")
+	}
+}
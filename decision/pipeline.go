@@ -0,0 +1,390 @@
+package decision
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/market"
+	"nofx/mcp"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// 流水线阶段名称
+const (
+	StageScreener = "screener" // 初筛：便宜的模型根据简要行情挑出候选币种
+	StageAnalyst  = "analyst"  // 分析：主模型基于完整市场数据给出决策
+	StageReviewer = "reviewer" // 复核：风控模型逐条检查开仓，可否决
+)
+
+// DefaultShortlistSize 初筛默认保留的候选币种数量
+const DefaultShortlistSize = 8
+
+// PipelineConfig 多阶段决策流水线配置（交易员配置中以JSON保存，未启用时单次调用AI）
+type PipelineConfig struct {
+	Enabled         bool   `json:"enabled"`
+	ScreenerModelID string `json:"screener_model_id,omitempty"` // 初筛使用的AI模型配置ID（空=沿用主模型）
+	ReviewerModelID string `json:"reviewer_model_id,omitempty"` // 复核使用的AI模型配置ID（空=沿用主模型）
+	ShortlistSize   int    `json:"shortlist_size,omitempty"`    // 初筛保留的候选数量（0=默认8）
+}
+
+// ParsePipelineConfig 解析流水线配置（空串表示未启用）
+func ParsePipelineConfig(raw string) (*PipelineConfig, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var cfg PipelineConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return nil, fmt.Errorf("解析决策流水线配置失败: %w", err)
+	}
+	if err := ValidatePipelineConfig(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// ValidatePipelineConfig 校验流水线配置
+func ValidatePipelineConfig(cfg *PipelineConfig) error {
+	if cfg.ShortlistSize < 0 || cfg.ShortlistSize > maxCandidateFetch {
+		return fmt.Errorf("初筛保留数量必须在 0-%d 之间", maxCandidateFetch)
+	}
+	return nil
+}
+
+// Pipeline 多阶段决策流水线：初筛 → 分析 → 风控复核
+type Pipeline struct {
+	Screener      *mcp.Client // 初筛模型（可使用便宜的模型）
+	Analyst       *mcp.Client // 分析模型（交易员的主模型）
+	Reviewer      *mcp.Client // 风控复核模型
+	ShortlistSize int
+}
+
+// StageTrace 流水线单个阶段的提示词、输出和耗时（写入决策日志）
+type StageTrace struct {
+	Stage        string `json:"stage"`
+	Model        string `json:"model"`
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	UserPrompt   string `json:"user_prompt,omitempty"`
+	Output       string `json:"output,omitempty"`
+	DurationMs   int64  `json:"duration_ms"`
+	Error        string `json:"error,omitempty"`
+}
+
+// GetPipelineDecision 通过多阶段流水线获取决策
+//
+// 初筛失败时按来源强度保留候选继续分析；复核失败时否决所有开仓（平仓等决策不受影响）
+func GetPipelineDecision(ctx *Context, p *Pipeline, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
+	if err := prepareMarketData(ctx, templateName); err != nil {
+		return nil, err
+	}
+
+	var stages []StageTrace
+	analysisCtx := ctx
+	if shortlisted, stage := screenCandidates(ctx, p.Screener, p.shortlistSize()); stage != nil {
+		stages = append(stages, *stage)
+		analysisCtx = shortlisted
+	}
+
+	start := time.Now()
+	fullDecision, err := analyzeWithAI(analysisCtx, p.Analyst, customPrompt, overrideBase, templateName)
+	analyst := StageTrace{Stage: StageAnalyst, Model: p.Analyst.Model, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		analyst.Error = err.Error()
+	}
+	if fullDecision != nil {
+		// 提示词见 FullDecision.SystemPrompt / UserPrompt，这里只记录决策结果
+		if data, marshalErr := json.Marshal(fullDecision.Decisions); marshalErr == nil {
+			analyst.Output = string(data)
+		}
+	}
+	stages = append(stages, analyst)
+	if err != nil {
+		if fullDecision != nil {
+			fullDecision.Stages = stages
+		}
+		return fullDecision, err
+	}
+
+	if stage := reviewOpens(ctx, p.Reviewer, fullDecision, templateName); stage != nil {
+		stages = append(stages, *stage)
+	}
+	fullDecision.Stages = stages
+	return fullDecision, nil
+}
+
+func (p *Pipeline) shortlistSize() int {
+	if p.ShortlistSize > 0 {
+		return p.ShortlistSize
+	}
+	return DefaultShortlistSize
+}
+
+// screenCandidates 用简要行情让初筛模型挑选候选币种，返回只保留入选候选的上下文副本
+// 候选数量不超过保留数量时跳过初筛（返回 nil stage）
+func screenCandidates(ctx *Context, client *mcp.Client, size int) (*Context, *StageTrace) {
+	positionSymbols := make(map[string]bool, len(ctx.Positions))
+	for _, pos := range ctx.Positions {
+		positionSymbols[pos.Symbol] = true
+	}
+	var candidates []CandidateCoin
+	for _, coin := range ctx.CandidateCoins {
+		if _, ok := ctx.MarketDataMap[coin.Symbol]; ok && !positionSymbols[coin.Symbol] {
+			candidates = append(candidates, coin)
+		}
+	}
+	if len(candidates) <= size {
+		return ctx, nil
+	}
+
+	lang := resolveUserPromptLayout(ctx.UserPromptLayout).Language
+	stage := &StageTrace{
+		Stage:        StageScreener,
+		Model:        client.Model,
+		SystemPrompt: fmt.Sprintf(promptText(lang, "screener_system"), size),
+		UserPrompt:   buildScreenerPrompt(ctx, candidates, lang),
+	}
+	start := time.Now()
+	output, err := client.CallWithMessages(stage.SystemPrompt, stage.UserPrompt)
+	stage.DurationMs = time.Since(start).Milliseconds()
+	stage.Output = output
+
+	var picked []string
+	if err == nil {
+		picked, err = extractSymbolList(output)
+	}
+	shortlist := shortlistCandidates(candidates, picked, size)
+	if err != nil {
+		stage.Error = fmt.Sprintf("%v（按来源强度保留前%d个候选）", err, size)
+		log.Printf("⚠️  初筛失败，按来源强度保留前%d个候选: %v", size, err)
+	}
+
+	// 未入选的候选从分析阶段的上下文中移除（持仓和 BTC 行情保留）
+	keep := make(map[string]bool, len(shortlist))
+	for _, coin := range shortlist {
+		keep[coin.Symbol] = true
+	}
+	screened := *ctx
+	screened.CandidateCoins = shortlist
+	screened.MarketDataMap = make(map[string]*market.Data, len(ctx.MarketDataMap))
+	for symbol, data := range ctx.MarketDataMap {
+		if keep[symbol] || positionSymbols[symbol] || symbol == "BTCUSDT" {
+			screened.MarketDataMap[symbol] = data
+		}
+	}
+	log.Printf("🔎 初筛: %d 个候选 → %d 个 (%s)", len(candidates), len(shortlist), strings.Join(symbolsOf(shortlist), ", "))
+	return &screened, stage
+}
+
+// shortlistCandidates 按初筛结果保留候选（保持来源强度顺序），结果不足时用强度最高的候选补足
+func shortlistCandidates(candidates []CandidateCoin, picked []string, size int) []CandidateCoin {
+	selected := make(map[string]bool, len(picked))
+	for _, symbol := range picked {
+		selected[strings.ToUpper(strings.TrimSpace(symbol))] = true
+	}
+
+	var shortlist []CandidateCoin
+	for _, coin := range candidates {
+		if selected[coin.Symbol] && len(shortlist) < size {
+			shortlist = append(shortlist, coin)
+		}
+	}
+	if len(shortlist) == 0 {
+		for _, coin := range candidates {
+			if len(shortlist) >= size {
+				break
+			}
+			shortlist = append(shortlist, coin)
+		}
+	}
+	return shortlist
+}
+
+// buildScreenerPrompt 候选币种的单行摘要（不含K线序列）
+func buildScreenerPrompt(ctx *Context, candidates []CandidateCoin, lang string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(promptText(lang, "screener_account"), ctx.Account.TotalEquity, len(ctx.Positions)))
+	sb.WriteString(fmt.Sprintf(promptText(lang, "screener_candidates_header"), len(candidates)))
+	for _, coin := range candidates {
+		data := ctx.MarketDataMap[coin.Symbol]
+		oi := ""
+		if data.OpenInterest != nil && data.CurrentPrice > 0 {
+			oi = fmt.Sprintf(" | OI %.1fM", data.OpenInterest.Latest*data.CurrentPrice/1_000_000)
+		}
+		sb.WriteString(fmt.Sprintf(promptText(lang, "screener_candidate"),
+			coin.Symbol, strings.Join(coin.Sources, "+"), data.CurrentPrice, data.PriceChange1h, data.PriceChange4h,
+			data.CurrentRSI7, data.CurrentMACD, data.FundingRate, oi))
+	}
+	return sb.String()
+}
+
+// isOpeningAction 会新增风险敞口的决策（开仓、加仓、反手），需要风控复核
+func isOpeningAction(action string) bool {
+	switch action {
+	case "open_long", "open_short", "add_to_position", "reverse":
+		return true
+	}
+	return false
+}
+
+// reviewOpens 让复核模型逐条检查开仓类决策（含加仓、反手），被否决的改为 wait（无开仓时跳过复核）
+func reviewOpens(ctx *Context, client *mcp.Client, fullDecision *FullDecision, templateName string) *StageTrace {
+	var opens []Decision
+	for _, d := range fullDecision.Decisions {
+		if isOpeningAction(d.Action) {
+			opens = append(opens, d)
+		}
+	}
+	if len(opens) == 0 {
+		return nil
+	}
+
+	lang := resolveUserPromptLayout(ctx.UserPromptLayout).Language
+	stage := &StageTrace{
+		Stage:        StageReviewer,
+		Model:        client.Model,
+		SystemPrompt: promptText(lang, "reviewer_system") + renderRiskRules(newPromptData(ctx), templateName),
+		UserPrompt:   buildReviewerPrompt(ctx, opens, lang),
+	}
+	start := time.Now()
+	output, err := client.CallWithMessages(stage.SystemPrompt, stage.UserPrompt)
+	stage.DurationMs = time.Since(start).Milliseconds()
+	stage.Output = output
+
+	var verdicts []reviewVerdict
+	if err == nil {
+		verdicts, err = extractVerdicts(output)
+	}
+	if err != nil {
+		stage.Error = fmt.Sprintf("%v（否决全部开仓）", err)
+		log.Printf("⚠️  风控复核失败，否决全部开仓: %v", err)
+	}
+
+	for i, d := range fullDecision.Decisions {
+		if !isOpeningAction(d.Action) {
+			continue
+		}
+		reason := "风控复核失败"
+		if err == nil {
+			verdict, ok := findVerdict(verdicts, d.Symbol, d.Action)
+			if ok && verdict.Approve {
+				continue
+			}
+			reason = "未给出复核结论"
+			if ok {
+				reason = verdict.Reason
+			}
+		}
+		log.Printf("🛑 风控复核否决 %s %s: %s", d.Symbol, d.Action, reason)
+		fullDecision.Decisions[i] = Decision{
+			Symbol:    d.Symbol,
+			Action:    "wait",
+			Reasoning: fmt.Sprintf("风控复核否决 %s: %s（原理由: %s）", d.Action, reason, d.Reasoning),
+		}
+	}
+	return stage
+}
+
+// buildReviewerPrompt 账户、持仓和待复核的开仓（含当前价和ATR）
+func buildReviewerPrompt(ctx *Context, opens []Decision, lang string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(promptText(lang, "reviewer_account"),
+		ctx.Account.TotalEquity, ctx.Account.AvailableBalance, ctx.Account.MarginUsedPct, len(ctx.Positions)))
+	if len(ctx.Positions) > 0 {
+		sb.WriteString(promptText(lang, "positions_header"))
+		for _, pos := range ctx.Positions {
+			sb.WriteString(fmt.Sprintf(promptText(lang, "reviewer_position"),
+				pos.Symbol, strings.ToUpper(pos.Side), pos.Leverage, pos.MarginUsed, pos.UnrealizedPnLPct))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(promptText(lang, "reviewer_opens_header"))
+	for _, d := range opens {
+		data, _ := json.Marshal(d)
+		sb.WriteString(string(data))
+		if md, ok := ctx.MarketDataMap[d.Symbol]; ok {
+			sb.WriteString(fmt.Sprintf(promptText(lang, "reviewer_price"), md.CurrentPrice))
+			if md.IntradaySeries != nil {
+				sb.WriteString(fmt.Sprintf(" | ATR14 %.6g", md.IntradaySeries.ATR14))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// renderRiskRules 渲染交易员模板使用的风控片段（复核依据与分析阶段一致）
+func renderRiskRules(data *PromptData, templateName string) string {
+	if templateName == "" {
+		templateName = "default"
+	}
+	var buf bytes.Buffer
+	if template, err := GetPromptTemplate(templateName); err == nil && template.tmpl != nil {
+		if err := template.tmpl.ExecuteTemplate(&buf, PartialRiskRules, data); err == nil {
+			return buf.String()
+		}
+		buf.Reset()
+	}
+	tmpl, err := template.New(PartialRiskRules).Funcs(promptFuncs).Option("missingkey=zero").Parse(builtinPartials[PartialRiskRules])
+	if err == nil && tmpl.Execute(&buf, data) == nil {
+		return buf.String()
+	}
+	return ""
+}
+
+// reviewVerdict 复核模型对单个开仓的结论
+type reviewVerdict struct {
+	Symbol  string `json:"symbol"`
+	Action  string `json:"action"`
+	Approve bool   `json:"approve"`
+	Reason  string `json:"reason"`
+}
+
+func findVerdict(verdicts []reviewVerdict, symbol, action string) (reviewVerdict, bool) {
+	for _, v := range verdicts {
+		if strings.EqualFold(v.Symbol, symbol) && (v.Action == "" || v.Action == action) {
+			return v, true
+		}
+	}
+	return reviewVerdict{}, false
+}
+
+// reJSONStringArray JSON字符串数组（初筛输出）
+var reJSONStringArray = regexp.MustCompile(`\[\s*(?:"[^"]*"\s*,?\s*)*\]`)
+
+// extractSymbolList 从初筛输出中提取币种列表
+func extractSymbolList(output string) ([]string, error) {
+	match := reJSONStringArray.FindString(removeInvisibleRunes(output))
+	if match == "" {
+		return nil, fmt.Errorf("初筛输出中没有找到币种列表")
+	}
+	var symbols []string
+	if err := json.Unmarshal([]byte(match), &symbols); err != nil {
+		return nil, fmt.Errorf("解析初筛输出失败: %w", err)
+	}
+	return symbols, nil
+}
+
+// extractVerdicts 从复核输出中提取结论列表
+func extractVerdicts(output string) ([]reviewVerdict, error) {
+	match := reJSONArray.FindString(removeInvisibleRunes(output))
+	if match == "" {
+		return nil, fmt.Errorf("复核输出中没有找到结论列表")
+	}
+	var verdicts []reviewVerdict
+	if err := json.Unmarshal([]byte(match), &verdicts); err != nil {
+		return nil, fmt.Errorf("解析复核输出失败: %w", err)
+	}
+	return verdicts, nil
+}
+
+func symbolsOf(coins []CandidateCoin) []string {
+	symbols := make([]string, len(coins))
+	for i, coin := range coins {
+		symbols[i] = coin.Symbol
+	}
+	return symbols
+}
@@ -0,0 +1,186 @@
+package decision
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nofx/market"
+	"nofx/mcp"
+	"strings"
+	"testing"
+)
+
+// fakeAIClient 返回固定回复的AI客户端
+func fakeAIClient(t *testing.T, reply string) *mcp.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": reply}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client := mcp.New()
+	client.SetCustomAPI(server.URL, "test-key", "fake-model")
+	return client
+}
+
+func TestShortlistCandidates(t *testing.T) {
+	candidates := []CandidateCoin{
+		{Symbol: "AUSDT", Sources: []string{"ai500", "oi_top"}},
+		{Symbol: "BUSDT", Sources: []string{"ai500"}},
+		{Symbol: "CUSDT", Sources: []string{"ai500"}},
+		{Symbol: "DUSDT", Sources: []string{"oi_top"}},
+	}
+	tests := []struct {
+		name   string
+		picked []string
+		size   int
+		want   string
+	}{
+		{name: "保持来源强度顺序", picked: []string{"dusdt", "BUSDT"}, size: 3, want: "BUSDT,DUSDT"},
+		{name: "超出保留数量时截断", picked: []string{"DUSDT", "CUSDT", "BUSDT"}, size: 2, want: "BUSDT,CUSDT"},
+		{name: "忽略未知币种", picked: []string{"XUSDT", "CUSDT"}, size: 3, want: "CUSDT"},
+		{name: "无有效结果时保留最强候选", picked: []string{"XUSDT"}, size: 2, want: "AUSDT,BUSDT"},
+		{name: "初筛失败", picked: nil, size: 3, want: "AUSDT,BUSDT,CUSDT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(symbolsOf(shortlistCandidates(candidates, tt.picked, tt.size)), ",")
+			if got != tt.want {
+				t.Errorf("shortlistCandidates() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractSymbolList(t *testing.T) {
+	symbols, err := extractSymbolList("入选币种如下：\n```json\n[\"SOLUSDT\", \"ETHUSDT\"]\n```")
+	if err != nil {
+		t.Fatalf("extractSymbolList() error = %v", err)
+	}
+	if strings.Join(symbols, ",") != "SOLUSDT,ETHUSDT" {
+		t.Errorf("extractSymbolList() = %v", symbols)
+	}
+	if _, err := extractSymbolList("没有合适的币种"); err == nil {
+		t.Error("没有币种列表时应返回错误")
+	}
+}
+
+func TestScreenCandidates(t *testing.T) {
+	ctx := &Context{
+		Account:       AccountInfo{TotalEquity: 1000},
+		Positions:     []PositionInfo{{Symbol: "ETHUSDT", Side: "long"}},
+		MarketDataMap: map[string]*market.Data{},
+	}
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "XRPUSDT", "DOGEUSDT"} {
+		ctx.MarketDataMap[symbol] = &market.Data{Symbol: symbol, CurrentPrice: 1}
+		ctx.CandidateCoins = append(ctx.CandidateCoins, CandidateCoin{Symbol: symbol, Sources: []string{"ai500"}})
+	}
+
+	screened, stage := screenCandidates(ctx, fakeAIClient(t, `["DOGEUSDT"]`), 2)
+	if stage == nil || stage.Stage != StageScreener || stage.Error != "" {
+		t.Fatalf("初筛阶段记录异常: %+v", stage)
+	}
+	if got := strings.Join(symbolsOf(screened.CandidateCoins), ","); got != "DOGEUSDT" {
+		t.Errorf("入选候选 = %s, want DOGEUSDT", got)
+	}
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT", "DOGEUSDT"} {
+		if _, ok := screened.MarketDataMap[symbol]; !ok {
+			t.Errorf("%s 的行情不应被移除", symbol)
+		}
+	}
+	for _, symbol := range []string{"SOLUSDT", "XRPUSDT"} {
+		if _, ok := screened.MarketDataMap[symbol]; ok {
+			t.Errorf("未入选的 %s 应从分析上下文中移除", symbol)
+		}
+	}
+	if len(ctx.MarketDataMap) != 5 || len(ctx.CandidateCoins) != 5 {
+		t.Error("初筛不应修改原上下文")
+	}
+	if !strings.Contains(stage.SystemPrompt, "初筛分析师") || !strings.Contains(stage.UserPrompt, "## 候选币种 (4个)") {
+		t.Errorf("默认布局的初筛提示词应为中文: %s\n%s", stage.SystemPrompt, stage.UserPrompt)
+	}
+
+	// 初筛提示词使用布局的语言
+	enCtx := *ctx
+	enCtx.UserPromptLayout = "default_en"
+	_, stage = screenCandidates(&enCtx, fakeAIClient(t, `["DOGEUSDT"]`), 2)
+	if !strings.Contains(stage.SystemPrompt, "at most 2") || !strings.Contains(stage.UserPrompt, "## Candidate coins (4)") {
+		t.Errorf("英文布局的初筛提示词应为英文: %s\n%s", stage.SystemPrompt, stage.UserPrompt)
+	}
+
+	// 候选数量不超过保留数量时跳过初筛
+	if _, stage := screenCandidates(ctx, fakeAIClient(t, `[]`), 4); stage != nil {
+		t.Error("候选数量不超过保留数量时不应调用初筛模型")
+	}
+}
+
+func TestReviewOpens(t *testing.T) {
+	ctx := &Context{
+		Account:       AccountInfo{TotalEquity: 1000, AvailableBalance: 800},
+		MarketDataMap: map[string]*market.Data{},
+	}
+	newDecision := func() *FullDecision {
+		return &FullDecision{Decisions: []Decision{
+			{Symbol: "ETHUSDT", Action: "open_long", Reasoning: "突破"},
+			{Symbol: "SOLUSDT", Action: "open_short", Reasoning: "破位"},
+			{Symbol: "BTCUSDT", Action: "close_long", Reasoning: "止盈"},
+			{Symbol: "DOGEUSDT", Action: "open_long", Reasoning: "放量"},
+		}}
+	}
+
+	t.Run("否决未通过和未复核的开仓", func(t *testing.T) {
+		reply := `[{"symbol": "ETHUSDT", "action": "open_long", "approve": false, "reason": "风险回报比不足"},
+			{"symbol": "SOLUSDT", "action": "open_short", "approve": true, "reason": "符合规则"}]`
+		fullDecision := newDecision()
+		stage := reviewOpens(ctx, fakeAIClient(t, reply), fullDecision, "")
+		if stage == nil || stage.Stage != StageReviewer || stage.Error != "" {
+			t.Fatalf("复核阶段记录异常: %+v", stage)
+		}
+		if !strings.Contains(stage.SystemPrompt, "硬约束") {
+			t.Error("复核提示词应包含风控规则")
+		}
+
+		want := []string{"wait", "open_short", "close_long", "wait"}
+		for i, d := range fullDecision.Decisions {
+			if d.Action != want[i] {
+				t.Errorf("%s action = %s, want %s", d.Symbol, d.Action, want[i])
+			}
+		}
+		if !strings.Contains(fullDecision.Decisions[0].Reasoning, "风险回报比不足") {
+			t.Errorf("否决理由应写入决策: %s", fullDecision.Decisions[0].Reasoning)
+		}
+	})
+
+	t.Run("复核提示词使用布局的语言", func(t *testing.T) {
+		enCtx := *ctx
+		enCtx.UserPromptLayout = "default_en"
+		reply := `[{"symbol": "ETHUSDT", "approve": true}, {"symbol": "SOLUSDT", "approve": true}, {"symbol": "DOGEUSDT", "approve": true}]`
+		stage := reviewOpens(&enCtx, fakeAIClient(t, reply), newDecision(), "")
+		if !strings.HasPrefix(stage.SystemPrompt, "You are the risk reviewer") || !strings.Contains(stage.UserPrompt, "## Opens under review") {
+			t.Errorf("英文布局的复核提示词应为英文: %s\n%s", stage.SystemPrompt, stage.UserPrompt)
+		}
+	})
+
+	t.Run("复核失败时否决全部开仓", func(t *testing.T) {
+		fullDecision := newDecision()
+		stage := reviewOpens(ctx, mcp.New(), fullDecision, "") // 未设置API密钥，调用失败
+		if stage == nil || stage.Error == "" {
+			t.Fatalf("复核失败应记录错误: %+v", stage)
+		}
+		want := []string{"wait", "wait", "close_long", "wait"}
+		for i, d := range fullDecision.Decisions {
+			if d.Action != want[i] {
+				t.Errorf("%s action = %s, want %s", d.Symbol, d.Action, want[i])
+			}
+		}
+	})
+
+	t.Run("没有开仓时跳过复核", func(t *testing.T) {
+		fullDecision := &FullDecision{Decisions: []Decision{{Symbol: "BTCUSDT", Action: "hold"}}}
+		if stage := reviewOpens(ctx, mcp.New(), fullDecision, ""); stage != nil {
+			t.Error("没有开仓时不应调用复核模型")
+		}
+	})
+}
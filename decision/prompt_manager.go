@@ -1,20 +1,117 @@
 package decision
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"nofx/config"
+	"nofx/market"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"text/template"
 )
 
 // PromptTemplate 系统提示词模板
 type PromptTemplate struct {
-	Name    string // 模板名称（文件名，不含扩展名）
-	Content string // 模板内容
+	Name       string                 // 模板名称（文件名，不含扩展名）
+	Content    string                 // 模板内容（不含头部指令行）
+	Indicators []market.IndicatorSpec // 需要写入 User Prompt 的技术指标
+	Source     string                 // 模板文件原始内容（含头部指令行，用于版本记录和回滚）
+	Hash       string                 // 原始内容与其解析到的共享片段的 SHA-256，决策记录中用于定位模板版本
+
+	tmpl *template.Template // 加载时解析并校验过的模板（含共享片段）
+}
+
+// Render 用模板变量渲染系统提示词（未经加载校验的模板在此解析）
+func (t *PromptTemplate) Render(data *PromptData) (string, error) {
+	tmpl := t.tmpl
+	if tmpl == nil {
+		compiled, err := compilePromptTemplate(t.Name, t.Content, builtinPartials)
+		if err != nil {
+			return "", err
+		}
+		tmpl = compiled
+	}
+	return renderPromptTemplate(tmpl, t.Content, data)
+}
+
+// indicatorsDirective 模板头部的指标声明指令，如：
+//
+//	@indicators: bb(20,2), adx(14)@4h, supertrend
+//
+// 指令行必须位于文件开头，加载时从模板内容中移除
+const indicatorsDirective = "@indicators:"
+
+// promptTemplateHash 模板版本哈希：模板原始内容加上渲染时会用到的共享片段（被引用的、自动追加的及其间接引用的），
+// 修改片段也会产生新的模板版本
+func promptTemplateHash(source, content string, partials map[string]string) string {
+	used := make(map[string]bool)
+	var visit func(text string)
+	visit = func(text string) {
+		for _, name := range sortedKeys(partials) {
+			if !used[name] && referencesPartial(text, name) {
+				used[name] = true
+				visit(partials[name])
+			}
+		}
+	}
+	for _, name := range autoAppendPartials {
+		if _, ok := partials[name]; ok && !used[name] {
+			used[name] = true
+			visit(partials[name])
+		}
+	}
+	visit(content)
+
+	var sb strings.Builder
+	sb.WriteString(source)
+	for _, name := range sortedKeys(partials) {
+		if used[name] {
+			sb.WriteString("\n\x00partial:" + name + "\n")
+			sb.WriteString(partials[name])
+		}
+	}
+	return config.PromptHash(sb.String())
 }
 
+// parsePromptTemplate 解析模板文件，提取头部指令行，并按解析到的共享片段计算版本哈希
+// 指标声明逐个试创建指标实例，格式错误或参数越界（如 bb(0)）时返回错误
+func parsePromptTemplate(name, content string, partials map[string]string) (*PromptTemplate, error) {
+	tmpl := &PromptTemplate{Name: name, Source: content}
+
+	lines := strings.Split(content, "\n")
+	body := 0
+	for body < len(lines) {
+		line := strings.TrimSpace(lines[body])
+		if !strings.HasPrefix(line, indicatorsDirective) {
+			break
+		}
+		specs, err := market.ParseIndicatorSpecs(strings.TrimPrefix(line, indicatorsDirective))
+		if err != nil {
+			return nil, fmt.Errorf("指标声明无效: %w", err)
+		}
+		for _, spec := range specs {
+			if _, err := market.NewIndicator(spec); err != nil {
+				return nil, fmt.Errorf("指标声明 %s 无效: %w", spec, err)
+			}
+		}
+		tmpl.Indicators = append(tmpl.Indicators, specs...)
+		body++
+	}
+
+	tmpl.Content = content
+	if body > 0 {
+		tmpl.Content = strings.TrimLeft(strings.Join(lines[body:], "\n"), "\n")
+	}
+	tmpl.Hash = promptTemplateHash(content, tmpl.Content, partials)
+	return tmpl, nil
+}
+
+// partialsDir 共享片段子目录（文件名即片段名，如 partials/risk_rules.txt）
+const partialsDir = "partials"
+
 // PromptManager 提示词管理器
 type PromptManager struct {
 	templates map[string]*PromptTemplate
@@ -36,6 +133,9 @@ func init() {
 	} else {
 		log.Printf("✓ 已加载 %d 个系统提示词模板", len(globalPromptManager.templates))
 	}
+	if err := LoadUserPromptLayouts(filepath.Join(promptsDir, userPromptLayoutsDir)); err != nil {
+		log.Printf("⚠️  加载用户提示词布局失败: %v", err)
+	}
 }
 
 // NewPromptManager 创建提示词管理器
@@ -46,27 +146,40 @@ func NewPromptManager() *PromptManager {
 }
 
 // LoadTemplates 从指定目录加载所有提示词模板
+// 每个模板加载时都会解析并试渲染，无效的模板不会被加载（已有同名模板时保留旧版本），并在返回的错误中列出
 func (pm *PromptManager) LoadTemplates(dir string) error {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
+	loaded, err := pm.loadTemplates(dir)
+	for name, template := range loaded {
+		pm.templates[name] = template
+	}
+	return err
+}
+
+// loadTemplates 读取并校验目录中的模板（调用方持有写锁）
+func (pm *PromptManager) loadTemplates(dir string) (map[string]*PromptTemplate, error) {
 	// 检查目录是否存在
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return fmt.Errorf("提示词目录不存在: %s", dir)
+		return nil, fmt.Errorf("提示词目录不存在: %s", dir)
 	}
 
 	// 扫描目录中的所有 .txt 文件
 	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
 	if err != nil {
-		return fmt.Errorf("扫描提示词目录失败: %w", err)
+		return nil, fmt.Errorf("扫描提示词目录失败: %w", err)
 	}
 
 	if len(files) == 0 {
 		log.Printf("⚠️  提示词目录 %s 中没有找到 .txt 文件", dir)
-		return nil
+		return nil, nil
 	}
 
+	partials, invalid := loadPartials(filepath.Join(dir, partialsDir))
+
 	// 加载每个模板文件
+	loaded := make(map[string]*PromptTemplate, len(files))
 	for _, file := range files {
 		// 读取文件内容
 		content, err := os.ReadFile(file)
@@ -79,16 +192,59 @@ func (pm *PromptManager) LoadTemplates(dir string) error {
 		fileName := filepath.Base(file)
 		templateName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
 
-		// 存储模板
-		pm.templates[templateName] = &PromptTemplate{
-			Name:    templateName,
-			Content: string(content),
+		template, err := parsePromptTemplate(templateName, string(content), partials)
+		if err == nil {
+			template.tmpl, err = compilePromptTemplate(templateName, template.Content, partials)
 		}
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", fileName, err))
+			if old, exists := pm.templates[templateName]; exists {
+				log.Printf("❌ 提示词模板 %s 无效，继续使用旧版本: %v", templateName, err)
+				loaded[templateName] = old
+			} else {
+				log.Printf("❌ 提示词模板 %s 无效，已跳过: %v", templateName, err)
+			}
+			continue
+		}
+
+		// 存储模板
+		loaded[templateName] = template
 
 		log.Printf("  📄 加载提示词模板: %s (%s)", templateName, fileName)
 	}
 
-	return nil
+	if len(invalid) > 0 {
+		return loaded, fmt.Errorf("提示词模板校验失败: %s", strings.Join(invalid, "; "))
+	}
+	return loaded, nil
+}
+
+// loadPartials 加载共享片段（同名文件覆盖内置片段，无效的片段回退到内置版本）
+func loadPartials(dir string) (map[string]string, []string) {
+	partials := make(map[string]string, len(builtinPartials))
+	for name, content := range builtinPartials {
+		partials[name] = content
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.txt"))
+	var invalid []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("⚠️  读取提示词片段失败 %s: %v", file, err)
+			continue
+		}
+		fileName := filepath.Base(file)
+		name := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+		if _, err := template.New(name).Funcs(promptFuncs).Parse(string(content)); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s/%s: %v", partialsDir, fileName, err))
+			log.Printf("❌ 提示词片段 %s 无效，已忽略: %v", name, err)
+			continue
+		}
+		partials[name] = string(content)
+		log.Printf("  🧩 加载提示词片段: %s", name)
+	}
+	return partials, invalid
 }
 
 // GetTemplate 获取指定名称的提示词模板
@@ -130,13 +286,17 @@ func (pm *PromptManager) GetAllTemplates() []*PromptTemplate {
 	return templates
 }
 
-// ReloadTemplates 重新加载所有模板
+// ReloadTemplates 重新加载所有模板（已删除的模板被移除，无效的模板保留旧版本）
 func (pm *PromptManager) ReloadTemplates(dir string) error {
 	pm.mu.Lock()
-	pm.templates = make(map[string]*PromptTemplate)
-	pm.mu.Unlock()
+	defer pm.mu.Unlock()
 
-	return pm.LoadTemplates(dir)
+	loaded, err := pm.loadTemplates(dir)
+	if loaded == nil {
+		loaded = make(map[string]*PromptTemplate)
+	}
+	pm.templates = loaded
+	return err
 }
 
 // === 全局函数（供外部调用）===
@@ -146,6 +306,20 @@ func GetPromptTemplate(name string) (*PromptTemplate, error) {
 	return globalPromptManager.GetTemplate(name)
 }
 
+// GetPromptTemplateIndicators 获取模板声明的技术指标（模板不存在时使用 default 模板的声明）
+func GetPromptTemplateIndicators(name string) []market.IndicatorSpec {
+	if name == "" {
+		name = "default"
+	}
+	template, err := GetPromptTemplate(name)
+	if err != nil {
+		if template, err = GetPromptTemplate("default"); err != nil {
+			return nil
+		}
+	}
+	return template.Indicators
+}
+
 // GetAllPromptTemplateNames 获取所有模板名称（全局函数）
 func GetAllPromptTemplateNames() []string {
 	return globalPromptManager.GetAllTemplateNames()
@@ -156,7 +330,31 @@ func GetAllPromptTemplates() []*PromptTemplate {
 	return globalPromptManager.GetAllTemplates()
 }
 
-// ReloadPromptTemplates 重新加载所有模板（全局函数）
+// ReloadPromptTemplates 重新加载所有模板和用户提示词布局（全局函数）
 func ReloadPromptTemplates() error {
-	return globalPromptManager.ReloadTemplates(promptsDir)
+	return errors.Join(
+		globalPromptManager.ReloadTemplates(promptsDir),
+		LoadUserPromptLayouts(filepath.Join(promptsDir, userPromptLayoutsDir)),
+	)
+}
+
+// SavePromptTemplate 校验并写入模板文件（prompts/<name>.txt），随后重新加载所有模板
+func SavePromptTemplate(name, source string) error {
+	if name == "" || filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("模板名称无效: %q", name)
+	}
+
+	partials, _ := loadPartials(filepath.Join(promptsDir, partialsDir))
+	template, err := parsePromptTemplate(name, source, partials)
+	if err == nil {
+		_, err = compilePromptTemplate(name, template.Content, partials)
+	}
+	if err != nil {
+		return fmt.Errorf("模板 %s 校验失败: %w", name, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(promptsDir, name+".txt"), []byte(source), 0644); err != nil {
+		return fmt.Errorf("写入模板文件失败: %w", err)
+	}
+	return ReloadPromptTemplates()
 }
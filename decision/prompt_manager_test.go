@@ -3,6 +3,7 @@ package decision
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -283,3 +284,48 @@ func TestReloadPromptTemplates_GlobalFunction(t *testing.T) {
 		t.Errorf("模板内容不正确: got %s, want '测试内容'", template.Content)
 	}
 }
+
+func TestPromptManager_IndicatorsDirective(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"trend.txt":   "@indicators: bb(20,2), adx@4h\n@indicators: supertrend(10,3)\n\n趋势策略",
+		"invalid.txt": "@indicators: unknown(1)\n无效指标",
+		"range.txt":   "@indicators: bb(20,2), bb(0)\n参数越界",
+		"plain.txt":   "普通策略\n@indicators: bb",
+	}
+	for filename, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, filename), []byte(content), 0644); err != nil {
+			t.Fatalf("创建测试文件失败 %s: %v", filename, err)
+		}
+	}
+
+	pm := NewPromptManager()
+	err := pm.LoadTemplates(tempDir)
+	if err == nil || !strings.Contains(err.Error(), "invalid.txt") || !strings.Contains(err.Error(), "range.txt") {
+		t.Fatalf("无效的指标声明应导致模板校验失败，got %v", err)
+	}
+
+	trend, _ := pm.GetTemplate("trend")
+	if trend.Content != "趋势策略" {
+		t.Errorf("指令行应从模板内容中移除，got %q", trend.Content)
+	}
+	var specs []string
+	for _, spec := range trend.Indicators {
+		specs = append(specs, spec.String())
+	}
+	if got := strings.Join(specs, ","); got != "bb(20,2),adx(14)@4h,supertrend(10,3)" {
+		t.Errorf("指标声明 = %s", got)
+	}
+
+	for _, name := range []string{"invalid", "range"} {
+		if _, err := pm.GetTemplate(name); err == nil {
+			t.Errorf("指标声明无效的模板 %s 不应被加载", name)
+		}
+	}
+
+	// 指令只在文件开头生效
+	plain, _ := pm.GetTemplate("plain")
+	if len(plain.Indicators) != 0 || plain.Content != files["plain.txt"] {
+		t.Errorf("非头部的指令不应解析，got %+v", plain)
+	}
+}
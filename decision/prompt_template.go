@@ -0,0 +1,241 @@
+package decision
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"nofx/market"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// 内置片段名称（模板中未引用时自动追加到系统提示词末尾）
+const (
+	PartialRiskRules    = "risk_rules"    // 硬约束（风险控制）
+	PartialOutputFormat = "output_format" // 输出格式
+)
+
+// PromptData 系统提示词模板可用的变量（取自 decision.Context）
+//
+//	{{.TraderName}}                      交易员名称
+//	{{.CurrentTime}}                     当前时间
+//	{{.RuntimeMinutes}} {{.CallCount}}   运行时长（分钟）、决策次数
+//	{{.Equity}} {{.AvailableBalance}}    账户净值、可用余额（USDT）
+//	{{.MarginUsedPct}} {{.PositionCount}} 保证金使用率、持仓数量
+//	{{.BTCETHLeverage}} {{.AltcoinLeverage}} 杠杆上限
+//	{{.Timeframes}}                      K线周期（如 {{join .Timeframes ", "}}）
+//	{{.CandidateCount}}                  候选币种数量
+//	{{.Trigger}}                         事件触发原因（定时周期为空）
+//	{{.Validation.MinRiskReward}} 等     交易员的决策校验规则（见 ValidationProfile）
+//	{{.Vars.name}}                       交易员自定义变量（未设置时为空，可用 {{default "值" .Vars.name}}）
+//
+// 共享片段通过 {{template "risk_rules" .}}、{{template "output_format" .}} 引用
+type PromptData struct {
+	TraderName       string
+	CurrentTime      string
+	RuntimeMinutes   int
+	CallCount        int
+	Equity           float64
+	AvailableBalance float64
+	MarginUsedPct    float64
+	PositionCount    int
+	BTCETHLeverage   int
+	AltcoinLeverage  int
+	Timeframes       []string
+	CandidateCount   int
+	Trigger          string
+	Validation       ValidationProfile
+	Vars             map[string]string
+}
+
+// newPromptData 从交易上下文提取模板变量
+func newPromptData(ctx *Context) *PromptData {
+	timeframes := ctx.Timeframes
+	if len(timeframes) == 0 {
+		timeframes = market.DefaultTimeframes
+	}
+	return &PromptData{
+		TraderName:       ctx.TraderName,
+		CurrentTime:      ctx.CurrentTime,
+		RuntimeMinutes:   ctx.RuntimeMinutes,
+		CallCount:        ctx.CallCount,
+		Equity:           ctx.Account.TotalEquity,
+		AvailableBalance: ctx.Account.AvailableBalance,
+		MarginUsedPct:    ctx.Account.MarginUsedPct,
+		PositionCount:    ctx.Account.PositionCount,
+		BTCETHLeverage:   ctx.BTCETHLeverage,
+		AltcoinLeverage:  ctx.AltcoinLeverage,
+		Timeframes:       timeframes,
+		CandidateCount:   len(ctx.CandidateCoins),
+		Trigger:          ctx.Trigger,
+		Validation:       validationProfileOf(ctx),
+		Vars:             ctx.PromptVars,
+	}
+}
+
+// samplePromptData 加载时校验模板所用的示例数据
+func samplePromptData() *PromptData {
+	return &PromptData{
+		TraderName:      "validate",
+		CurrentTime:     "2006-01-02 15:04:05",
+		Equity:          1000,
+		BTCETHLeverage:  5,
+		AltcoinLeverage: 5,
+		Timeframes:      market.DefaultTimeframes,
+		Validation:      DefaultValidationProfile(),
+		Vars:            map[string]string{},
+	}
+}
+
+// promptFuncs 模板可用的函数
+var promptFuncs = template.FuncMap{
+	"mul":  func(a, b float64) float64 { return a * b },
+	"join": strings.Join,
+	// default 值为空时使用默认值：{{default "稳健" .Vars.style}}
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// builtinPartials 内置共享片段（可被 prompts/partials/ 下的同名文件覆盖）
+var builtinPartials = map[string]string{
+	PartialRiskRules: `# 硬约束（风险控制）
+
+1. 风险回报比: 必须 ≥ 1:{{printf "%g" .Validation.MinRiskReward}}（按当前价格计算，冒1%风险，赚{{printf "%g" .Validation.MinRiskReward}}%+收益）
+2. 最多持仓: 3个币种（质量>数量）
+3. 单币仓位: 山寨{{printf "%.0f" (mul .Equity 0.8)}}-{{printf "%.0f" (mul .Equity .Validation.MaxPositionEquityAlt)}} U | BTC/ETH {{printf "%.0f" (mul .Equity 5)}}-{{printf "%.0f" (mul .Equity .Validation.MaxPositionEquityBTCETH)}} U
+4. 杠杆限制: **山寨币最大{{.AltcoinLeverage}}x杠杆** | **BTC/ETH最大{{.BTCETHLeverage}}x杠杆** (⚠️ 严格执行，不可超过)
+5. 保证金: 总使用率 ≤ 90%
+6. 开仓金额: 山寨 **≥{{printf "%g" .Validation.MinPositionUSDAlt}} USDT** | BTC/ETH **≥{{printf "%g" .Validation.MinPositionUSDBTCETH}} USDT** (交易所最小名义价值 + 安全边际)
+{{- with .Validation}}{{if or .MinStopDistance .MaxStopDistance}}
+- 止损距离（距当前价）: {{if .MinStopDistance}}≥ {{printf "%g" .MinStopDistance}}{{end}}{{if and .MinStopDistance .MaxStopDistance}} 且 {{end}}{{if .MaxStopDistance}}≤ {{printf "%g" .MaxStopDistance}}{{end}}{{if eq .StopDistanceUnit "atr"}} 倍ATR14{{else}}%{{end}}{{end}}{{if .AllowedActions}}
+- 允许的操作: {{join .AllowedActions ", "}}, hold, wait（其他操作会被拒绝）{{end}}{{end}}
+
+`,
+	PartialOutputFormat: `# 输出格式 (严格遵守)
+
+**必须使用XML标签 <reasoning> 和 <decision> 标签分隔思维链和决策JSON，避免解析错误**
+
+## 格式要求
+
+<reasoning>
+你的思维链分析...
+- 简洁分析你的思考过程
+</reasoning>
+
+<decision>
+` + "```json" + `
+[
+  {"symbol": "BTCUSDT", "action": "open_short", "leverage": {{.BTCETHLeverage}}, "position_size_usd": {{printf "%.0f" (mul .Equity 5)}}, "stop_loss": 97000, "take_profit": 91000, "confidence": 85, "risk_usd": 300, "reasoning": "下跌趋势+MACD死叉"},
+  {"symbol": "ETHUSDT", "action": "close_long", "reasoning": "止盈离场"}
+]
+` + "```" + `
+</decision>
+
+## 字段说明
+
+- ` + "`action`" + `: open_long | open_short | close_long | close_short | add_to_position | reverse | update_sl_tp | hold | wait
+- ` + "`confidence`" + `: 0-100（开仓建议≥75）
+- ` + "`hold_hours`" + `: 可选，预期持仓时长（小时），用于估算资金费成本
+- 开仓时必填: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd, reasoning
+- add_to_position（按原方向加仓）: position_size_usd 为加仓金额，stop_loss/take_profit 必填且作用于加仓后的整个仓位，leverage 可省略（沿用持仓杠杆）
+- reverse（平掉当前持仓并反向开仓）: 字段同开仓，按新方向填写
+- update_sl_tp（同时调整止损止盈）: 必填 new_stop_loss, new_take_profit
+- 已有同方向持仓时不能再 open_long/open_short，请使用 add_to_position
+
+`,
+}
+
+// autoAppendPartials 模板未引用时自动追加的片段（按顺序）
+var autoAppendPartials = []string{PartialRiskRules, PartialOutputFormat}
+
+// reVarName 自定义变量名（需可作为 {{.Vars.name}} 引用）
+var reVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// compilePromptTemplate 解析模板并用示例数据试渲染，确保模板在进入交易周期前可用
+func compilePromptTemplate(name, content string, partials map[string]string) (*template.Template, error) {
+	tmpl := template.New(name).Funcs(promptFuncs).Option("missingkey=zero")
+	for _, partialName := range sortedKeys(partials) {
+		if _, err := tmpl.New(partialName).Parse(partials[partialName]); err != nil {
+			return nil, fmt.Errorf("解析片段 %s 失败: %w", partialName, err)
+		}
+	}
+	if _, err := tmpl.Parse(content); err != nil {
+		return nil, fmt.Errorf("解析模板失败: %w", err)
+	}
+
+	if err := tmpl.Execute(&bytes.Buffer{}, samplePromptData()); err != nil {
+		return nil, fmt.Errorf("试渲染模板失败: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderPromptTemplate 渲染系统提示词：模板内容 + 模板未引用的内置片段
+func renderPromptTemplate(tmpl *template.Template, content string, data *PromptData) (string, error) {
+	if data.Validation.MinRiskReward == 0 {
+		// 未设置校验规则时按默认规则渲染
+		withDefaults := *data
+		withDefaults.Validation = DefaultValidationProfile()
+		data = &withDefaults
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染模板失败: %w", err)
+	}
+	buf.WriteString("\n\n")
+
+	for _, partialName := range autoAppendPartials {
+		if referencesPartial(content, partialName) {
+			continue
+		}
+		if err := tmpl.ExecuteTemplate(&buf, partialName, data); err != nil {
+			return "", fmt.Errorf("渲染片段 %s 失败: %w", partialName, err)
+		}
+	}
+	return buf.String(), nil
+}
+
+// referencesPartial 模板内容是否引用了指定片段
+func referencesPartial(content, partialName string) bool {
+	re := regexp.MustCompile(`\{\{-?\s*template\s+"` + regexp.QuoteMeta(partialName) + `"`)
+	return re.MatchString(content)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ParsePromptVariables 解析交易员的自定义模板变量（JSON对象，空串表示无变量）
+func ParsePromptVariables(raw string) (map[string]string, error) {
+	vars := map[string]string{}
+	if strings.TrimSpace(raw) == "" {
+		return vars, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &vars); err != nil {
+		return nil, fmt.Errorf("解析模板变量失败: %w", err)
+	}
+	if err := ValidatePromptVariables(vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// ValidatePromptVariables 校验自定义模板变量名
+func ValidatePromptVariables(vars map[string]string) error {
+	for name := range vars {
+		if !reVarName.MatchString(name) {
+			return fmt.Errorf("模板变量名无效: %q（只能包含字母、数字和下划线，且不能以数字开头）", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package decision
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPromptTemplateRender(t *testing.T) {
+	data := &PromptData{
+		TraderName:      "alpha",
+		Equity:          1000,
+		BTCETHLeverage:  10,
+		AltcoinLeverage: 3,
+		Timeframes:      []string{"15m", "1h"},
+		Vars:            map[string]string{"style": "激进"},
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		contains []string
+		// 硬约束标题应出现的次数（模板引用片段时不再自动追加）
+		riskRules int
+	}{
+		{
+			name:      "变量与函数",
+			content:   "交易员 {{.TraderName}} 周期 {{join .Timeframes \"/\"}} 风格 {{.Vars.style}} 节奏 {{default \"稳健\" .Vars.pace}}",
+			contains:  []string{"交易员 alpha 周期 15m/1h 风格 激进 节奏 稳健", "山寨币最大3x杠杆", "BTC/ETH 5000-10000 U", "# 输出格式"},
+			riskRules: 1,
+		},
+		{
+			name:      "引用片段",
+			content:   "策略\n{{template \"risk_rules\" .}}自定义结尾",
+			contains:  []string{"自定义结尾", "# 输出格式"},
+			riskRules: 1,
+		},
+		{
+			name:      "未设置的变量为空",
+			content:   "[{{.Vars.missing}}]",
+			contains:  []string{"[]"},
+			riskRules: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, err := (&PromptTemplate{Name: "test", Content: tt.content}).Render(data)
+			if err != nil {
+				t.Fatalf("Render() 失败: %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("提示词缺少 %q:\n%s", want, prompt)
+				}
+			}
+			if got := strings.Count(prompt, "# 硬约束"); got != tt.riskRules {
+				t.Errorf("硬约束出现 %d 次, 期望 %d", got, tt.riskRules)
+			}
+		})
+	}
+}
+
+func TestPromptManager_ValidatesTemplates(t *testing.T) {
+	tempDir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("good.txt", "好模板 {{.Equity}}")
+	write("broken.txt", "坏模板 {{.Equity")
+	write("unknown_field.txt", "{{.NoSuchField}}")
+	write("partials/risk_rules.txt", "自定义风控 {{.AltcoinLeverage}}x\n")
+
+	pm := NewPromptManager()
+	err := pm.LoadTemplates(tempDir)
+	if err == nil || !strings.Contains(err.Error(), "broken.txt") || !strings.Contains(err.Error(), "unknown_field.txt") {
+		t.Fatalf("LoadTemplates() 应报告无效模板, got %v", err)
+	}
+	if _, err := pm.GetTemplate("broken"); err == nil {
+		t.Error("无效模板不应被加载")
+	}
+
+	good, err := pm.GetTemplate("good")
+	if err != nil {
+		t.Fatalf("获取 good 模板失败: %v", err)
+	}
+	prompt, err := good.Render(&PromptData{Equity: 100, AltcoinLeverage: 4})
+	if err != nil {
+		t.Fatalf("Render() 失败: %v", err)
+	}
+	if !strings.Contains(prompt, "自定义风控 4x") || strings.Contains(prompt, "# 硬约束") {
+		t.Errorf("片段文件应覆盖内置硬约束:\n%s", prompt)
+	}
+
+	// 重新加载时模板被改坏，继续使用旧版本
+	write("good.txt", "{{if}}")
+	if err := pm.ReloadTemplates(tempDir); err == nil {
+		t.Fatal("ReloadTemplates() 应报告无效模板")
+	}
+	reloaded, err := pm.GetTemplate("good")
+	if err != nil {
+		t.Fatalf("无效模板应保留旧版本: %v", err)
+	}
+	if reloaded.Content != "好模板 {{.Equity}}" {
+		t.Errorf("保留的模板内容 = %q", reloaded.Content)
+	}
+}
+
+func TestParsePromptVariables(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "空串", raw: "", want: map[string]string{}},
+		{name: "有效变量", raw: `{"style":"激进","max_trades":"3"}`, want: map[string]string{"style": "激进", "max_trades": "3"}},
+		{name: "变量名含横线", raw: `{"max-trades":"3"}`, wantErr: true},
+		{name: "变量名以数字开头", raw: `{"1st":"x"}`, wantErr: true},
+		{name: "非JSON对象", raw: `["a"]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePromptVariables(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePromptVariables() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParsePromptVariables() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("变量 %s = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+// TestPromptTemplateHashCoversPartials 测试模板哈希随引用（含自动追加与间接引用）的片段变化，未引用片段不影响哈希
+func TestPromptTemplateHashCoversPartials(t *testing.T) {
+	source := `你是交易员 {{template "style" .}}`
+	partials := map[string]string{
+		"style":             `稳健 {{template "tone" .}}`,
+		"tone":              "冷静",
+		"unused":            "未引用",
+		PartialRiskRules:    "风控规则",
+		PartialOutputFormat: "输出格式",
+	}
+	base := promptTemplateHash(source, source, partials)
+
+	change := func(name, content string) string {
+		modified := make(map[string]string, len(partials))
+		for k, v := range partials {
+			modified[k] = v
+		}
+		modified[name] = content
+		return promptTemplateHash(source, source, modified)
+	}
+
+	tests := []struct {
+		name    string
+		partial string
+		changed bool
+	}{
+		{name: "直接引用的片段", partial: "style", changed: true},
+		{name: "间接引用的片段", partial: "tone", changed: true},
+		{name: "自动追加的片段", partial: PartialRiskRules, changed: true},
+		{name: "未引用的片段", partial: "unused", changed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := change(tt.partial, "修改后") != base; got != tt.changed {
+				t.Errorf("修改片段 %s 后哈希变化 = %v, want %v", tt.partial, got, tt.changed)
+			}
+		})
+	}
+}
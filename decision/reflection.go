@@ -0,0 +1,166 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"nofx/mcp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxLessonRunes 单条教训的最大长度（字符），超出部分被截断
+	MaxLessonRunes = 160
+	// maxLessonsPerReflection 单次反思最多产生的教训数
+	maxLessonsPerReflection = 5
+)
+
+// Lesson 从已平仓交易中总结的教训（Symbol 为空表示通用教训）
+type Lesson struct {
+	Symbol    string    `json:"symbol"`
+	Content   string    `json:"lesson"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// ClosedTrade 反思使用的已平仓交易
+type ClosedTrade struct {
+	Symbol      string
+	Side        string
+	Leverage    int
+	OpenPrice   float64
+	ClosePrice  float64
+	PnL         float64
+	PnLPct      float64
+	Duration    string
+	WasStopLoss bool
+	CloseTime   time.Time
+}
+
+// Reflect 让AI从已平仓交易中总结简短的教训（已有教训一并提供，避免重复）
+func Reflect(client *mcp.Client, trades []ClosedTrade, existing []Lesson) ([]Lesson, error) {
+	if len(trades) == 0 {
+		return nil, nil
+	}
+	output, err := client.CallWithMessages(reflectionSystemPrompt, buildReflectionPrompt(trades, existing))
+	if err != nil {
+		return nil, fmt.Errorf("调用AI反思失败: %w", err)
+	}
+	return parseLessons(output)
+}
+
+// buildReflectionPrompt 已平仓交易明细 + 已有教训
+func buildReflectionPrompt(trades []ClosedTrade, existing []Lesson) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("## 最近平仓的交易 (%d笔)\n", len(trades)))
+	for _, t := range trades {
+		stop := ""
+		if t.WasStopLoss {
+			stop = " | 止损"
+		}
+		sb.WriteString(fmt.Sprintf("- %s %s %dx | 开仓%.6g → 平仓%.6g | 盈亏%+.2f USDT (%+.2f%%) | 持仓%s%s | %s\n",
+			t.Symbol, strings.ToUpper(t.Side), t.Leverage, t.OpenPrice, t.ClosePrice, t.PnL, t.PnLPct,
+			t.Duration, stop, t.CloseTime.Format("01-02 15:04")))
+	}
+	if len(existing) > 0 {
+		sb.WriteString("\n## 已有的教训（不要重复）\n")
+		sb.WriteString(formatLessons(existing))
+	}
+	return sb.String()
+}
+
+// parseLessons 解析反思输出，清理空白并截断过长的教训
+func parseLessons(output string) ([]Lesson, error) {
+	cleaned := removeInvisibleRunes(output)
+	match := reJSONArray.FindString(cleaned)
+	if match == "" {
+		if strings.Contains(cleaned, "[]") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("反思输出中没有找到教训列表")
+	}
+	var parsed []Lesson
+	if err := json.Unmarshal([]byte(match), &parsed); err != nil {
+		return nil, fmt.Errorf("解析反思输出失败: %w", err)
+	}
+
+	var lessons []Lesson
+	for _, lesson := range parsed {
+		lesson.Symbol = strings.ToUpper(strings.TrimSpace(lesson.Symbol))
+		lesson.Content = TruncateLesson(lesson.Content)
+		if lesson.Content == "" {
+			continue
+		}
+		lessons = append(lessons, lesson)
+		if len(lessons) >= maxLessonsPerReflection {
+			break
+		}
+	}
+	return lessons, nil
+}
+
+// TruncateLesson 去除首尾空白并截断到 MaxLessonRunes 个字符
+func TruncateLesson(content string) string {
+	runes := []rune(strings.Join(strings.Fields(content), " "))
+	if len(runes) > MaxLessonRunes {
+		return string(runes[:MaxLessonRunes-1]) + "…"
+	}
+	return string(runes)
+}
+
+// SelectLessons 选出与当前币种相关的教训并控制在 token 上限内：
+// 相关币种的教训优先，其次是通用教训，同类中新的优先；其他币种的教训不注入
+func SelectLessons(lessons []Lesson, symbols []string, maxTokens int) []Lesson {
+	relevant := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		relevant[symbol] = true
+	}
+
+	var selected []Lesson
+	for _, lesson := range lessons {
+		if lesson.Symbol == "" || relevant[lesson.Symbol] {
+			selected = append(selected, lesson)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if (selected[i].Symbol != "") != (selected[j].Symbol != "") {
+			return selected[i].Symbol != ""
+		}
+		return selected[i].CreatedAt.After(selected[j].CreatedAt)
+	})
+
+	used := 0
+	for i, lesson := range selected {
+		used += EstimateTokens(formatLesson(lesson))
+		if used > maxTokens {
+			return selected[:i]
+		}
+	}
+	return selected
+}
+
+func formatLesson(lesson Lesson) string {
+	if lesson.Symbol == "" {
+		return "- " + lesson.Content + "\n"
+	}
+	return fmt.Sprintf("- [%s] %s\n", lesson.Symbol, lesson.Content)
+}
+
+func formatLessons(lessons []Lesson) string {
+	var sb strings.Builder
+	for _, lesson := range lessons {
+		sb.WriteString(formatLesson(lesson))
+	}
+	return sb.String()
+}
+
+const reflectionSystemPrompt = `你是交易复盘分析师。根据最近平仓的交易，总结可以指导后续决策的简短教训。
+
+要求：
+1. 每条教训一句话（不超过80字），具体、可执行，例如入场时机、止损距离、持仓时长、币种特性
+2. 只针对某个币种的教训填写 symbol，通用教训 symbol 留空
+3. 不要重复已有的教训；没有新的教训时输出 []
+4. 最多5条
+
+只输出JSON数组：
+[{"symbol": "SOLUSDT", "lesson": "..."}, {"symbol": "", "lesson": "..."}]`
@@ -0,0 +1,80 @@
+package decision
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLessons(t *testing.T) {
+	output := "复盘结果：\n```json\n[{\"symbol\": \" solusdt \", \"lesson\": \"追高开多  容易被插针止损\"}, {\"symbol\": \"\", \"lesson\": \"  \"}, {\"symbol\": \"\", \"lesson\": \"亏损单不要拖过4小时\"}]\n```"
+	lessons, err := parseLessons(output)
+	if err != nil {
+		t.Fatalf("parseLessons() error = %v", err)
+	}
+	if len(lessons) != 2 {
+		t.Fatalf("空教训应被忽略, got %+v", lessons)
+	}
+	if lessons[0].Symbol != "SOLUSDT" || lessons[0].Content != "追高开多 容易被插针止损" {
+		t.Errorf("lessons[0] = %+v", lessons[0])
+	}
+
+	if lessons, err := parseLessons("没有新的教训 []"); err != nil || len(lessons) != 0 {
+		t.Errorf("空列表应返回无教训, got %v, err=%v", lessons, err)
+	}
+	if _, err := parseLessons("没有找到任何规律"); err == nil {
+		t.Error("没有教训列表时应返回错误")
+	}
+}
+
+func TestTruncateLesson(t *testing.T) {
+	long := strings.Repeat("教", MaxLessonRunes+20)
+	if got := []rune(TruncateLesson(long)); len(got) != MaxLessonRunes {
+		t.Errorf("TruncateLesson() 长度 = %d, want %d", len(got), MaxLessonRunes)
+	}
+	if got := TruncateLesson("  止损\n放宽 "); got != "止损 放宽" {
+		t.Errorf("TruncateLesson() = %q", got)
+	}
+}
+
+func TestSelectLessons(t *testing.T) {
+	now := time.Now()
+	lessons := []Lesson{
+		{Content: "通用教训-旧", CreatedAt: now.Add(-2 * time.Hour)},
+		{Symbol: "DOGEUSDT", Content: "无关币种", CreatedAt: now},
+		{Symbol: "SOLUSDT", Content: "SOL教训", CreatedAt: now.Add(-3 * time.Hour)},
+		{Content: "通用教训-新", CreatedAt: now.Add(-time.Hour)},
+	}
+
+	names := func(selected []Lesson) string {
+		var parts []string
+		for _, l := range selected {
+			parts = append(parts, l.Content)
+		}
+		return strings.Join(parts, ",")
+	}
+
+	if got := names(SelectLessons(lessons, []string{"SOLUSDT", "ETHUSDT"}, 1000)); got != "SOL教训,通用教训-新,通用教训-旧" {
+		t.Errorf("SelectLessons() = %s", got)
+	}
+	// token 上限只够一条
+	limit := EstimateTokens(formatLesson(lessons[2]))
+	if got := names(SelectLessons(lessons, []string{"SOLUSDT"}, limit)); got != "SOL教训" {
+		t.Errorf("SelectLessons() 超出上限 = %s", got)
+	}
+	if got := SelectLessons(lessons, nil, 0); len(got) != 0 {
+		t.Errorf("上限为0时不应注入教训, got %v", got)
+	}
+}
+
+func TestLessonsSection(t *testing.T) {
+	ctx := &Context{Lessons: []Lesson{{Symbol: "SOLUSDT", Content: "追高开多容易被插针止损"}, {Content: "亏损单不要拖过4小时"}}}
+	got := lessonsSection(ctx, LanguageZH)
+	want := "## 📝 历史交易教训\n- [SOLUSDT] 追高开多容易被插针止损\n- 亏损单不要拖过4小时\n\n"
+	if got != want {
+		t.Errorf("lessonsSection() = %q, want %q", got, want)
+	}
+	if lessonsSection(&Context{}, LanguageZH) != "" {
+		t.Error("没有教训时不应输出区块")
+	}
+}
@@ -0,0 +1,92 @@
+package decision
+
+import (
+	"fmt"
+	"log"
+	"nofx/mcp"
+	"strings"
+	"time"
+)
+
+// maxRepairAttempts AI输出未通过解析或校验时，最多让模型修正的次数
+const maxRepairAttempts = 2
+
+// 自我修正的结果
+const (
+	RepairFixed      = "fixed"       // 修正后全部通过
+	RepairInvalid    = "invalid"     // 修正后仍有错误
+	RepairCallFailed = "call_failed" // 调用AI失败
+)
+
+// RepairAttempt 一次自我修正：发给模型的错误、模型修正后的输出和结果
+type RepairAttempt struct {
+	Attempt    int    `json:"attempt"`
+	Error      string `json:"error"`            // 上一次输出的解析或校验错误（原样发给模型）
+	Output     string `json:"output,omitempty"` // 模型修正后的输出（调用失败时为错误信息）
+	Outcome    string `json:"outcome"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// parseWithRepair 解析并校验AI响应；无法解析或有决策未通过校验时，把上一次的输出和错误发回给模型修正，
+// 最多 maxRepairAttempts 次。修正结果能解析、且未通过校验的决策不多于当前结果时才采用，否则保留当前结果；
+// 最终仍无法解析时返回错误，仍有决策未通过校验时只保留通过校验的决策
+func parseWithRepair(ctx *Context, client *mcp.Client, systemPrompt, userPrompt, aiResponse, lang string) (*FullDecision, []RepairAttempt, error) {
+	fullDecision, err := parseFullDecisionResponse(aiResponse, ctx)
+	conversation := []mcp.Message{{Role: "user", Content: userPrompt}}
+	output, problem := aiResponse, describeProblem(fullDecision, err)
+
+	var repairs []RepairAttempt
+	for attempt := 1; problem != "" && attempt <= maxRepairAttempts; attempt++ {
+		log.Printf("🔧 AI输出未通过校验，请求模型修正 (%d/%d): %s", attempt, maxRepairAttempts, problem)
+		conversation = append(conversation,
+			mcp.Message{Role: "assistant", Content: output},
+			mcp.Message{Role: "user", Content: fmt.Sprintf(promptText(lang, "repair"), problem)})
+		repair := RepairAttempt{Attempt: attempt, Error: problem}
+
+		start := time.Now()
+		var callErr error
+		output, callErr = client.CallWithConversation(systemPrompt, conversation)
+		repair.DurationMs = time.Since(start).Milliseconds()
+		if callErr != nil {
+			repair.Output = callErr.Error()
+			repair.Outcome = RepairCallFailed
+			repairs = append(repairs, repair)
+			log.Printf("⚠️  自我修正调用AI失败: %v", callErr)
+			break
+		}
+
+		repair.Output = output
+		repaired, repairErr := parseFullDecisionResponse(output, ctx)
+		problem = describeProblem(repaired, repairErr)
+		repair.Outcome = RepairInvalid
+		if problem == "" {
+			repair.Outcome = RepairFixed
+		}
+		if repairErr == nil && (err != nil || len(repaired.Rejections) <= len(fullDecision.Rejections)) {
+			fullDecision, err = repaired, nil
+		} else {
+			log.Printf("⚠️  自我修正 #%d 失败，修正结果不如当前结果，已保留当前结果: %s", attempt, problem)
+		}
+		repairs = append(repairs, repair)
+		log.Printf("🔧 自我修正 #%d: %s", attempt, repair.Outcome)
+	}
+
+	if err != nil {
+		return fullDecision, repairs, err
+	}
+	if len(fullDecision.Rejections) > 0 {
+		log.Printf("⚠️  %d 条决策未通过校验，已跳过，其余 %d 条照常执行", len(fullDecision.Rejections), len(fullDecision.Decisions))
+	}
+	return fullDecision, repairs, nil
+}
+
+// describeProblem 发给模型的错误说明（无法解析的错误或逐条的校验拒绝原因），没有问题时为空
+func describeProblem(fullDecision *FullDecision, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	if len(fullDecision.Rejections) == 0 {
+		return ""
+	}
+	return "- " + strings.Join(fullDecision.Rejections, "\n- ")
+}
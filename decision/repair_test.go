@@ -0,0 +1,127 @@
+package decision
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nofx/market"
+	"nofx/mcp"
+	"strings"
+	"testing"
+)
+
+// sequenceAIClient 依次返回给定回复的AI客户端，记录每次请求的消息数
+func sequenceAIClient(t *testing.T, replies ...string) (*mcp.Client, *[]int) {
+	t.Helper()
+	var messageCounts []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		reply := replies[len(messageCounts)%len(replies)]
+		messageCounts = append(messageCounts, len(req.Messages))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": reply}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client := mcp.New()
+	client.SetCustomAPI(server.URL, "test-key", "fake-model")
+	return client, &messageCounts
+}
+
+func TestParseWithRepair(t *testing.T) {
+	ctx := &Context{
+		Account:         AccountInfo{TotalEquity: 100},
+		BTCETHLeverage:  10,
+		AltcoinLeverage: 5,
+		MarketDataMap:   map[string]*market.Data{"SOLUSDT": {Symbol: "SOLUSDT", CurrentPrice: 100}},
+	}
+	const (
+		holdOnly = `<decision>[{"symbol": "BTCUSDT", "action": "hold", "reasoning": "持有"}]</decision>`
+		// 按标记价风险回报比只有2:1
+		lowRR = `<decision>[{"symbol": "BTCUSDT", "action": "hold", "reasoning": "持有"},
+{"symbol": "SOLUSDT", "action": "open_long", "leverage": 3, "position_size_usd": 100, "stop_loss": 95, "take_profit": 110, "reasoning": "突破"}]</decision>`
+		// 缺少逗号，无法解析
+		malformed = "<decision>\n```json\n[{\"symbol\": \"SOLUSDT\" \"action\": \"wait\"}]\n```\n</decision>"
+		// 两条开仓的风险回报比都过低，比 lowRR 更差
+		worseRR = `<decision>[{"symbol": "SOLUSDT", "action": "open_long", "leverage": 3, "position_size_usd": 100, "stop_loss": 95, "take_profit": 110, "reasoning": "突破"},
+{"symbol": "SOLUSDT", "action": "open_short", "leverage": 3, "position_size_usd": 100, "stop_loss": 105, "take_profit": 90, "reasoning": "回落"}]</decision>`
+		fixedRR = `<decision>[{"symbol": "BTCUSDT", "action": "hold", "reasoning": "持有"},
+{"symbol": "SOLUSDT", "action": "open_long", "leverage": 3, "position_size_usd": 100, "stop_loss": 95, "take_profit": 120, "reasoning": "突破"}]</decision>`
+	)
+
+	t.Run("通过校验时不修正", func(t *testing.T) {
+		client, calls := sequenceAIClient(t, holdOnly)
+		fullDecision, repairs, err := parseWithRepair(ctx, client, "system", "user", holdOnly, LanguageZH)
+		if err != nil || len(repairs) != 0 || len(*calls) != 0 {
+			t.Fatalf("err = %v, repairs = %+v, calls = %d", err, repairs, len(*calls))
+		}
+		if len(fullDecision.Decisions) != 1 {
+			t.Errorf("决策数 = %d, want 1", len(fullDecision.Decisions))
+		}
+	})
+
+	t.Run("无法解析时修正成功", func(t *testing.T) {
+		client, calls := sequenceAIClient(t, fixedRR)
+		fullDecision, repairs, err := parseWithRepair(ctx, client, "system", "user", malformed, LanguageZH)
+		if err != nil {
+			t.Fatalf("修正后应能解析: %v", err)
+		}
+		if len(repairs) != 1 || repairs[0].Outcome != RepairFixed || repairs[0].Error == "" {
+			t.Fatalf("修正记录异常: %+v", repairs)
+		}
+		// system + 原始 user + 上一次输出 + 修正要求
+		if len(*calls) != 1 || (*calls)[0] != 4 {
+			t.Errorf("修正请求应包含完整对话: %v", *calls)
+		}
+		if len(fullDecision.Decisions) != 2 || len(fullDecision.Rejections) != 0 {
+			t.Errorf("修正后决策异常: %+v", fullDecision)
+		}
+	})
+
+	t.Run("修正失败时只执行通过校验的决策", func(t *testing.T) {
+		client, calls := sequenceAIClient(t, lowRR)
+		fullDecision, repairs, err := parseWithRepair(ctx, client, "system", "user", lowRR, LanguageZH)
+		if err != nil {
+			t.Fatalf("部分决策未通过校验不应返回错误: %v", err)
+		}
+		if len(repairs) != maxRepairAttempts || repairs[1].Outcome != RepairInvalid || !strings.Contains(repairs[0].Error, "风险回报比过低") {
+			t.Fatalf("修正记录异常: %+v", repairs)
+		}
+		if len(*calls) != 2 || (*calls)[1] != 6 {
+			t.Errorf("第二次修正应带上前一次的对话: %v", *calls)
+		}
+		if len(fullDecision.Decisions) != 1 || fullDecision.Decisions[0].Action != "hold" || len(fullDecision.Rejections) != 1 {
+			t.Errorf("应只保留通过校验的决策: %+v", fullDecision)
+		}
+	})
+
+	t.Run("修正结果更差时保留原结果", func(t *testing.T) {
+		for _, reply := range []string{worseRR, malformed} {
+			client, _ := sequenceAIClient(t, reply)
+			fullDecision, repairs, err := parseWithRepair(ctx, client, "system", "user", lowRR, LanguageZH)
+			if err != nil {
+				t.Fatalf("保留原结果时不应返回错误: %v", err)
+			}
+			if len(repairs) != maxRepairAttempts || repairs[1].Outcome != RepairInvalid {
+				t.Fatalf("修正记录异常: %+v", repairs)
+			}
+			if len(fullDecision.Decisions) != 1 || fullDecision.Decisions[0].Action != "hold" || len(fullDecision.Rejections) != 1 {
+				t.Errorf("应保留原结果: %+v", fullDecision)
+			}
+		}
+	})
+
+	t.Run("修正调用失败时返回解析错误", func(t *testing.T) {
+		_, repairs, err := parseWithRepair(ctx, mcp.New(), "system", "user", malformed, LanguageZH) // 未设置API密钥，调用失败
+		if err == nil {
+			t.Fatal("无法解析且修正失败时应返回错误")
+		}
+		if len(repairs) != 1 || repairs[0].Outcome != RepairCallFailed {
+			t.Errorf("修正记录异常: %+v", repairs)
+		}
+	})
+}
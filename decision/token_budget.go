@@ -0,0 +1,263 @@
+package decision
+
+import (
+	"fmt"
+	"nofx/market"
+	"nofx/mcp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// promptBudgetSafetyPct 为 token 估算误差预留的比例
+	promptBudgetSafetyPct = 10
+	// minSeriesPoints K线序列截断后至少保留的根数
+	minSeriesPoints = 3
+)
+
+// Tokenizer 计算文本的 token 数（可按模型注册精确的分词器，默认使用 EstimateTokens 估算）
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// TokenizerFunc 函数形式的 Tokenizer
+type TokenizerFunc func(text string) int
+
+// CountTokens 实现 Tokenizer
+func (f TokenizerFunc) CountTokens(text string) int { return f(text) }
+
+var (
+	tokenizersMu sync.RWMutex
+	// tokenizers 模型名称前缀 → 分词器（最长前缀优先）
+	tokenizers = map[string]Tokenizer{}
+)
+
+// RegisterTokenizer 为模型注册分词器，modelPrefix 按前缀匹配模型名称
+func RegisterTokenizer(modelPrefix string, tokenizer Tokenizer) {
+	tokenizersMu.Lock()
+	defer tokenizersMu.Unlock()
+	tokenizers[modelPrefix] = tokenizer
+}
+
+// tokenizerFor 模型对应的分词器（未注册时使用估算）
+func tokenizerFor(model string) Tokenizer {
+	tokenizersMu.RLock()
+	defer tokenizersMu.RUnlock()
+
+	best := ""
+	var tokenizer Tokenizer = TokenizerFunc(EstimateTokens)
+	for prefix, t := range tokenizers {
+		if strings.HasPrefix(model, prefix) && len(prefix) >= len(best) {
+			best, tokenizer = prefix, t
+		}
+	}
+	return tokenizer
+}
+
+// EstimateTokens 启发式估算 token 数：ASCII 约4个字符1个token，中文等非ASCII字符按每字1个token
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for i := 0; i < len(text); {
+		if text[i] < utf8.RuneSelf {
+			ascii++
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		other++
+		i += size
+	}
+	return (ascii+3)/4 + other
+}
+
+// PromptBudget 本次请求的输入 token 预算（模型上下文窗口 - 输出预留 - 估算误差预留）
+type PromptBudget struct {
+	Limit     int
+	Tokenizer Tokenizer
+}
+
+// promptBudgetFor 按 AI 客户端的模型计算输入预算，输出预留（AI_MAX_TOKENS）占满上下文窗口时返回错误
+func promptBudgetFor(client *mcp.Client) (PromptBudget, error) {
+	contextTokens := client.ContextTokens()
+	limit := (contextTokens - client.MaxTokens) * (100 - promptBudgetSafetyPct) / 100
+	if limit <= 0 {
+		return PromptBudget{}, fmt.Errorf("AI_MAX_TOKENS (%d) 不能大于等于模型 %s 的上下文窗口 (%d)", client.MaxTokens, client.Model, contextTokens)
+	}
+	return PromptBudget{Limit: limit, Tokenizer: tokenizerFor(client.Model)}, nil
+}
+
+// rankCandidates 按信号来源强度排序候选币种（多来源优先，同等强度保持币种池原有顺序）
+func rankCandidates(coins []CandidateCoin) []CandidateCoin {
+	ranked := make([]CandidateCoin, len(coins))
+	copy(ranked, coins)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return len(ranked[i].Sources) > len(ranked[j].Sources)
+	})
+	return ranked
+}
+
+// assembledUserPrompt 预算内组装的 User Prompt 及其实际使用的数据
+type assembledUserPrompt struct {
+	Prompt         string
+	Tokens         int
+	Cuts           []string                // 裁剪记录（未裁剪时为空）
+	MarketDataMap  map[string]*market.Data // 裁剪后的市场数据（AI实际看到的）
+	CandidateCoins []CandidateCoin         // 裁剪后的候选币种
+}
+
+// assembleUserPrompt 在预算内渲染 User Prompt，超出时依次裁剪：
+//  1. 候选币种的K线序列截断为最近一半
+//  2. 按来源强度从弱到强移除候选币种
+//  3. 持仓币种的K线序列截断为最近一半
+//
+// 持仓、账户等信息不会被移除。返回的裁剪记录写入决策日志，裁剪后的数据写入市场数据快照
+func assembleUserPrompt(ctx *Context, layout *UserPromptLayout, budget int, tokenizer Tokenizer) *assembledUserPrompt {
+	work := *ctx
+	work.MarketDataMap = make(map[string]*market.Data, len(ctx.MarketDataMap))
+	for symbol, data := range ctx.MarketDataMap {
+		work.MarketDataMap[symbol] = data
+	}
+	work.CandidateCoins = append([]CandidateCoin(nil), ctx.CandidateCoins...)
+
+	result := &assembledUserPrompt{}
+	done := func() *assembledUserPrompt {
+		result.MarketDataMap, result.CandidateCoins = work.MarketDataMap, work.CandidateCoins
+		return result
+	}
+	rerender := func() bool {
+		result.Prompt = renderUserPrompt(&work, layout)
+		result.Tokens = tokenizer.CountTokens(result.Prompt)
+		return result.Tokens <= budget
+	}
+	if rerender() {
+		return done()
+	}
+
+	positionSymbols := make(map[string]bool, len(ctx.Positions))
+	for _, pos := range ctx.Positions {
+		positionSymbols[pos.Symbol] = true
+	}
+
+	// 1. 截断候选币种的K线序列（保留最新的一半）
+	if keep, truncated := truncateSymbols(&work, func(symbol string) bool { return !positionSymbols[symbol] }); truncated > 0 {
+		result.Cuts = append(result.Cuts, fmt.Sprintf("%d个候选币种的K线序列截断为最近%d根", truncated, keep))
+		if rerender() {
+			return done()
+		}
+	}
+
+	// 2. 从最弱的候选币种开始移除
+	for i := len(work.CandidateCoins) - 1; i >= 0; i-- {
+		coin := work.CandidateCoins[i]
+		if _, ok := work.MarketDataMap[coin.Symbol]; !ok || positionSymbols[coin.Symbol] {
+			continue
+		}
+		work.CandidateCoins = append(work.CandidateCoins[:i:i], work.CandidateCoins[i+1:]...)
+		if sym, err := market.ParseSymbol(coin.Symbol, market.VenueBinance); err != nil || sym.Base != "BTC" { // BTC 区块仍需要行情
+			delete(work.MarketDataMap, coin.Symbol)
+		}
+		result.Cuts = append(result.Cuts, fmt.Sprintf("移除候选币种 %s（来源: %s）", coin.Symbol, strings.Join(coin.Sources, "+")))
+		if rerender() {
+			return done()
+		}
+	}
+
+	// 3. 截断持仓币种的K线序列
+	if keep, truncated := truncateSymbols(&work, func(symbol string) bool { return positionSymbols[symbol] }); truncated > 0 {
+		result.Cuts = append(result.Cuts, fmt.Sprintf("%d个持仓币种的K线序列截断为最近%d根", truncated, keep))
+		if rerender() {
+			return done()
+		}
+	}
+
+	result.Cuts = append(result.Cuts, fmt.Sprintf("裁剪后仍超出预算 %d tokens（持仓和账户信息不再裁剪）", result.Tokens-budget))
+	return done()
+}
+
+// truncateSymbols 将满足条件的币种的K线序列截断为最近一半，返回保留的根数和截断的币种数
+func truncateSymbols(ctx *Context, match func(symbol string) bool) (int, int) {
+	keep, truncated := 0, 0
+	for symbol, data := range ctx.MarketDataMap {
+		if !match(symbol) {
+			continue
+		}
+		points := seriesLength(data)
+		if points <= minSeriesPoints {
+			continue
+		}
+		n := max(points/2, minSeriesPoints)
+		ctx.MarketDataMap[symbol] = truncateMarketData(data, n)
+		keep = max(keep, n)
+		truncated++
+	}
+	return keep, truncated
+}
+
+// seriesLength 市场数据中最长的K线序列长度
+func seriesLength(data *market.Data) int {
+	n := intradayLength(data.IntradaySeries)
+	for _, tf := range data.Timeframes {
+		n = max(n, intradayLength(tf.Series))
+	}
+	return n
+}
+
+func intradayLength(series *market.IntradayData) int {
+	if series == nil {
+		return 0
+	}
+	return max(len(series.MidPrices), len(series.EMA20Values), len(series.MACDValues),
+		len(series.RSI7Values), len(series.RSI14Values), len(series.Volume))
+}
+
+// truncateMarketData 复制市场数据并只保留各序列最新的 n 个值（汇总指标不变，不修改原数据）
+func truncateMarketData(data *market.Data, n int) *market.Data {
+	truncated := *data
+	truncated.IntradaySeries = truncateIntraday(data.IntradaySeries, n)
+	truncated.LongerTermContext = truncateLongerTerm(data.LongerTermContext, n)
+	if len(data.Timeframes) > 0 {
+		truncated.Timeframes = make([]*market.TimeframeData, len(data.Timeframes))
+		for i, tf := range data.Timeframes {
+			truncated.Timeframes[i] = &market.TimeframeData{
+				Interval: tf.Interval,
+				Series:   truncateIntraday(tf.Series, n),
+				Context:  truncateLongerTerm(tf.Context, n),
+			}
+		}
+	}
+	return &truncated
+}
+
+func truncateIntraday(series *market.IntradayData, n int) *market.IntradayData {
+	if series == nil {
+		return nil
+	}
+	return &market.IntradayData{
+		MidPrices:   lastN(series.MidPrices, n),
+		EMA20Values: lastN(series.EMA20Values, n),
+		MACDValues:  lastN(series.MACDValues, n),
+		RSI7Values:  lastN(series.RSI7Values, n),
+		RSI14Values: lastN(series.RSI14Values, n),
+		Volume:      lastN(series.Volume, n),
+		ATR14:       series.ATR14,
+	}
+}
+
+func truncateLongerTerm(ctx *market.LongerTermData, n int) *market.LongerTermData {
+	if ctx == nil {
+		return nil
+	}
+	truncated := *ctx
+	truncated.MACDValues = lastN(ctx.MACDValues, n)
+	truncated.RSI14Values = lastN(ctx.RSI14Values, n)
+	return &truncated
+}
+
+func lastN(values []float64, n int) []float64 {
+	if len(values) <= n {
+		return values
+	}
+	return values[len(values)-n:]
+}
@@ -0,0 +1,149 @@
+package decision
+
+import (
+	"fmt"
+	"nofx/market"
+	"nofx/mcp"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "abcd", want: 1},
+		{text: "abcde", want: 2},
+		{text: "账户净值", want: 4},
+		{text: "BTC 价格", want: 3},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPromptBudgetFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxTokens int
+		wantLimit int
+		wantErr   bool
+	}{
+		{name: "正常预算", maxTokens: 2000, wantLimit: 27000},
+		{name: "输出预留等于上下文窗口", maxTokens: 32000, wantErr: true},
+		{name: "输出预留超过上下文窗口", maxTokens: 40000, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mcp.Client{Model: "unknown-model", MaxTokens: tt.maxTokens, ContextWindow: 32000}
+			budget, err := promptBudgetFor(client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("promptBudgetFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && budget.Limit != tt.wantLimit {
+				t.Errorf("promptBudgetFor() Limit = %d, want %d", budget.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestRankCandidates(t *testing.T) {
+	coins := []CandidateCoin{
+		{Symbol: "AUSDT", Sources: []string{"ai500"}},
+		{Symbol: "BUSDT", Sources: []string{"ai500", "oi_top"}},
+		{Symbol: "CUSDT", Sources: []string{"oi_top"}},
+		{Symbol: "DUSDT", Sources: []string{"ai500", "oi_top"}},
+	}
+	var got []string
+	for _, coin := range rankCandidates(coins) {
+		got = append(got, coin.Symbol)
+	}
+	if want := "BUSDT,DUSDT,AUSDT,CUSDT"; strings.Join(got, ",") != want {
+		t.Errorf("rankCandidates() = %v, want %s", got, want)
+	}
+	if coins[0].Symbol != "AUSDT" {
+		t.Error("rankCandidates 不应修改原切片")
+	}
+}
+
+func TestAssembleUserPrompt(t *testing.T) {
+	series := func() *market.IntradayData {
+		values := make([]float64, 10)
+		for i := range values {
+			values[i] = float64(100 + i)
+		}
+		return &market.IntradayData{MidPrices: values, EMA20Values: values, MACDValues: values, RSI7Values: values}
+	}
+	ctx := &Context{
+		Account:       AccountInfo{TotalEquity: 1000, AvailableBalance: 1000},
+		Positions:     []PositionInfo{{Symbol: "ETHUSDT", Side: "long", Leverage: 5}},
+		MarketDataMap: map[string]*market.Data{},
+	}
+	ctx.MarketDataMap["ETHUSDT"] = &market.Data{Symbol: "ETHUSDT", CurrentPrice: 3000, IntradaySeries: series()}
+	for i := 0; i < 6; i++ {
+		symbol := fmt.Sprintf("COIN%dUSDT", i)
+		sources := []string{"ai500"}
+		if i < 2 {
+			sources = append(sources, "oi_top")
+		}
+		ctx.CandidateCoins = append(ctx.CandidateCoins, CandidateCoin{Symbol: symbol, Sources: sources})
+		ctx.MarketDataMap[symbol] = &market.Data{Symbol: symbol, CurrentPrice: 1, IntradaySeries: series()}
+	}
+	layout, _ := GetUserPromptLayout(DefaultUserPromptLayout)
+	tokenizer := TokenizerFunc(EstimateTokens)
+	full := EstimateTokens(renderUserPrompt(ctx, layout))
+
+	t.Run("预算充足不裁剪", func(t *testing.T) {
+		assembled := assembleUserPrompt(ctx, layout, full, tokenizer)
+		if assembled.Tokens != full || len(assembled.Cuts) != 0 || len(assembled.MarketDataMap) != len(ctx.MarketDataMap) {
+			t.Errorf("tokens = %d (全量 %d), cuts = %v", assembled.Tokens, full, assembled.Cuts)
+		}
+	})
+
+	t.Run("截断序列并移除最弱候选", func(t *testing.T) {
+		budget := full * 55 / 100
+		assembled := assembleUserPrompt(ctx, layout, budget, tokenizer)
+		prompt, tokens, cuts := assembled.Prompt, assembled.Tokens, assembled.Cuts
+		if tokens > budget {
+			t.Fatalf("tokens = %d 超出预算 %d, cuts = %v", tokens, budget, cuts)
+		}
+		if len(cuts) < 2 || !strings.Contains(cuts[0], "候选币种的K线序列截断为最近5根") || !strings.Contains(cuts[1], "COIN5USDT") {
+			t.Errorf("裁剪顺序不符合预期: %v", cuts)
+		}
+		for _, want := range []string{"ETHUSDT", "COIN0USDT", "COIN1USDT"} {
+			if !strings.Contains(prompt, want) {
+				t.Errorf("持仓和强信号候选不应被移除: 缺少 %s", want)
+			}
+		}
+		if strings.Contains(prompt, "COIN5USDT") {
+			t.Error("最弱的候选应先被移除")
+		}
+		// 返回的数据与提示词一致（用于市场数据快照）
+		if _, ok := assembled.MarketDataMap["COIN5USDT"]; ok {
+			t.Error("被移除的候选不应出现在返回的市场数据中")
+		}
+		for _, coin := range assembled.CandidateCoins {
+			if coin.Symbol == "COIN5USDT" {
+				t.Error("被移除的候选不应出现在返回的候选币种中")
+			}
+		}
+		if got := len(assembled.MarketDataMap["COIN0USDT"].IntradaySeries.MidPrices); got != 5 {
+			t.Errorf("返回的候选K线序列 = %d 根, want 截断后的5根", got)
+		}
+	})
+
+	t.Run("预算不足时记录超出", func(t *testing.T) {
+		cuts := assembleUserPrompt(ctx, layout, 10, tokenizer).Cuts
+		if last := cuts[len(cuts)-1]; !strings.Contains(last, "仍超出预算") {
+			t.Errorf("cuts = %v", cuts)
+		}
+	})
+
+	if len(ctx.CandidateCoins) != 6 || len(ctx.MarketDataMap["COIN0USDT"].IntradaySeries.MidPrices) != 10 {
+		t.Error("裁剪不应修改原始上下文和市场数据")
+	}
+}
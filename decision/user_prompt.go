@@ -0,0 +1,531 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/market"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 用户提示词语言
+const (
+	LanguageZH = "zh"
+	LanguageEN = "en"
+)
+
+// 用户提示词区块（布局按顺序组合，没有数据的区块不输出）
+const (
+	SectionStatus      = "status"      // 时间、周期编号、事件触发原因
+	SectionBTC         = "btc"         // BTC 行情概览
+	SectionAccount     = "account"     // 账户净值、余额、保证金
+	SectionAlerts      = "alerts"      // 上次决策以来的市场警报
+	SectionPositions   = "positions"   // 当前持仓（含完整市场数据）
+	SectionExposure    = "exposure"    // 组合敞口与相关性
+	SectionCandidates  = "candidates"  // 候选币种（含完整市场数据）
+	SectionPerformance = "performance" // 历史表现（夏普比率）
+	SectionLessons     = "lessons"     // 从已平仓交易总结的教训
+	SectionNews        = "news"        // 新闻摘要（由 GET_NEWS 扩展点提供，内置布局不包含）
+	SectionInstruction = "instruction" // 结尾指令
+)
+
+// DefaultUserPromptLayout 默认布局名称
+const DefaultUserPromptLayout = "default"
+
+// userPromptLayoutsDir 布局文件子目录（prompts/user/*.json）
+const userPromptLayoutsDir = "user"
+
+// UserPromptLayout 用户提示词布局（命名 + 版本，按顺序组合区块）
+type UserPromptLayout struct {
+	Name        string   `json:"name"`
+	Version     int      `json:"version"`
+	Language    string   `json:"language"` // zh / en
+	Sections    []string `json:"sections"`
+	Instruction string   `json:"instruction,omitempty"` // 自定义结尾指令（为空使用语言默认）
+}
+
+// ID 布局标识（name@vN），记录在决策日志中用于审计和对比
+func (l *UserPromptLayout) ID() string {
+	return fmt.Sprintf("%s@v%d", l.Name, l.Version)
+}
+
+// UserPromptSection 区块渲染函数（没有数据时返回空串）
+type UserPromptSection func(ctx *Context, lang string) string
+
+var (
+	userPromptMu       sync.RWMutex
+	userPromptSections = map[string]UserPromptSection{
+		SectionStatus:      statusSection,
+		SectionBTC:         btcSection,
+		SectionAccount:     accountSection,
+		SectionAlerts:      alertsSection,
+		SectionPositions:   positionsSection,
+		SectionExposure:    exposureSection,
+		SectionCandidates:  candidatesSection,
+		SectionPerformance: performanceSection,
+		SectionLessons:     lessonsSection,
+		SectionNews:        newsSection,
+		SectionInstruction: nil, // 由布局的 Instruction 决定，见 renderUserPrompt
+	}
+	// userPromptLayouts 布局名称 → 版本 → 布局
+	userPromptLayouts = builtinUserPromptLayouts()
+)
+
+// builtinUserPromptLayouts 内置布局（可被 prompts/user/ 下的同名同版本文件覆盖）
+func builtinUserPromptLayouts() map[string]map[int]*UserPromptLayout {
+	sections := []string{
+		SectionStatus, SectionBTC, SectionAccount, SectionAlerts, SectionPositions,
+		SectionExposure, SectionCandidates, SectionPerformance, SectionInstruction,
+	}
+	// v2 在历史表现之后加入交易教训
+	sectionsV2 := []string{
+		SectionStatus, SectionBTC, SectionAccount, SectionAlerts, SectionPositions,
+		SectionExposure, SectionCandidates, SectionPerformance, SectionLessons, SectionInstruction,
+	}
+	return map[string]map[int]*UserPromptLayout{
+		DefaultUserPromptLayout: {
+			1: {Name: DefaultUserPromptLayout, Version: 1, Language: LanguageZH, Sections: sections},
+			2: {Name: DefaultUserPromptLayout, Version: 2, Language: LanguageZH, Sections: sectionsV2},
+		},
+		"default_en": {
+			1: {Name: "default_en", Version: 1, Language: LanguageEN, Sections: sections},
+			2: {Name: "default_en", Version: 2, Language: LanguageEN, Sections: sectionsV2},
+		},
+	}
+}
+
+// RegisterUserPromptSection 注册自定义区块（应在加载布局之前调用，同名区块会被替换）
+func RegisterUserPromptSection(name string, section UserPromptSection) {
+	userPromptMu.Lock()
+	defer userPromptMu.Unlock()
+	userPromptSections[name] = section
+}
+
+// validateUserPromptLayout 校验布局（调用方持有锁）
+func validateUserPromptLayout(layout *UserPromptLayout) error {
+	if layout.Name == "" || strings.Contains(layout.Name, "@") {
+		return fmt.Errorf("布局名称无效: %q", layout.Name)
+	}
+	if layout.Version < 1 {
+		return fmt.Errorf("布局 %s 的版本号必须 ≥ 1", layout.Name)
+	}
+	if _, ok := userPromptText[layout.Language]; !ok {
+		return fmt.Errorf("布局 %s 的语言无效: %q（支持 zh、en）", layout.Name, layout.Language)
+	}
+	if len(layout.Sections) == 0 {
+		return fmt.Errorf("布局 %s 没有任何区块", layout.Name)
+	}
+	for _, section := range layout.Sections {
+		if _, ok := userPromptSections[section]; !ok {
+			return fmt.Errorf("布局 %s 包含未知区块: %q", layout.Name, section)
+		}
+	}
+	return nil
+}
+
+// LoadUserPromptLayouts 从目录加载布局文件（内置布局 + 文件），无效的文件被跳过并在返回的错误中列出
+func LoadUserPromptLayouts(dir string) error {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	userPromptMu.Lock()
+	defer userPromptMu.Unlock()
+
+	layouts := builtinUserPromptLayouts()
+	var invalid []string
+	for _, file := range files {
+		var layout UserPromptLayout
+		data, err := os.ReadFile(file)
+		if err == nil {
+			err = json.Unmarshal(data, &layout)
+		}
+		if err == nil {
+			if layout.Name == "" {
+				layout.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			}
+			err = validateUserPromptLayout(&layout)
+		}
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", filepath.Base(file), err))
+			log.Printf("❌ 用户提示词布局 %s 无效，已跳过: %v", filepath.Base(file), err)
+			continue
+		}
+
+		if layouts[layout.Name] == nil {
+			layouts[layout.Name] = make(map[int]*UserPromptLayout)
+		}
+		layouts[layout.Name][layout.Version] = &layout
+		log.Printf("  🧱 加载用户提示词布局: %s", layout.ID())
+	}
+	userPromptLayouts = layouts
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("用户提示词布局校验失败: %s", strings.Join(invalid, "; "))
+	}
+	return nil
+}
+
+// GetUserPromptLayout 获取布局：name 使用最新版本，name@vN 使用指定版本，空串使用默认布局
+func GetUserPromptLayout(ref string) (*UserPromptLayout, error) {
+	if ref == "" {
+		ref = DefaultUserPromptLayout
+	}
+	name, version := ref, 0
+	if i := strings.LastIndex(ref, "@v"); i > 0 {
+		if _, err := fmt.Sscanf(ref[i+2:], "%d", &version); err != nil || version < 1 {
+			return nil, fmt.Errorf("布局版本无效: %s", ref)
+		}
+		name = ref[:i]
+	}
+
+	userPromptMu.RLock()
+	defer userPromptMu.RUnlock()
+
+	versions, ok := userPromptLayouts[name]
+	if !ok {
+		return nil, fmt.Errorf("用户提示词布局不存在: %s", name)
+	}
+	if version == 0 {
+		for v := range versions {
+			version = max(version, v)
+		}
+	}
+	layout, ok := versions[version]
+	if !ok {
+		return nil, fmt.Errorf("用户提示词布局不存在: %s", ref)
+	}
+	return layout, nil
+}
+
+// GetAllUserPromptLayouts 获取所有布局的所有版本（按名称、版本排序）
+func GetAllUserPromptLayouts() []*UserPromptLayout {
+	userPromptMu.RLock()
+	defer userPromptMu.RUnlock()
+
+	var layouts []*UserPromptLayout
+	for _, versions := range userPromptLayouts {
+		for _, layout := range versions {
+			layouts = append(layouts, layout)
+		}
+	}
+	sort.Slice(layouts, func(i, j int) bool {
+		if layouts[i].Name != layouts[j].Name {
+			return layouts[i].Name < layouts[j].Name
+		}
+		return layouts[i].Version < layouts[j].Version
+	})
+	return layouts
+}
+
+// LayoutHasSection 布局是否包含指定区块（布局不存在时按默认布局判断）
+func LayoutHasSection(ref, section string) bool {
+	for _, name := range resolveUserPromptLayout(ref).Sections {
+		if name == section {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveUserPromptLayout 交易员选择的布局（不存在时回退到默认布局）
+func resolveUserPromptLayout(ref string) *UserPromptLayout {
+	layout, err := GetUserPromptLayout(ref)
+	if err != nil {
+		log.Printf("⚠️  %v，使用默认布局", err)
+		layout, _ = GetUserPromptLayout(DefaultUserPromptLayout)
+	}
+	return layout
+}
+
+// renderUserPrompt 按布局依次渲染区块
+func renderUserPrompt(ctx *Context, layout *UserPromptLayout) string {
+	userPromptMu.RLock()
+	defer userPromptMu.RUnlock()
+
+	var sb strings.Builder
+	for _, name := range layout.Sections {
+		if name == SectionInstruction {
+			instruction := layout.Instruction
+			if instruction == "" {
+				instruction = promptText(layout.Language, "instruction")
+			}
+			sb.WriteString("---\n\n")
+			sb.WriteString(strings.TrimRight(instruction, "\n") + "\n")
+			continue
+		}
+		if section := userPromptSections[name]; section != nil {
+			sb.WriteString(section(ctx, layout.Language))
+		}
+	}
+	return sb.String()
+}
+
+func statusSection(ctx *Context, lang string) string {
+	s := fmt.Sprintf(promptText(lang, "status"), ctx.CurrentTime, ctx.CallCount, ctx.RuntimeMinutes)
+	if ctx.Trigger != "" {
+		s += fmt.Sprintf(promptText(lang, "trigger"), ctx.Trigger)
+	}
+	if len(ctx.Rejections) > 0 {
+		// 上个周期未通过校验的决策，把原因反馈给AI以便修正
+		s += promptText(lang, "rejections_header")
+		for _, reason := range ctx.Rejections {
+			s += "- " + reason + "\n"
+		}
+		s += "\n"
+	}
+	return s
+}
+
+func btcSection(ctx *Context, lang string) string {
+	btcData, hasBTC := ctx.MarketDataMap["BTCUSDT"]
+	if !hasBTC {
+		return ""
+	}
+	return fmt.Sprintf("BTC: %.2f (1h: %+.2f%%, 4h: %+.2f%%) | MACD: %.4f | RSI: %.2f\n\n",
+		btcData.CurrentPrice, btcData.PriceChange1h, btcData.PriceChange4h,
+		btcData.CurrentMACD, btcData.CurrentRSI7)
+}
+
+func accountSection(ctx *Context, lang string) string {
+	return fmt.Sprintf(promptText(lang, "account"),
+		ctx.Account.TotalEquity,
+		ctx.Account.AvailableBalance,
+		(ctx.Account.AvailableBalance/ctx.Account.TotalEquity)*100,
+		ctx.Account.TotalPnLPct,
+		ctx.Account.MarginUsedPct,
+		ctx.Account.PositionCount)
+}
+
+func alertsSection(ctx *Context, lang string) string {
+	if lang == LanguageZH {
+		return market.FormatAlerts(ctx.Alerts)
+	}
+	if len(ctx.Alerts) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(promptText(lang, "alerts_header"))
+	for _, alert := range ctx.Alerts {
+		sb.WriteString(fmt.Sprintf("- [%s] %s\n", alert.Timestamp.Format("15:04:05"), alert.Message))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func positionsSection(ctx *Context, lang string) string {
+	if len(ctx.Positions) == 0 {
+		return promptText(lang, "no_positions")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(promptText(lang, "positions_header"))
+	for i, pos := range ctx.Positions {
+		// 计算持仓时长
+		holdingDuration := ""
+		if pos.UpdateTime > 0 {
+			durationMs := time.Now().UnixMilli() - pos.UpdateTime
+			durationMin := durationMs / (1000 * 60) // 转换为分钟
+			if durationMin < 60 {
+				holdingDuration = fmt.Sprintf(promptText(lang, "held_minutes"), durationMin)
+			} else {
+				holdingDuration = fmt.Sprintf(promptText(lang, "held_hours"), durationMin/60, durationMin%60)
+			}
+		}
+
+		fundingInfo := ""
+		if pos.FundingFee != 0 {
+			fundingInfo = fmt.Sprintf(promptText(lang, "funding_fee"), pos.FundingFee)
+		}
+
+		sb.WriteString(fmt.Sprintf(promptText(lang, "position"),
+			i+1, pos.Symbol, strings.ToUpper(pos.Side),
+			pos.EntryPrice, pos.MarkPrice, pos.UnrealizedPnLPct, pos.UnrealizedPnL, pos.PeakPnLPct,
+			pos.Leverage, pos.MarginUsed, pos.LiquidationPrice, fundingInfo, holdingDuration))
+
+		// 使用FormatMarketData输出完整市场数据
+		if marketData, ok := ctx.MarketDataMap[pos.Symbol]; ok {
+			sb.WriteString(market.Format(marketData))
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func exposureSection(ctx *Context, lang string) string {
+	return formatExposure(ctx.Exposure, lang)
+}
+
+func candidatesSection(ctx *Context, lang string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(promptText(lang, "candidates_header"), len(ctx.MarketDataMap)))
+	displayedCount := 0
+	for _, coin := range ctx.CandidateCoins {
+		marketData, hasData := ctx.MarketDataMap[coin.Symbol]
+		if !hasData {
+			continue
+		}
+		displayedCount++
+
+		sourceTags := ""
+		if len(coin.Sources) > 1 {
+			sourceTags = promptText(lang, "source_dual")
+		} else if len(coin.Sources) == 1 && coin.Sources[0] == "oi_top" {
+			sourceTags = promptText(lang, "source_oi_top")
+		}
+
+		// 使用FormatMarketData输出完整市场数据
+		sb.WriteString(fmt.Sprintf("### %d. %s%s\n\n", displayedCount, coin.Symbol, sourceTags))
+		sb.WriteString(market.Format(marketData))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func performanceSection(ctx *Context, lang string) string {
+	if ctx.Performance == nil {
+		return ""
+	}
+	// 直接从interface{}中提取SharpeRatio
+	type PerformanceData struct {
+		SharpeRatio float64 `json:"sharpe_ratio"`
+	}
+	var perfData PerformanceData
+	jsonData, err := json.Marshal(ctx.Performance)
+	if err != nil || json.Unmarshal(jsonData, &perfData) != nil {
+		return ""
+	}
+	return fmt.Sprintf(promptText(lang, "sharpe"), perfData.SharpeRatio)
+}
+
+func lessonsSection(ctx *Context, lang string) string {
+	if len(ctx.Lessons) == 0 {
+		return ""
+	}
+	return promptText(lang, "lessons_header") + formatLessons(ctx.Lessons) + "\n"
+}
+
+func newsSection(ctx *Context, lang string) string {
+	if len(ctx.News) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(promptText(lang, "news_header"))
+	for _, item := range ctx.News {
+		sb.WriteString("- " + item + "\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// promptText 按布局语言取提示词文案（用户提示词及流水线初筛、复核阶段，未翻译的文案使用中文）
+func promptText(lang, key string) string {
+	if text, ok := userPromptText[lang][key]; ok {
+		return text
+	}
+	return userPromptText[LanguageZH][key]
+}
+
+// userPromptText 各语言的提示词文案
+var userPromptText = map[string]map[string]string{
+	LanguageZH: {
+		"status":            "时间: %s | 周期: #%d | 运行: %d分钟\n\n",
+		"trigger":           "⚡ 本次为事件触发的额外决策: %s\n\n",
+		"rejections_header": "⚠️ 上个周期以下决策未通过校验，未执行:\n",
+		"account":           "账户: 净值%.2f | 余额%.2f (%.1f%%) | 盈亏%+.2f%% | 保证金%.1f%% | 持仓%d个\n\n",
+		"alerts_header":     "## 市场警报（上次决策以来）\n",
+		"positions_header":  "## 当前持仓\n",
+		"no_positions":      "当前持仓: 无\n\n",
+		"position":          "%d. %s %s | 入场价%.4f 当前价%.4f | 盈亏%+.2f%% | 盈亏金额%+.2f USDT | 最高收益率%.2f%% | 杠杆%dx | 保证金%.0f | 强平价%.4f%s%s\n\n",
+		"held_minutes":      " | 持仓时长%d分钟",
+		"held_hours":        " | 持仓时长%d小时%d分钟",
+		"funding_fee":       " | 资金费%+.2f USDT",
+		"candidates_header": "## 候选币种 (%d个)\n\n",
+		"source_dual":       " (AI500+OI_Top双重信号)",
+		"source_oi_top":     " (OI_Top持仓增长)",
+		"sharpe":            "## 📊 夏普比率: %.2f\n\n",
+		"lessons_header":    "## 📝 历史交易教训\n",
+		"news_header":       "## 新闻\n",
+		"instruction":       "现在请分析并输出决策（思维链 + JSON）",
+		"repair":            "你上一次的输出未通过系统校验:\n%s\n\n请根据以上错误修正，重新输出完整的决策（<reasoning> 思维链 + <decision> JSON 数组），没有问题的决策保持不变。",
+
+		"exposure_header":    "## 📐 组合敞口\n",
+		"exposure_summary":   "多头%.0f U | 空头%.0f U | 净敞口%+.0f U (净值的%+.0f%%) | 总敞口%.0f%% | BTC Beta调整后净敞口%+.0f U\n",
+		"exposure_position":  "- %s %s 名义%+.0f U | %s | 等效BTC敞口%+.0f U\n",
+		"correlation_header": "## 📐 相关性\n",
+		"correlated_pairs":   "高相关币对 (%s收益率 |ρ|≥%.1f):\n",
+		"pair_same_bet":      "- ⚠️ %s / %s ρ=%.2f：两个持仓风险叠加，实际是同一笔押注\n",
+		"pair_hedged":        "- %s / %s ρ=%.2f：两个持仓方向相互对冲\n",
+		"pair_candidate":     "- %s ~ 持仓%s(%s) ρ=%.2f：开仓前考虑是否只是加码同一押注\n",
+
+		"screener_system": `你是加密货币合约交易的初筛分析师。根据每个候选币种的简要行情，挑选最值得深入分析的币种（最多%d个）。
+优先选择：趋势清晰、波动充足、多个信号来源共振的币种；避开资金费率极端或流动性不足的币种。
+
+只输出JSON字符串数组，例如 ["SOLUSDT", "ETHUSDT"]，不要输出其他内容。`,
+		"screener_account":           "账户: 净值%.2f | 持仓%d个\n\n",
+		"screener_candidates_header": "## 候选币种 (%d个)\n",
+		"screener_candidate":         "- %s [%s] 价格 %.6g | 1h %+.2f%% | 4h %+.2f%% | RSI7 %.1f | MACD %.4g | 资金费率 %.2e%s\n",
+		"reviewer_system": `你是风控复核员。逐条检查交易员提出的开仓（含加仓 add_to_position 和反手 reverse）是否违反下面的风控规则；违反规则、止损止盈方向错误或风险回报明显不合理的开仓应否决。
+
+只输出JSON数组，每个待复核的开仓一项：
+[{"symbol": "BTCUSDT", "action": "open_long", "approve": true, "reason": "简要理由"}]
+
+`,
+		"reviewer_account":      "账户: 净值%.2f | 可用%.2f | 保证金使用率%.1f%% | 持仓%d个\n\n",
+		"reviewer_position":     "- %s %s | 杠杆%dx | 保证金%.0f | 盈亏%+.2f%%\n",
+		"reviewer_opens_header": "## 待复核的开仓\n",
+		"reviewer_price":        "\n  当前价 %.6g",
+	},
+	LanguageEN: {
+		"status":            "Time: %s | Cycle: #%d | Runtime: %d min\n\n",
+		"trigger":           "⚡ This is an extra event-triggered decision: %s\n\n",
+		"rejections_header": "⚠️ These decisions from the last cycle failed validation and were not executed:\n",
+		"account":           "Account: equity %.2f | available %.2f (%.1f%%) | PnL %+.2f%% | margin used %.1f%% | positions %d\n\n",
+		"alerts_header":     "## Market alerts (since last decision)\n",
+		"positions_header":  "## Current positions\n",
+		"no_positions":      "Current positions: none\n\n",
+		"position":          "%d. %s %s | entry %.4f mark %.4f | PnL %+.2f%% | PnL %+.2f USDT | peak return %.2f%% | leverage %dx | margin %.0f | liquidation %.4f%s%s\n\n",
+		"held_minutes":      " | held %d min",
+		"held_hours":        " | held %dh %dmin",
+		"funding_fee":       " | funding %+.2f USDT",
+		"candidates_header": "## Candidate coins (%d)\n\n",
+		"source_dual":       " (AI500 + OI_Top double signal)",
+		"source_oi_top":     " (OI_Top open interest growth)",
+		"sharpe":            "## 📊 Sharpe ratio: %.2f\n\n",
+		"lessons_header":    "## 📝 Lessons from past trades\n",
+		"news_header":       "## News\n",
+		"instruction":       "Now analyze and output your decision (reasoning + JSON)",
+		"repair":            "Your previous answer failed validation:\n%s\n\nFix these errors and output the complete decision again (<reasoning> + <decision> JSON array). Keep the decisions that had no errors unchanged.",
+
+		"exposure_header":    "## 📐 Portfolio exposure\n",
+		"exposure_summary":   "long %.0f U | short %.0f U | net %+.0f U (%+.0f%% of equity) | gross %.0f%% | BTC beta-adjusted net %+.0f U\n",
+		"exposure_position":  "- %s %s notional %+.0f U | %s | BTC-equivalent %+.0f U\n",
+		"correlation_header": "## 📐 Correlation\n",
+		"correlated_pairs":   "Highly correlated pairs (%s returns |ρ|≥%.1f):\n",
+		"pair_same_bet":      "- ⚠️ %s / %s ρ=%.2f: both positions add up to the same bet\n",
+		"pair_hedged":        "- %s / %s ρ=%.2f: the two positions hedge each other\n",
+		"pair_candidate":     "- %s ~ held %s(%s) ρ=%.2f: opening it may just add to the same bet\n",
+
+		"screener_system": `You are the screening analyst for crypto perpetual futures trading. From the brief market summary of each candidate coin, pick the coins most worth a deeper analysis (at most %d).
+Prefer coins with a clear trend, enough volatility and several agreeing signal sources; avoid coins with extreme funding rates or thin liquidity.
+
+Output only a JSON array of strings, e.g. ["SOLUSDT", "ETHUSDT"], and nothing else.`,
+		"screener_account":           "Account: equity %.2f | positions %d\n\n",
+		"screener_candidates_header": "## Candidate coins (%d)\n",
+		"screener_candidate":         "- %s [%s] price %.6g | 1h %+.2f%% | 4h %+.2f%% | RSI7 %.1f | MACD %.4g | funding %.2e%s\n",
+		"reviewer_system": `You are the risk reviewer. Check each position the trader proposes to open (including add_to_position and reverse) against the risk rules below; reject any open that breaks a rule, has its stop loss or take profit on the wrong side, or has a clearly unreasonable risk-reward.
+
+Output only a JSON array with one item per open under review:
+[{"symbol": "BTCUSDT", "action": "open_long", "approve": true, "reason": "short reason"}]
+
+`,
+		"reviewer_account":      "Account: equity %.2f | available %.2f | margin used %.1f%% | positions %d\n\n",
+		"reviewer_position":     "- %s %s | leverage %dx | margin %.0f | PnL %+.2f%%\n",
+		"reviewer_opens_header": "## Opens under review\n",
+		"reviewer_price":        "\n  price %.6g",
+	},
+}
@@ -0,0 +1,118 @@
+package decision
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderUserPromptLayouts(t *testing.T) {
+	ctx := &Context{
+		CurrentTime:    "2025-01-01 00:00:00",
+		CallCount:      7,
+		RuntimeMinutes: 30,
+		Account:        AccountInfo{TotalEquity: 1000, AvailableBalance: 500, PositionCount: 0},
+		Performance:    map[string]float64{"sharpe_ratio": 1.5},
+		News:           []string{"ETF 获批"},
+		Rejections:     []string{"决策 #1 SOLUSDT open_long: 风险回报比过低(2.00:1)"},
+	}
+
+	tests := []struct {
+		name        string
+		layout      *UserPromptLayout
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "默认中文布局",
+			layout:      &UserPromptLayout{Name: "t", Version: 1, Language: LanguageZH, Sections: builtinUserPromptLayouts()[DefaultUserPromptLayout][1].Sections},
+			contains:    []string{"时间: 2025-01-01 00:00:00 | 周期: #7", "账户: 净值1000.00 | 余额500.00 (50.0%)", "当前持仓: 无", "## 📊 夏普比率: 1.50", "现在请分析并输出决策", "决策未通过校验", "- 决策 #1 SOLUSDT open_long: 风险回报比过低"},
+			notContains: []string{"## 新闻"},
+		},
+		{
+			name:        "英文布局",
+			layout:      &UserPromptLayout{Name: "t", Version: 1, Language: LanguageEN, Sections: builtinUserPromptLayouts()["default_en"][1].Sections},
+			contains:    []string{"Time: 2025-01-01 00:00:00 | Cycle: #7", "Account: equity 1000.00", "Current positions: none", "Sharpe ratio: 1.50", "failed validation"},
+			notContains: []string{"账户", "夏普", "## News"},
+		},
+		{
+			name:     "自定义布局包含新闻",
+			layout:   &UserPromptLayout{Name: "t", Version: 1, Language: LanguageZH, Sections: []string{SectionNews, SectionInstruction}},
+			contains: []string{"## 新闻\n- ETF 获批"},
+		},
+		{
+			name:        "自定义区块顺序与结尾指令",
+			layout:      &UserPromptLayout{Name: "t", Version: 1, Language: LanguageZH, Sections: []string{SectionAccount, SectionInstruction}, Instruction: "只输出JSON"},
+			contains:    []string{"账户: 净值", "---\n\n只输出JSON\n"},
+			notContains: []string{"时间:", "夏普比率", "现在请分析"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := renderUserPrompt(ctx, tt.layout)
+			for _, want := range tt.contains {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("提示词缺少 %q:\n%s", want, prompt)
+				}
+			}
+			for _, unwanted := range tt.notContains {
+				if strings.Contains(prompt, unwanted) {
+					t.Errorf("提示词不应包含 %q:\n%s", unwanted, prompt)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadUserPromptLayouts(t *testing.T) {
+	t.Cleanup(func() { LoadUserPromptLayouts(t.TempDir()) })
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"lean.v1.json": `{"name":"lean","version":1,"language":"en","sections":["account","instruction"]}`,
+		"lean.v2.json": `{"name":"lean","version":2,"language":"en","sections":["status","account","instruction"]}`,
+		"bad.json":     `{"name":"bad","version":1,"language":"zh","sections":["account","weather"]}`,
+		"badlang.json": `{"name":"badlang","version":1,"language":"fr","sections":["account"]}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := LoadUserPromptLayouts(dir)
+	if err == nil || !strings.Contains(err.Error(), "bad.json") || !strings.Contains(err.Error(), "badlang.json") {
+		t.Fatalf("LoadUserPromptLayouts() 应报告无效布局, got %v", err)
+	}
+
+	tests := []struct {
+		ref         string
+		wantID      string
+		expectError bool
+	}{
+		{ref: "", wantID: "default@v2"},
+		{ref: "default@v1", wantID: "default@v1"},
+		{ref: "default_en", wantID: "default_en@v2"},
+		{ref: "lean", wantID: "lean@v2"},
+		{ref: "lean@v1", wantID: "lean@v1"},
+		{ref: "lean@v3", expectError: true},
+		{ref: "bad", expectError: true},
+		{ref: "lean@vx", expectError: true},
+	}
+	for _, tt := range tests {
+		layout, err := GetUserPromptLayout(tt.ref)
+		if (err != nil) != tt.expectError {
+			t.Errorf("GetUserPromptLayout(%q) error = %v, expectError %v", tt.ref, err, tt.expectError)
+			continue
+		}
+		if !tt.expectError && layout.ID() != tt.wantID {
+			t.Errorf("GetUserPromptLayout(%q) = %s, want %s", tt.ref, layout.ID(), tt.wantID)
+		}
+	}
+
+	if layout := resolveUserPromptLayout("missing"); layout.ID() != "default@v2" {
+		t.Errorf("不存在的布局应回退到默认布局, got %s", layout.ID())
+	}
+}
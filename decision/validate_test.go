@@ -1,6 +1,8 @@
 package decision
 
 import (
+	"nofx/market"
+	"strings"
 	"testing"
 )
 
@@ -83,11 +85,17 @@ func TestLeverageFallback(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateDecision(&tt.decision, tt.accountEquity, tt.btcEthLeverage, tt.altcoinLeverage)
+			v := &decisionValidator{
+				profile:         DefaultValidationProfile(),
+				equity:          tt.accountEquity,
+				btcEthLeverage:  tt.btcEthLeverage,
+				altcoinLeverage: tt.altcoinLeverage,
+			}
+			err := v.validate(&tt.decision)
 
 			// 检查错误状态
 			if (err != nil) != tt.wantError {
-				t.Errorf("validateDecision() error = %v, wantError %v", err, tt.wantError)
+				t.Errorf("validate() error = %v, wantError %v", err, tt.wantError)
 				return
 			}
 
@@ -98,3 +106,181 @@ func TestLeverageFallback(t *testing.T) {
 		})
 	}
 }
+
+func TestParseValidationProfile(t *testing.T) {
+	profile, err := ParseValidationProfile("")
+	if err != nil || profile.MinRiskReward != 3 || profile.MaxPositionEquityAlt != 1.5 || profile.StopDistanceUnit != StopDistancePct {
+		t.Fatalf("空配置应使用默认规则: %+v, err = %v", profile, err)
+	}
+
+	profile, err = ParseValidationProfile(`{"min_risk_reward": 2, "stop_distance_unit": "ATR", "allowed_actions": ["open_long", "OPEN_LONG", "close_long"]}`)
+	if err != nil {
+		t.Fatalf("ParseValidationProfile() error = %v", err)
+	}
+	if profile.MinRiskReward != 2 || profile.MaxPositionEquityBTCETH != 10 || profile.MinPositionUSDAlt != 12 {
+		t.Errorf("未设置的数值应使用默认值: %+v", profile)
+	}
+	if profile.StopDistanceUnit != StopDistanceATR || strings.Join(profile.AllowedActions, ",") != "open_long,close_long" {
+		t.Errorf("单位和操作列表应规范化: %+v", profile)
+	}
+
+	for _, raw := range []string{
+		`{"min_risk_reward": -1}`,
+		`{"stop_distance_unit": "usd"}`,
+		`{"min_stop_distance": 3, "max_stop_distance": 1}`,
+		`{"allowed_actions": ["buy"]}`,
+		`{invalid`,
+	} {
+		if _, err := ParseValidationProfile(raw); err == nil {
+			t.Errorf("%s 应返回错误", raw)
+		}
+	}
+}
+
+// TestValidateAgainstMarkPrice 风险回报比和止损距离按当前标记价计算（缺少标记价时退回K线收盘价）
+func TestValidateAgainstMarkPrice(t *testing.T) {
+	marketData := map[string]*market.Data{
+		// K线收盘价与标记价不同，校验应使用标记价
+		"SOLUSDT": {Symbol: "SOLUSDT", CurrentPrice: 103, MarkPrice: 100, LongerTermContext: &market.LongerTermData{ATR14: 2}},
+		"ETHUSDT": {Symbol: "ETHUSDT", CurrentPrice: 100},
+	}
+	openLong := func(stopLoss, takeProfit float64) Decision {
+		return Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 3, PositionSizeUSD: 100, StopLoss: stopLoss, TakeProfit: takeProfit}
+	}
+	withProfile := func(modify func(*ValidationProfile)) ValidationProfile {
+		profile := DefaultValidationProfile()
+		modify(&profile)
+		return profile
+	}
+
+	tests := []struct {
+		name     string
+		profile  ValidationProfile
+		decision Decision
+		wantErr  string
+	}{
+		{name: "风险回报比达标", profile: DefaultValidationProfile(), decision: openLong(95, 115)},
+		// 假设入场价(98)下为4:1，按标记价只有2:1
+		{name: "按标记价风险回报比不足", profile: DefaultValidationProfile(), decision: openLong(95, 110), wantErr: "风险回报比过低"},
+		{name: "放宽风险回报比", profile: withProfile(func(p *ValidationProfile) { p.MinRiskReward = 2 }), decision: openLong(95, 110)},
+		{name: "止损在标记价之上", profile: DefaultValidationProfile(), decision: openLong(101, 130), wantErr: "当前标记价"},
+		{name: "缺少标记价时按收盘价计算", profile: DefaultValidationProfile(),
+			decision: Decision{Symbol: "ETHUSDT", Action: "open_long", Leverage: 3, PositionSizeUSD: 100, StopLoss: 95, TakeProfit: 110}, wantErr: "风险回报比过低(2.00:1)"},
+		{name: "止损距离低于ATR下限", profile: withProfile(func(p *ValidationProfile) {
+			p.StopDistanceUnit, p.MinStopDistance, p.MaxStopDistance = StopDistanceATR, 1, 3
+		}), decision: openLong(99, 120), wantErr: "止损距离过近"},
+		{name: "止损距离在ATR范围内", profile: withProfile(func(p *ValidationProfile) {
+			p.StopDistanceUnit, p.MinStopDistance, p.MaxStopDistance = StopDistanceATR, 1, 3
+		}), decision: openLong(95, 120)},
+		{name: "止损距离超过百分比上限", profile: withProfile(func(p *ValidationProfile) { p.MaxStopDistance = 2 }),
+			decision: openLong(95, 120), wantErr: "止损距离过远"},
+		{name: "仓位超过净值倍数", profile: DefaultValidationProfile(),
+			decision: Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 3, PositionSizeUSD: 200, StopLoss: 95, TakeProfit: 120}, wantErr: "1.5倍账户净值"},
+		{name: "提高仓位上限", profile: withProfile(func(p *ValidationProfile) { p.MaxPositionEquityAlt = 3 }),
+			decision: Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 3, PositionSizeUSD: 200, StopLoss: 95, TakeProfit: 120}},
+		{name: "操作不在允许范围内", profile: withProfile(func(p *ValidationProfile) { p.AllowedActions = []string{"open_long", "close_long"} }),
+			decision: Decision{Symbol: "SOLUSDT", Action: "open_short", Leverage: 3, PositionSizeUSD: 100, StopLoss: 105, TakeProfit: 85}, wantErr: "不在允许范围内"},
+		{name: "观望始终允许", profile: withProfile(func(p *ValidationProfile) { p.AllowedActions = []string{"close_long"} }),
+			decision: Decision{Symbol: "SOLUSDT", Action: "wait"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &decisionValidator{profile: tt.profile, equity: 100, btcEthLeverage: 10, altcoinLeverage: 5, marketData: marketData}
+			err := v.validate(&tt.decision)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePositionActions(t *testing.T) {
+	marketData := map[string]*market.Data{
+		"SOLUSDT": {Symbol: "SOLUSDT", CurrentPrice: 100},
+	}
+	longPosition := []PositionInfo{{Symbol: "SOLUSDT", Side: "long", Quantity: 0.5, MarkPrice: 100, Leverage: 3}}
+
+	tests := []struct {
+		name      string
+		positions []PositionInfo
+		decision  Decision
+		wantErr   string
+		wantLev   int
+	}{
+		{name: "加仓沿用持仓杠杆", positions: longPosition,
+			decision: Decision{Symbol: "SOLUSDT", Action: "add_to_position", PositionSizeUSD: 50, StopLoss: 95, TakeProfit: 115}, wantLev: 3},
+		{name: "加仓没有持仓", decision: Decision{Symbol: "SOLUSDT", Action: "add_to_position", PositionSizeUSD: 50, StopLoss: 95, TakeProfit: 115},
+			wantErr: "没有持仓"},
+		// 已有50 + 加仓120 超过1.5倍净值(150)
+		{name: "加仓后超过仓位上限", positions: longPosition,
+			decision: Decision{Symbol: "SOLUSDT", Action: "add_to_position", PositionSizeUSD: 120, StopLoss: 95, TakeProfit: 115}, wantErr: "已有50 + 加仓120"},
+		{name: "加仓止损方向按持仓方向", positions: longPosition,
+			decision: Decision{Symbol: "SOLUSDT", Action: "add_to_position", PositionSizeUSD: 50, StopLoss: 105, TakeProfit: 85}, wantErr: "做多时止损价必须小于止盈价"},
+		{name: "反手按反方向校验", positions: longPosition,
+			decision: Decision{Symbol: "SOLUSDT", Action: "reverse", Leverage: 3, PositionSizeUSD: 100, StopLoss: 105, TakeProfit: 85}, wantLev: 3},
+		{name: "反手止损方向错误", positions: longPosition,
+			decision: Decision{Symbol: "SOLUSDT", Action: "reverse", Leverage: 3, PositionSizeUSD: 100, StopLoss: 95, TakeProfit: 115}, wantErr: "做空时止损价必须大于止盈价"},
+		{name: "同时调整止损止盈", positions: longPosition,
+			decision: Decision{Symbol: "SOLUSDT", Action: "update_sl_tp", NewStopLoss: 98, NewTakeProfit: 120}},
+		{name: "同时调整缺少止盈", positions: longPosition,
+			decision: Decision{Symbol: "SOLUSDT", Action: "update_sl_tp", NewStopLoss: 98}, wantErr: "必须大于0"},
+		{name: "同时调整方向错误", positions: longPosition,
+			decision: Decision{Symbol: "SOLUSDT", Action: "update_sl_tp", NewStopLoss: 120, NewTakeProfit: 98}, wantErr: "多仓的新止损价必须小于新止盈价"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &decisionValidator{profile: DefaultValidationProfile(), equity: 100, btcEthLeverage: 10, altcoinLeverage: 5,
+				marketData: marketData, positions: tt.positions}
+			err := v.validate(&tt.decision)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v", err)
+				}
+				if tt.wantLev != 0 && tt.decision.Leverage != tt.wantLev {
+					t.Errorf("Leverage = %d, want %d", tt.decision.Leverage, tt.wantLev)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFullDecisionResponseRejections(t *testing.T) {
+	ctx := &Context{
+		Account:         AccountInfo{TotalEquity: 100},
+		BTCETHLeverage:  10,
+		AltcoinLeverage: 5,
+		MarketDataMap:   map[string]*market.Data{"SOLUSDT": {Symbol: "SOLUSDT", CurrentPrice: 100}},
+	}
+	response := `<reasoning>分析</reasoning>
+<decision>
+[{"symbol": "BTCUSDT", "action": "hold", "reasoning": "持有"},
+ {"symbol": "SOLUSDT", "action": "open_long", "leverage": 3, "position_size_usd": 100, "stop_loss": 95, "take_profit": 110, "reasoning": "突破"}]
+</decision>`
+
+	fullDecision, err := parseFullDecisionResponse(response, ctx)
+	if err != nil {
+		t.Fatalf("未通过校验的决策不应导致解析失败: %v", err)
+	}
+	if len(fullDecision.Rejections) != 1 || !strings.HasPrefix(fullDecision.Rejections[0], "决策 #2 SOLUSDT open_long") {
+		t.Fatalf("拒绝原因应记录未通过的决策: %+v", fullDecision.Rejections)
+	}
+	if len(fullDecision.Decisions) != 1 || fullDecision.Decisions[0].Action != "hold" {
+		t.Errorf("应只保留通过校验的决策: %+v", fullDecision.Decisions)
+	}
+
+	ctx.Validation = &ValidationProfile{MinRiskReward: 2}
+	ValidateValidationProfile(ctx.Validation)
+	if fullDecision, _ := parseFullDecisionResponse(response, ctx); len(fullDecision.Rejections) != 0 || len(fullDecision.Decisions) != 2 {
+		t.Errorf("放宽风险回报比后应全部通过验证: %+v", fullDecision)
+	}
+}
@@ -0,0 +1,394 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/market"
+	"strings"
+)
+
+// 止损距离单位
+const (
+	StopDistancePct = "pct" // 止损价距当前标记价的百分比
+	StopDistanceATR = "atr" // 止损价距当前标记价的 ATR14 倍数
+)
+
+// validActions AI可以输出的操作
+var validActions = map[string]bool{
+	"open_long":          true,
+	"open_short":         true,
+	"close_long":         true,
+	"close_short":        true,
+	"update_stop_loss":   true,
+	"update_take_profit": true,
+	"partial_close":      true,
+	"add_to_position":    true,
+	"reverse":            true,
+	"update_sl_tp":       true,
+	"hold":               true,
+	"wait":               true,
+}
+
+// ValidationProfile 决策校验规则（按交易员配置，存储在 traders.validation_profile）
+// 开仓的风险回报比按交易所当前标记价计算（缺少标记价时退回最新K线收盘价）；未通过校验的原因会在下个周期反馈给AI
+type ValidationProfile struct {
+	MinRiskReward           float64  `json:"min_risk_reward"`             // 最小风险回报比（收益/风险）
+	MaxPositionEquityBTCETH float64  `json:"max_position_equity_btc_eth"` // BTC/ETH 单币仓位上限（账户净值倍数）
+	MaxPositionEquityAlt    float64  `json:"max_position_equity_alt"`     // 山寨币单币仓位上限（账户净值倍数）
+	MinPositionUSDBTCETH    float64  `json:"min_position_usd_btc_eth"`    // BTC/ETH 最小开仓金额（USDT）
+	MinPositionUSDAlt       float64  `json:"min_position_usd_alt"`        // 山寨币最小开仓金额（USDT）
+	StopDistanceUnit        string   `json:"stop_distance_unit"`          // 止损距离单位: pct 或 atr
+	MinStopDistance         float64  `json:"min_stop_distance"`           // 止损距离下限（0 不限制）
+	MaxStopDistance         float64  `json:"max_stop_distance"`           // 止损距离上限（0 不限制）
+	AllowedActions          []string `json:"allowed_actions,omitempty"`   // 允许的操作（为空允许全部，hold/wait 始终允许）
+}
+
+// DefaultValidationProfile 默认规则：风险回报比≥3，山寨1.5倍/BTC·ETH 10倍净值，最小12/60 USDT
+func DefaultValidationProfile() ValidationProfile {
+	return ValidationProfile{
+		MinRiskReward:           3,
+		MaxPositionEquityBTCETH: 10,
+		MaxPositionEquityAlt:    1.5,
+		MinPositionUSDBTCETH:    60, // BTC/ETH 因价格高和精度限制需要更大金额
+		MinPositionUSDAlt:       12, // Binance 最小名义价值 10 USDT + 20% 安全边际
+		StopDistanceUnit:        StopDistancePct,
+	}
+}
+
+// ParseValidationProfile 解析数据库中存储的配置（空字符串使用默认配置）
+func ParseValidationProfile(raw string) (ValidationProfile, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return DefaultValidationProfile(), nil
+	}
+
+	var profile ValidationProfile
+	if err := json.Unmarshal([]byte(raw), &profile); err != nil {
+		return ValidationProfile{}, fmt.Errorf("解析校验配置失败: %w", err)
+	}
+	if err := ValidateValidationProfile(&profile); err != nil {
+		return ValidationProfile{}, err
+	}
+	return profile, nil
+}
+
+// ValidateValidationProfile 校验配置并补全默认参数（未设置的数值使用默认值）
+func ValidateValidationProfile(p *ValidationProfile) error {
+	if p.MinRiskReward < 0 || p.MaxPositionEquityBTCETH < 0 || p.MaxPositionEquityAlt < 0 ||
+		p.MinPositionUSDBTCETH < 0 || p.MinPositionUSDAlt < 0 || p.MinStopDistance < 0 || p.MaxStopDistance < 0 {
+		return fmt.Errorf("校验参数无效: 数值不能为负")
+	}
+	if p.MaxStopDistance > 0 && p.MinStopDistance > p.MaxStopDistance {
+		return fmt.Errorf("校验参数无效: min_stop_distance 不能大于 max_stop_distance")
+	}
+
+	p.StopDistanceUnit = strings.ToLower(strings.TrimSpace(p.StopDistanceUnit))
+	switch p.StopDistanceUnit {
+	case "":
+		p.StopDistanceUnit = StopDistancePct
+	case StopDistancePct, StopDistanceATR:
+	default:
+		return fmt.Errorf("校验参数无效: stop_distance_unit 只能是 %s 或 %s", StopDistancePct, StopDistanceATR)
+	}
+
+	seen := make(map[string]bool, len(p.AllowedActions))
+	var actions []string
+	for _, action := range p.AllowedActions {
+		action = strings.ToLower(strings.TrimSpace(action))
+		if !validActions[action] {
+			return fmt.Errorf("校验参数无效: 未知的操作 %q", action)
+		}
+		if !seen[action] {
+			seen[action] = true
+			actions = append(actions, action)
+		}
+	}
+	p.AllowedActions = actions
+
+	defaults := DefaultValidationProfile()
+	if p.MinRiskReward == 0 {
+		p.MinRiskReward = defaults.MinRiskReward
+	}
+	if p.MaxPositionEquityBTCETH == 0 {
+		p.MaxPositionEquityBTCETH = defaults.MaxPositionEquityBTCETH
+	}
+	if p.MaxPositionEquityAlt == 0 {
+		p.MaxPositionEquityAlt = defaults.MaxPositionEquityAlt
+	}
+	if p.MinPositionUSDBTCETH == 0 {
+		p.MinPositionUSDBTCETH = defaults.MinPositionUSDBTCETH
+	}
+	if p.MinPositionUSDAlt == 0 {
+		p.MinPositionUSDAlt = defaults.MinPositionUSDAlt
+	}
+	return nil
+}
+
+// IsActionAllowed 操作是否被配置允许（hold/wait 不产生交易，始终允许）
+func (p ValidationProfile) IsActionAllowed(action string) bool {
+	if len(p.AllowedActions) == 0 || action == "hold" || action == "wait" {
+		return true
+	}
+	for _, allowed := range p.AllowedActions {
+		if allowed == action {
+			return true
+		}
+	}
+	return false
+}
+
+// validationProfileOf 上下文使用的校验配置（未设置时使用默认配置）
+func validationProfileOf(ctx *Context) ValidationProfile {
+	if ctx.Validation != nil {
+		return *ctx.Validation
+	}
+	return DefaultValidationProfile()
+}
+
+// decisionValidator 按交易员的校验配置检查AI决策
+type decisionValidator struct {
+	profile         ValidationProfile
+	equity          float64
+	btcEthLeverage  int
+	altcoinLeverage int
+	marketData      map[string]*market.Data // 当前标记价和ATR（缺失的币种退回假设入场价）
+	positions       []PositionInfo          // 当前持仓（加仓、反手、同时调整止损止盈需要已有持仓）
+}
+
+func newDecisionValidator(ctx *Context) *decisionValidator {
+	return &decisionValidator{
+		profile:         validationProfileOf(ctx),
+		equity:          ctx.Account.TotalEquity,
+		btcEthLeverage:  ctx.BTCETHLeverage,
+		altcoinLeverage: ctx.AltcoinLeverage,
+		marketData:      ctx.MarketDataMap,
+		positions:       ctx.Positions,
+	}
+}
+
+// validateAll 逐条验证决策，返回通过校验的决策和未通过决策的拒绝原因
+func (v *decisionValidator) validateAll(decisions []Decision) ([]Decision, []string) {
+	valid := make([]Decision, 0, len(decisions))
+	var rejections []string
+	for i := range decisions {
+		d := decisions[i]
+		if err := v.validate(&d); err != nil {
+			rejections = append(rejections, fmt.Sprintf("决策 #%d %s %s: %v", i+1, d.Symbol, d.Action, err))
+			continue
+		}
+		valid = append(valid, d)
+	}
+	return valid, rejections
+}
+
+// validate 验证单个决策的有效性（杠杆超限时就地修正为上限值）
+func (v *decisionValidator) validate(d *Decision) error {
+	if !validActions[d.Action] {
+		return fmt.Errorf("无效的action: %s", d.Action)
+	}
+	if !v.profile.IsActionAllowed(d.Action) {
+		return fmt.Errorf("操作 %s 不在允许范围内（允许: %s）", d.Action, strings.Join(v.profile.AllowedActions, ", "))
+	}
+
+	switch d.Action {
+	case "open_long", "open_short":
+		return v.validateOpen(d, d.Action == "open_long", 0)
+	case "add_to_position":
+		// 加仓方向跟随已有持仓，未给出杠杆时沿用持仓杠杆；单币仓位上限按加仓后的总价值计算
+		pos, err := v.positionOf(d.Symbol)
+		if err != nil {
+			return err
+		}
+		if d.Leverage == 0 {
+			d.Leverage = pos.Leverage
+		}
+		return v.validateOpen(d, pos.Side == "long", pos.Quantity*pos.MarkPrice)
+	case "reverse":
+		// 反手：平掉已有持仓后按开反方向仓位校验
+		pos, err := v.positionOf(d.Symbol)
+		if err != nil {
+			return err
+		}
+		return v.validateOpen(d, pos.Side == "short", 0)
+	case "update_sl_tp":
+		pos, err := v.positionOf(d.Symbol)
+		if err != nil {
+			return err
+		}
+		if d.NewStopLoss <= 0 || d.NewTakeProfit <= 0 {
+			return fmt.Errorf("新止损和新止盈价格必须大于0")
+		}
+		if pos.Side == "long" && d.NewStopLoss >= d.NewTakeProfit {
+			return fmt.Errorf("多仓的新止损价必须小于新止盈价")
+		}
+		if pos.Side == "short" && d.NewStopLoss <= d.NewTakeProfit {
+			return fmt.Errorf("空仓的新止损价必须大于新止盈价")
+		}
+	case "update_stop_loss":
+		if d.NewStopLoss <= 0 {
+			return fmt.Errorf("新止损价格必须大于0: %.2f", d.NewStopLoss)
+		}
+	case "update_take_profit":
+		if d.NewTakeProfit <= 0 {
+			return fmt.Errorf("新止盈价格必须大于0: %.2f", d.NewTakeProfit)
+		}
+	case "partial_close":
+		if d.ClosePercentage <= 0 || d.ClosePercentage > 100 {
+			return fmt.Errorf("平仓百分比必须在0-100之间: %.1f", d.ClosePercentage)
+		}
+	}
+	return nil
+}
+
+// positionOf 币种的当前持仓（没有持仓或同时持有多空仓位时返回错误）
+func (v *decisionValidator) positionOf(symbol string) (*PositionInfo, error) {
+	var found *PositionInfo
+	for i := range v.positions {
+		if v.positions[i].Symbol != symbol {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%s 同时持有多空仓位，无法确定操作方向", symbol)
+		}
+		found = &v.positions[i]
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s 没有持仓", symbol)
+	}
+	return found, nil
+}
+
+// validateOpen 开仓类决策（开仓、加仓、反手）：杠杆、仓位金额、止损止盈方向、风险回报比和止损距离
+// long 为开仓后的持仓方向，existingValue 为加仓前已有的仓位价值（计入单币仓位上限）
+func (v *decisionValidator) validateOpen(d *Decision, long bool, existingValue float64) error {
+	p := v.profile
+	maxLeverage, maxEquityMultiple, minPositionSize := v.altcoinLeverage, p.MaxPositionEquityAlt, p.MinPositionUSDAlt
+	if market.IsBTCETH(d.Symbol) {
+		maxLeverage, maxEquityMultiple, minPositionSize = v.btcEthLeverage, p.MaxPositionEquityBTCETH, p.MinPositionUSDBTCETH
+	}
+
+	// ✅ Fallback 机制：杠杆超限时自动修正为上限值（而不是直接拒绝决策）
+	if d.Leverage <= 0 {
+		return fmt.Errorf("杠杆必须大于0: %d", d.Leverage)
+	}
+	if d.Leverage > maxLeverage {
+		log.Printf("⚠️  [Leverage Fallback] %s 杠杆超限 (%dx > %dx)，自动调整为上限值 %dx",
+			d.Symbol, d.Leverage, maxLeverage, maxLeverage)
+		d.Leverage = maxLeverage
+	}
+
+	// 验证仓位金额（下限防止数量格式化为 0，上限加1%容差以避免浮点数精度问题）
+	if d.PositionSizeUSD <= 0 {
+		return fmt.Errorf("仓位大小必须大于0: %.2f", d.PositionSizeUSD)
+	}
+	if d.PositionSizeUSD < minPositionSize {
+		return fmt.Errorf("开仓金额过小(%.2f USDT)，必须≥%.2f USDT（交易所最小名义价值和数量精度要求）", d.PositionSizeUSD, minPositionSize)
+	}
+	maxPositionValue := v.equity * maxEquityMultiple
+	if existingValue+d.PositionSizeUSD > maxPositionValue*1.01 {
+		if existingValue > 0 {
+			return fmt.Errorf("单币种仓位价值不能超过%.0f USDT（%g倍账户净值），已有%.0f + 加仓%.0f", maxPositionValue, maxEquityMultiple, existingValue, d.PositionSizeUSD)
+		}
+		return fmt.Errorf("单币种仓位价值不能超过%.0f USDT（%g倍账户净值），实际: %.0f", maxPositionValue, maxEquityMultiple, d.PositionSizeUSD)
+	}
+
+	if d.StopLoss <= 0 || d.TakeProfit <= 0 {
+		return fmt.Errorf("止损和止盈必须大于0")
+	}
+	if long && d.StopLoss >= d.TakeProfit {
+		return fmt.Errorf("做多时止损价必须小于止盈价")
+	}
+	if !long && d.StopLoss <= d.TakeProfit {
+		return fmt.Errorf("做空时止损价必须大于止盈价")
+	}
+
+	// 风险回报比按当前标记价计算；没有行情时退回止损止盈之间20%位置的假设入场价
+	data := v.marketData[d.Symbol]
+	entryPrice := referencePrice(data)
+	entryLabel := "标记价"
+	hasPrice := entryPrice > 0
+	if hasPrice {
+		if long && (d.StopLoss >= entryPrice || d.TakeProfit <= entryPrice) {
+			return fmt.Errorf("做多时止损价(%.6g)必须低于、止盈价(%.6g)必须高于当前标记价(%.6g)", d.StopLoss, d.TakeProfit, entryPrice)
+		}
+		if !long && (d.StopLoss <= entryPrice || d.TakeProfit >= entryPrice) {
+			return fmt.Errorf("做空时止损价(%.6g)必须高于、止盈价(%.6g)必须低于当前标记价(%.6g)", d.StopLoss, d.TakeProfit, entryPrice)
+		}
+	} else {
+		entryPrice = d.StopLoss + (d.TakeProfit-d.StopLoss)*0.2
+		entryLabel = "假设入场价"
+	}
+
+	risk := math.Abs(entryPrice - d.StopLoss)
+	reward := math.Abs(d.TakeProfit - entryPrice)
+	riskRewardRatio := reward / risk
+	if riskRewardRatio < p.MinRiskReward {
+		return fmt.Errorf("风险回报比过低(%.2f:1)，必须≥%.2f:1 [%s:%.6g 风险:%.2f%% 收益:%.2f%%] [止损:%.6g 止盈:%.6g]",
+			riskRewardRatio, p.MinRiskReward, entryLabel, entryPrice, risk/entryPrice*100, reward/entryPrice*100, d.StopLoss, d.TakeProfit)
+	}
+
+	if hasPrice {
+		return v.checkStopDistance(data, entryPrice, risk)
+	}
+	return nil
+}
+
+// referencePrice 校验使用的当前价格：交易所标记价，缺少时退回最新K线收盘价
+func referencePrice(data *market.Data) float64 {
+	if data == nil {
+		return 0
+	}
+	if data.MarkPrice > 0 {
+		return data.MarkPrice
+	}
+	if data.CurrentPrice > 0 {
+		log.Printf("⚠️  %s 缺少标记价格，使用最新K线收盘价 %.6g 校验", data.Symbol, data.CurrentPrice)
+	}
+	return data.CurrentPrice
+}
+
+// checkStopDistance 止损距离（距当前标记价）须在配置范围内；按ATR计算但缺少ATR时跳过
+func (v *decisionValidator) checkStopDistance(data *market.Data, price, risk float64) error {
+	p := v.profile
+	if p.MinStopDistance == 0 && p.MaxStopDistance == 0 {
+		return nil
+	}
+
+	distance := risk / price * 100
+	format := "%.2f%%"
+	if p.StopDistanceUnit == StopDistanceATR {
+		atr := stopDistanceATR(data)
+		if atr <= 0 {
+			log.Printf("⚠️  %s 缺少ATR数据，跳过止损距离检查", data.Symbol)
+			return nil
+		}
+		distance = risk / atr
+		format = "%.2f ATR"
+	}
+
+	if distance < p.MinStopDistance {
+		return fmt.Errorf("止损距离过近("+format+")，必须≥"+format, distance, p.MinStopDistance)
+	}
+	if p.MaxStopDistance > 0 && distance > p.MaxStopDistance {
+		return fmt.Errorf("止损距离过远("+format+")，必须≤"+format, distance, p.MaxStopDistance)
+	}
+	return nil
+}
+
+// stopDistanceATR 止损距离使用的ATR14：优先最长周期，其次日内周期
+func stopDistanceATR(data *market.Data) float64 {
+	if n := len(data.Timeframes); n > 0 {
+		if tf := data.Timeframes[n-1]; tf != nil && tf.Context != nil && tf.Context.ATR14 > 0 {
+			return tf.Context.ATR14
+		}
+	}
+	if data.LongerTermContext != nil && data.LongerTermContext.ATR14 > 0 {
+		return data.LongerTermContext.ATR14
+	}
+	if data.IntradaySeries != nil {
+		return data.IntradaySeries.ATR14
+	}
+	return 0
+}
@@ -0,0 +1,20 @@
+package hook
+
+import "log"
+
+type NewsResult struct {
+	Err  error
+	News []string // 新闻摘要，按重要性排序
+}
+
+func (r *NewsResult) Error() error {
+	if r.Err != nil {
+		log.Printf("⚠️ 执行GetNews时出错: %v", r.Err)
+	}
+	return r.Err
+}
+
+func (r *NewsResult) GetResult() []string {
+	r.Error()
+	return r.News
+}
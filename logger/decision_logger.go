@@ -1,19 +1,26 @@
 package logger
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math"
+	"nofx/market"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// marketSnapshotDir 市场数据快照子目录（与决策记录分开存放，避免被当作决策记录读取）
+const marketSnapshotDir = "snapshots"
+
 // DecisionRecord 决策记录
 type DecisionRecord struct {
 	Timestamp      time.Time          `json:"timestamp"`       // 决策时间
 	CycleNumber    int                `json:"cycle_number"`    // 周期编号
+	Trigger        string             `json:"trigger"`         // 触发原因（scheduled 为定时周期，否则为触发事件列表）
 	SystemPrompt   string             `json:"system_prompt"`   // 系统提示词（发送给AI的系统prompt）
 	InputPrompt    string             `json:"input_prompt"`    // 发送给AI的输入prompt
 	CoTTrace       string             `json:"cot_trace"`       // AI思维链（输出）
@@ -27,6 +34,56 @@ type DecisionRecord struct {
 	ErrorMessage   string             `json:"error_message"`   // 错误信息（如果有）
 	// AIRequestDurationMs 记录 AI API 调用耗时（毫秒），方便评估调用性能
 	AIRequestDurationMs int64 `json:"ai_request_duration_ms,omitempty"`
+	// FundingPayments 自上个周期以来交易所结算的资金费，AnalyzePerformance 按时间归属到对应交易
+	FundingPayments []FundingPayment `json:"funding_payments,omitempty"`
+	// UserPromptLayout 生成输入prompt使用的布局（name@vN）
+	UserPromptLayout string `json:"user_prompt_layout,omitempty"`
+	// PromptTemplate / PromptTemplateHash 系统提示词模板及其版本哈希，CustomPromptHash 自定义prompt的版本哈希
+	PromptTemplate     string `json:"prompt_template,omitempty"`
+	PromptTemplateHash string `json:"prompt_template_hash,omitempty"`
+	CustomPromptHash   string `json:"custom_prompt_hash,omitempty"`
+	// PromptTokens / PromptTokenBudget 估算的输入token数和预算，PromptCuts 为满足预算对输入prompt做的裁剪
+	PromptTokens      int      `json:"prompt_tokens,omitempty"`
+	PromptTokenBudget int      `json:"prompt_token_budget,omitempty"`
+	PromptCuts        []string `json:"prompt_cuts,omitempty"`
+	// PipelineStages 多阶段决策流水线各阶段的记录（未启用流水线时为空）
+	PipelineStages []PipelineStage `json:"pipeline_stages,omitempty"`
+	// RepairAttempts AI输出未通过解析或校验时的自我修正记录（无需修正时为空）
+	RepairAttempts []RepairAttempt `json:"repair_attempts,omitempty"`
+	// Rejections 修正后仍未通过校验、未执行的决策及原因
+	Rejections []string `json:"rejections,omitempty"`
+	// MarketSnapshotFile 本周期发送给AI的结构化市场数据快照文件名（gzip压缩，见 LoadMarketSnapshot）
+	MarketSnapshotFile string `json:"market_snapshot_file,omitempty"`
+
+	// MarketData 本周期发送给AI的市场数据，LogDecision 时写入快照文件，不写入决策记录本身
+	MarketData map[string]*market.Data `json:"-"`
+}
+
+// PipelineStage 决策流水线单个阶段（初筛/分析/复核）的提示词、输出和耗时
+type PipelineStage struct {
+	Stage        string `json:"stage"`
+	Model        string `json:"model"`
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	UserPrompt   string `json:"user_prompt,omitempty"`
+	Output       string `json:"output,omitempty"`
+	DurationMs   int64  `json:"duration_ms"`
+	Error        string `json:"error,omitempty"`
+}
+
+// RepairAttempt 一次自我修正：发给模型的错误、模型修正后的输出和结果（fixed / invalid / call_failed）
+type RepairAttempt struct {
+	Attempt    int    `json:"attempt"`
+	Error      string `json:"error"`
+	Output     string `json:"output,omitempty"`
+	Outcome    string `json:"outcome"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// MarketSnapshot 决策周期的市场数据快照（用于审计AI看到的数据，或用新模板重新渲染提示词）
+type MarketSnapshot struct {
+	Timestamp   time.Time               `json:"timestamp"`
+	CycleNumber int                     `json:"cycle_number"`
+	MarketData  map[string]*market.Data `json:"market_data"`
 }
 
 // AccountSnapshot 账户状态快照
@@ -50,17 +107,28 @@ type PositionSnapshot struct {
 	LiquidationPrice float64 `json:"liquidation_price"`
 }
 
+// FundingPayment 资金费流水
+type FundingPayment struct {
+	Symbol string    `json:"symbol"`
+	Side   string    `json:"side,omitempty"` // 归属的持仓方向，为空表示同步时已无持仓（按当时持有的仓位归属）
+	Amount float64   `json:"amount"`         // USDT，正数为收取，负数为支付
+	Time   time.Time `json:"time"`
+}
+
 // DecisionAction 决策动作
 type DecisionAction struct {
-	Action    string    `json:"action"`    // open_long, open_short, close_long, close_short, update_stop_loss, update_take_profit, partial_close
-	Symbol    string    `json:"symbol"`    // 币种
-	Quantity  float64   `json:"quantity"`  // 数量（部分平仓时使用）
-	Leverage  int       `json:"leverage"`  // 杠杆（开仓时）
-	Price     float64   `json:"price"`     // 执行价格
-	OrderID   int64     `json:"order_id"`  // 订单ID
-	Timestamp time.Time `json:"timestamp"` // 执行时间
-	Success   bool      `json:"success"`   // 是否成功
-	Error     string    `json:"error"`     // 错误信息
+	Action    string    `json:"action"`         // open_long, open_short, close_long, close_short, update_stop_loss, update_take_profit, update_sl_tp, partial_close, add_to_position, reverse
+	Symbol    string    `json:"symbol"`         // 币种
+	Side      string    `json:"side,omitempty"` // 执行后的持仓方向（add_to_position、reverse、update_sl_tp 记录）
+	Quantity  float64   `json:"quantity"`       // 数量（部分平仓时使用）
+	Leverage  int       `json:"leverage"`       // 杠杆（开仓时）
+	Price     float64   `json:"price"`          // 执行价格
+	OrderID   int64     `json:"order_id"`       // 订单ID
+	Timestamp time.Time `json:"timestamp"`      // 执行时间
+	Success   bool      `json:"success"`        // 是否成功
+	Error     string    `json:"error"`          // 错误信息
+	// Warning 执行警告（如开仓时资金费率不利），不影响执行结果
+	Warning string `json:"warning,omitempty"`
 }
 
 // DecisionLogger 决策日志记录器
@@ -102,6 +170,20 @@ func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
 		record.Timestamp.Format("20060102_150405"),
 		record.CycleNumber)
 
+	// 先写市场数据快照，记录中保存快照文件名（快照失败不影响决策记录）
+	if len(record.MarketData) > 0 {
+		snapshotFile := strings.TrimSuffix(filename, ".json") + ".market.json.gz"
+		if err := l.writeMarketSnapshot(snapshotFile, &MarketSnapshot{
+			Timestamp:   record.Timestamp,
+			CycleNumber: record.CycleNumber,
+			MarketData:  record.MarketData,
+		}); err != nil {
+			fmt.Printf("⚠ 保存市场数据快照失败: %v\n", err)
+		} else {
+			record.MarketSnapshotFile = snapshotFile
+		}
+	}
+
 	filepath := filepath.Join(l.logDir, filename)
 
 	// 序列化为JSON（带缩进，方便阅读）
@@ -119,6 +201,56 @@ func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
 	return nil
 }
 
+// writeMarketSnapshot 写入gzip压缩的市场数据快照
+func (l *DecisionLogger) writeMarketSnapshot(name string, snapshot *MarketSnapshot) error {
+	dir := filepath.Join(l.logDir, marketSnapshotDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("创建快照目录失败: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("创建快照文件失败: %w", err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	if err := json.NewEncoder(gz).Encode(snapshot); err != nil {
+		gz.Close()
+		return fmt.Errorf("序列化市场数据快照失败: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("压缩市场数据快照失败: %w", err)
+	}
+	return nil
+}
+
+// LoadMarketSnapshot 读取决策记录的市场数据快照（name 为 DecisionRecord.MarketSnapshotFile）
+func (l *DecisionLogger) LoadMarketSnapshot(name string) (*MarketSnapshot, error) {
+	name = filepath.Base(name) // 只允许读取快照目录下的文件
+	if !strings.HasSuffix(name, ".market.json.gz") {
+		return nil, fmt.Errorf("无效的快照文件名: %q", name)
+	}
+
+	file, err := os.Open(filepath.Join(l.logDir, marketSnapshotDir, name))
+	if err != nil {
+		return nil, fmt.Errorf("打开市场数据快照失败: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("解压市场数据快照失败: %w", err)
+	}
+	defer gz.Close()
+
+	var snapshot MarketSnapshot
+	if err := json.NewDecoder(gz).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("解析市场数据快照失败: %w", err)
+	}
+	return &snapshot, nil
+}
+
 // GetLatestRecords 获取最近N条记录（按时间正序：从旧到新）
 func (l *DecisionLogger) GetLatestRecords(n int) ([]*DecisionRecord, error) {
 	files, err := ioutil.ReadDir(l.logDir)
@@ -211,6 +343,19 @@ func (l *DecisionLogger) CleanOldRecords(days int) error {
 		}
 	}
 
+	// 同步清理市场数据快照
+	snapshotDir := filepath.Join(l.logDir, marketSnapshotDir)
+	if snapshots, err := ioutil.ReadDir(snapshotDir); err == nil {
+		for _, file := range snapshots {
+			if file.IsDir() || !file.ModTime().Before(cutoffTime) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(snapshotDir, file.Name())); err != nil {
+				fmt.Printf("⚠ 删除旧快照失败 %s: %v\n", file.Name(), err)
+			}
+		}
+	}
+
 	if removedCount > 0 {
 		fmt.Printf("🗑️ 已清理 %d 条旧记录（%d天前）\n", removedCount, days)
 	}
@@ -250,6 +395,10 @@ func (l *DecisionLogger) GetStatistics() (*Statistics, error) {
 				switch action.Action {
 				case "open_long", "open_short":
 					stats.TotalOpenPositions++
+				case "reverse":
+					// 反手 = 平掉原仓位 + 开反方向仓位（加仓不算新开仓）
+					stats.TotalOpenPositions++
+					stats.TotalClosePositions++
 				case "close_long", "close_short", "auto_close_long", "auto_close_short":
 					stats.TotalClosePositions++
 					// 🔧 BUG FIX：partial_close 不計入 TotalClosePositions，避免重複計數
@@ -294,6 +443,7 @@ type TradeOutcome struct {
 	OpenTime      time.Time `json:"open_time"`      // 开仓时间
 	CloseTime     time.Time `json:"close_time"`     // 平仓时间
 	WasStopLoss   bool      `json:"was_stop_loss"`  // 是否止损
+	FundingFee    float64   `json:"funding_fee"`    // 持仓期间的资金费（正数为收取，负数为支付），已计入 PnL
 }
 
 // PerformanceAnalysis 交易表现分析
@@ -310,6 +460,7 @@ type PerformanceAnalysis struct {
 	SymbolStats   map[string]*SymbolPerformance `json:"symbol_stats"`   // 各币种表现
 	BestSymbol    string                        `json:"best_symbol"`    // 表现最好的币种
 	WorstSymbol   string                        `json:"worst_symbol"`   // 表现最差的币种
+	TotalFunding  float64                       `json:"total_funding"`  // 已平仓交易的资金费合计
 }
 
 // SymbolPerformance 币种表现统计
@@ -323,6 +474,100 @@ type SymbolPerformance struct {
 	AvgPnL        float64 `json:"avg_pn_l"`       // 平均盈亏
 }
 
+// pairingActions 交易配对使用的操作：反手拆成平掉原仓位和开反方向仓位（同一价格和时间），其余原样保留
+func pairingActions(actions []DecisionAction) []DecisionAction {
+	expanded := make([]DecisionAction, 0, len(actions))
+	for _, action := range actions {
+		if action.Action != "reverse" || (action.Side != "long" && action.Side != "short") {
+			expanded = append(expanded, action)
+			continue
+		}
+		closedSide := "long"
+		if action.Side == "long" {
+			closedSide = "short"
+		}
+		closeAction := action
+		closeAction.Action = "close_" + closedSide
+		closeAction.Side = closedSide
+		openAction := action
+		openAction.Action = "open_" + action.Side
+		expanded = append(expanded, closeAction, openAction)
+	}
+	return expanded
+}
+
+// attributeFundingPayment 把资金费计入对应持仓：记录了方向时只归属该方向，
+// 否则按名义价值拆分到结算时已开仓的各方向（旧记录没有方向）
+func attributeFundingPayment(openPositions map[string]map[string]interface{}, payment FundingPayment) {
+	sides := []string{"long", "short"}
+	if payment.Side != "" {
+		sides = []string{payment.Side}
+	}
+
+	held := make(map[string]map[string]interface{})
+	total := 0.0
+	for _, side := range sides {
+		openPos, exists := openPositions[payment.Symbol+"_"+side]
+		if !exists {
+			continue
+		}
+		if openTime, _ := openPos["openTime"].(time.Time); payment.Time.Before(openTime) {
+			continue
+		}
+		held[side] = openPos
+		quantity, _ := openPos["quantity"].(float64)
+		openPrice, _ := openPos["openPrice"].(float64)
+		total += quantity * openPrice
+	}
+
+	for _, openPos := range held {
+		share := payment.Amount / float64(len(held))
+		if total > 0 {
+			quantity, _ := openPos["quantity"].(float64)
+			openPrice, _ := openPos["openPrice"].(float64)
+			share = payment.Amount * quantity * openPrice / total
+		}
+		fundingFee, _ := openPos["fundingFee"].(float64)
+		openPos["fundingFee"] = fundingFee + share
+	}
+}
+
+// scaleIntoPosition 加仓：按剩余数量和加仓数量加权平均开仓价，累加仓位数量；没有开仓记录时作为新开仓
+func scaleIntoPosition(openPositions map[string]map[string]interface{}, posKey string, action DecisionAction) {
+	openPos, exists := openPositions[posKey]
+	if !exists {
+		openPositions[posKey] = map[string]interface{}{
+			"side":              action.Side,
+			"openPrice":         action.Price,
+			"openTime":          action.Timestamp,
+			"quantity":          action.Quantity,
+			"leverage":          action.Leverage,
+			"remainingQuantity": action.Quantity,
+		}
+		return
+	}
+
+	openPrice := openPos["openPrice"].(float64)
+	quantity := openPos["quantity"].(float64)
+	remainingQty, _ := openPos["remainingQuantity"].(float64)
+	if remainingQty == 0 {
+		remainingQty = quantity // 兼容舊數據（沒有 remainingQuantity 字段）
+	}
+	if total := remainingQty + action.Quantity; total > 0 {
+		openPos["openPrice"] = (openPrice*remainingQty + action.Price*action.Quantity) / total
+	}
+	openPos["quantity"] = quantity + action.Quantity
+	openPos["remainingQuantity"] = remainingQty + action.Quantity
+	if action.Leverage > 0 {
+		openPos["leverage"] = action.Leverage
+	}
+}
+
+// isPartialCloseAction 是否为部分平仓（AI的 partial_close 或强平保护的自动减仓）
+func isPartialCloseAction(action string) bool {
+	return action == "partial_close" || action == "auto_reduce_long" || action == "auto_reduce_short"
+}
+
 // AnalyzePerformance 分析最近N个周期的交易表现
 func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAnalysis, error) {
 	records, err := l.GetLatestRecords(lookbackCycles)
@@ -349,21 +594,25 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 	// 获取更多历史记录来构建完整的持仓状态（使用更大的窗口）
 	allRecords, err := l.GetLatestRecords(lookbackCycles * 3) // 扩大3倍窗口
 	if err == nil && len(allRecords) > len(records) {
-		// 先从扩大的窗口中收集所有开仓记录
-		for _, record := range allRecords {
-			for _, action := range record.Decisions {
+		// 先从分析窗口之前的记录中收集未平仓的持仓（窗口内的记录由下面的循环处理，避免加仓被重复累加）
+		for _, record := range allRecords[:len(allRecords)-len(records)] {
+			for _, action := range pairingActions(record.Decisions) {
 				if !action.Success {
 					continue
 				}
 
 				symbol := action.Symbol
 				side := ""
-				if action.Action == "open_long" || action.Action == "close_long" || action.Action == "partial_close" || action.Action == "auto_close_long" {
+				if action.Action == "open_long" || action.Action == "close_long" || action.Action == "partial_close" || action.Action == "auto_close_long" || action.Action == "auto_reduce_long" {
 					side = "long"
-				} else if action.Action == "open_short" || action.Action == "close_short" || action.Action == "auto_close_short" {
+				} else if action.Action == "open_short" || action.Action == "close_short" || action.Action == "auto_close_short" || action.Action == "auto_reduce_short" {
 					side = "short"
 				}
 
+				if action.Action == "add_to_position" {
+					side = action.Side
+				}
+
 				// partial_close 需要根據持倉判斷方向
 				if action.Action == "partial_close" && side == "" {
 					for key, pos := range openPositions {
@@ -386,6 +635,8 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 						"quantity":  action.Quantity,
 						"leverage":  action.Leverage,
 					}
+				case "add_to_position":
+					scaleIntoPosition(openPositions, posKey, action)
 				case "close_long", "close_short", "auto_close_long", "auto_close_short":
 					// 移除已平仓记录
 					delete(openPositions, posKey)
@@ -397,18 +648,26 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 
 	// 遍历分析窗口内的记录，生成交易结果
 	for _, record := range records {
-		for _, action := range record.Decisions {
+		// 资金费在周期开始时拉取，早于本周期的决策，先归属到当时仍持有的仓位
+		for _, payment := range record.FundingPayments {
+			attributeFundingPayment(openPositions, payment)
+		}
+
+		for _, action := range pairingActions(record.Decisions) {
 			if !action.Success {
 				continue
 			}
 
 			symbol := action.Symbol
 			side := ""
-			if action.Action == "open_long" || action.Action == "close_long" || action.Action == "partial_close" || action.Action == "auto_close_long" {
+			if action.Action == "open_long" || action.Action == "close_long" || action.Action == "partial_close" || action.Action == "auto_close_long" || action.Action == "auto_reduce_long" {
 				side = "long"
-			} else if action.Action == "open_short" || action.Action == "close_short" || action.Action == "auto_close_short" {
+			} else if action.Action == "open_short" || action.Action == "close_short" || action.Action == "auto_close_short" || action.Action == "auto_reduce_short" {
 				side = "short"
 			}
+			if action.Action == "add_to_position" {
+				side = action.Side
+			}
 
 			// partial_close 需要根據持倉判斷方向
 			if action.Action == "partial_close" {
@@ -438,7 +697,11 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					"partialCloseVolume": 0.0,             // 🔧 BUG FIX：部分平倉總量
 				}
 
-			case "close_long", "close_short", "partial_close", "auto_close_long", "auto_close_short":
+			case "add_to_position":
+				// 加仓：加权平均开仓价，保留已累积的部分平仓盈亏
+				scaleIntoPosition(openPositions, posKey, action)
+
+			case "close_long", "close_short", "partial_close", "auto_close_long", "auto_close_short", "auto_reduce_long", "auto_reduce_short":
 				// 查找对应的开仓记录（可能来自预填充或当前窗口）
 				if openPos, exists := openPositions[posKey]; exists {
 					openPrice := openPos["openPrice"].(float64)
@@ -456,9 +719,9 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					partialCloseCount, _ := openPos["partialCloseCount"].(int)
 					partialCloseVolume, _ := openPos["partialCloseVolume"].(float64)
 
-					// 对于 partial_close，使用实际平仓数量；否则使用剩余仓位数量
+					// 对于 partial_close（含强平保护的自动减仓），使用实际平仓数量；否则使用剩余仓位数量
 					actualQuantity := remainingQty
-					if action.Action == "partial_close" {
+					if isPartialCloseAction(action.Action) {
 						actualQuantity = action.Quantity
 					}
 
@@ -471,7 +734,7 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					}
 
 					// 🔧 BUG FIX：處理 partial_close 聚合邏輯
-					if action.Action == "partial_close" {
+					if isPartialCloseAction(action.Action) {
 						// 累積盈虧和數量
 						accumulatedPnL += pnl
 						remainingQty -= actualQuantity
@@ -486,7 +749,9 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 
 						// 判斷是否已完全平倉
 						if remainingQty <= 0.0001 { // 使用小閾值避免浮點誤差
-							// ✅ 完全平倉：記錄為一筆完整交易
+							// ✅ 完全平倉：記錄為一筆完整交易（計入持倉期間的資金費）
+							fundingFee, _ := openPos["fundingFee"].(float64)
+							accumulatedPnL += fundingFee
 							positionValue := quantity * openPrice
 							marginUsed := positionValue / float64(leverage)
 							pnlPct := 0.0
@@ -508,9 +773,11 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 								Duration:      action.Timestamp.Sub(openTime).String(),
 								OpenTime:      openTime,
 								CloseTime:     action.Timestamp,
+								FundingFee:    fundingFee,
 							}
 
 							analysis.RecentTrades = append(analysis.RecentTrades, outcome)
+							analysis.TotalFunding += fundingFee
 							analysis.TotalTrades++ // 🔧 只在完全平倉時計數
 
 							// 分类交易
@@ -544,8 +811,9 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 
 					} else {
 						// 🔧 完全平倉（close_long/close_short/auto_close）
-						// 如果之前有部分平倉，需要加上累積的 PnL
-						totalPnL := accumulatedPnL + pnl
+						// 如果之前有部分平倉，需要加上累積的 PnL，以及持倉期間的資金費
+						fundingFee, _ := openPos["fundingFee"].(float64)
+						totalPnL := accumulatedPnL + pnl + fundingFee
 
 						positionValue := quantity * openPrice
 						marginUsed := positionValue / float64(leverage)
@@ -568,9 +836,11 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 							Duration:      action.Timestamp.Sub(openTime).String(),
 							OpenTime:      openTime,
 							CloseTime:     action.Timestamp,
+							FundingFee:    fundingFee,
 						}
 
 						analysis.RecentTrades = append(analysis.RecentTrades, outcome)
+						analysis.TotalFunding += fundingFee
 						analysis.TotalTrades++
 
 						// 分类交易
@@ -736,3 +1006,79 @@ func (l *DecisionLogger) calculateSharpeRatio(records []*DecisionRecord) float64
 	sharpeRatio := meanReturn / stdDev
 	return sharpeRatio
 }
+
+// PromptVersionPerformance 某个提示词版本生效期间的表现
+type PromptVersionPerformance struct {
+	Hash             string    `json:"hash"`
+	Cycles           int       `json:"cycles"`            // 使用该版本的决策周期数
+	SuccessfulCycles int       `json:"successful_cycles"` // 成功完成的周期数
+	OpenActions      int       `json:"open_actions"`      // 成功执行的开仓次数
+	CloseActions     int       `json:"close_actions"`     // 成功执行的平仓次数（含部分平仓）
+	EquityChange     float64   `json:"equity_change"`     // 该版本生效期间的净值变化（USDT，按周期累加到下个周期的净值）
+	EquityChangePct  float64   `json:"equity_change_pct"` // 各周期净值变化百分比之和
+	FirstUsed        time.Time `json:"first_used"`
+	LastUsed         time.Time `json:"last_used"`
+}
+
+// AnalyzePromptVersion 统计使用指定提示词版本（模板哈希或自定义prompt哈希）的周期表现
+func (l *DecisionLogger) AnalyzePromptVersion(hash string) (*PromptVersionPerformance, error) {
+	records, err := l.GetLatestRecords(10000)
+	if err != nil {
+		return nil, fmt.Errorf("读取历史记录失败: %w", err)
+	}
+
+	perf := &PromptVersionPerformance{Hash: hash}
+	for i, record := range records {
+		if record.PromptTemplateHash != hash && record.CustomPromptHash != hash {
+			continue
+		}
+
+		perf.Cycles++
+		if record.Success {
+			perf.SuccessfulCycles++
+		}
+		if perf.FirstUsed.IsZero() {
+			perf.FirstUsed = record.Timestamp
+		}
+		perf.LastUsed = record.Timestamp
+
+		for _, action := range record.Decisions {
+			if !action.Success {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(action.Action, "open_"):
+				perf.OpenActions++
+			case strings.HasPrefix(action.Action, "close_"), isPartialCloseAction(action.Action), strings.HasPrefix(action.Action, "auto_close_"):
+				perf.CloseActions++
+			}
+		}
+
+		// 本周期决策的结果体现在下个周期的净值上
+		if i+1 < len(records) {
+			before := record.AccountState.TotalBalance
+			after := records[i+1].AccountState.TotalBalance
+			if before > 0 && after > 0 {
+				perf.EquityChange += after - before
+				perf.EquityChangePct += (after - before) / before * 100
+			}
+		}
+	}
+	return perf, nil
+}
+
+// Add 合并多个交易员同一版本的表现
+func (p *PromptVersionPerformance) Add(other *PromptVersionPerformance) {
+	p.Cycles += other.Cycles
+	p.SuccessfulCycles += other.SuccessfulCycles
+	p.OpenActions += other.OpenActions
+	p.CloseActions += other.CloseActions
+	p.EquityChange += other.EquityChange
+	p.EquityChangePct += other.EquityChangePct
+	if !other.FirstUsed.IsZero() && (p.FirstUsed.IsZero() || other.FirstUsed.Before(p.FirstUsed)) {
+		p.FirstUsed = other.FirstUsed
+	}
+	if other.LastUsed.After(p.LastUsed) {
+		p.LastUsed = other.LastUsed
+	}
+}
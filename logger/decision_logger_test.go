@@ -0,0 +1,234 @@
+package logger
+
+import (
+	"math"
+	"nofx/market"
+	"testing"
+	"time"
+)
+
+// TestAnalyzePerformanceFundingAttribution 测试资金费按时间归属到对应交易并计入盈亏
+func TestAnalyzePerformanceFundingAttribution(t *testing.T) {
+	l := NewDecisionLogger(t.TempDir())
+	openTime := time.Now().Add(-24 * time.Hour)
+
+	records := []*DecisionRecord{
+		{Decisions: []DecisionAction{{
+			Action: "open_long", Symbol: "BTCUSDT", Quantity: 0.1, Leverage: 10,
+			Price: 50000, Timestamp: openTime, Success: true,
+		}}},
+		{FundingPayments: []FundingPayment{
+			{Symbol: "BTCUSDT", Amount: -0.5, Time: openTime.Add(8 * time.Hour)},
+			{Symbol: "BTCUSDT", Amount: -0.2, Time: openTime.Add(-time.Hour)},   // 开仓之前，不归属
+			{Symbol: "ETHUSDT", Amount: 1.0, Time: openTime.Add(8 * time.Hour)}, // 无持仓，不归属
+		}},
+		{
+			FundingPayments: []FundingPayment{{Symbol: "BTCUSDT", Amount: -0.3, Time: openTime.Add(16 * time.Hour)}},
+			Decisions: []DecisionAction{{
+				Action: "close_long", Symbol: "BTCUSDT", Price: 51000,
+				Timestamp: openTime.Add(20 * time.Hour), Success: true,
+			}},
+		},
+	}
+	for _, record := range records {
+		if err := l.LogDecision(record); err != nil {
+			t.Fatalf("LogDecision: %v", err)
+		}
+	}
+
+	analysis, err := l.AnalyzePerformance(10)
+	if err != nil {
+		t.Fatalf("AnalyzePerformance: %v", err)
+	}
+	if len(analysis.RecentTrades) != 1 {
+		t.Fatalf("RecentTrades = %d, want 1", len(analysis.RecentTrades))
+	}
+
+	trade := analysis.RecentTrades[0]
+	if math.Abs(trade.FundingFee-(-0.8)) > 1e-9 {
+		t.Errorf("FundingFee = %v, want -0.8", trade.FundingFee)
+	}
+	// 价格盈亏 0.1 × 1000 = 100，扣除资金费 0.8
+	if math.Abs(trade.PnL-99.2) > 1e-9 {
+		t.Errorf("PnL = %v, want 99.2", trade.PnL)
+	}
+	if math.Abs(analysis.TotalFunding-(-0.8)) > 1e-9 {
+		t.Errorf("TotalFunding = %v, want -0.8", analysis.TotalFunding)
+	}
+}
+
+// TestAnalyzePerformanceFundingBySide 测试双向持仓时资金费按记录的方向归属，无方向的旧记录按名义价值拆分
+func TestAnalyzePerformanceFundingBySide(t *testing.T) {
+	l := NewDecisionLogger(t.TempDir())
+	openTime := time.Now().Add(-24 * time.Hour)
+
+	records := []*DecisionRecord{
+		{Decisions: []DecisionAction{
+			{Action: "open_long", Symbol: "BTCUSDT", Quantity: 0.3, Leverage: 10, Price: 50000, Timestamp: openTime, Success: true},
+			{Action: "open_short", Symbol: "BTCUSDT", Quantity: 0.1, Leverage: 10, Price: 50000, Timestamp: openTime, Success: true},
+		}},
+		{FundingPayments: []FundingPayment{
+			{Symbol: "BTCUSDT", Side: "short", Amount: -0.4, Time: openTime.Add(8 * time.Hour)},
+			{Symbol: "BTCUSDT", Amount: -0.2, Time: openTime.Add(8 * time.Hour)}, // 旧记录，按名义价值 3:1 拆分
+		}},
+		{Decisions: []DecisionAction{
+			{Action: "close_long", Symbol: "BTCUSDT", Price: 50000, Timestamp: openTime.Add(10 * time.Hour), Success: true},
+			{Action: "close_short", Symbol: "BTCUSDT", Price: 50000, Timestamp: openTime.Add(10 * time.Hour), Success: true},
+		}},
+	}
+	for _, record := range records {
+		if err := l.LogDecision(record); err != nil {
+			t.Fatalf("LogDecision: %v", err)
+		}
+	}
+
+	analysis, err := l.AnalyzePerformance(10)
+	if err != nil {
+		t.Fatalf("AnalyzePerformance: %v", err)
+	}
+	want := map[string]float64{"long": -0.15, "short": -0.45}
+	if len(analysis.RecentTrades) != 2 {
+		t.Fatalf("RecentTrades = %d, want 2", len(analysis.RecentTrades))
+	}
+	for _, trade := range analysis.RecentTrades {
+		if math.Abs(trade.FundingFee-want[trade.Side]) > 1e-9 {
+			t.Errorf("%s FundingFee = %v, want %v", trade.Side, trade.FundingFee, want[trade.Side])
+		}
+	}
+}
+
+// TestAnalyzePerformanceScaleInAndReverse 测试加仓按加权均价合并到原仓位，反手拆成平仓和反向开仓
+func TestAnalyzePerformanceScaleInAndReverse(t *testing.T) {
+	l := NewDecisionLogger(t.TempDir())
+	start := time.Now().Add(-24 * time.Hour)
+
+	actions := []DecisionAction{
+		{Action: "open_long", Symbol: "BTCUSDT", Quantity: 0.1, Leverage: 10, Price: 50000, Timestamp: start, Success: true},
+		{Action: "add_to_position", Symbol: "BTCUSDT", Side: "long", Quantity: 0.1, Leverage: 10, Price: 52000,
+			Timestamp: start.Add(time.Hour), Success: true},
+		{Action: "reverse", Symbol: "BTCUSDT", Side: "short", Quantity: 0.2, Leverage: 10, Price: 53000,
+			Timestamp: start.Add(2 * time.Hour), Success: true},
+		{Action: "close_short", Symbol: "BTCUSDT", Price: 52000, Timestamp: start.Add(3 * time.Hour), Success: true},
+	}
+	for _, action := range actions {
+		if err := l.LogDecision(&DecisionRecord{Decisions: []DecisionAction{action}}); err != nil {
+			t.Fatalf("LogDecision: %v", err)
+		}
+	}
+
+	analysis, err := l.AnalyzePerformance(10)
+	if err != nil {
+		t.Fatalf("AnalyzePerformance: %v", err)
+	}
+	if len(analysis.RecentTrades) != 2 {
+		t.Fatalf("RecentTrades = %d, want 2", len(analysis.RecentTrades))
+	}
+
+	// 最新的在前：反手开的空仓 0.2 × (53000 - 52000) = 200
+	short, long := analysis.RecentTrades[0], analysis.RecentTrades[1]
+	if short.Side != "short" || math.Abs(short.PnL-200) > 1e-6 {
+		t.Errorf("short trade = %s PnL %v, want short PnL 200", short.Side, short.PnL)
+	}
+	// 加仓后均价 51000，数量 0.2，反手价 53000 平仓：0.2 × 2000 = 400
+	if long.Side != "long" || math.Abs(long.OpenPrice-51000) > 1e-6 || math.Abs(long.Quantity-0.2) > 1e-9 {
+		t.Errorf("long trade = %s open %v qty %v, want long open 51000 qty 0.2", long.Side, long.OpenPrice, long.Quantity)
+	}
+	if math.Abs(long.PnL-400) > 1e-6 || math.Abs(long.ClosePrice-53000) > 1e-6 {
+		t.Errorf("long trade PnL %v close %v, want PnL 400 close 53000", long.PnL, long.ClosePrice)
+	}
+}
+
+// TestMarketSnapshotRoundTrip 测试市场数据快照压缩保存、随记录引用并可读回，且不影响记录读取
+func TestMarketSnapshotRoundTrip(t *testing.T) {
+	l := NewDecisionLogger(t.TempDir())
+
+	record := &DecisionRecord{
+		MarketData: map[string]*market.Data{
+			"BTCUSDT": {
+				Symbol:       "BTCUSDT",
+				CurrentPrice: 65000.5,
+				FundingRate:  0.0001,
+				OpenInterest: &market.OIData{Latest: 12345},
+			},
+		},
+	}
+	if err := l.LogDecision(record); err != nil {
+		t.Fatalf("LogDecision: %v", err)
+	}
+	if record.MarketSnapshotFile == "" {
+		t.Fatal("MarketSnapshotFile 未设置")
+	}
+	if err := l.LogDecision(&DecisionRecord{}); err != nil { // 无市场数据时不写快照
+		t.Fatalf("LogDecision: %v", err)
+	}
+
+	records, err := l.GetLatestRecords(10)
+	if err != nil {
+		t.Fatalf("GetLatestRecords: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("记录数量 = %d，期望 2（快照不应被当作记录读取）", len(records))
+	}
+	if records[0].MarketSnapshotFile != record.MarketSnapshotFile || records[1].MarketSnapshotFile != "" {
+		t.Fatalf("快照文件名 = %q / %q", records[0].MarketSnapshotFile, records[1].MarketSnapshotFile)
+	}
+
+	snapshot, err := l.LoadMarketSnapshot(records[0].MarketSnapshotFile)
+	if err != nil {
+		t.Fatalf("LoadMarketSnapshot: %v", err)
+	}
+	btc := snapshot.MarketData["BTCUSDT"]
+	if snapshot.CycleNumber != 1 || btc == nil || btc.CurrentPrice != 65000.5 || btc.OpenInterest == nil || btc.OpenInterest.Latest != 12345 {
+		t.Fatalf("快照内容不一致: %+v", snapshot)
+	}
+
+	if _, err := l.LoadMarketSnapshot("../" + records[0].MarketSnapshotFile + ".json"); err == nil {
+		t.Fatal("非快照文件名应返回错误")
+	}
+}
+
+// TestAnalyzePromptVersion 测试按提示词版本哈希统计周期与净值变化
+func TestAnalyzePromptVersion(t *testing.T) {
+	l := NewDecisionLogger(t.TempDir())
+
+	records := []*DecisionRecord{
+		{PromptTemplateHash: "v1", Success: true, AccountState: AccountSnapshot{TotalBalance: 1000},
+			Decisions: []DecisionAction{{Action: "open_long", Symbol: "BTCUSDT", Success: true}}},
+		{PromptTemplateHash: "v1", Success: false, AccountState: AccountSnapshot{TotalBalance: 1100}},
+		{PromptTemplateHash: "v2", CustomPromptHash: "c1", Success: true, AccountState: AccountSnapshot{TotalBalance: 1050},
+			Decisions: []DecisionAction{{Action: "close_long", Symbol: "BTCUSDT", Success: true}}},
+		{PromptTemplateHash: "v2", Success: true, AccountState: AccountSnapshot{TotalBalance: 1071}},
+	}
+	for _, record := range records {
+		if err := l.LogDecision(record); err != nil {
+			t.Fatalf("LogDecision: %v", err)
+		}
+	}
+
+	v1, err := l.AnalyzePromptVersion("v1")
+	if err != nil {
+		t.Fatalf("AnalyzePromptVersion: %v", err)
+	}
+	if v1.Cycles != 2 || v1.SuccessfulCycles != 1 || v1.OpenActions != 1 || v1.CloseActions != 0 {
+		t.Errorf("v1 统计 = %+v", v1)
+	}
+	// 1000→1100→1050，两个周期均归属 v1
+	if math.Abs(v1.EquityChange-50) > 1e-9 {
+		t.Errorf("v1 EquityChange = %v, want 50", v1.EquityChange)
+	}
+
+	c1, err := l.AnalyzePromptVersion("c1")
+	if err != nil {
+		t.Fatalf("AnalyzePromptVersion: %v", err)
+	}
+	if c1.Cycles != 1 || c1.CloseActions != 1 || math.Abs(c1.EquityChange-21) > 1e-9 || math.Abs(c1.EquityChangePct-2) > 1e-9 {
+		t.Errorf("c1 统计 = %+v", c1)
+	}
+
+	// 最后一个周期还没有结果，不计入净值变化
+	v2, _ := l.AnalyzePromptVersion("v2")
+	v2.Add(v1)
+	if v2.Cycles != 4 || math.Abs(v2.EquityChange-71) > 1e-9 {
+		t.Errorf("合并后统计 = %+v", v2)
+	}
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	stdlog "log"
 	"nofx/config"
 	"os"
 
@@ -193,6 +194,26 @@ func Errorf(format string, args ...interface{}) {
 	Log.Errorf(format, args...)
 }
 
+// Alertf 发送风控告警
+// 以Error级别记录，启用Telegram推送时会同步推送；logger未初始化时退化为标准日志输出
+func Alertf(format string, args ...interface{}) {
+	if Log == nil {
+		stdlog.Printf("🚨 [ALERT] "+format, args...)
+		return
+	}
+	Log.Errorf("🚨 [ALERT] "+format, args...)
+}
+
+// Notifyf 发送通知（如市场警报）
+// 以Warn级别记录，Telegram最低推送级别为warn或更低时会推送；logger未初始化时退化为标准日志输出
+func Notifyf(format string, args ...interface{}) {
+	if Log == nil {
+		stdlog.Printf("📣 "+format, args...)
+		return
+	}
+	Log.Warnf("📣 "+format, args...)
+}
+
 func Fatal(args ...interface{}) {
 	Log.Fatal(args...)
 }
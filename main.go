@@ -8,6 +8,7 @@ import (
 	"nofx/auth"
 	"nofx/config"
 	"nofx/crypto"
+	"nofx/logger"
 	"nofx/manager"
 	"nofx/market"
 	"nofx/pool"
@@ -200,6 +201,9 @@ func main() {
 		log.Printf("⚠️  加载内测码到数据库失败: %v", err)
 	}
 
+	// 记录系统提示词模板版本（模板文件变化时生成新版本）
+	api.RecordPromptTemplateVersions(database)
+
 	// 获取系统配置
 	useDefaultCoinsStr, _ := database.GetSystemConfig("use_default_coins")
 	useDefaultCoins := useDefaultCoinsStr == "true"
@@ -350,6 +354,13 @@ func main() {
 	// 启动流行情数据 - 默认使用所有交易员设置的币种 如果没有设置币种 则优先使用系统默认
 	go market.NewWSMonitor(150).Start(database.GetCustomCoins())
 	//go market.NewWSMonitor(150).Start([]string{}) //这里是一个使用方式 传入空的话 则使用market市场的所有币种
+	// 市场警报推送到日志（配置Telegram时按 min_level 推送），只通知运行中交易员持有或关注的币种，避免全市场警报刷屏
+	market.AddAlertListener(func(alert market.Alert) {
+		if !traderManager.IsSymbolWatched(alert.Symbol) {
+			return
+		}
+		logger.Notifyf("[市场警报] %s", alert.Message)
+	})
 	// 设置优雅退出
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -0,0 +1,361 @@
+package manager
+
+import (
+	"fmt"
+	"math"
+	"nofx/config"
+	"nofx/logger"
+	"sort"
+	"time"
+)
+
+const (
+	// experimentSignificance 显著性水平（双侧）
+	experimentSignificance = 0.05
+	// experimentMinSamples 至少需要的配对收益样本数，样本不足时不判定显著性
+	experimentMinSamples = 20
+	// experimentAlignInterval 对齐各变体净值曲线的时间粒度（取每个时间段内最后一个净值）
+	experimentAlignInterval = time.Hour
+)
+
+// ExperimentReport 实验对比报告
+type ExperimentReport struct {
+	ExperimentID   string                     `json:"experiment_id"`
+	Name           string                     `json:"name"`
+	Status         string                     `json:"status"`
+	InitialBalance float64                    `json:"initial_balance"`
+	StartedAt      *time.Time                 `json:"started_at,omitempty"`
+	StoppedAt      *time.Time                 `json:"stopped_at,omitempty"`
+	Control        string                     `json:"control"` // 对照组（第一个变体）
+	Variants       []*ExperimentVariantReport `json:"variants"`
+	Winner         string                     `json:"winner,omitempty"` // 显著优于对照组且平均周期收益最高的变体
+	Warnings       []string                   `json:"warnings,omitempty"`
+}
+
+// ExperimentVariantReport 单个变体在实验期间的表现
+type ExperimentVariantReport struct {
+	config.ExperimentVariant
+	Cycles             int                `json:"cycles"`
+	StartEquity        float64            `json:"start_equity"`
+	EndEquity          float64            `json:"end_equity"`
+	ReturnPct          float64            `json:"return_pct"`
+	MaxDrawdownPct     float64            `json:"max_drawdown_pct"`
+	MeanCycleReturnPct float64            `json:"mean_cycle_return_pct"`
+	StdCycleReturnPct  float64            `json:"std_cycle_return_pct"`
+	TotalTrades        int                `json:"total_trades"`
+	WinRate            float64            `json:"win_rate"`
+	ProfitFactor       float64            `json:"profit_factor"`
+	SharpeRatio        float64            `json:"sharpe_ratio"`
+	TotalPnL           float64            `json:"total_pnl"`
+	VsControl          *VariantComparison `json:"vs_control,omitempty"` // 对照组本身为空
+	Error              string             `json:"error,omitempty"`
+
+	cycleReturns []float64
+	equityCurve  []equityPoint
+}
+
+// equityPoint 净值曲线上的一个点
+type equityPoint struct {
+	Time   time.Time
+	Equity float64
+}
+
+// VariantComparison 变体与对照组按时间对齐后的收益比较（配对 t 检验）
+type VariantComparison struct {
+	MeanDiffPct float64 `json:"mean_diff_pct"` // 同一时间段收益差的均值（变体 - 对照组）
+	Samples     int     `json:"samples"`       // 配对样本数
+	TStat       float64 `json:"t_stat"`
+	DF          float64 `json:"df"`
+	PValue      float64 `json:"p_value"`
+	Significant bool    `json:"significant"`
+	Note        string  `json:"note,omitempty"`
+}
+
+// BuildExperimentReport 汇总实验各变体自启动以来的表现，并与对照组做显著性检验
+func (tm *TraderManager) BuildExperimentReport(exp *config.Experiment) (*ExperimentReport, error) {
+	if len(exp.Variants) < 2 {
+		return nil, fmt.Errorf("实验至少需要两个变体")
+	}
+
+	report := &ExperimentReport{
+		ExperimentID:   exp.ID,
+		Name:           exp.Name,
+		Status:         exp.Status,
+		InitialBalance: exp.InitialBalance,
+		StartedAt:      exp.StartedAt,
+		StoppedAt:      exp.StoppedAt,
+		Control:        exp.Variants[0].Label,
+	}
+	if exp.StartedAt == nil {
+		report.Warnings = append(report.Warnings, "实验尚未启动")
+	}
+
+	exchanges := make(map[string]string)
+	for _, variant := range exp.Variants {
+		vr := &ExperimentVariantReport{ExperimentVariant: variant}
+		report.Variants = append(report.Variants, vr)
+
+		if other, shared := exchanges[variant.ExchangeID]; shared {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("变体 %s 与 %s 使用同一交易所账户，持仓和余额会相互影响", variant.Label, other))
+		} else {
+			exchanges[variant.ExchangeID] = variant.Label
+		}
+
+		trader, err := tm.GetTrader(variant.TraderID)
+		if err != nil {
+			vr.Error = err.Error()
+			continue
+		}
+		if exp.StartedAt == nil {
+			continue
+		}
+		if err := fillVariantReport(vr, trader.GetDecisionLogger(), *exp.StartedAt, exp.StoppedAt); err != nil {
+			vr.Error = err.Error()
+		}
+	}
+
+	control := report.Variants[0]
+	var best *ExperimentVariantReport
+	for _, vr := range report.Variants[1:] {
+		variantReturns, controlReturns := alignedReturns(vr.equityCurve, control.equityCurve, experimentAlignInterval)
+		vr.VsControl = compareCycleReturns(variantReturns, controlReturns)
+		if vr.VsControl.Significant && vr.VsControl.MeanDiffPct > 0 && (best == nil || vr.MeanCycleReturnPct > best.MeanCycleReturnPct) {
+			best = vr
+		}
+	}
+	if best != nil {
+		report.Winner = best.Label
+	}
+	return report, nil
+}
+
+// fillVariantReport 从决策日志统计实验期间（启动之后、停止之前）的净值曲线和交易表现
+func fillVariantReport(vr *ExperimentVariantReport, decisionLogger *logger.DecisionLogger, startedAt time.Time, stoppedAt *time.Time) error {
+	records, err := decisionLogger.GetLatestRecords(10000)
+	if err != nil {
+		return fmt.Errorf("读取决策记录失败: %w", err)
+	}
+
+	sinceStart := 0
+	var equity []float64
+	var curve []equityPoint
+	for _, record := range records {
+		if record.Timestamp.Before(startedAt) {
+			continue
+		}
+		sinceStart++
+		if stoppedAt != nil && record.Timestamp.After(*stoppedAt) {
+			continue
+		}
+		if record.AccountState.TotalBalance > 0 {
+			equity = append(equity, record.AccountState.TotalBalance)
+			curve = append(curve, equityPoint{Time: record.Timestamp, Equity: record.AccountState.TotalBalance})
+		}
+	}
+	vr.Cycles = len(equity)
+	vr.equityCurve = curve
+	if len(equity) == 0 {
+		return nil
+	}
+
+	vr.StartEquity = equity[0]
+	vr.EndEquity = equity[len(equity)-1]
+	vr.ReturnPct = (vr.EndEquity - vr.StartEquity) / vr.StartEquity * 100
+
+	peak := equity[0]
+	for i, e := range equity {
+		if e > peak {
+			peak = e
+		}
+		if drawdown := (peak - e) / peak * 100; drawdown > vr.MaxDrawdownPct {
+			vr.MaxDrawdownPct = drawdown
+		}
+		if i > 0 {
+			vr.cycleReturns = append(vr.cycleReturns, (e-equity[i-1])/equity[i-1]*100)
+		}
+	}
+	vr.MeanCycleReturnPct, vr.StdCycleReturnPct = meanStd(vr.cycleReturns)
+
+	// 只分析启动之后的周期，避免基础配置或实验前的交易混入
+	analysis, err := decisionLogger.AnalyzePerformance(sinceStart)
+	if err != nil {
+		return fmt.Errorf("分析交易表现失败: %w", err)
+	}
+	vr.TotalTrades = analysis.TotalTrades
+	vr.WinRate = analysis.WinRate
+	vr.ProfitFactor = analysis.ProfitFactor
+	vr.SharpeRatio = analysis.SharpeRatio
+	for _, stats := range analysis.SymbolStats {
+		vr.TotalPnL += stats.TotalPnL
+	}
+	return nil
+}
+
+// alignedReturns 把两条净值曲线按固定时间粒度对齐（取每段最后一个净值），
+// 返回两者都有数据的相邻时间段之间的收益率（%），两组结果一一对应
+func alignedReturns(variant, control []equityPoint, interval time.Duration) (variantReturns, controlReturns []float64) {
+	if len(variant) == 0 || len(control) == 0 {
+		return nil, nil
+	}
+	origin := variant[0].Time
+	if control[0].Time.Before(origin) {
+		origin = control[0].Time
+	}
+	bucketize := func(curve []equityPoint) map[int64]float64 {
+		buckets := make(map[int64]float64)
+		for _, p := range curve {
+			buckets[int64(p.Time.Sub(origin)/interval)] = p.Equity
+		}
+		return buckets
+	}
+	variantBuckets, controlBuckets := bucketize(variant), bucketize(control)
+
+	var common []int64
+	for bucket := range variantBuckets {
+		if _, ok := controlBuckets[bucket]; ok {
+			common = append(common, bucket)
+		}
+	}
+	sort.Slice(common, func(i, j int) bool { return common[i] < common[j] })
+
+	for i := 1; i < len(common); i++ {
+		prev, cur := common[i-1], common[i]
+		variantReturns = append(variantReturns, (variantBuckets[cur]-variantBuckets[prev])/variantBuckets[prev]*100)
+		controlReturns = append(controlReturns, (controlBuckets[cur]-controlBuckets[prev])/controlBuckets[prev]*100)
+	}
+	return variantReturns, controlReturns
+}
+
+// compareCycleReturns 对按时间对齐的两组收益做配对 t 检验（双侧）
+// 同一时间段的收益共享同一市场行情，配对后只检验策略差异，避免行情波动掩盖或放大差别
+func compareCycleReturns(variant, control []float64) *VariantComparison {
+	cmp := &VariantComparison{}
+	if len(variant) != len(control) {
+		cmp.PValue = 1
+		cmp.Note = "收益序列未对齐，无法检验"
+		return cmp
+	}
+
+	diffs := make([]float64, len(variant))
+	for i := range variant {
+		diffs[i] = variant[i] - control[i]
+	}
+	meanDiff, stdDiff := meanStd(diffs)
+	cmp.MeanDiffPct = meanDiff
+	cmp.Samples = len(diffs)
+
+	if len(diffs) < 2 {
+		cmp.PValue = 1
+		cmp.Note = "样本不足，无法检验"
+		return cmp
+	}
+	if stdDiff == 0 {
+		cmp.PValue = 1
+		cmp.Note = "收益差无波动，无法检验"
+		return cmp
+	}
+
+	cmp.TStat = meanDiff / (stdDiff / math.Sqrt(float64(len(diffs))))
+	cmp.DF = float64(len(diffs) - 1)
+	cmp.PValue = studentTTwoSidedP(cmp.TStat, cmp.DF)
+
+	if len(diffs) < experimentMinSamples {
+		cmp.Note = fmt.Sprintf("配对样本不足 %d 个，结果仅供参考", experimentMinSamples)
+		return cmp
+	}
+	cmp.Significant = cmp.PValue < experimentSignificance
+	return cmp
+}
+
+// meanStd 均值和样本标准差
+func meanStd(values []float64) (mean, std float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var sumSq float64
+	for _, v := range values {
+		sumSq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sumSq / float64(len(values)-1))
+}
+
+// studentTTwoSidedP t 分布双侧 p 值：P(|T| > |t|) = I_{df/(df+t²)}(df/2, 1/2)
+func studentTTwoSidedP(t, df float64) float64 {
+	return regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// regularizedIncompleteBeta 正则化不完全Beta函数 I_x(a, b)（连分式展开）
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+
+	// 连分式在 x < (a+1)/(a+b+2) 时收敛较快，否则使用对称关系
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(a, b, x) / a
+	}
+	return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+}
+
+// betaContinuedFraction 不完全Beta函数的连分式（修正 Lentz 算法）
+func betaContinuedFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 200
+		epsilon       = 1e-12
+		tiny          = 1e-300
+	)
+
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		// 偶数项
+		num := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		// 奇数项
+		num = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStudentTTwoSidedP(t *testing.T) {
+	tests := []struct {
+		t, df, want float64
+	}{
+		{t: 0, df: 10, want: 1},
+		{t: 2.0, df: 10, want: 0.07339},
+		{t: -2.228, df: 10, want: 0.05},
+		{t: 1.96, df: 1e6, want: 0.05},
+		{t: 12.706, df: 1, want: 0.05},
+	}
+	for _, tt := range tests {
+		if got := studentTTwoSidedP(tt.t, tt.df); math.Abs(got-tt.want) > 5e-4 {
+			t.Errorf("studentTTwoSidedP(%v, %v) = %.5f, want %.5f", tt.t, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestCompareCycleReturns(t *testing.T) {
+	control := make([]float64, 30)
+	better := make([]float64, 30)
+	noisy := make([]float64, 30)
+	for i := range control {
+		wiggle := float64(i%5-2) * 0.1
+		jitter := float64(i%3-1) * 0.1
+		control[i] = wiggle
+		better[i] = 0.5 + wiggle + jitter
+		noisy[i] = 0.02 + wiggle + jitter
+	}
+
+	if cmp := compareCycleReturns(better, control); !cmp.Significant || math.Abs(cmp.MeanDiffPct-0.5) > 1e-9 || cmp.Samples != 30 {
+		t.Errorf("明显更优的变体应显著: %+v", cmp)
+	}
+	if cmp := compareCycleReturns(noisy, control); cmp.Significant {
+		t.Errorf("微小差异不应显著: %+v", cmp)
+	}
+	if cmp := compareCycleReturns(better[:5], control[:5]); cmp.Significant || cmp.Note == "" {
+		t.Errorf("样本不足时不应判定显著: %+v", cmp)
+	}
+	if cmp := compareCycleReturns(better, control[:10]); cmp.Significant || cmp.Note == "" {
+		t.Errorf("未对齐的序列不应判定显著: %+v", cmp)
+	}
+}
+
+func TestAlignedReturns(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int, equity float64) equityPoint {
+		return equityPoint{Time: start.Add(time.Duration(minutes) * time.Minute), Equity: equity}
+	}
+	// 变体每30分钟一个周期，对照组每60分钟一个周期，且对照组缺少第3个小时的数据
+	variant := []equityPoint{at(0, 100), at(30, 101), at(60, 102), at(90, 103), at(120, 104), at(150, 105), at(180, 106)}
+	control := []equityPoint{at(5, 1000), at(65, 1010), at(185, 1030)}
+
+	variantReturns, controlReturns := alignedReturns(variant, control, time.Hour)
+	wantVariant := []float64{(103.0 - 101.0) / 101.0 * 100, (106.0 - 103.0) / 103.0 * 100}
+	wantControl := []float64{1, (1030.0 - 1010.0) / 1010.0 * 100}
+	if len(variantReturns) != 2 || len(controlReturns) != 2 {
+		t.Fatalf("期望2个配对收益, got %v / %v", variantReturns, controlReturns)
+	}
+	for i := range wantVariant {
+		if math.Abs(variantReturns[i]-wantVariant[i]) > 1e-9 || math.Abs(controlReturns[i]-wantControl[i]) > 1e-9 {
+			t.Errorf("第%d个配对收益 = (%v, %v), want (%v, %v)", i, variantReturns[i], controlReturns[i], wantVariant[i], wantControl[i])
+		}
+	}
+
+	if v, c := alignedReturns(nil, control, time.Hour); v != nil || c != nil {
+		t.Errorf("空曲线应返回nil")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"nofx/config"
+	"nofx/decision"
+	"nofx/market"
 	"nofx/trader"
 	"sort"
 	"strconv"
@@ -239,7 +241,21 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		DefaultCoins:          defaultCoins,
 		TradingCoins:          tradingCoins,
 		SystemPromptTemplate:  traderCfg.SystemPromptTemplate, // 系统提示词模板
-	}
+		ExitPolicies:          parseExitPolicies(traderCfg),
+		LiquidationGuard:      parseLiquidationGuard(traderCfg),
+		FundingGuard:          parseFundingGuard(traderCfg),
+		Timeframes:            parseTimeframes(traderCfg),
+		MaxDepthPct:           traderCfg.MaxDepthPct,
+		AlertTriggers:         traderCfg.AlertTriggers,
+		EventTriggers:         parseEventTriggers(traderCfg),
+		PromptVariables:       parsePromptVariables(traderCfg),
+		UserPromptLayout:      traderCfg.UserPromptLayout,
+		Reflection:            parseReflection(traderCfg),
+		ValidationProfile:     parseValidationProfile(traderCfg),
+	}
+
+	// 多阶段决策流水线（初筛/复核模型从用户的AI模型配置中查找）
+	traderConfig.Pipeline, traderConfig.PipelineScreener, traderConfig.PipelineReviewer = parseDecisionPipeline(traderCfg, database, userID)
 
 	// 根据交易所类型设置API密钥
 	if exchangeCfg.ID == "binance" {
@@ -283,6 +299,122 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 	return nil
 }
 
+// parseExitPolicies 解析交易员的利润保护策略，配置无效时回退到默认策略
+func parseExitPolicies(traderCfg *config.TraderRecord) []trader.ExitPolicy {
+	policies, err := trader.ParseExitPolicies(traderCfg.ExitPolicies)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的利润保护策略无效，使用默认策略: %v", traderCfg.Name, err)
+		return trader.DefaultExitPolicies()
+	}
+	return policies
+}
+
+// parseLiquidationGuard 解析交易员的强平距离保护配置，配置无效时回退到默认配置
+func parseLiquidationGuard(traderCfg *config.TraderRecord) *trader.LiquidationGuardConfig {
+	guard, err := trader.ParseLiquidationGuard(traderCfg.LiquidationGuard)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的强平保护配置无效，使用默认配置: %v", traderCfg.Name, err)
+		guard = trader.DefaultLiquidationGuard()
+	}
+	return &guard
+}
+
+// parseFundingGuard 解析交易员的开仓资金费检查配置，配置无效时回退到默认配置
+func parseFundingGuard(traderCfg *config.TraderRecord) *trader.FundingGuardConfig {
+	guard, err := trader.ParseFundingGuard(traderCfg.FundingGuard)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的资金费检查配置无效，使用默认配置: %v", traderCfg.Name, err)
+		guard = trader.DefaultFundingGuard()
+	}
+	return &guard
+}
+
+// parseEventTriggers 解析交易员的事件触发配置，配置无效时回退到默认配置
+func parseEventTriggers(traderCfg *config.TraderRecord) *trader.EventTriggerConfig {
+	triggers, err := trader.ParseEventTriggers(traderCfg.EventTriggers)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的事件触发配置无效，使用默认配置: %v", traderCfg.Name, err)
+		triggers = trader.DefaultEventTriggers()
+	}
+	return &triggers
+}
+
+// parseReflection 解析交易员的交易反思配置，配置无效时回退到默认配置
+func parseReflection(traderCfg *config.TraderRecord) *trader.ReflectionConfig {
+	reflection, err := trader.ParseReflection(traderCfg.Reflection)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的反思配置无效，使用默认配置: %v", traderCfg.Name, err)
+		reflection = trader.DefaultReflection()
+	}
+	return &reflection
+}
+
+// parseValidationProfile 解析交易员的决策校验规则，配置无效时回退到默认规则
+func parseValidationProfile(traderCfg *config.TraderRecord) *decision.ValidationProfile {
+	profile, err := decision.ParseValidationProfile(traderCfg.ValidationProfile)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的校验规则无效，使用默认规则: %v", traderCfg.Name, err)
+		profile = decision.DefaultValidationProfile()
+	}
+	return &profile
+}
+
+// parsePromptVariables 解析交易员的系统提示词模板变量，配置无效时不使用自定义变量
+func parsePromptVariables(traderCfg *config.TraderRecord) map[string]string {
+	vars, err := decision.ParsePromptVariables(traderCfg.PromptVariables)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的模板变量配置无效，已忽略: %v", traderCfg.Name, err)
+		return nil
+	}
+	return vars
+}
+
+// parseDecisionPipeline 解析交易员的决策流水线配置并查找初筛/复核模型，配置无效时不启用流水线
+func parseDecisionPipeline(traderCfg *config.TraderRecord, database *config.Database, userID string) (*decision.PipelineConfig, *trader.PipelineModel, *trader.PipelineModel) {
+	cfg, err := decision.ParsePipelineConfig(traderCfg.DecisionPipeline)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的决策流水线配置无效，已忽略: %v", traderCfg.Name, err)
+		return nil, nil, nil
+	}
+	if cfg == nil || !cfg.Enabled {
+		return cfg, nil, nil
+	}
+
+	models, err := database.GetAIModels(userID)
+	if err != nil {
+		log.Printf("⚠️ 获取AI模型配置失败，流水线各阶段沿用主模型: %v", err)
+		return cfg, nil, nil
+	}
+	lookup := func(id string) *trader.PipelineModel {
+		if id == "" {
+			return nil
+		}
+		for _, m := range models {
+			if m.ID == id && m.Enabled && m.APIKey != "" {
+				return &trader.PipelineModel{
+					Provider:        m.Provider,
+					APIKey:          m.APIKey,
+					CustomAPIURL:    m.CustomAPIURL,
+					CustomModelName: m.CustomModelName,
+				}
+			}
+		}
+		log.Printf("⚠️ 交易员 %s 的流水线模型 %s 不可用，沿用主模型", traderCfg.Name, id)
+		return nil
+	}
+	return cfg, lookup(cfg.ScreenerModelID), lookup(cfg.ReviewerModelID)
+}
+
+// parseTimeframes 解析交易员的K线周期配置，配置无效时回退到默认周期
+func parseTimeframes(traderCfg *config.TraderRecord) []string {
+	timeframes, err := market.ParseTimeframes(traderCfg.Timeframes)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的K线周期配置无效，使用默认周期: %v", traderCfg.Name, err)
+		return nil
+	}
+	return timeframes
+}
+
 // AddTrader 从数据库配置添加trader (移除旧版兼容性)
 
 // AddTraderFromDB 从数据库配置添加trader
@@ -345,7 +477,21 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		IsCrossMargin:         traderCfg.IsCrossMargin,
 		DefaultCoins:          defaultCoins,
 		TradingCoins:          tradingCoins,
-	}
+		ExitPolicies:          parseExitPolicies(traderCfg),
+		LiquidationGuard:      parseLiquidationGuard(traderCfg),
+		FundingGuard:          parseFundingGuard(traderCfg),
+		Timeframes:            parseTimeframes(traderCfg),
+		MaxDepthPct:           traderCfg.MaxDepthPct,
+		AlertTriggers:         traderCfg.AlertTriggers,
+		EventTriggers:         parseEventTriggers(traderCfg),
+		PromptVariables:       parsePromptVariables(traderCfg),
+		UserPromptLayout:      traderCfg.UserPromptLayout,
+		Reflection:            parseReflection(traderCfg),
+		ValidationProfile:     parseValidationProfile(traderCfg),
+	}
+
+	// 多阶段决策流水线（初筛/复核模型从用户的AI模型配置中查找）
+	traderConfig.Pipeline, traderConfig.PipelineScreener, traderConfig.PipelineReviewer = parseDecisionPipeline(traderCfg, database, userID)
 
 	// 根据交易所类型设置API密钥
 	if exchangeCfg.ID == "binance" {
@@ -413,6 +559,19 @@ func (tm *TraderManager) GetAllTraders() map[string]*trader.AutoTrader {
 	return result
 }
 
+// IsSymbolWatched 是否有运行中的trader持有或关注该币种
+func (tm *TraderManager) IsSymbolWatched(symbol string) bool {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	for _, t := range tm.traders {
+		if t.IsWatchingSymbol(symbol) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetTraderIDs 获取所有trader ID列表
 func (tm *TraderManager) GetTraderIDs() []string {
 	tm.mu.RLock()
@@ -1043,7 +1202,21 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		TradingCoins:         tradingCoins,
 		SystemPromptTemplate: traderCfg.SystemPromptTemplate, // 系统提示词模板
 		HyperliquidTestnet:   exchangeCfg.Testnet,            // Hyperliquid测试网
-	}
+		ExitPolicies:         parseExitPolicies(traderCfg),
+		LiquidationGuard:     parseLiquidationGuard(traderCfg),
+		FundingGuard:         parseFundingGuard(traderCfg),
+		Timeframes:           parseTimeframes(traderCfg),
+		MaxDepthPct:          traderCfg.MaxDepthPct,
+		AlertTriggers:        traderCfg.AlertTriggers,
+		EventTriggers:        parseEventTriggers(traderCfg),
+		PromptVariables:      parsePromptVariables(traderCfg),
+		UserPromptLayout:     traderCfg.UserPromptLayout,
+		Reflection:           parseReflection(traderCfg),
+		ValidationProfile:    parseValidationProfile(traderCfg),
+	}
+
+	// 多阶段决策流水线（初筛/复核模型从用户的AI模型配置中查找）
+	traderConfig.Pipeline, traderConfig.PipelineScreener, traderConfig.PipelineReviewer = parseDecisionPipeline(traderCfg, database, userID)
 
 	// 根据交易所类型设置API密钥
 	if exchangeCfg.ID == "binance" {
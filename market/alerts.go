@@ -0,0 +1,301 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 警报类型
+const (
+	AlertVolumeSpike   = "volume_spike"   // 成交量突增（当前K线成交量 / 前20根均量）
+	AlertPriceChange   = "price_change"   // 15分钟价格变化超过阈值
+	AlertVolumeTrend   = "volume_trend"   // 成交量趋势放大（近5根均量 / 近20根均量）
+	AlertRSIOverbought = "rsi_overbought" // RSI14 超买
+	AlertRSIOversold   = "rsi_oversold"   // RSI14 超卖
+)
+
+const (
+	alertCooldown    = 15 * time.Minute // 同一币种同类警报的最短间隔，避免指标持续越界时反复推送
+	maxAlertHistory  = 500              // 保留的最近警报数量（供API查询）
+	minFeatureKlines = 21               // 计算特征所需的最少K线数量
+)
+
+var (
+	alertListenersMu sync.RWMutex
+	alertListeners   = make(map[int]AlertListener)
+	nextAlertID      int
+
+	alertHistoryMu sync.RWMutex
+	alertHistory   []Alert // 按时间顺序，最多 maxAlertHistory 条
+)
+
+// AlertListener 警报回调（在警报分发goroutine中调用，实现方不应阻塞）
+type AlertListener func(alert Alert)
+
+// AddAlertListener 注册警报监听器，返回取消注册的函数（与监控器是否启动无关）
+func AddAlertListener(listener AlertListener) func() {
+	alertListenersMu.Lock()
+	defer alertListenersMu.Unlock()
+
+	id := nextAlertID
+	nextAlertID++
+	alertListeners[id] = listener
+
+	return func() {
+		alertListenersMu.Lock()
+		defer alertListenersMu.Unlock()
+		delete(alertListeners, id)
+	}
+}
+
+// RecentAlerts 最近的警报（从新到旧），symbol 为空时返回所有币种
+func RecentAlerts(symbol string, limit int) []Alert {
+	alertHistoryMu.RLock()
+	defer alertHistoryMu.RUnlock()
+
+	symbol = strings.ToUpper(symbol)
+	var alerts []Alert
+	for i := len(alertHistory) - 1; i >= 0 && (limit <= 0 || len(alerts) < limit); i-- {
+		if symbol == "" || alertHistory[i].Symbol == symbol {
+			alerts = append(alerts, alertHistory[i])
+		}
+	}
+	return alerts
+}
+
+// GetFeatures 获取币种最近一根已收盘K线的特征（未计算时返回nil）
+func (m *WSMonitor) GetFeatures(symbol string) *SymbolFeatures {
+	value, ok := m.featuresMap.Load(strings.ToUpper(symbol))
+	if !ok {
+		return nil
+	}
+	features := *value.(*SymbolFeatures)
+	return &features
+}
+
+// onKlineClosed K线收盘时计算特征并检查警报
+func (m *WSMonitor) onKlineClosed(symbol string, klines []Kline, interval string) {
+	features := computeFeatures(symbol, klines, interval)
+	if features == nil {
+		return
+	}
+	m.featuresMap.Store(symbol, features)
+
+	now := features.Timestamp
+	value, _ := m.symbolStats.LoadOrStore(symbol, &SymbolStats{})
+	stats := value.(*SymbolStats)
+	stats.LastActiveTime = now
+
+	for _, alert := range checkAlerts(features, config.AlertThresholds) {
+		key := symbol + "|" + alert.Type
+		if last, ok := m.alertCooldowns.Load(key); ok && now.Sub(last.(time.Time)) < alertCooldown {
+			continue
+		}
+		m.alertCooldowns.Store(key, now)
+
+		stats.AlertCount++
+		stats.LastAlertTime = now
+		if alert.Type == AlertVolumeSpike {
+			stats.VolumeSpikeCount++
+		}
+
+		select {
+		case m.alertsChan <- alert:
+		default:
+			log.Printf("⚠️ 警报通道已满，丢弃 %s", alert.Message)
+		}
+	}
+}
+
+// dispatchAlerts 记录警报并分发给所有监听器（alertsChan 关闭后退出）
+func (m *WSMonitor) dispatchAlerts() {
+	for alert := range m.alertsChan {
+		recordAlert(alert)
+
+		alertListenersMu.RLock()
+		for _, listener := range alertListeners {
+			listener(alert)
+		}
+		alertListenersMu.RUnlock()
+	}
+}
+
+func recordAlert(alert Alert) {
+	alertHistoryMu.Lock()
+	defer alertHistoryMu.Unlock()
+
+	alertHistory = append(alertHistory, alert)
+	if over := len(alertHistory) - maxAlertHistory; over > 0 {
+		alertHistory = append(alertHistory[:0], alertHistory[over:]...)
+	}
+}
+
+// computeFeatures 基于已收盘K线计算特征（价格变化为小数比例，与 AlertThresholds 口径一致）
+func computeFeatures(symbol string, klines []Kline, interval string) *SymbolFeatures {
+	n := len(klines)
+	if n < minFeatureKlines {
+		return nil
+	}
+	last := klines[n-1]
+	features := &SymbolFeatures{
+		Symbol:    symbol,
+		Timestamp: time.UnixMilli(last.CloseTime),
+		Price:     last.Close,
+		Volume:    last.Volume,
+		RSI14:     calculateRSI(klines, 14),
+		SMA5:      closeSMA(klines, 5),
+		SMA10:     closeSMA(klines, 10),
+		SMA20:     closeSMA(klines, 20),
+	}
+	if last.CloseTime == 0 {
+		features.Timestamp = time.Now()
+	}
+
+	if d, ok := intervalDurations[interval]; ok {
+		features.PriceChange15Min = changeOverBars(klines, 15*time.Minute, d)
+		features.PriceChange1H = changeOverBars(klines, time.Hour, d)
+		features.PriceChange4H = changeOverBars(klines, 4*time.Hour, d)
+	}
+
+	// 成交量：当前K线相对之前N根的均量，趋势为近5根均量相对近20根均量
+	if avg := meanVolume(klines[n-6 : n-1]); avg > 0 {
+		features.VolumeRatio5 = last.Volume / avg
+	}
+	if avg := meanVolume(klines[n-21 : n-1]); avg > 0 {
+		features.VolumeRatio20 = last.Volume / avg
+	}
+	if avg20 := meanVolume(klines[n-20:]); avg20 > 0 {
+		features.VolumeTrend = meanVolume(klines[n-5:]) / avg20
+	}
+
+	if last.Low > 0 {
+		features.HighLowRatio = last.High / last.Low
+	}
+
+	// 近20根收益率标准差
+	var returns []float64
+	for i := n - 20; i < n; i++ {
+		if prev := klines[i-1].Close; prev > 0 {
+			returns = append(returns, klines[i].Close/prev-1)
+		}
+	}
+	features.Volatility20 = stdDev(returns)
+
+	// 收盘价在近20根最高/最低区间中的位置（0=最低，1=最高）
+	high, low := klines[n-20].High, klines[n-20].Low
+	for _, k := range klines[n-20:] {
+		high = math.Max(high, k.High)
+		low = math.Min(low, k.Low)
+	}
+	features.PositionInRange = 0.5
+	if high > low {
+		features.PositionInRange = (last.Close - low) / (high - low)
+	}
+	return features
+}
+
+// checkAlerts 按阈值生成警报
+func checkAlerts(f *SymbolFeatures, t AlertThresholds) []Alert {
+	var alerts []Alert
+	add := func(alertType string, value, threshold float64, message string) {
+		alerts = append(alerts, Alert{
+			Type:      alertType,
+			Symbol:    f.Symbol,
+			Value:     value,
+			Threshold: threshold,
+			Message:   message,
+			Timestamp: f.Timestamp,
+		})
+	}
+
+	if t.VolumeSpike > 0 && f.VolumeRatio20 >= t.VolumeSpike {
+		add(AlertVolumeSpike, f.VolumeRatio20, t.VolumeSpike,
+			fmt.Sprintf("%s 成交量突增 %.1f 倍（阈值 %.1f）", f.Symbol, f.VolumeRatio20, t.VolumeSpike))
+	}
+	if t.PriceChange15Min > 0 && math.Abs(f.PriceChange15Min) >= t.PriceChange15Min {
+		add(AlertPriceChange, f.PriceChange15Min, t.PriceChange15Min,
+			fmt.Sprintf("%s 15分钟价格变化 %+.2f%%（阈值 ±%.2f%%）", f.Symbol, f.PriceChange15Min*100, t.PriceChange15Min*100))
+	}
+	if t.VolumeTrend > 0 && f.VolumeTrend >= t.VolumeTrend {
+		add(AlertVolumeTrend, f.VolumeTrend, t.VolumeTrend,
+			fmt.Sprintf("%s 成交量持续放大 %.1f 倍（阈值 %.1f）", f.Symbol, f.VolumeTrend, t.VolumeTrend))
+	}
+	if t.RSIOverbought > 0 && f.RSI14 >= t.RSIOverbought {
+		add(AlertRSIOverbought, f.RSI14, t.RSIOverbought,
+			fmt.Sprintf("%s RSI14 超买 %.1f（阈值 %.0f）", f.Symbol, f.RSI14, t.RSIOverbought))
+	}
+	if t.RSIOversold > 0 && f.RSI14 > 0 && f.RSI14 <= t.RSIOversold {
+		add(AlertRSIOversold, f.RSI14, t.RSIOversold,
+			fmt.Sprintf("%s RSI14 超卖 %.1f（阈值 %.0f）", f.Symbol, f.RSI14, t.RSIOversold))
+	}
+	return alerts
+}
+
+// changeOverBars 计算一段时间内的价格变化比例（K线不足时返回0）
+func changeOverBars(klines []Kline, window, interval time.Duration) float64 {
+	bars := int(window / interval)
+	if bars <= 0 || len(klines) <= bars {
+		return 0
+	}
+	past := klines[len(klines)-1-bars].Close
+	if past <= 0 {
+		return 0
+	}
+	return klines[len(klines)-1].Close/past - 1
+}
+
+func closeSMA(klines []Kline, period int) float64 {
+	if len(klines) < period {
+		return 0
+	}
+	sum := 0.0
+	for _, k := range klines[len(klines)-period:] {
+		sum += k.Close
+	}
+	return sum / float64(period)
+}
+
+func meanVolume(klines []Kline) float64 {
+	if len(klines) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, k := range klines {
+		sum += k.Volume
+	}
+	return sum / float64(len(klines))
+}
+
+func stdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
+
+// FormatAlerts 将警报格式化为提示词段落
+func FormatAlerts(alerts []Alert) string {
+	if len(alerts) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("## 市场警报（上次决策以来）\n")
+	for _, alert := range alerts {
+		sb.WriteString(fmt.Sprintf("- [%s] %s\n", alert.Timestamp.Format("15:04:05"), alert.Message))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
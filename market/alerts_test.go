@@ -0,0 +1,158 @@
+package market
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeFeatureKlines 生成n根3m K线，收盘价从100起每根上涨step，成交量均为100
+func makeFeatureKlines(n int, step float64) []Kline {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	klines := make([]Kline, n)
+	for i := range klines {
+		price := 100 + float64(i)*step
+		openTime := start.Add(time.Duration(i) * 3 * time.Minute)
+		klines[i] = Kline{
+			OpenTime:  openTime.UnixMilli(),
+			Open:      price,
+			High:      price + 1,
+			Low:       price - 1,
+			Close:     price,
+			Volume:    100,
+			CloseTime: openTime.Add(3*time.Minute - time.Millisecond).UnixMilli(),
+		}
+	}
+	return klines
+}
+
+// TestComputeFeatures 测试K线特征计算
+func TestComputeFeatures(t *testing.T) {
+	if f := computeFeatures("BTCUSDT", makeFeatureKlines(minFeatureKlines-1, 1), "3m"); f != nil {
+		t.Fatalf("K线不足时应返回nil")
+	}
+
+	klines := makeFeatureKlines(30, 1)
+	klines[29].Volume = 500 // 最后一根放量
+	f := computeFeatures("BTCUSDT", klines, "3m")
+	if f == nil {
+		t.Fatal("特征不应为nil")
+	}
+
+	approx := func(name string, got, want float64) {
+		t.Helper()
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	approx("Price", f.Price, 129)
+	approx("PriceChange15Min", f.PriceChange15Min, 129.0/124-1) // 3m周期：5根之前
+	approx("PriceChange1H", f.PriceChange1H, 129.0/109-1)       // 20根之前
+	approx("PriceChange4H", f.PriceChange4H, 0)                 // K线不足
+	approx("VolumeRatio20", f.VolumeRatio20, 5)
+	approx("VolumeTrend", f.VolumeTrend, (500.0+400)/5/((500.0+1900)/20))
+	approx("SMA5", f.SMA5, 127)
+	approx("PositionInRange", f.PositionInRange, (129.0-109)/(130-109))
+	if f.RSI14 != 100 {
+		t.Errorf("单边上涨时RSI应为100, got %v", f.RSI14)
+	}
+	if !f.Timestamp.Equal(time.UnixMilli(klines[29].CloseTime)) {
+		t.Errorf("Timestamp 应为最后一根K线收盘时间")
+	}
+}
+
+// TestCheckAlerts 测试警报阈值
+func TestCheckAlerts(t *testing.T) {
+	thresholds := AlertThresholds{VolumeSpike: 3, PriceChange15Min: 0.05, VolumeTrend: 2, RSIOverbought: 70, RSIOversold: 30}
+
+	tests := []struct {
+		name     string
+		features SymbolFeatures
+		want     []string
+	}{
+		{name: "无警报", features: SymbolFeatures{VolumeRatio20: 1, PriceChange15Min: 0.01, VolumeTrend: 1, RSI14: 50}},
+		{name: "成交量突增", features: SymbolFeatures{VolumeRatio20: 3, RSI14: 50}, want: []string{AlertVolumeSpike}},
+		{name: "价格下跌", features: SymbolFeatures{PriceChange15Min: -0.06, RSI14: 50}, want: []string{AlertPriceChange}},
+		{name: "放量超买", features: SymbolFeatures{VolumeTrend: 2.5, RSI14: 75}, want: []string{AlertVolumeTrend, AlertRSIOverbought}},
+		{name: "超卖", features: SymbolFeatures{RSI14: 25}, want: []string{AlertRSIOversold}},
+		{name: "RSI未计算", features: SymbolFeatures{RSI14: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.features.Symbol = "ETHUSDT"
+			alerts := checkAlerts(&tt.features, thresholds)
+			if len(alerts) != len(tt.want) {
+				t.Fatalf("got %d alerts, want %d: %+v", len(alerts), len(tt.want), alerts)
+			}
+			for i, alert := range alerts {
+				if alert.Type != tt.want[i] || alert.Symbol != "ETHUSDT" {
+					t.Errorf("alert[%d] = %s/%s, want %s/ETHUSDT", i, alert.Type, alert.Symbol, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestOnKlineClosedCooldown 测试同类警报冷却与分发
+func TestOnKlineClosedCooldown(t *testing.T) {
+	alertHistory = nil
+	defer func() { alertHistory = nil }()
+
+	m := &WSMonitor{alertsChan: make(chan Alert, 10)}
+	klines := makeFeatureKlines(30, 0)
+	for i := range klines {
+		klines[i].Close += float64(i % 2) // 价格来回波动，RSI居中，只触发成交量突增
+	}
+	klines[29].Volume = 1000
+
+	m.onKlineClosed("BTCUSDT", klines, "3m")
+	m.onKlineClosed("BTCUSDT", klines, "3m") // 冷却期内不重复
+	if len(m.alertsChan) != 1 {
+		t.Fatalf("冷却期内应只产生1条警报, got %d", len(m.alertsChan))
+	}
+
+	// 冷却期过后再次触发
+	later := append([]Kline(nil), klines...)
+	later[29].CloseTime += alertCooldown.Milliseconds()
+	m.onKlineClosed("BTCUSDT", later, "3m")
+	if len(m.alertsChan) != 2 {
+		t.Fatalf("冷却期过后应再次产生警报, got %d", len(m.alertsChan))
+	}
+	if f := m.GetFeatures("btcusdt"); f == nil || f.VolumeRatio20 != 10 {
+		t.Errorf("GetFeatures = %+v", f)
+	}
+
+	var received []Alert
+	remove := AddAlertListener(func(alert Alert) { received = append(received, alert) })
+	defer remove()
+	close(m.alertsChan)
+	m.dispatchAlerts()
+
+	if len(received) != 2 {
+		t.Fatalf("监听器应收到2条警报, got %d", len(received))
+	}
+	recent := RecentAlerts("BTCUSDT", 1)
+	if len(recent) != 1 || !recent[0].Timestamp.Equal(received[1].Timestamp) {
+		t.Errorf("RecentAlerts 应从新到旧返回, got %+v", recent)
+	}
+	if len(RecentAlerts("ETHUSDT", 0)) != 0 {
+		t.Errorf("其他币种不应有警报")
+	}
+}
+
+// TestFormatAlerts 测试警报提示词格式
+func TestFormatAlerts(t *testing.T) {
+	if FormatAlerts(nil) != "" {
+		t.Errorf("无警报时应返回空串")
+	}
+	text := FormatAlerts([]Alert{{
+		Symbol:    "SOLUSDT",
+		Message:   "SOLUSDT 成交量突增 4.0 倍（阈值 3.0）",
+		Timestamp: time.Date(2025, 1, 1, 8, 30, 0, 0, time.Local),
+	}})
+	if !strings.Contains(text, "## 市场警报") || !strings.Contains(text, "- [08:30:00] SOLUSDT 成交量突增") {
+		t.Errorf("格式不符: %q", text)
+	}
+}
@@ -12,14 +12,25 @@ import (
 )
 
 const (
-	baseURL = "https://fapi.binance.com"
+	baseURL      = "https://fapi.binance.com"
+	asterBaseURL = "https://fapi.asterdex.com" // Aster 合约行情接口与币安兼容
 )
 
 type APIClient struct {
-	client *http.Client
+	client  *http.Client
+	baseURL string
 }
 
 func NewAPIClient() *APIClient {
+	return newAPIClient(baseURL)
+}
+
+// NewAsterAPIClient 创建 Aster 行情客户端
+func NewAsterAPIClient() *APIClient {
+	return newAPIClient(asterBaseURL)
+}
+
+func newAPIClient(base string) *APIClient {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 	}
@@ -31,12 +42,13 @@ func NewAPIClient() *APIClient {
 	}
 
 	return &APIClient{
-		client: client,
+		client:  client,
+		baseURL: base,
 	}
 }
 
 func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
-	url := fmt.Sprintf("%s/fapi/v1/exchangeInfo", baseURL)
+	url := fmt.Sprintf("%s/fapi/v1/exchangeInfo", c.baseURL)
 	resp, err := c.client.Get(url)
 	if err != nil {
 		return nil, err
@@ -57,7 +69,7 @@ func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
 }
 
 func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
-	url := fmt.Sprintf("%s/fapi/v1/klines", baseURL)
+	url := fmt.Sprintf("%s/fapi/v1/klines", c.baseURL)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -124,7 +136,7 @@ func parseKline(kr KlineResponse) (Kline, error) {
 }
 
 func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
-	url := fmt.Sprintf("%s/fapi/v1/ticker/price", baseURL)
+	url := fmt.Sprintf("%s/fapi/v1/ticker/price", c.baseURL)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return 0, err
@@ -158,3 +170,162 @@ func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
 
 	return price, nil
 }
+
+// GetDepth 获取订单簿快照（limit 为档位数量，可选 5/10/20/50/100...）
+func (c *APIClient) GetDepth(symbol string, limit int) (*OrderBook, error) {
+	url := fmt.Sprintf("%s/fapi/v1/depth", c.baseURL)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	q := req.URL.Query()
+	q.Add("symbol", symbol)
+	q.Add("limit", strconv.Itoa(limit))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var depth struct {
+		Bids [][2]string `json:"bids"`
+		Asks [][2]string `json:"asks"`
+	}
+	if err := json.Unmarshal(body, &depth); err != nil {
+		log.Printf("获取深度数据失败,响应内容: %s", string(body))
+		return nil, err
+	}
+
+	return &OrderBook{
+		Bids:       parsePriceLevels(depth.Bids),
+		Asks:       parsePriceLevels(depth.Asks),
+		UpdateTime: time.Now(),
+	}, nil
+}
+
+// GetOpenInterest 获取持仓量（币本位数量）
+func (c *APIClient) GetOpenInterest(symbol string) (*OIData, error) {
+	url := fmt.Sprintf("%s/fapi/v1/openInterest?symbol=%s", c.baseURL, symbol)
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		OpenInterest string `json:"openInterest"`
+		Symbol       string `json:"symbol"`
+		Time         int64  `json:"time"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	oi, _ := strconv.ParseFloat(result.OpenInterest, 64)
+
+	return &OIData{
+		Latest:  oi,
+		Average: oi * 0.999, // 近似平均值
+	}, nil
+}
+
+// GetFundingIntervals 获取调整过资金费结算间隔的币种（币种 -> 小时），未列出的币种按8小时结算
+func (c *APIClient) GetFundingIntervals() (map[string]float64, error) {
+	resp, err := c.client.Get(c.baseURL + "/fapi/v1/fundingInfo")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取资金费结算间隔失败: HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []struct {
+		Symbol               string `json:"symbol"`
+		FundingIntervalHours int    `json:"fundingIntervalHours"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	intervals := make(map[string]float64, len(result))
+	for _, item := range result {
+		if item.FundingIntervalHours > 0 {
+			intervals[item.Symbol] = float64(item.FundingIntervalHours)
+		}
+	}
+	return intervals, nil
+}
+
+// GetFundingRate 获取最新资金费率（该币种结算周期的单期费率）
+func (c *APIClient) GetFundingRate(symbol string) (float64, error) {
+	result, err := c.getPremiumIndex(symbol)
+	if err != nil {
+		return 0, err
+	}
+	rate, _ := strconv.ParseFloat(result.LastFundingRate, 64)
+	return rate, nil
+}
+
+// GetMarkPrice 获取标记价格（与资金费率同一接口，不缓存）
+func (c *APIClient) GetMarkPrice(symbol string) (float64, error) {
+	result, err := c.getPremiumIndex(symbol)
+	if err != nil {
+		return 0, err
+	}
+	price, err := strconv.ParseFloat(result.MarkPrice, 64)
+	if err != nil || price <= 0 {
+		return 0, fmt.Errorf("%s 标记价格无效: %q", symbol, result.MarkPrice)
+	}
+	return price, nil
+}
+
+// premiumIndex /fapi/v1/premiumIndex 返回的标记价格和资金费率
+type premiumIndex struct {
+	Symbol          string `json:"symbol"`
+	MarkPrice       string `json:"markPrice"`
+	IndexPrice      string `json:"indexPrice"`
+	LastFundingRate string `json:"lastFundingRate"`
+	NextFundingTime int64  `json:"nextFundingTime"`
+	InterestRate    string `json:"interestRate"`
+	Time            int64  `json:"time"`
+}
+
+func (c *APIClient) getPremiumIndex(symbol string) (*premiumIndex, error) {
+	url := fmt.Sprintf("%s/fapi/v1/premiumIndex?symbol=%s", c.baseURL, symbol)
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result premiumIndex
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
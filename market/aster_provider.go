@@ -0,0 +1,73 @@
+package market
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// restKlineTTL REST数据源的K线缓存时间（同一决策周期内多次读取只请求一次）
+const restKlineTTL = 15 * time.Second
+
+type klineCacheEntry struct {
+	klines    []Kline
+	fetchedAt time.Time
+}
+
+// asterProvider Aster合约行情（接口与币安兼容，按需请求REST并短时缓存）
+type asterProvider struct {
+	api          *APIClient
+	klineCache   sync.Map // symbol|interval -> *klineCacheEntry
+	fundingCache sync.Map // symbol -> *FundingRateCache
+	intervals    *fundingIntervalCache
+}
+
+func newAsterProvider() *asterProvider {
+	api := NewAsterAPIClient()
+	return &asterProvider{api: api, intervals: &fundingIntervalCache{fetch: api.GetFundingIntervals}}
+}
+
+func (p *asterProvider) Name() string { return "aster" }
+
+func (p *asterProvider) GetKlines(symbol, interval string) ([]Kline, error) {
+	symbol = strings.ToUpper(symbol)
+	key := symbol + "|" + interval
+	if value, ok := p.klineCache.Load(key); ok {
+		entry := value.(*klineCacheEntry)
+		if time.Since(entry.fetchedAt) < restKlineTTL {
+			return append([]Kline(nil), entry.klines...), nil
+		}
+	}
+
+	klines, err := p.api.GetKlines(symbol, interval, 100)
+	if err != nil {
+		return nil, err
+	}
+	p.klineCache.Store(key, &klineCacheEntry{klines: klines, fetchedAt: time.Now()})
+	return append([]Kline(nil), klines...), nil
+}
+
+func (p *asterProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	return p.api.GetOpenInterest(strings.ToUpper(symbol))
+}
+
+func (p *asterProvider) GetFundingRate(symbol string) (float64, error) {
+	return cachedFundingRate(&p.fundingCache, strings.ToUpper(symbol), normalizedFundingRate(p.api.GetFundingRate, p.intervals))
+}
+
+func (p *asterProvider) GetMarkPrice(symbol string) (float64, error) {
+	return p.api.GetMarkPrice(strings.ToUpper(symbol))
+}
+
+func (p *asterProvider) FundingIntervalHours(symbol string) float64 {
+	return p.intervals.get(symbol)
+}
+
+func (p *asterProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	return p.api.GetDepth(strings.ToUpper(symbol), depthLevels)
+}
+
+// SubscribePrices Aster 只使用REST接口，不提供实时价格推送
+func (p *asterProvider) SubscribePrices(listener PriceListener) (func(), bool) {
+	return nil, false
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,17 +16,21 @@ type CombinedStreamsClient struct {
 	conn        *websocket.Conn
 	mu          sync.RWMutex
 	subscribers map[string]chan []byte
+	streams     map[string]bool // 已订阅的流，重连后全部恢复
 	reconnect   bool
 	done        chan struct{}
 	batchSize   int // 每批订阅的流数量
+	health      *clientHealth
 }
 
 func NewCombinedStreamsClient(batchSize int) *CombinedStreamsClient {
 	return &CombinedStreamsClient{
 		subscribers: make(map[string]chan []byte),
+		streams:     make(map[string]bool),
 		reconnect:   true,
 		done:        make(chan struct{}),
 		batchSize:   batchSize,
+		health:      newClientHealth(),
 	}
 }
 
@@ -39,10 +44,12 @@ func (c *CombinedStreamsClient) Connect() error {
 	if err != nil {
 		return fmt.Errorf("组合流WebSocket连接失败: %v", err)
 	}
+	keepAlive(conn)
 
 	c.mu.Lock()
 	c.conn = conn
 	c.mu.Unlock()
+	c.health.setConnected(true, time.Now())
 
 	log.Println("组合流WebSocket连接成功")
 	go c.readMessages()
@@ -52,6 +59,16 @@ func (c *CombinedStreamsClient) Connect() error {
 
 // BatchSubscribeKlines 批量订阅K线
 func (c *CombinedStreamsClient) BatchSubscribeKlines(symbols []string, interval string) error {
+	return c.batchSubscribe(symbols, "kline_"+interval)
+}
+
+// SubscribeForceOrders 订阅全市场强平单
+func (c *CombinedStreamsClient) SubscribeForceOrders() error {
+	return c.subscribeStreams([]string{forceOrderStream})
+}
+
+// batchSubscribe 按批次订阅 <symbol>@<suffix> 流
+func (c *CombinedStreamsClient) batchSubscribe(symbols []string, suffix string) error {
 	// 将symbols分批处理
 	batches := c.splitIntoBatches(symbols, c.batchSize)
 
@@ -60,7 +77,7 @@ func (c *CombinedStreamsClient) BatchSubscribeKlines(symbols []string, interval
 
 		streams := make([]string, len(batch))
 		for j, symbol := range batch {
-			streams[j] = fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), interval)
+			streams[j] = fmt.Sprintf("%s@%s", strings.ToLower(symbol), suffix)
 		}
 
 		if err := c.subscribeStreams(streams); err != nil {
@@ -76,6 +93,29 @@ func (c *CombinedStreamsClient) BatchSubscribeKlines(symbols []string, interval
 	return nil
 }
 
+// resubscribe 重连后按批次恢复所有已订阅的流
+func (c *CombinedStreamsClient) resubscribe() error {
+	c.mu.RLock()
+	streams := make([]string, 0, len(c.streams))
+	for stream := range c.streams {
+		streams = append(streams, stream)
+	}
+	c.mu.RUnlock()
+	sort.Strings(streams)
+
+	batches := c.splitIntoBatches(streams, c.batchSize)
+	for i, batch := range batches {
+		if err := c.subscribeStreams(batch); err != nil {
+			return fmt.Errorf("恢复第 %d 批订阅失败: %v", i+1, err)
+		}
+		if i < len(batches)-1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	log.Printf("组合流已恢复 %d 个订阅", len(streams))
+	return nil
+}
+
 // splitIntoBatches 将切片分成指定大小的批次
 func (c *CombinedStreamsClient) splitIntoBatches(symbols []string, batchSize int) [][]string {
 	var batches [][]string
@@ -99,15 +139,51 @@ func (c *CombinedStreamsClient) subscribeStreams(streams []string) error {
 		"id":     time.Now().UnixNano(),
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	// 写锁：gorilla/websocket 不支持并发写
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	if c.conn == nil {
 		return fmt.Errorf("WebSocket未连接")
 	}
 
 	log.Printf("订阅流: %v", streams)
-	return c.conn.WriteJSON(subscribeMsg)
+	if err := c.conn.WriteJSON(subscribeMsg); err != nil {
+		return err
+	}
+	for _, stream := range streams {
+		c.streams[stream] = true
+	}
+	c.health.addStreams(streams, time.Now())
+	return nil
+}
+
+// unsubscribeStreams 退订多个流并移除订阅者（订阅者通道关闭，处理goroutine随之退出）
+// 未连接时只移除登记，重连后不再恢复
+func (c *CombinedStreamsClient) unsubscribeStreams(streams []string) error {
+	unsubscribeMsg := map[string]interface{}{
+		"method": "UNSUBSCRIBE",
+		"params": streams,
+		"id":     time.Now().UnixNano(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, stream := range streams {
+		delete(c.streams, stream)
+		if ch, ok := c.subscribers[stream]; ok {
+			close(ch)
+			delete(c.subscribers, stream)
+		}
+	}
+	c.health.removeStreams(streams)
+
+	if c.conn == nil {
+		return nil
+	}
+	log.Printf("退订流: %v", streams)
+	return c.conn.WriteJSON(unsubscribeMsg)
 }
 
 func (c *CombinedStreamsClient) readMessages() {
@@ -125,9 +201,12 @@ func (c *CombinedStreamsClient) readMessages() {
 				continue
 			}
 
+			conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
 			_, message, err := conn.ReadMessage()
 			if err != nil {
 				log.Printf("读取组合流消息失败: %v", err)
+				c.health.setConnected(false, time.Now())
+				conn.Close()
 				c.handleReconnect()
 				return
 			}
@@ -147,12 +226,15 @@ func (c *CombinedStreamsClient) handleCombinedMessage(message []byte) {
 		log.Printf("解析组合消息失败: %v", err)
 		return
 	}
+	if combinedMsg.Stream == "" {
+		return // 订阅请求的响应
+	}
+	c.health.recordMessage(combinedMsg.Stream, time.Now())
 
+	// 持有读锁发送（非阻塞），避免与退订时关闭通道并发
 	c.mu.RLock()
-	ch, exists := c.subscribers[combinedMsg.Stream]
-	c.mu.RUnlock()
-
-	if exists {
+	defer c.mu.RUnlock()
+	if ch, exists := c.subscribers[combinedMsg.Stream]; exists {
 		select {
 		case ch <- combinedMsg.Data:
 		default:
@@ -180,6 +262,11 @@ func (c *CombinedStreamsClient) handleReconnect() {
 	if err := c.Connect(); err != nil {
 		log.Printf("组合流重新连接失败: %v", err)
 		go c.handleReconnect()
+		return
+	}
+	// 新连接没有任何订阅，需要恢复；断线期间缺失的K线在收到新K线时检测缺口并回填
+	if err := c.resubscribe(); err != nil {
+		log.Printf("❌ %v", err)
 	}
 }
 
@@ -1,9 +1,8 @@
 package market
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"log"
 	"math"
 	"strconv"
 	"strings"
@@ -21,22 +20,63 @@ type FundingRateCache struct {
 var (
 	fundingRateMap sync.Map // map[string]*FundingRateCache
 	frCacheTTL     = 1 * time.Hour
+
+	binanceFundingIntervals = &fundingIntervalCache{fetch: NewAPIClient().GetFundingIntervals}
 )
 
-// Get 获取指定代币的市场数据
+// DefaultFundingIntervalHours 默认资金费结算间隔（小时），资金费率统一折算为该间隔的单期费率
+const DefaultFundingIntervalHours = 8.0
+
+// fundingIntervalCache 币安兼容接口的资金费结算间隔缓存（接口只返回调整过间隔的币种，其余为8小时）
+type fundingIntervalCache struct {
+	mu        sync.Mutex
+	intervals map[string]float64
+	updatedAt time.Time
+	fetch     func() (map[string]float64, error)
+}
+
+// get 获取币种的结算间隔，请求失败时沿用上次结果（没有结果时为8小时），1小时内不重复请求
+func (c *fundingIntervalCache) get(symbol string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.updatedAt.IsZero() || time.Since(c.updatedAt) >= frCacheTTL {
+		intervals, err := c.fetch()
+		if err != nil {
+			log.Printf("⚠️ 获取资金费结算间隔失败，使用上次结果: %v", err)
+		} else {
+			c.intervals = intervals
+		}
+		c.updatedAt = time.Now()
+	}
+	if hours, ok := c.intervals[strings.ToUpper(symbol)]; ok && hours > 0 {
+		return hours
+	}
+	return DefaultFundingIntervalHours
+}
+
+// Get 获取指定代币的市场数据（币安行情）
 func Get(symbol string) (*Data, error) {
+	if WSMonitorCli == nil {
+		return nil, fmt.Errorf("行情监控未启动，无法获取 %s 市场数据", symbol)
+	}
+	return fetchDefaultData(Binance, symbol)
+}
+
+// fetchDefaultData 从数据源获取默认3m/4h周期的市场数据
+func fetchDefaultData(p MarketDataProvider, symbol string) (*Data, error) {
 	var klines3m, klines4h []Kline
 	var err error
 	// 标准化symbol
 	symbol = Normalize(symbol)
 	// 获取3分钟K线数据 (最近10个)
-	klines3m, err = WSMonitorCli.GetCurrentKlines(symbol, "3m") // 多获取一些用于计算
+	klines3m, err = p.GetKlines(symbol, "3m") // 多获取一些用于计算
 	if err != nil {
 		return nil, fmt.Errorf("获取3分钟K线失败: %v", err)
 	}
 
 	// 获取4小时K线数据 (最近10个)
-	klines4h, err = WSMonitorCli.GetCurrentKlines(symbol, "4h") // 多获取用于计算指标
+	klines4h, err = p.GetKlines(symbol, "4h") // 多获取用于计算指标
 	if err != nil {
 		return nil, fmt.Errorf("获取4小时K线失败: %v", err)
 	}
@@ -75,14 +115,15 @@ func Get(symbol string) (*Data, error) {
 	}
 
 	// 获取OI数据
-	oiData, err := getOpenInterestData(symbol)
+	oiData, err := p.GetOpenInterest(symbol)
 	if err != nil {
 		// OI失败不影响整体,使用默认值
 		oiData = &OIData{Latest: 0, Average: 0}
 	}
 
 	// 获取Funding Rate
-	fundingRate, _ := getFundingRate(symbol)
+	fundingRate, _ := p.GetFundingRate(symbol)
+	markPrice := getMarkPrice(p, symbol)
 
 	// 计算日内系列数据
 	intradayData := calculateIntradaySeries(klines3m)
@@ -90,9 +131,13 @@ func Get(symbol string) (*Data, error) {
 	// 计算长期数据
 	longerTermData := calculateLongerTermData(klines4h)
 
+	// 订单簿特征（失败不影响整体）
+	depthData := getDepthData(p, symbol)
+
 	return &Data{
 		Symbol:            symbol,
 		CurrentPrice:      currentPrice,
+		MarkPrice:         markPrice,
 		PriceChange1h:     priceChange1h,
 		PriceChange4h:     priceChange4h,
 		CurrentEMA20:      currentEMA20,
@@ -100,8 +145,11 @@ func Get(symbol string) (*Data, error) {
 		CurrentRSI7:       currentRSI7,
 		OpenInterest:      oiData,
 		FundingRate:       fundingRate,
+		FundingInterval:   p.FundingIntervalHours(symbol),
 		IntradaySeries:    intradayData,
 		LongerTermContext: longerTermData,
+		Depth:             depthData,
+		Flow:              getFlowFrom(p, symbol),
 	}, nil
 }
 
@@ -219,6 +267,22 @@ func calculateATR(klines []Kline, period int) float64 {
 	return atr
 }
 
+// GetATR 获取指定周期K线的ATR（供风控模块使用，p 为nil时使用币安）
+func GetATR(p MarketDataProvider, symbol, interval string, period int) (float64, error) {
+	if p == nil {
+		p = Binance
+	}
+	klines, err := p.GetKlines(Normalize(symbol), interval)
+	if err != nil {
+		return 0, fmt.Errorf("获取%sK线失败: %w", interval, err)
+	}
+	atr := calculateATR(klines, period)
+	if atr <= 0 {
+		return 0, fmt.Errorf("%s K线数量不足，无法计算ATR%d", interval, period)
+	}
+	return atr, nil
+}
+
 // calculateIntradaySeries 计算日内系列数据
 func calculateIntradaySeries(klines []Kline) *IntradayData {
 	data := &IntradayData{
@@ -317,83 +381,55 @@ func calculateLongerTermData(klines []Kline) *LongerTermData {
 
 // getOpenInterestData 获取OI数据
 func getOpenInterestData(symbol string) (*OIData, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/openInterest?symbol=%s", symbol)
-
-	apiClient := NewAPIClient()
-	resp, err := apiClient.client.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	return NewAPIClient().GetOpenInterest(symbol)
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// getMarkPrice 获取标记价格，失败时返回0（使用方退回最新K线收盘价）
+func getMarkPrice(p MarketDataProvider, symbol string) float64 {
+	price, err := p.GetMarkPrice(symbol)
 	if err != nil {
-		return nil, err
+		log.Printf("⚠️  获取 %s 标记价格失败: %v", symbol, err)
+		return 0
 	}
+	return price
+}
 
-	var result struct {
-		OpenInterest string `json:"openInterest"`
-		Symbol       string `json:"symbol"`
-		Time         int64  `json:"time"`
-	}
+// getFundingRate 获取资金费率（优化：使用 1 小时缓存）
+func getFundingRate(symbol string) (float64, error) {
+	return cachedFundingRate(&fundingRateMap, symbol, normalizedFundingRate(NewAPIClient().GetFundingRate, binanceFundingIntervals))
+}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
+// normalizedFundingRate 把单期费率折算为8小时单期费率（部分币种每4小时或每小时结算）
+func normalizedFundingRate(fetch func(symbol string) (float64, error), intervals *fundingIntervalCache) func(symbol string) (float64, error) {
+	return func(symbol string) (float64, error) {
+		rate, err := fetch(symbol)
+		if err != nil {
+			return 0, err
+		}
+		return rate * DefaultFundingIntervalHours / intervals.get(symbol), nil
 	}
-
-	oi, _ := strconv.ParseFloat(result.OpenInterest, 64)
-
-	return &OIData{
-		Latest:  oi,
-		Average: oi * 0.999, // 近似平均值
-	}, nil
 }
 
-// getFundingRate 获取资金费率（优化：使用 1 小时缓存）
-func getFundingRate(symbol string) (float64, error) {
+// cachedFundingRate 带缓存的资金费率查询（cache 按数据源区分）
+func cachedFundingRate(cache *sync.Map, symbol string, fetch func(symbol string) (float64, error)) (float64, error) {
 	// 检查缓存（有效期 1 小时）
 	// Funding Rate 每 8 小时才更新，1 小时缓存非常合理
-	if cached, ok := fundingRateMap.Load(symbol); ok {
-		cache := cached.(*FundingRateCache)
-		if time.Since(cache.UpdatedAt) < frCacheTTL {
+	if cached, ok := cache.Load(symbol); ok {
+		entry := cached.(*FundingRateCache)
+		if time.Since(entry.UpdatedAt) < frCacheTTL {
 			// 缓存命中，直接返回
-			return cache.Rate, nil
+			return entry.Rate, nil
 		}
 	}
 
 	// 缓存过期或不存在，调用 API
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/premiumIndex?symbol=%s", symbol)
-
-	apiClient := NewAPIClient()
-	resp, err := apiClient.client.Get(url)
+	rate, err := fetch(symbol)
 	if err != nil {
 		return 0, err
 	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
-	}
-
-	var result struct {
-		Symbol          string `json:"symbol"`
-		MarkPrice       string `json:"markPrice"`
-		IndexPrice      string `json:"indexPrice"`
-		LastFundingRate string `json:"lastFundingRate"`
-		NextFundingTime int64  `json:"nextFundingTime"`
-		InterestRate    string `json:"interestRate"`
-		Time            int64  `json:"time"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, err
-	}
-
-	rate, _ := strconv.ParseFloat(result.LastFundingRate, 64)
 
 	// 更新缓存
-	fundingRateMap.Store(symbol, &FundingRateCache{
+	cache.Store(symbol, &FundingRateCache{
 		Rate:      rate,
 		UpdatedAt: time.Now(),
 	})
@@ -423,32 +459,13 @@ func Format(data *Data) string {
 
 	sb.WriteString(fmt.Sprintf("Funding Rate: %.2e\n\n", data.FundingRate))
 
+	formatDepth(&sb, data.Depth)
+	formatFlow(&sb, data.Flow)
+
 	if data.IntradaySeries != nil {
 		sb.WriteString("Intraday series (3‑minute intervals, oldest → latest):\n\n")
 
-		if len(data.IntradaySeries.MidPrices) > 0 {
-			sb.WriteString(fmt.Sprintf("Mid prices: %s\n\n", formatFloatSlice(data.IntradaySeries.MidPrices)))
-		}
-
-		if len(data.IntradaySeries.EMA20Values) > 0 {
-			sb.WriteString(fmt.Sprintf("EMA indicators (20‑period): %s\n\n", formatFloatSlice(data.IntradaySeries.EMA20Values)))
-		}
-
-		if len(data.IntradaySeries.MACDValues) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.IntradaySeries.MACDValues)))
-		}
-
-		if len(data.IntradaySeries.RSI7Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI indicators (7‑Period): %s\n\n", formatFloatSlice(data.IntradaySeries.RSI7Values)))
-		}
-
-		if len(data.IntradaySeries.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI indicators (14‑Period): %s\n\n", formatFloatSlice(data.IntradaySeries.RSI14Values)))
-		}
-
-		if len(data.IntradaySeries.Volume) > 0 {
-			sb.WriteString(fmt.Sprintf("Volume: %s\n\n", formatFloatSlice(data.IntradaySeries.Volume)))
-		}
+		writeSeries(&sb, data.IntradaySeries)
 
 		sb.WriteString(fmt.Sprintf("3m ATR (14‑period): %.3f\n\n", data.IntradaySeries.ATR14))
 	}
@@ -456,14 +473,7 @@ func Format(data *Data) string {
 	if data.LongerTermContext != nil {
 		sb.WriteString("Longer‑term context (4‑hour timeframe):\n\n")
 
-		sb.WriteString(fmt.Sprintf("20‑Period EMA: %.3f vs. 50‑Period EMA: %.3f\n\n",
-			data.LongerTermContext.EMA20, data.LongerTermContext.EMA50))
-
-		sb.WriteString(fmt.Sprintf("3‑Period ATR: %.3f vs. 14‑Period ATR: %.3f\n\n",
-			data.LongerTermContext.ATR3, data.LongerTermContext.ATR14))
-
-		sb.WriteString(fmt.Sprintf("Current Volume: %.3f vs. Average Volume: %.3f\n\n",
-			data.LongerTermContext.CurrentVolume, data.LongerTermContext.AverageVolume))
+		writeLongerTermContext(&sb, data.LongerTermContext)
 
 		if len(data.LongerTermContext.MACDValues) > 0 {
 			sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.LongerTermContext.MACDValues)))
@@ -474,9 +484,62 @@ func Format(data *Data) string {
 		}
 	}
 
+	// 自定义周期：每个周期一段（序列指标 + 汇总指标）
+	for _, tf := range data.Timeframes {
+		sb.WriteString(fmt.Sprintf("Timeframe %s (oldest → latest):\n\n", tf.Interval))
+		if tf.Series != nil {
+			writeSeries(&sb, tf.Series)
+			sb.WriteString(fmt.Sprintf("%s ATR (14‑period): %.3f\n\n", tf.Interval, tf.Series.ATR14))
+		}
+		if tf.Context != nil {
+			writeLongerTermContext(&sb, tf.Context)
+		}
+	}
+
+	formatIndicators(&sb, data.Indicators)
+
 	return sb.String()
 }
 
+// writeSeries 输出K线序列指标（价格、EMA、MACD、RSI、成交量）
+func writeSeries(sb *strings.Builder, series *IntradayData) {
+	if len(series.MidPrices) > 0 {
+		sb.WriteString(fmt.Sprintf("Mid prices: %s\n\n", formatFloatSlice(series.MidPrices)))
+	}
+
+	if len(series.EMA20Values) > 0 {
+		sb.WriteString(fmt.Sprintf("EMA indicators (20‑period): %s\n\n", formatFloatSlice(series.EMA20Values)))
+	}
+
+	if len(series.MACDValues) > 0 {
+		sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(series.MACDValues)))
+	}
+
+	if len(series.RSI7Values) > 0 {
+		sb.WriteString(fmt.Sprintf("RSI indicators (7‑Period): %s\n\n", formatFloatSlice(series.RSI7Values)))
+	}
+
+	if len(series.RSI14Values) > 0 {
+		sb.WriteString(fmt.Sprintf("RSI indicators (14‑Period): %s\n\n", formatFloatSlice(series.RSI14Values)))
+	}
+
+	if len(series.Volume) > 0 {
+		sb.WriteString(fmt.Sprintf("Volume: %s\n\n", formatFloatSlice(series.Volume)))
+	}
+}
+
+// writeLongerTermContext 输出汇总指标（EMA20/50、ATR3/14、成交量对比）
+func writeLongerTermContext(sb *strings.Builder, ctx *LongerTermData) {
+	sb.WriteString(fmt.Sprintf("20‑Period EMA: %.3f vs. 50‑Period EMA: %.3f\n\n",
+		ctx.EMA20, ctx.EMA50))
+
+	sb.WriteString(fmt.Sprintf("3‑Period ATR: %.3f vs. 14‑Period ATR: %.3f\n\n",
+		ctx.ATR3, ctx.ATR14))
+
+	sb.WriteString(fmt.Sprintf("Current Volume: %.3f vs. Average Volume: %.3f\n\n",
+		ctx.CurrentVolume, ctx.AverageVolume))
+}
+
 // formatPriceWithDynamicPrecision 根据价格区间动态选择精度
 // 这样可以完美支持从超低价 meme coin (< 0.0001) 到 BTC/ETH 的所有币种
 func formatPriceWithDynamicPrecision(price float64) string {
@@ -517,13 +580,9 @@ func formatFloatSlice(values []float64) string {
 	return "[" + strings.Join(strValues, ", ") + "]"
 }
 
-// Normalize 标准化symbol,确保是USDT交易对
+// Normalize 标准化symbol为币安格式（保留USDT/USDC计价，只有币种名时补全USDT，kPEPE 转为 1000PEPEUSDT）
 func Normalize(symbol string) string {
-	symbol = strings.ToUpper(symbol)
-	if strings.HasSuffix(symbol, "USDT") {
-		return symbol
-	}
-	return symbol + "USDT"
+	return ConvertSymbol(symbol, VenueBinance, VenueBinance)
 }
 
 // parseFloat 解析float值
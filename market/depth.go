@@ -0,0 +1,224 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	depthStreamSuffix = "depth20@100ms" // 有限档深度流：前20档，100ms推送
+	depthLevels       = 20
+	depthBandPct      = 1.0             // 统计中间价 ±1% 范围内的挂单深度
+	wallMultiple      = 5.0             // 单档挂单金额达到该侧中位数的倍数视为大单墙
+	depthStaleAfter   = 5 * time.Second // 超过该时间未更新的订单簿视为过期，改用REST快照
+)
+
+// PriceLevel 订单簿单档
+type PriceLevel struct {
+	Price    float64
+	Quantity float64
+}
+
+// OrderBook 订单簿快照（买盘价格从高到低，卖盘价格从低到高）
+type OrderBook struct {
+	Bids       []PriceLevel
+	Asks       []PriceLevel
+	UpdateTime time.Time
+}
+
+// DepthWall 大单墙
+type DepthWall struct {
+	Price    float64
+	Notional float64 // 挂单金额（USDT）
+	Multiple float64 // 相对该侧中位数的倍数
+	DistPct  float64 // 距中间价的百分比
+}
+
+// DepthData 订单簿微观结构特征
+type DepthData struct {
+	MidPrice     float64
+	SpreadBps    float64    // 买卖价差（基点）
+	Imbalance    float64    // 前20档买卖金额失衡度 (bid-ask)/(bid+ask)，范围 -1~1，正数表示买盘更厚
+	BidDepth1Pct float64    // 中间价下方1%内的买盘金额（USDT）
+	AskDepth1Pct float64    // 中间价上方1%内的卖盘金额（USDT）
+	BidWall      *DepthWall // 买盘最大的大单墙（无则为nil）
+	AskWall      *DepthWall // 卖盘最大的大单墙（无则为nil）
+}
+
+// depthWSData 有限档深度流消息
+type depthWSData struct {
+	EventTime int64       `json:"E"`
+	Symbol    string      `json:"s"`
+	Bids      [][2]string `json:"b"`
+	Asks      [][2]string `json:"a"`
+}
+
+// parsePriceLevels 解析 [价格, 数量] 字符串数组，跳过数量为0的档位
+func parsePriceLevels(raw [][2]string) []PriceLevel {
+	levels := make([]PriceLevel, 0, len(raw))
+	for _, level := range raw {
+		price, err1 := strconv.ParseFloat(level[0], 64)
+		qty, err2 := strconv.ParseFloat(level[1], 64)
+		if err1 != nil || err2 != nil || qty <= 0 {
+			continue
+		}
+		levels = append(levels, PriceLevel{Price: price, Quantity: qty})
+	}
+	return levels
+}
+
+// ComputeDepthFeatures 从订单簿计算价差、失衡度、±1%深度和大单墙
+func ComputeDepthFeatures(book *OrderBook) *DepthData {
+	if book == nil || len(book.Bids) == 0 || len(book.Asks) == 0 {
+		return nil
+	}
+
+	bestBid, bestAsk := book.Bids[0].Price, book.Asks[0].Price
+	mid := (bestBid + bestAsk) / 2
+	if mid <= 0 {
+		return nil
+	}
+
+	data := &DepthData{
+		MidPrice:  mid,
+		SpreadBps: (bestAsk - bestBid) / mid * 10000,
+	}
+
+	var bidTotal, askTotal float64
+	for _, level := range book.Bids {
+		notional := level.Price * level.Quantity
+		bidTotal += notional
+		if level.Price >= mid*(1-depthBandPct/100) {
+			data.BidDepth1Pct += notional
+		}
+	}
+	for _, level := range book.Asks {
+		notional := level.Price * level.Quantity
+		askTotal += notional
+		if level.Price <= mid*(1+depthBandPct/100) {
+			data.AskDepth1Pct += notional
+		}
+	}
+	if bidTotal+askTotal > 0 {
+		data.Imbalance = (bidTotal - askTotal) / (bidTotal + askTotal)
+	}
+
+	data.BidWall = findWall(book.Bids, mid)
+	data.AskWall = findWall(book.Asks, mid)
+	return data
+}
+
+// findWall 找出挂单金额最大且达到中位数 wallMultiple 倍的档位
+func findWall(levels []PriceLevel, mid float64) *DepthWall {
+	if len(levels) < 3 {
+		return nil
+	}
+
+	notionals := make([]float64, len(levels))
+	for i, level := range levels {
+		notionals[i] = level.Price * level.Quantity
+	}
+	sorted := append([]float64(nil), notionals...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	if median <= 0 {
+		return nil
+	}
+
+	var wall *DepthWall
+	for i, notional := range notionals {
+		multiple := notional / median
+		if multiple < wallMultiple || (wall != nil && notional <= wall.Notional) {
+			continue
+		}
+		dist := (levels[i].Price - mid) / mid * 100
+		if dist < 0 {
+			dist = -dist
+		}
+		wall = &DepthWall{Price: levels[i].Price, Notional: notional, Multiple: multiple, DistPct: dist}
+	}
+	return wall
+}
+
+// subscribeDepth 按需订阅深度流（不再被关注时由 evictOnDemandStreams 退订）
+func (m *WSMonitor) subscribeDepth(symbol string) error {
+	stream := fmt.Sprintf("%s@%s", strings.ToLower(symbol), depthStreamSuffix)
+	return m.acquireStream(symbol, stream, 100,
+		func(ch <-chan []byte) { m.handleDepthData(symbol, ch) },
+		func() { m.depthMap.Delete(symbol) })
+}
+
+func (m *WSMonitor) handleDepthData(symbol string, ch <-chan []byte) {
+	for data := range ch {
+		var depth depthWSData
+		if err := json.Unmarshal(data, &depth); err != nil {
+			log.Printf("解析深度数据失败: %v", err)
+			continue
+		}
+		m.depthMap.Store(symbol, &OrderBook{
+			Bids:       parsePriceLevels(depth.Bids),
+			Asks:       parsePriceLevels(depth.Asks),
+			UpdateTime: time.Now(),
+		})
+	}
+}
+
+// GetOrderBook 获取订单簿（优先使用WebSocket推送；首次访问或数据过期时使用REST快照）
+// 按需订阅深度流，只订阅实际被交易员关注的币种
+func (m *WSMonitor) GetOrderBook(symbol string) (*OrderBook, error) {
+	symbol = strings.ToUpper(symbol)
+	if err := m.subscribeDepth(symbol); err != nil {
+		log.Printf("警告: 订阅%s深度流失败: %v (使用API数据)", symbol, err)
+	}
+	if value, ok := m.depthMap.Load(symbol); ok {
+		book := value.(*OrderBook)
+		if time.Since(book.UpdateTime) < depthStaleAfter {
+			return book, nil
+		}
+	}
+
+	book, err := NewAPIClient().GetDepth(symbol, depthLevels)
+	if err != nil {
+		return nil, fmt.Errorf("获取%s深度失败: %w", symbol, err)
+	}
+	m.depthMap.Store(symbol, book)
+	return book, nil
+}
+
+// getDepthData 获取订单簿特征，失败时返回nil（深度数据不影响其他市场数据）
+func getDepthData(p MarketDataProvider, symbol string) *DepthData {
+	if !Available(p) {
+		return nil
+	}
+	book, err := p.GetOrderBook(symbol)
+	if err != nil {
+		log.Printf("⚠️ %v", err)
+		return nil
+	}
+	return ComputeDepthFeatures(book)
+}
+
+// formatDepth 输出订单簿段落
+func formatDepth(sb *strings.Builder, depth *DepthData) {
+	if depth == nil {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("Order book (top %d levels): spread %.2f bps, bid/ask imbalance %+.2f, depth within ±1%%: bids %.0f USDT / asks %.0f USDT\n\n",
+		depthLevels, depth.SpreadBps, depth.Imbalance, depth.BidDepth1Pct, depth.AskDepth1Pct))
+	if depth.BidWall != nil {
+		sb.WriteString(fmt.Sprintf("Bid wall: %s (%.0f USDT, %.1fx median, %.2f%% below mid)\n\n",
+			formatPriceWithDynamicPrecision(depth.BidWall.Price), depth.BidWall.Notional, depth.BidWall.Multiple, depth.BidWall.DistPct))
+	}
+	if depth.AskWall != nil {
+		sb.WriteString(fmt.Sprintf("Ask wall: %s (%.0f USDT, %.1fx median, %.2f%% above mid)\n\n",
+			formatPriceWithDynamicPrecision(depth.AskWall.Price), depth.AskWall.Notional, depth.AskWall.Multiple, depth.AskWall.DistPct))
+	}
+}
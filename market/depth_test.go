@@ -0,0 +1,101 @@
+package market
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// testOrderBook 中间价100、价差0.2的订单簿，买盘99.0处有大单墙
+func testOrderBook() *OrderBook {
+	book := &OrderBook{}
+	for i := 0; i < 10; i++ {
+		bidQty, askQty := 10.0, 5.0
+		if i == 9 {
+			bidQty = 100
+		}
+		book.Bids = append(book.Bids, PriceLevel{Price: 99.9 - 0.1*float64(i), Quantity: bidQty})
+		book.Asks = append(book.Asks, PriceLevel{Price: 100.1 + 0.2*float64(i), Quantity: askQty})
+	}
+	return book
+}
+
+// TestComputeDepthFeatures 测试价差、失衡度、±1%深度和大单墙
+func TestComputeDepthFeatures(t *testing.T) {
+	depth := ComputeDepthFeatures(testOrderBook())
+	if depth == nil {
+		t.Fatal("ComputeDepthFeatures 返回nil")
+	}
+
+	// 买盘: 99.9..99.1 各10个 + 99.0×100；卖盘: 100.1..101.9 各5个
+	bidTotal := 0.0
+	for i := 0; i < 9; i++ {
+		bidTotal += (99.9 - 0.1*float64(i)) * 10
+	}
+	bidTotal += 99.0 * 100
+	askTotal := 0.0
+	askBand := 0.0
+	for i := 0; i < 10; i++ {
+		price := 100.1 + 0.2*float64(i)
+		askTotal += price * 5
+		if price <= 101 {
+			askBand += price * 5
+		}
+	}
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{name: "MidPrice", got: depth.MidPrice, want: 100},
+		{name: "SpreadBps", got: depth.SpreadBps, want: 20},
+		{name: "Imbalance", got: depth.Imbalance, want: (bidTotal - askTotal) / (bidTotal + askTotal)},
+		{name: "BidDepth1Pct", got: depth.BidDepth1Pct, want: bidTotal},
+		{name: "AskDepth1Pct", got: depth.AskDepth1Pct, want: askBand},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-6 {
+			t.Errorf("%s = %.6f, want %.6f", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if depth.BidWall == nil {
+		t.Fatal("应识别出买盘大单墙")
+	}
+	if math.Abs(depth.BidWall.Price-99.0) > 1e-9 || math.Abs(depth.BidWall.DistPct-1.0) > 1e-6 {
+		t.Errorf("BidWall = %+v, want price 99.0 at 1%%", depth.BidWall)
+	}
+	if depth.AskWall != nil {
+		t.Errorf("卖盘挂单均匀，不应有大单墙: %+v", depth.AskWall)
+	}
+
+	if ComputeDepthFeatures(&OrderBook{Bids: testOrderBook().Bids}) != nil {
+		t.Error("单边订单簿应返回nil")
+	}
+}
+
+// TestParsePriceLevels 测试深度档位解析
+func TestParsePriceLevels(t *testing.T) {
+	levels := parsePriceLevels([][2]string{{"100.5", "2"}, {"100.4", "0"}, {"x", "1"}, {"100.3", "0.5"}})
+	if len(levels) != 2 {
+		t.Fatalf("len = %d, want 2 (跳过数量为0和无法解析的档位)", len(levels))
+	}
+	if levels[0] != (PriceLevel{Price: 100.5, Quantity: 2}) || levels[1] != (PriceLevel{Price: 100.3, Quantity: 0.5}) {
+		t.Errorf("levels = %+v", levels)
+	}
+}
+
+// TestFormatDepth 测试订单簿写入市场数据输出
+func TestFormatDepth(t *testing.T) {
+	data := &Data{Symbol: "BTCUSDT", Depth: ComputeDepthFeatures(testOrderBook())}
+	output := Format(data)
+	for _, want := range []string{"Order book (top 20 levels): spread 20.00 bps", "Bid wall: 99.0", "1.00% below mid"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("输出缺少 %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "Ask wall") {
+		t.Errorf("不应输出卖盘大单墙:\n%s", output)
+	}
+}
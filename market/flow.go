@@ -0,0 +1,415 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	aggTradeStreamSuffix  = "aggTrade"
+	forceOrderStream      = "!forceOrder@arr" // 全市场强平单流（每个交易对每秒最多推送一笔最新强平）
+	flowMaxWindow         = 15 * time.Minute  // 主动成交统计保留的最长窗口
+	liquidationWindow     = time.Hour         // 强平统计窗口
+	maxLiquidationEvents  = 1000              // 单个交易对保留的强平单上限
+	liquidationClusterPct = 0.5               // 价格相差0.5%以内的同方向强平单归为一簇
+	maxLiquidationCluster = 3                 // 输出的强平簇数量
+)
+
+// flowWindows 输出主动成交统计的窗口
+var flowWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// FlowWindow 一个窗口内的主动成交统计
+type FlowWindow struct {
+	Window       time.Duration
+	BuyVolume    float64 // 主动买入成交额（USDT）
+	SellVolume   float64 // 主动卖出成交额（USDT）
+	Delta        float64 // 成交量差 Buy-Sell（USDT）
+	BuySellRatio float64 // 主动买卖比 Buy/Sell（无卖出时为0）
+}
+
+// LiquidationCluster 价格相近的同方向强平单
+type LiquidationCluster struct {
+	Side      string // long=多头被强平（强平卖单），short=空头被强平（强平买单）
+	LowPrice  float64
+	HighPrice float64
+	AvgPrice  float64 // 按金额加权的平均价格
+	Notional  float64 // 强平金额（USDT）
+	Count     int
+}
+
+// LiquidationData 最近1小时的强平统计
+type LiquidationData struct {
+	LongNotional  float64 // 多头强平金额（USDT）
+	ShortNotional float64 // 空头强平金额（USDT）
+	Count         int
+	Clusters      []LiquidationCluster // 按金额从大到小
+}
+
+// FlowData 成交流与强平数据
+type FlowData struct {
+	Windows      []FlowWindow     // 已有足够数据覆盖的窗口（从短到长），订阅后需要积累一段时间
+	CVDSeries    []float64        // 最近15分钟每分钟末的累计成交量差（USDT，oldest → latest）
+	Liquidations *LiquidationData // 无强平时为nil
+}
+
+// aggTradeWSData 归集成交流消息
+type aggTradeWSData struct {
+	Symbol       string `json:"s"`
+	Price        string `json:"p"`
+	Quantity     string `json:"q"`
+	TradeTime    int64  `json:"T"`
+	IsBuyerMaker bool   `json:"m"` // 买方为maker即主动卖出
+}
+
+// forceOrderWSData 强平单流消息
+type forceOrderWSData struct {
+	Order struct {
+		Symbol       string `json:"s"`
+		Side         string `json:"S"`
+		AvgPrice     string `json:"ap"`
+		Price        string `json:"p"`
+		FilledQty    string `json:"z"`
+		OrigQuantity string `json:"q"`
+		TradeTime    int64  `json:"T"`
+	} `json:"o"`
+}
+
+// flowBucket 每秒的主动成交额
+type flowBucket struct {
+	sec  int64
+	buy  float64
+	sell float64
+}
+
+// tradeFlow 单个交易对的滚动主动成交统计（按秒聚合，只保留 flowMaxWindow 内的数据）
+type tradeFlow struct {
+	mu       sync.Mutex
+	buckets  []flowBucket
+	firstSec int64 // 开始统计的时间，用于判断窗口是否被完整覆盖
+}
+
+func (f *tradeFlow) add(tradeTimeMs int64, price, qty float64, isBuyerMaker bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sec := tradeTimeMs / 1000
+	if f.firstSec == 0 {
+		f.firstSec = sec
+	}
+	notional := price * qty
+
+	n := len(f.buckets)
+	if n == 0 || sec > f.buckets[n-1].sec {
+		f.buckets = append(f.buckets, flowBucket{sec: sec})
+		n++
+	}
+	// 乱序到达的成交计入最新一秒，误差可以忽略
+	if isBuyerMaker {
+		f.buckets[n-1].sell += notional
+	} else {
+		f.buckets[n-1].buy += notional
+	}
+
+	cutoff := sec - int64(flowMaxWindow/time.Second)
+	drop := 0
+	for drop < len(f.buckets) && f.buckets[drop].sec <= cutoff {
+		drop++
+	}
+	if drop > 0 {
+		f.buckets = append(f.buckets[:0], f.buckets[drop:]...)
+	}
+}
+
+// snapshot 计算截至 nowMs 的各窗口统计和每分钟CVD
+func (f *tradeFlow) snapshot(nowMs int64) ([]FlowWindow, []float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.firstSec == 0 {
+		return nil, nil
+	}
+	nowSec := nowMs / 1000
+	covered := time.Duration(nowSec-f.firstSec) * time.Second
+
+	var windows []FlowWindow
+	for _, window := range flowWindows {
+		if covered < window {
+			break
+		}
+		start := nowSec - int64(window/time.Second)
+		w := FlowWindow{Window: window}
+		for _, b := range f.buckets {
+			if b.sec > start && b.sec <= nowSec {
+				w.BuyVolume += b.buy
+				w.SellVolume += b.sell
+			}
+		}
+		w.Delta = w.BuyVolume - w.SellVolume
+		if w.SellVolume > 0 {
+			w.BuySellRatio = w.BuyVolume / w.SellVolume
+		}
+		windows = append(windows, w)
+	}
+
+	// 每分钟末的累计成交量差，只输出已完整覆盖的分钟
+	minutes := int(covered / time.Minute)
+	if limit := int(flowMaxWindow / time.Minute); minutes > limit {
+		minutes = limit
+	}
+	var series []float64
+	if minutes > 0 {
+		series = make([]float64, minutes)
+		start := nowSec - int64(minutes)*60
+		for _, b := range f.buckets {
+			if b.sec <= start || b.sec > nowSec {
+				continue
+			}
+			idx := int((b.sec - start - 1) / 60)
+			series[idx] += b.buy - b.sell
+		}
+		for i := 1; i < len(series); i++ {
+			series[i] += series[i-1]
+		}
+	}
+	return windows, series
+}
+
+// LiquidationEvent 单笔强平单
+type LiquidationEvent struct {
+	Side     string // long=多头被强平，short=空头被强平
+	Price    float64
+	Notional float64
+	Time     int64 // 毫秒时间戳
+}
+
+// liquidationBook 单个交易对最近的强平单
+type liquidationBook struct {
+	mu     sync.Mutex
+	events []LiquidationEvent
+}
+
+func (b *liquidationBook) add(event LiquidationEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.events = append(b.events, event)
+	cutoff := event.Time - liquidationWindow.Milliseconds()
+	drop := 0
+	for drop < len(b.events) && b.events[drop].Time < cutoff {
+		drop++
+	}
+	if over := len(b.events) - drop - maxLiquidationEvents; over > 0 {
+		drop += over
+	}
+	if drop > 0 {
+		b.events = append(b.events[:0], b.events[drop:]...)
+	}
+}
+
+// snapshot 统计截至 nowMs 最近1小时的强平（无强平时返回nil）
+func (b *liquidationBook) snapshot(nowMs int64) *LiquidationData {
+	b.mu.Lock()
+	var events []LiquidationEvent
+	cutoff := nowMs - liquidationWindow.Milliseconds()
+	for _, e := range b.events {
+		if e.Time >= cutoff && e.Time <= nowMs {
+			events = append(events, e)
+		}
+	}
+	b.mu.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+	data := &LiquidationData{Count: len(events), Clusters: clusterLiquidations(events)}
+	for _, e := range events {
+		if e.Side == "long" {
+			data.LongNotional += e.Notional
+		} else {
+			data.ShortNotional += e.Notional
+		}
+	}
+	return data
+}
+
+// clusterLiquidations 按方向和价格聚类强平单，返回金额最大的 maxLiquidationCluster 个簇
+func clusterLiquidations(events []LiquidationEvent) []LiquidationCluster {
+	sorted := append([]LiquidationEvent(nil), events...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Side != sorted[j].Side {
+			return sorted[i].Side < sorted[j].Side
+		}
+		return sorted[i].Price < sorted[j].Price
+	})
+
+	var clusters []LiquidationCluster
+	var weighted float64
+	for _, e := range sorted {
+		n := len(clusters)
+		if n > 0 && clusters[n-1].Side == e.Side && e.Price <= clusters[n-1].LowPrice*(1+liquidationClusterPct/100) {
+			c := &clusters[n-1]
+			c.HighPrice = e.Price
+			c.Notional += e.Notional
+			c.Count++
+			weighted += e.Price * e.Notional
+			c.AvgPrice = weighted / c.Notional
+			continue
+		}
+		weighted = e.Price * e.Notional
+		clusters = append(clusters, LiquidationCluster{
+			Side: e.Side, LowPrice: e.Price, HighPrice: e.Price, AvgPrice: e.Price, Notional: e.Notional, Count: 1,
+		})
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].Notional > clusters[j].Notional })
+	if len(clusters) > maxLiquidationCluster {
+		clusters = clusters[:maxLiquidationCluster]
+	}
+	return clusters
+}
+
+// subscribeAggTrade 按需订阅归集成交流（不再被关注时由 evictOnDemandStreams 退订，并丢弃已积累的成交统计）
+func (m *WSMonitor) subscribeAggTrade(symbol string) error {
+	stream := fmt.Sprintf("%s@%s", strings.ToLower(symbol), aggTradeStreamSuffix)
+	return m.acquireStream(symbol, stream, 1000,
+		func(ch <-chan []byte) {
+			for data := range ch {
+				m.processAggTrade(symbol, data)
+			}
+		},
+		func() { m.flowMap.Delete(symbol) })
+}
+
+func (m *WSMonitor) processAggTrade(symbol string, data []byte) {
+	var trade aggTradeWSData
+	if err := json.Unmarshal(data, &trade); err != nil {
+		log.Printf("解析归集成交数据失败: %v", err)
+		return
+	}
+	price, err1 := parseFloat(trade.Price)
+	qty, err2 := parseFloat(trade.Quantity)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	value, _ := m.flowMap.LoadOrStore(symbol, &tradeFlow{})
+	value.(*tradeFlow).add(trade.TradeTime, price, qty, trade.IsBuyerMaker)
+}
+
+// subscribeForceOrders 订阅全市场强平单流
+func (m *WSMonitor) subscribeForceOrders() error {
+	if _, loaded := m.subscribed.LoadOrStore(forceOrderStream, true); loaded {
+		return nil
+	}
+	ch := m.combinedClient.AddSubscriber(forceOrderStream, 1000)
+	go func() {
+		for data := range ch {
+			m.processForceOrder(data)
+		}
+	}()
+	return m.combinedClient.SubscribeForceOrders()
+}
+
+func (m *WSMonitor) processForceOrder(data []byte) {
+	var msg forceOrderWSData
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("解析强平数据失败: %v", err)
+		return
+	}
+	order := msg.Order
+	price, err := parseFloat(order.AvgPrice)
+	if err != nil || price <= 0 {
+		price, _ = parseFloat(order.Price)
+	}
+	qty, err := parseFloat(order.FilledQty)
+	if err != nil || qty <= 0 {
+		qty, _ = parseFloat(order.OrigQuantity)
+	}
+	if price <= 0 || qty <= 0 {
+		return
+	}
+
+	// 强平卖单平掉的是多头仓位
+	side := "short"
+	if order.Side == "SELL" {
+		side = "long"
+	}
+	value, _ := m.liquidationMap.LoadOrStore(order.Symbol, &liquidationBook{})
+	value.(*liquidationBook).add(LiquidationEvent{Side: side, Price: price, Notional: price * qty, Time: order.TradeTime})
+}
+
+// GetFlow 获取主动成交与强平数据（首次访问时按需订阅归集成交流，之后逐步积累窗口数据）
+func (m *WSMonitor) GetFlow(symbol string) *FlowData {
+	symbol = strings.ToUpper(symbol)
+	if err := m.subscribeAggTrade(symbol); err != nil {
+		log.Printf("警告: 订阅%s归集成交流失败: %v", symbol, err)
+	}
+
+	nowMs := time.Now().UnixMilli()
+	data := &FlowData{}
+	if value, ok := m.flowMap.Load(symbol); ok {
+		data.Windows, data.CVDSeries = value.(*tradeFlow).snapshot(nowMs)
+	}
+	if value, ok := m.liquidationMap.Load(symbol); ok {
+		data.Liquidations = value.(*liquidationBook).snapshot(nowMs)
+	}
+	if len(data.Windows) == 0 && len(data.CVDSeries) == 0 && data.Liquidations == nil {
+		return nil
+	}
+	return data
+}
+
+// getFlowData 获取成交流数据，监控器未启动时返回nil
+func getFlowData(symbol string) *FlowData {
+	if WSMonitorCli == nil {
+		return nil
+	}
+	return WSMonitorCli.GetFlow(symbol)
+}
+
+// formatFlow 输出主动成交与强平段落
+func formatFlow(sb *strings.Builder, flow *FlowData) {
+	if flow == nil {
+		return
+	}
+
+	if len(flow.Windows) > 0 {
+		parts := make([]string, len(flow.Windows))
+		for i, w := range flow.Windows {
+			parts[i] = fmt.Sprintf("%s delta %+.0f USDT (buy %.0f / sell %.0f, ratio %.2f)",
+				formatWindow(w.Window), w.Delta, w.BuyVolume, w.SellVolume, w.BuySellRatio)
+		}
+		sb.WriteString(fmt.Sprintf("Taker flow: %s\n\n", strings.Join(parts, "; ")))
+	}
+	if len(flow.CVDSeries) > 0 {
+		values := make([]string, len(flow.CVDSeries))
+		for i, v := range flow.CVDSeries {
+			values[i] = fmt.Sprintf("%.0f", v)
+		}
+		sb.WriteString(fmt.Sprintf("Cumulative volume delta (1‑minute, USDT, oldest → latest): [%s]\n\n", strings.Join(values, ", ")))
+	}
+
+	liq := flow.Liquidations
+	if liq == nil {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("Liquidations (last 1h, %d orders): longs %.0f USDT / shorts %.0f USDT\n\n",
+		liq.Count, liq.LongNotional, liq.ShortNotional))
+	for _, c := range liq.Clusters {
+		sb.WriteString(fmt.Sprintf("Liquidation cluster: %s liquidated %s-%s (avg %s, %.0f USDT, %d orders)\n\n",
+			c.Side+"s", formatPriceWithDynamicPrecision(c.LowPrice), formatPriceWithDynamicPrecision(c.HighPrice),
+			formatPriceWithDynamicPrecision(c.AvgPrice), c.Notional, c.Count))
+	}
+}
+
+// formatWindow 将窗口时长格式化为 1m/5m/15m
+func formatWindow(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%dm", int(d/time.Minute))
+}
@@ -0,0 +1,145 @@
+package market
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTradeFlowWindows 测试主动成交窗口统计与窗口覆盖判断
+func TestTradeFlowWindows(t *testing.T) {
+	f := &tradeFlow{}
+	base := int64(1_700_000_000_000)
+	// 16分钟内每10秒一笔：偶数笔主动买入 100×2，奇数笔主动卖出 100×1
+	for i := 0; i < 96; i++ {
+		f.add(base+int64(i)*10_000, 100, float64(2-i%2), i%2 == 1)
+	}
+	now := base + 95*10_000
+
+	windows, series := f.snapshot(now)
+	if len(windows) != 3 {
+		t.Fatalf("len(windows) = %d, want 3", len(windows))
+	}
+	tests := []struct {
+		buy, sell  float64
+		wantRatio  float64
+		wantWindow time.Duration
+	}{
+		{buy: 3 * 200, sell: 3 * 100, wantRatio: 2, wantWindow: time.Minute},
+		{buy: 15 * 200, sell: 15 * 100, wantRatio: 2, wantWindow: 5 * time.Minute},
+		{buy: 45 * 200, sell: 45 * 100, wantRatio: 2, wantWindow: 15 * time.Minute},
+	}
+	for i, tt := range tests {
+		w := windows[i]
+		if w.Window != tt.wantWindow || w.BuyVolume != tt.buy || w.SellVolume != tt.sell ||
+			w.Delta != tt.buy-tt.sell || math.Abs(w.BuySellRatio-tt.wantRatio) > 1e-9 {
+			t.Errorf("windows[%d] = %+v, want buy %.0f sell %.0f", i, w, tt.buy, tt.sell)
+		}
+	}
+
+	// 每分钟 +300，累计 15 分钟
+	if len(series) != 15 || series[0] != 300 || series[14] != 4500 {
+		t.Errorf("CVD series = %v, want 15 points from 300 to 4500", series)
+	}
+
+	// 旧数据按最长窗口裁剪
+	if oldest := f.buckets[0].sec; oldest <= now/1000-int64(flowMaxWindow/time.Second) {
+		t.Errorf("最早的bucket %d 应已被裁剪", oldest)
+	}
+
+	// 刚开始统计时窗口未被覆盖
+	fresh := &tradeFlow{}
+	fresh.add(base, 100, 1, false)
+	windows, series = fresh.snapshot(base + 30_000)
+	if len(windows) != 0 || len(series) != 0 {
+		t.Errorf("30秒数据不应输出窗口: %v %v", windows, series)
+	}
+}
+
+// TestClusterLiquidations 测试强平单按方向和价格聚类
+func TestClusterLiquidations(t *testing.T) {
+	events := []LiquidationEvent{
+		{Side: "long", Price: 100.0, Notional: 1000},
+		{Side: "long", Price: 100.4, Notional: 3000},
+		{Side: "long", Price: 102.0, Notional: 500},
+		{Side: "short", Price: 110.0, Notional: 2000},
+		{Side: "short", Price: 110.3, Notional: 2000},
+		{Side: "long", Price: 95.0, Notional: 100},
+	}
+
+	clusters := clusterLiquidations(events)
+	if len(clusters) != maxLiquidationCluster {
+		t.Fatalf("len(clusters) = %d, want %d", len(clusters), maxLiquidationCluster)
+	}
+	first := clusters[0]
+	if first.Side != "long" || first.Notional != 4000 || first.Count != 2 || first.LowPrice != 100.0 || first.HighPrice != 100.4 {
+		t.Errorf("clusters[0] = %+v", first)
+	}
+	if math.Abs(first.AvgPrice-100.3) > 1e-9 {
+		t.Errorf("AvgPrice = %.6f, want 100.3", first.AvgPrice)
+	}
+	if second := clusters[1]; second.Side != "short" || second.Notional != 4000 || second.Count != 2 {
+		t.Errorf("clusters[1] = %+v", second)
+	}
+	if third := clusters[2]; third.Notional != 500 {
+		t.Errorf("clusters[2] = %+v, want 102.0 单笔簇", third)
+	}
+}
+
+// TestProcessStreamMessages 测试归集成交与强平单消息解析
+func TestProcessStreamMessages(t *testing.T) {
+	m := &WSMonitor{}
+	base := int64(1_700_000_000_000)
+
+	m.processAggTrade("BTCUSDT", []byte(`{"e":"aggTrade","s":"BTCUSDT","p":"50000","q":"0.2","T":1700000000000,"m":false}`))
+	m.processAggTrade("BTCUSDT", []byte(`{"e":"aggTrade","s":"BTCUSDT","p":"50000","q":"0.1","T":1700000070000,"m":true}`))
+	value, ok := m.flowMap.Load("BTCUSDT")
+	if !ok {
+		t.Fatal("未记录归集成交")
+	}
+	windows, _ := value.(*tradeFlow).snapshot(base + 70_000)
+	if len(windows) != 1 || windows[0].BuyVolume != 0 || windows[0].SellVolume != 5000 {
+		t.Errorf("1m窗口 = %+v, want 只有最近一笔主动卖出 5000", windows)
+	}
+
+	m.processForceOrder([]byte(`{"e":"forceOrder","o":{"s":"ETHUSDT","S":"SELL","p":"2990","ap":"3000","q":"2","z":"2","T":1700000000000}}`))
+	m.processForceOrder([]byte(`{"e":"forceOrder","o":{"s":"ETHUSDT","S":"BUY","p":"3100","ap":"0","q":"1","z":"0","T":1700000001000}}`))
+	value, ok = m.liquidationMap.Load("ETHUSDT")
+	if !ok {
+		t.Fatal("未记录强平单")
+	}
+	liq := value.(*liquidationBook).snapshot(base + 2_000)
+	if liq == nil || liq.Count != 2 || liq.LongNotional != 6000 || liq.ShortNotional != 3100 {
+		t.Errorf("强平统计 = %+v, want longs 6000 / shorts 3100", liq)
+	}
+	if liq := value.(*liquidationBook).snapshot(base + liquidationWindow.Milliseconds() + 5_000); liq != nil {
+		t.Errorf("超过1小时的强平单不应统计: %+v", liq)
+	}
+}
+
+// TestFormatFlow 测试成交流与强平写入市场数据输出
+func TestFormatFlow(t *testing.T) {
+	data := &Data{
+		Symbol: "BTCUSDT",
+		Flow: &FlowData{
+			Windows:   []FlowWindow{{Window: time.Minute, BuyVolume: 3000, SellVolume: 1000, Delta: 2000, BuySellRatio: 3}},
+			CVDSeries: []float64{-500, 1500},
+			Liquidations: &LiquidationData{
+				LongNotional: 4000, Count: 2,
+				Clusters: []LiquidationCluster{{Side: "long", LowPrice: 100, HighPrice: 100.4, AvgPrice: 100.3, Notional: 4000, Count: 2}},
+			},
+		},
+	}
+	output := Format(data)
+	for _, want := range []string{
+		"Taker flow: 1m delta +2000 USDT (buy 3000 / sell 1000, ratio 3.00)",
+		"Cumulative volume delta (1‑minute, USDT, oldest → latest): [-500, 1500]",
+		"Liquidations (last 1h, 2 orders): longs 4000 USDT / shorts 0 USDT",
+		"Liquidation cluster: longs liquidated 100.0",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("输出缺少 %q:\n%s", want, output)
+		}
+	}
+}
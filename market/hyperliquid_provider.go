@@ -0,0 +1,523 @@
+package market
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	hyperliquidMainnetURL = "https://api.hyperliquid.xyz"
+	hyperliquidTestnetURL = "https://api.hyperliquid-testnet.xyz"
+	hyperliquidCtxTTL     = 10 * time.Second // 资产上下文（资金费率、持仓量）缓存时间
+	hyperliquidFundingH   = 1.0              // Hyperliquid 每小时结算资金费
+	hyperliquidPingEvery  = 50 * time.Second // 服务端60秒无消息会断开连接
+)
+
+// hyperliquidProvider Hyperliquid 行情（info 接口 + WebSocket K线推送）
+type hyperliquidProvider struct {
+	infoURL string
+	client  *http.Client
+	candles *hyperliquidCandleStream // 为nil时每次请求 info 接口
+
+	ctxMu      sync.Mutex
+	coins      map[string]string // 大写币种 -> Hyperliquid 币种名（如 KPEPE -> kPEPE）
+	assetCtxs  map[string]hyperliquidAssetCtx
+	ctxUpdated time.Time
+}
+
+type hyperliquidAssetCtx struct {
+	Funding      string `json:"funding"`
+	OpenInterest string `json:"openInterest"`
+	MarkPx       string `json:"markPx"`
+}
+
+// hyperliquidCandle info 接口和 WebSocket 的K线格式
+type hyperliquidCandle struct {
+	OpenTime  int64  `json:"t"`
+	CloseTime int64  `json:"T"`
+	Coin      string `json:"s"`
+	Interval  string `json:"i"`
+	Open      string `json:"o"`
+	Close     string `json:"c"`
+	High      string `json:"h"`
+	Low       string `json:"l"`
+	Volume    string `json:"v"`
+	Trades    int    `json:"n"`
+}
+
+func (c hyperliquidCandle) toKline() Kline {
+	k := Kline{OpenTime: c.OpenTime, CloseTime: c.CloseTime, Trades: c.Trades}
+	k.Open, _ = parseFloat(c.Open)
+	k.High, _ = parseFloat(c.High)
+	k.Low, _ = parseFloat(c.Low)
+	k.Close, _ = parseFloat(c.Close)
+	k.Volume, _ = parseFloat(c.Volume)
+	return k
+}
+
+func newHyperliquidProvider(testnet bool) *hyperliquidProvider {
+	apiURL := hyperliquidMainnetURL
+	if testnet {
+		apiURL = hyperliquidTestnetURL
+	}
+	return &hyperliquidProvider{
+		infoURL: apiURL + "/info",
+		client:  NewAPIClient().client,
+		candles: newHyperliquidCandleStream(strings.Replace(apiURL, "https://", "wss://", 1) + "/ws"),
+	}
+}
+
+func (p *hyperliquidProvider) Name() string { return "hyperliquid" }
+
+// info 请求 info 接口
+func (p *hyperliquidProvider) info(request interface{}, out interface{}) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Post(p.infoURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Hyperliquid info 接口返回 %d: %s", resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// refreshAssetCtxs 刷新币种列表和资产上下文（带缓存）
+func (p *hyperliquidProvider) refreshAssetCtxs() error {
+	p.ctxMu.Lock()
+	defer p.ctxMu.Unlock()
+	if p.assetCtxs != nil && time.Since(p.ctxUpdated) < hyperliquidCtxTTL {
+		return nil
+	}
+
+	var raw []json.RawMessage
+	if err := p.info(map[string]string{"type": "metaAndAssetCtxs"}, &raw); err != nil {
+		return fmt.Errorf("获取Hyperliquid资产信息失败: %w", err)
+	}
+	if len(raw) != 2 {
+		return fmt.Errorf("Hyperliquid资产信息格式错误")
+	}
+	var meta struct {
+		Universe []struct {
+			Name string `json:"name"`
+		} `json:"universe"`
+	}
+	var ctxs []hyperliquidAssetCtx
+	if err := json.Unmarshal(raw[0], &meta); err != nil {
+		return fmt.Errorf("解析Hyperliquid币种列表失败: %w", err)
+	}
+	if err := json.Unmarshal(raw[1], &ctxs); err != nil {
+		return fmt.Errorf("解析Hyperliquid资产上下文失败: %w", err)
+	}
+
+	coins := make(map[string]string, len(meta.Universe))
+	assetCtxs := make(map[string]hyperliquidAssetCtx, len(meta.Universe))
+	for i, asset := range meta.Universe {
+		coins[strings.ToUpper(asset.Name)] = asset.Name
+		if i < len(ctxs) {
+			assetCtxs[asset.Name] = ctxs[i]
+		}
+	}
+	p.coins, p.assetCtxs, p.ctxUpdated = coins, assetCtxs, time.Now()
+	return nil
+}
+
+// resolveCoin 将 BTCUSDT / 1000PEPEUSDT 转换为 Hyperliquid 币种名 BTC / kPEPE
+func (p *hyperliquidProvider) resolveCoin(symbol string) (string, error) {
+	if err := p.refreshAssetCtxs(); err != nil {
+		return "", err
+	}
+	base := strings.ToUpper(ConvertSymbol(symbol, VenueBinance, VenueHyperliquid))
+	p.ctxMu.Lock()
+	defer p.ctxMu.Unlock()
+	coin, ok := p.coins[base]
+	if !ok {
+		return "", fmt.Errorf("Hyperliquid 不存在币种: %s", base)
+	}
+	return coin, nil
+}
+
+func (p *hyperliquidProvider) assetCtx(symbol string) (hyperliquidAssetCtx, error) {
+	coin, err := p.resolveCoin(symbol)
+	if err != nil {
+		return hyperliquidAssetCtx{}, err
+	}
+	p.ctxMu.Lock()
+	defer p.ctxMu.Unlock()
+	return p.assetCtxs[coin], nil
+}
+
+func (p *hyperliquidProvider) GetKlines(symbol, interval string) ([]Kline, error) {
+	coin, err := p.resolveCoin(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if p.candles != nil {
+		if klines, ok := p.candles.get(coin, interval); ok {
+			return klines, nil
+		}
+	}
+
+	duration, ok := IntervalDuration(interval)
+	if !ok {
+		return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+	end := time.Now()
+	request := map[string]interface{}{
+		"type": "candleSnapshot",
+		"req": map[string]interface{}{
+			"coin":      coin,
+			"interval":  interval,
+			"startTime": end.Add(-100 * duration).UnixMilli(),
+			"endTime":   end.UnixMilli(),
+		},
+	}
+	var candles []hyperliquidCandle
+	if err := p.info(request, &candles); err != nil {
+		return nil, fmt.Errorf("获取Hyperliquid %s K线失败: %w", coin, err)
+	}
+	klines := make([]Kline, 0, len(candles))
+	for _, c := range candles {
+		klines = append(klines, c.toKline())
+	}
+	if len(klines) > 100 {
+		klines = klines[len(klines)-100:]
+	}
+
+	// 快照写入缓存后由WebSocket推送持续更新
+	if p.candles != nil {
+		p.candles.track(coin, interval, klines)
+	}
+	return klines, nil
+}
+
+func (p *hyperliquidProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	ctx, err := p.assetCtx(symbol)
+	if err != nil {
+		return nil, err
+	}
+	oi, _ := parseFloat(ctx.OpenInterest)
+	return &OIData{Latest: oi, Average: oi * 0.999}, nil
+}
+
+func (p *hyperliquidProvider) GetFundingRate(symbol string) (float64, error) {
+	ctx, err := p.assetCtx(symbol)
+	if err != nil {
+		return 0, err
+	}
+	rate, err := parseFloat(ctx.Funding)
+	if err != nil {
+		return 0, err
+	}
+	return rate * DefaultFundingIntervalHours / hyperliquidFundingH, nil
+}
+
+func (p *hyperliquidProvider) GetMarkPrice(symbol string) (float64, error) {
+	ctx, err := p.assetCtx(symbol)
+	if err != nil {
+		return 0, err
+	}
+	price, err := parseFloat(ctx.MarkPx)
+	if err != nil || price <= 0 {
+		return 0, fmt.Errorf("%s 标记价格无效: %q", symbol, ctx.MarkPx)
+	}
+	return price, nil
+}
+
+func (p *hyperliquidProvider) FundingIntervalHours(symbol string) float64 {
+	return hyperliquidFundingH
+}
+
+func (p *hyperliquidProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	coin, err := p.resolveCoin(symbol)
+	if err != nil {
+		return nil, err
+	}
+	var book struct {
+		Levels [2][]struct {
+			Px string `json:"px"`
+			Sz string `json:"sz"`
+		} `json:"levels"`
+	}
+	if err := p.info(map[string]string{"type": "l2Book", "coin": coin}, &book); err != nil {
+		return nil, fmt.Errorf("获取Hyperliquid %s 订单簿失败: %w", coin, err)
+	}
+
+	sides := make([][][2]string, 2)
+	for i, levels := range book.Levels {
+		if len(levels) > depthLevels {
+			levels = levels[:depthLevels]
+		}
+		for _, level := range levels {
+			sides[i] = append(sides[i], [2]string{level.Px, level.Sz})
+		}
+	}
+	return &OrderBook{
+		Bids:       parsePriceLevels(sides[0]),
+		Asks:       parsePriceLevels(sides[1]),
+		UpdateTime: time.Now(),
+	}, nil
+}
+
+// SubscribePrices 订阅 allMids 中间价推送（连接断开期间没有推送，重连后自动恢复）
+func (p *hyperliquidProvider) SubscribePrices(listener PriceListener) (func(), bool) {
+	return p.candles.addPriceListener(listener), true
+}
+
+// hyperliquidCandleStream Hyperliquid K线与中间价推送
+// 只缓存已订阅且连接正常期间的K线；断线后清空缓存，重连前由 info 接口提供数据，避免K线缺口
+type hyperliquidCandleStream struct {
+	url    string
+	once   sync.Once
+	mu     sync.Mutex // 保护 conn/subs，并串行化写操作
+	conn   *websocket.Conn
+	subs   map[string]bool // coin|interval
+	klines sync.Map        // coin|interval -> []Kline
+	mids   bool            // 是否订阅了 allMids（有价格监听器后订阅）
+
+	listenersMu    sync.RWMutex
+	priceListeners map[int]PriceListener
+	nextListenerID int
+}
+
+func newHyperliquidCandleStream(url string) *hyperliquidCandleStream {
+	return &hyperliquidCandleStream{url: url, subs: make(map[string]bool)}
+}
+
+func candleKey(coin, interval string) string {
+	return coin + "|" + interval
+}
+
+// get 获取推送维护的K线（未订阅或连接断开时返回false）
+func (s *hyperliquidCandleStream) get(coin, interval string) ([]Kline, bool) {
+	value, ok := s.klines.Load(candleKey(coin, interval))
+	if !ok {
+		return nil, false
+	}
+	klines := value.([]Kline)
+	return append([]Kline(nil), klines...), true
+}
+
+// track 写入K线快照并订阅推送（连接未就绪时不缓存快照）
+func (s *hyperliquidCandleStream) track(coin, interval string, snapshot []Kline) {
+	s.once.Do(func() { go s.run() })
+
+	key := candleKey(coin, interval)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.klines.Store(key, append([]Kline(nil), snapshot...))
+	}
+	if s.subs[key] {
+		return
+	}
+	s.subs[key] = true
+	if s.conn != nil {
+		if err := s.conn.WriteJSON(candleSubscription(coin, interval)); err != nil {
+			log.Printf("⚠️ 订阅Hyperliquid %s K线失败: %v", key, err)
+		}
+	}
+}
+
+// addPriceListener 注册中间价监听器（首次注册时订阅 allMids），返回取消注册的函数
+func (s *hyperliquidCandleStream) addPriceListener(listener PriceListener) func() {
+	s.once.Do(func() { go s.run() })
+
+	s.listenersMu.Lock()
+	if s.priceListeners == nil {
+		s.priceListeners = make(map[int]PriceListener)
+	}
+	id := s.nextListenerID
+	s.nextListenerID++
+	s.priceListeners[id] = listener
+	s.listenersMu.Unlock()
+
+	s.mu.Lock()
+	if !s.mids {
+		s.mids = true
+		if s.conn != nil {
+			if err := s.conn.WriteJSON(midsSubscription()); err != nil {
+				log.Printf("⚠️ 订阅Hyperliquid中间价失败: %v", err)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	return func() {
+		s.listenersMu.Lock()
+		defer s.listenersMu.Unlock()
+		delete(s.priceListeners, id)
+	}
+}
+
+func midsSubscription() map[string]interface{} {
+	return map[string]interface{}{
+		"method":       "subscribe",
+		"subscription": map[string]string{"type": "allMids"},
+	}
+}
+
+func candleSubscription(coin, interval string) map[string]interface{} {
+	return map[string]interface{}{
+		"method":       "subscribe",
+		"subscription": map[string]string{"type": "candle", "coin": coin, "interval": interval},
+	}
+}
+
+// run 维持连接：断线后清空缓存并自动重连、重新订阅
+func (s *hyperliquidCandleStream) run() {
+	for {
+		conn, _, err := (&websocket.Dialer{HandshakeTimeout: 10 * time.Second}).Dial(s.url, nil)
+		if err != nil {
+			log.Printf("Hyperliquid WebSocket连接失败: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		s.mu.Lock()
+		s.conn = conn
+		for key := range s.subs {
+			parts := strings.SplitN(key, "|", 2)
+			if err := conn.WriteJSON(candleSubscription(parts[0], parts[1])); err != nil {
+				log.Printf("⚠️ 订阅Hyperliquid %s K线失败: %v", key, err)
+			}
+		}
+		if s.mids {
+			if err := conn.WriteJSON(midsSubscription()); err != nil {
+				log.Printf("⚠️ 订阅Hyperliquid中间价失败: %v", err)
+			}
+		}
+		s.mu.Unlock()
+		log.Printf("Hyperliquid WebSocket连接成功")
+
+		done := make(chan struct{})
+		go s.keepAlive(conn, done)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				log.Printf("读取Hyperliquid消息失败: %v", err)
+				break
+			}
+			s.handleMessage(message)
+		}
+		close(done)
+
+		s.mu.Lock()
+		s.conn = nil
+		s.klines.Range(func(key, _ interface{}) bool {
+			s.klines.Delete(key)
+			return true
+		})
+		s.mu.Unlock()
+		conn.Close()
+		time.Sleep(3 * time.Second)
+	}
+}
+
+func (s *hyperliquidCandleStream) keepAlive(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(hyperliquidPingEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			err := conn.WriteJSON(map[string]string{"method": "ping"})
+			s.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleMessage 处理K线推送（同一根K线覆盖，新K线追加，保留最近100根）和中间价推送
+func (s *hyperliquidCandleStream) handleMessage(message []byte) {
+	var msg struct {
+		Channel string          `json:"channel"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return
+	}
+	if msg.Channel == "allMids" {
+		s.handleMids(msg.Data)
+		return
+	}
+	if msg.Channel != "candle" {
+		return
+	}
+	var candle hyperliquidCandle
+	if err := json.Unmarshal(msg.Data, &candle); err != nil {
+		log.Printf("解析Hyperliquid K线失败: %v", err)
+		return
+	}
+
+	key := candleKey(candle.Coin, candle.Interval)
+	value, ok := s.klines.Load(key)
+	if !ok {
+		// 还没有快照，等待下次 info 请求写入
+		return
+	}
+	klines := append([]Kline(nil), value.([]Kline)...)
+	kline := candle.toKline()
+	if n := len(klines); n > 0 && klines[n-1].OpenTime == kline.OpenTime {
+		klines[n-1] = kline
+	} else {
+		klines = append(klines, kline)
+		if len(klines) > 100 {
+			klines = klines[1:]
+		}
+	}
+	s.klines.Store(key, klines)
+}
+
+// handleMids 把中间价推送转换为币安格式币种名后通知价格监听器
+func (s *hyperliquidCandleStream) handleMids(data json.RawMessage) {
+	s.listenersMu.RLock()
+	defer s.listenersMu.RUnlock()
+	if len(s.priceListeners) == 0 {
+		return
+	}
+
+	var mids struct {
+		Mids map[string]string `json:"mids"`
+	}
+	if err := json.Unmarshal(data, &mids); err != nil {
+		log.Printf("解析Hyperliquid中间价失败: %v", err)
+		return
+	}
+	for coin, raw := range mids.Mids {
+		// "@" 开头的是现货交易对
+		if strings.HasPrefix(coin, "@") {
+			continue
+		}
+		price, err := parseFloat(raw)
+		if err != nil || price <= 0 {
+			continue
+		}
+		symbol := ConvertSymbol(coin, VenueHyperliquid, VenueBinance)
+		for _, listener := range s.priceListeners {
+			listener(symbol, price)
+		}
+	}
+}
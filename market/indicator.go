@@ -0,0 +1,243 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Indicator 技术指标，按时间顺序每输入一根K线调用一次 Update
+// 注意：目前只提供批量重放——每个决策周期由 EvaluateIndicator 新建实例并重放完整K线序列，
+// 指标状态不跨周期保留，也不接入 WSMonitor 的K线推送
+type Indicator interface {
+	Update(k Kline)    // 输入下一根K线（按时间顺序）
+	Ready() bool       // K线数量是否足以输出有效值
+	Fields() []string  // 输出字段名（如 upper/middle/lower）
+	Values() []float64 // 当前值，顺序与 Fields 一致
+}
+
+// IndicatorFactory 按参数创建指标实例（参数已按默认值补全）
+type IndicatorFactory func(params []float64) (Indicator, error)
+
+type indicatorDef struct {
+	defaults []float64
+	factory  IndicatorFactory
+}
+
+var indicatorRegistry = make(map[string]indicatorDef)
+
+// RegisterIndicator 注册技术指标（名称不区分大小写，defaults 为各参数的默认值）
+func RegisterIndicator(name string, defaults []float64, factory IndicatorFactory) {
+	indicatorRegistry[strings.ToLower(name)] = indicatorDef{defaults: defaults, factory: factory}
+}
+
+// IndicatorNames 已注册的指标名称（按字母排序）
+func IndicatorNames() []string {
+	names := make([]string, 0, len(indicatorRegistry))
+	for name := range indicatorRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IndicatorSpec 指标声明，如 "bb(20,2)@4h" 表示4小时K线上的20周期、2倍标准差布林带
+type IndicatorSpec struct {
+	Name     string
+	Params   []float64
+	Interval string // 为空时使用交易员的主周期（最短周期）
+}
+
+// String 规范化的指标声明
+func (s IndicatorSpec) String() string {
+	var sb strings.Builder
+	sb.WriteString(s.Name)
+	if len(s.Params) > 0 {
+		params := make([]string, len(s.Params))
+		for i, p := range s.Params {
+			params[i] = strconv.FormatFloat(p, 'f', -1, 64)
+		}
+		sb.WriteString("(" + strings.Join(params, ",") + ")")
+	}
+	if s.Interval != "" {
+		sb.WriteString("@" + s.Interval)
+	}
+	return sb.String()
+}
+
+// ParseIndicatorSpec 解析单个指标声明（未给出的参数使用默认值）
+func ParseIndicatorSpec(raw string) (IndicatorSpec, error) {
+	raw = strings.TrimSpace(raw)
+	var spec IndicatorSpec
+
+	if at := strings.LastIndex(raw, "@"); at >= 0 {
+		spec.Interval = strings.TrimSpace(raw[at+1:])
+		raw = strings.TrimSpace(raw[:at])
+		if _, ok := intervalDurations[spec.Interval]; !ok {
+			return IndicatorSpec{}, fmt.Errorf("指标 %s 的K线周期无效: %s", raw, spec.Interval)
+		}
+	}
+
+	var params []float64
+	if open := strings.Index(raw, "("); open >= 0 {
+		if !strings.HasSuffix(raw, ")") {
+			return IndicatorSpec{}, fmt.Errorf("指标声明格式错误: %s", raw)
+		}
+		for _, part := range strings.Split(raw[open+1:len(raw)-1], ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil || v < 0 {
+				return IndicatorSpec{}, fmt.Errorf("指标 %s 的参数无效: %s", raw, part)
+			}
+			params = append(params, v)
+		}
+		raw = raw[:open]
+	}
+
+	spec.Name = strings.ToLower(strings.TrimSpace(raw))
+	def, ok := indicatorRegistry[spec.Name]
+	if !ok {
+		return IndicatorSpec{}, fmt.Errorf("未知的技术指标: %s（支持: %s）", spec.Name, strings.Join(IndicatorNames(), ", "))
+	}
+	if len(params) > len(def.defaults) {
+		return IndicatorSpec{}, fmt.Errorf("指标 %s 最多 %d 个参数", spec.Name, len(def.defaults))
+	}
+	spec.Params = append(params, def.defaults[len(params):]...)
+	return spec, nil
+}
+
+// ParseIndicatorSpecs 解析逗号分隔的指标声明列表（括号内的逗号不作为分隔符）
+func ParseIndicatorSpecs(raw string) ([]IndicatorSpec, error) {
+	var specs []IndicatorSpec
+	depth, start := 0, 0
+	for i := 0; i <= len(raw); i++ {
+		if i < len(raw) {
+			switch raw[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if part := strings.TrimSpace(raw[start:i]); part != "" {
+			spec, err := ParseIndicatorSpec(part)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec)
+		}
+		start = i + 1
+	}
+	return specs, nil
+}
+
+// NewIndicator 按声明创建指标实例
+func NewIndicator(spec IndicatorSpec) (Indicator, error) {
+	def, ok := indicatorRegistry[spec.Name]
+	if !ok {
+		return nil, fmt.Errorf("未知的技术指标: %s", spec.Name)
+	}
+	params := spec.Params
+	if len(params) < len(def.defaults) {
+		params = append(append([]float64(nil), params...), def.defaults[len(params):]...)
+	}
+	return def.factory(params)
+}
+
+// IndicatorValue 指标计算结果（用于写入提示词）
+type IndicatorValue struct {
+	Spec   string // 规范化的指标声明，如 "bb(20,2)@4h"
+	Fields []string
+	Values []float64
+}
+
+// EvaluateIndicator 在K线序列上计算指标的最新值
+func EvaluateIndicator(spec IndicatorSpec, klines []Kline) (IndicatorValue, error) {
+	ind, err := NewIndicator(spec)
+	if err != nil {
+		return IndicatorValue{}, err
+	}
+	for _, k := range klines {
+		ind.Update(k)
+	}
+	if !ind.Ready() {
+		return IndicatorValue{}, fmt.Errorf("K线数量不足，无法计算 %s（当前 %d 根）", spec, len(klines))
+	}
+	return IndicatorValue{Spec: spec.String(), Fields: ind.Fields(), Values: ind.Values()}, nil
+}
+
+// ComputeIndicators 在数据源的K线上计算交易对的一组指标（p 为nil时使用币安），未指定周期的指标使用 defaultInterval
+// 每次调用都重放数据源返回的完整K线序列（同一周期的K线只获取一次）；单个指标失败只记录日志，不影响其他指标
+func ComputeIndicators(p MarketDataProvider, symbol string, specs []IndicatorSpec, defaultInterval string) []IndicatorValue {
+	if p == nil {
+		p = Binance
+	}
+	if len(specs) == 0 || !Available(p) {
+		return nil
+	}
+
+	klineCache := make(map[string][]Kline)
+	var results []IndicatorValue
+	for _, spec := range specs {
+		if spec.Interval == "" {
+			spec.Interval = defaultInterval
+		}
+		klines, ok := klineCache[spec.Interval]
+		if !ok {
+			var err error
+			klines, err = p.GetKlines(Normalize(symbol), spec.Interval)
+			if err != nil {
+				log.Printf("⚠️ 获取 %s %s K线失败，跳过指标: %v", symbol, spec.Interval, err)
+			}
+			klineCache[spec.Interval] = klines
+		}
+
+		value, err := EvaluateIndicator(spec, klines)
+		if err != nil {
+			log.Printf("⚠️ %s 指标 %s 计算失败: %v", symbol, spec, err)
+			continue
+		}
+		results = append(results, value)
+	}
+	return results
+}
+
+// formatIndicators 输出指标段落
+func formatIndicators(sb *strings.Builder, values []IndicatorValue) {
+	if len(values) == 0 {
+		return
+	}
+	sb.WriteString("Technical indicators (latest values):\n\n")
+	for _, v := range values {
+		parts := make([]string, len(v.Fields))
+		for i, field := range v.Fields {
+			parts[i] = fmt.Sprintf("%s=%s", field, formatIndicatorNumber(v.Values[i]))
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n\n", v.Spec, strings.Join(parts, ", ")))
+	}
+}
+
+// formatIndicatorNumber 指标值可能为负（OBV、趋势方向），按绝对值选择精度
+func formatIndicatorNumber(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+	if v < 0 {
+		return "-" + formatPriceWithDynamicPrecision(math.Abs(v))
+	}
+	return formatPriceWithDynamicPrecision(v)
+}
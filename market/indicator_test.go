@@ -0,0 +1,170 @@
+package market
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// generateIndicatorKlines 生成带趋势和波动的确定性K线（黄金值由独立的批量算法离线计算）
+func generateIndicatorKlines(count int) []Kline {
+	klines := make([]Kline, count)
+	prev := 100.0
+	for i := 0; i < count; i++ {
+		close := 100 + 5*math.Sin(float64(i)*0.3) + 0.1*float64(i)
+		open := prev
+		klines[i] = Kline{
+			OpenTime: int64(i * 180000),
+			Open:     open,
+			High:     math.Max(open, close) + 0.5 + 0.2*math.Cos(float64(i)),
+			Low:      math.Min(open, close) - 0.5 - 0.1*math.Sin(float64(i)*0.7),
+			Close:    close,
+			Volume:   1000 + 50*float64(i%7),
+		}
+		prev = close
+	}
+	return klines
+}
+
+// TestIndicatorGoldenValues 测试各指标的计算结果与黄金值一致
+func TestIndicatorGoldenValues(t *testing.T) {
+	tests := []struct {
+		spec   string
+		count  int
+		fields []string
+		want   []float64
+	}{
+		{spec: "bb", count: 100, fields: []string{"upper", "middle", "lower"}, want: []float64{116.02635717336304, 109.18355670572882, 102.3407562380946}},
+		{spec: "vwap", count: 100, fields: []string{"vwap"}, want: []float64{105.10933262343151}},
+		{spec: "vwap(20)", count: 100, fields: []string{"vwap"}, want: []float64{109.13476180681795}},
+		{spec: "stochrsi", count: 60, fields: []string{"k", "d"}, want: []float64{2.940434728593418, 1.0691513311347405}},
+		{spec: "stochrsi", count: 70, fields: []string{"k", "d"}, want: []float64{99.5191560526369, 99.8397186842123}},
+		{spec: "adx", count: 100, fields: []string{"adx", "plus_di", "minus_di"}, want: []float64{24.266131627280963, 18.07701775385188, 30.977309200889444}},
+		{spec: "obv", count: 100, fields: []string{"obv"}, want: []float64{-250}},
+		{spec: "supertrend", count: 100, fields: []string{"value", "direction"}, want: []float64{110.81773775263663, -1}},
+		{spec: "supertrend", count: 70, fields: []string{"value", "direction"}, want: []float64{106.1719576077088, 1}},
+		{spec: "ichimoku", count: 100, fields: []string{"tenkan", "kijun", "span_a", "span_b"}, want: []float64{109.25858992631679, 108.48018576925897, 108.09379440186493, 105.31177247975006}},
+		{spec: "donchian", count: 100, fields: []string{"upper", "middle", "lower"}, want: []float64{114.50200412327344, 108.50086580949818, 102.49972749572292}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			spec, err := ParseIndicatorSpec(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseIndicatorSpec(%q) error: %v", tt.spec, err)
+			}
+			got, err := EvaluateIndicator(spec, generateIndicatorKlines(tt.count))
+			if err != nil {
+				t.Fatalf("EvaluateIndicator error: %v", err)
+			}
+			if strings.Join(got.Fields, ",") != strings.Join(tt.fields, ",") {
+				t.Fatalf("Fields = %v, want %v", got.Fields, tt.fields)
+			}
+			for i, want := range tt.want {
+				if math.Abs(got.Values[i]-want) > 1e-9 {
+					t.Errorf("%s = %.12f, want %.12f", tt.fields[i], got.Values[i], want)
+				}
+			}
+		})
+	}
+}
+
+// TestIndicatorStreamingMatchesBatch 测试增量计算与 calculateRSI/calculateATR 的批量结果一致
+func TestIndicatorStreamingMatchesBatch(t *testing.T) {
+	klines := generateIndicatorKlines(100)
+
+	rsi := wilderRSI{period: 14}
+	atr := wilderATR{period: 14}
+	for i, k := range klines {
+		rsi.update(k.Close)
+		atr.update(k)
+		if i < 20 {
+			continue
+		}
+		if want := calculateRSI(klines[:i+1], 14); math.Abs(rsi.value()-want) > 1e-9 {
+			t.Fatalf("第%d根 RSI = %.10f, want %.10f", i, rsi.value(), want)
+		}
+		if want := calculateATR(klines[:i+1], 14); math.Abs(atr.atr-want) > 1e-9 {
+			t.Fatalf("第%d根 ATR = %.10f, want %.10f", i, atr.atr, want)
+		}
+	}
+}
+
+// TestIndicatorNotReady 测试K线数量不足时返回错误
+func TestIndicatorNotReady(t *testing.T) {
+	tests := []struct {
+		spec  string
+		ready int // 刚好可以输出的K线数量
+	}{
+		{spec: "bb(20,2)", ready: 20},
+		{spec: "donchian(10)", ready: 10},
+		{spec: "adx(14)", ready: 28},
+		{spec: "stochrsi(14,14,3,3)", ready: 32},
+		{spec: "supertrend(10,3)", ready: 11},
+		{spec: "ichimoku(9,26,52)", ready: 78},
+		{spec: "obv", ready: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			spec, err := ParseIndicatorSpec(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseIndicatorSpec error: %v", err)
+			}
+			if _, err := EvaluateIndicator(spec, generateIndicatorKlines(tt.ready-1)); err == nil {
+				t.Errorf("%d 根K线时应返回数量不足错误", tt.ready-1)
+			}
+			if _, err := EvaluateIndicator(spec, generateIndicatorKlines(tt.ready)); err != nil {
+				t.Errorf("%d 根K线时应可以计算: %v", tt.ready, err)
+			}
+		})
+	}
+}
+
+// TestParseIndicatorSpecs 测试指标声明解析
+func TestParseIndicatorSpecs(t *testing.T) {
+	specs, err := ParseIndicatorSpecs("BB(20, 2.5)@4h, vwap, stochrsi(14,14), ichimoku@1d")
+	if err != nil {
+		t.Fatalf("ParseIndicatorSpecs error: %v", err)
+	}
+	var got []string
+	for _, spec := range specs {
+		got = append(got, spec.String())
+	}
+	want := "bb(20,2.5)@4h|vwap(0)|stochrsi(14,14,3,3)|ichimoku(9,26,52)@1d"
+	if strings.Join(got, "|") != want {
+		t.Errorf("specs = %s, want %s", strings.Join(got, "|"), want)
+	}
+
+	for _, raw := range []string{"macd2", "bb(20,2,1)", "bb(x)", "adx@7m", "bb(20"} {
+		if _, err := ParseIndicatorSpecs(raw); err == nil {
+			t.Errorf("ParseIndicatorSpecs(%q) 应返回错误", raw)
+		}
+	}
+	for _, raw := range []string{"bb(0)", "stochrsi(14,0)", "adx(0)"} {
+		spec, err := ParseIndicatorSpec(raw)
+		if err != nil {
+			t.Fatalf("ParseIndicatorSpec(%q) error: %v", raw, err)
+		}
+		if _, err := NewIndicator(spec); err == nil {
+			t.Errorf("NewIndicator(%s) 参数越界应返回错误", spec)
+		}
+	}
+}
+
+// TestFormatIndicators 测试指标写入市场数据输出
+func TestFormatIndicators(t *testing.T) {
+	data := &Data{
+		Symbol: "BTCUSDT",
+		Indicators: []IndicatorValue{
+			{Spec: "supertrend(10,3)@4h", Fields: []string{"value", "direction"}, Values: []float64{45678.9, -1}},
+			{Spec: "obv", Fields: []string{"obv"}, Values: []float64{0}},
+		},
+	}
+	output := Format(data)
+	for _, want := range []string{"Technical indicators", "supertrend(10,3)@4h: value=45678.90, direction=-1.0000", "obv: obv=0"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("输出缺少 %q:\n%s", want, output)
+		}
+	}
+}
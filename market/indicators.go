@@ -0,0 +1,558 @@
+package market
+
+import (
+	"fmt"
+	"math"
+)
+
+// 内置技术指标，Update 只更新滚动状态（每根K线 O(1) 或 O(周期)），重放整段K线的开销与K线数量成线性
+func init() {
+	RegisterIndicator("bb", []float64{20, 2}, newBollinger)
+	RegisterIndicator("vwap", []float64{0}, newVWAP)
+	RegisterIndicator("stochrsi", []float64{14, 14, 3, 3}, newStochRSI)
+	RegisterIndicator("adx", []float64{14}, newADX)
+	RegisterIndicator("obv", nil, newOBV)
+	RegisterIndicator("supertrend", []float64{10, 3}, newSupertrend)
+	RegisterIndicator("ichimoku", []float64{9, 26, 52}, newIchimoku)
+	RegisterIndicator("donchian", []float64{20}, newDonchian)
+}
+
+// periodParam 校验周期参数（必须为正整数）
+func periodParam(name string, v float64) (int, error) {
+	if v < 1 || v != math.Trunc(v) {
+		return 0, fmt.Errorf("%s 必须为正整数，当前: %v", name, v)
+	}
+	return int(v), nil
+}
+
+// rollingWindow 固定长度的滑动窗口
+type rollingWindow struct {
+	values []float64
+	next   int
+	filled bool
+}
+
+func newRollingWindow(size int) *rollingWindow {
+	return &rollingWindow{values: make([]float64, size)}
+}
+
+func (w *rollingWindow) push(v float64) {
+	w.values[w.next] = v
+	w.next = (w.next + 1) % len(w.values)
+	if w.next == 0 {
+		w.filled = true
+	}
+}
+
+func (w *rollingWindow) full() bool { return w.filled }
+
+// items 当前窗口内的值（未填满时只返回已有值）
+func (w *rollingWindow) items() []float64 {
+	if w.filled {
+		return w.values
+	}
+	return w.values[:w.next]
+}
+
+// maxMinLast 最近 n 个值中的最大值与最小值（n 不超过窗口长度）
+func (w *rollingWindow) maxMinLast(n int) (float64, float64) {
+	size := len(w.values)
+	hi, lo := math.Inf(-1), math.Inf(1)
+	for i := 1; i <= n; i++ {
+		v := w.values[(w.next-i+size)%size]
+		hi = math.Max(hi, v)
+		lo = math.Min(lo, v)
+	}
+	return hi, lo
+}
+
+func (w *rollingWindow) sum() float64 {
+	total := 0.0
+	for _, v := range w.items() {
+		total += v
+	}
+	return total
+}
+
+// wilderRSI 增量RSI，与 calculateRSI 口径一致（首个均值取简单平均，之后Wilder平滑）
+type wilderRSI struct {
+	period           int
+	prevClose        float64
+	count            int // 已处理的K线数量
+	avgGain, avgLoss float64
+}
+
+func (r *wilderRSI) update(close float64) {
+	r.count++
+	if r.count == 1 {
+		r.prevClose = close
+		return
+	}
+	change := close - r.prevClose
+	r.prevClose = close
+	gain, loss := math.Max(change, 0), math.Max(-change, 0)
+
+	n := float64(r.period)
+	changes := r.count - 1
+	if changes <= r.period {
+		r.avgGain += gain / n
+		r.avgLoss += loss / n
+		return
+	}
+	r.avgGain = (r.avgGain*(n-1) + gain) / n
+	r.avgLoss = (r.avgLoss*(n-1) + loss) / n
+}
+
+func (r *wilderRSI) ready() bool { return r.count > r.period }
+
+func (r *wilderRSI) value() float64 {
+	if r.avgLoss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+r.avgGain/r.avgLoss)
+}
+
+// wilderATR 增量ATR，与 calculateATR 口径一致
+type wilderATR struct {
+	period    int
+	prevClose float64
+	count     int
+	atr       float64
+}
+
+func (a *wilderATR) update(k Kline) {
+	a.count++
+	if a.count == 1 {
+		a.prevClose = k.Close
+		return
+	}
+	tr := trueRange(k, a.prevClose)
+	a.prevClose = k.Close
+
+	n := float64(a.period)
+	if a.count-1 <= a.period {
+		a.atr += tr / n
+		return
+	}
+	a.atr = (a.atr*(n-1) + tr) / n
+}
+
+func (a *wilderATR) ready() bool { return a.count > a.period }
+
+func trueRange(k Kline, prevClose float64) float64 {
+	return math.Max(k.High-k.Low, math.Max(math.Abs(k.High-prevClose), math.Abs(k.Low-prevClose)))
+}
+
+// ===== 布林带 bb(period, k) =====
+
+type bollinger struct {
+	k      float64
+	closes *rollingWindow
+}
+
+func newBollinger(params []float64) (Indicator, error) {
+	period, err := periodParam("bb 周期", params[0])
+	if err != nil {
+		return nil, err
+	}
+	return &bollinger{k: params[1], closes: newRollingWindow(period)}, nil
+}
+
+func (b *bollinger) Update(k Kline)   { b.closes.push(k.Close) }
+func (b *bollinger) Ready() bool      { return b.closes.full() }
+func (b *bollinger) Fields() []string { return []string{"upper", "middle", "lower"} }
+
+func (b *bollinger) Values() []float64 {
+	values := b.closes.items()
+	n := float64(len(values))
+	mean := b.closes.sum() / n
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(variance / n) // 总体标准差
+	return []float64{mean + b.k*std, mean, mean - b.k*std}
+}
+
+// ===== 成交量加权均价 vwap(period)，period=0 表示累计全部K线 =====
+
+type vwap struct {
+	rolling          bool
+	pv, vol          *rollingWindow
+	sumPV, sumVol    float64
+	lastTypicalPrice float64
+	count            int
+}
+
+func newVWAP(params []float64) (Indicator, error) {
+	if params[0] == 0 {
+		return &vwap{}, nil
+	}
+	period, err := periodParam("vwap 周期", params[0])
+	if err != nil {
+		return nil, err
+	}
+	return &vwap{rolling: true, pv: newRollingWindow(period), vol: newRollingWindow(period)}, nil
+}
+
+func (v *vwap) Update(k Kline) {
+	v.count++
+	tp := (k.High + k.Low + k.Close) / 3
+	v.lastTypicalPrice = tp
+	if v.rolling {
+		v.pv.push(tp * k.Volume)
+		v.vol.push(k.Volume)
+		return
+	}
+	v.sumPV += tp * k.Volume
+	v.sumVol += k.Volume
+}
+
+func (v *vwap) Ready() bool {
+	if v.rolling {
+		return v.pv.full()
+	}
+	return v.count > 0
+}
+
+func (v *vwap) Fields() []string { return []string{"vwap"} }
+
+func (v *vwap) Values() []float64 {
+	sumPV, sumVol := v.sumPV, v.sumVol
+	if v.rolling {
+		sumPV, sumVol = v.pv.sum(), v.vol.sum()
+	}
+	if sumVol == 0 {
+		return []float64{v.lastTypicalPrice}
+	}
+	return []float64{sumPV / sumVol}
+}
+
+// ===== 随机RSI stochrsi(rsiPeriod, stochPeriod, kSmooth, dSmooth) =====
+
+type stochRSI struct {
+	rsi    wilderRSI
+	rsiWin *rollingWindow
+	kWin   *rollingWindow
+	dWin   *rollingWindow
+}
+
+func newStochRSI(params []float64) (Indicator, error) {
+	names := []string{"stochrsi RSI周期", "stochrsi 随机周期", "stochrsi K平滑", "stochrsi D平滑"}
+	periods := make([]int, len(params))
+	for i, p := range params {
+		v, err := periodParam(names[i], p)
+		if err != nil {
+			return nil, err
+		}
+		periods[i] = v
+	}
+	return &stochRSI{
+		rsi:    wilderRSI{period: periods[0]},
+		rsiWin: newRollingWindow(periods[1]),
+		kWin:   newRollingWindow(periods[2]),
+		dWin:   newRollingWindow(periods[3]),
+	}, nil
+}
+
+func (s *stochRSI) Update(k Kline) {
+	s.rsi.update(k.Close)
+	if !s.rsi.ready() {
+		return
+	}
+	rsi := s.rsi.value()
+	s.rsiWin.push(rsi)
+	if !s.rsiWin.full() {
+		return
+	}
+
+	hi, lo := s.rsiWin.maxMinLast(len(s.rsiWin.values))
+	stoch := 0.0
+	if hi > lo {
+		stoch = (rsi - lo) / (hi - lo) * 100
+	}
+	s.kWin.push(stoch)
+	if !s.kWin.full() {
+		return
+	}
+	s.dWin.push(s.kWin.sum() / float64(len(s.kWin.values)))
+}
+
+func (s *stochRSI) Ready() bool      { return s.dWin.full() }
+func (s *stochRSI) Fields() []string { return []string{"k", "d"} }
+
+func (s *stochRSI) Values() []float64 {
+	k := s.kWin.sum() / float64(len(s.kWin.values))
+	d := s.dWin.sum() / float64(len(s.dWin.values))
+	return []float64{k, d}
+}
+
+// ===== 平均趋向指标 adx(period)，Wilder 平滑 =====
+
+type adx struct {
+	period                    int
+	count                     int
+	prev                      Kline
+	smTR, smPlusDM, smMinusDM float64
+	dxCount                   int
+	adx                       float64
+	plusDI, minusDI           float64
+}
+
+func newADX(params []float64) (Indicator, error) {
+	period, err := periodParam("adx 周期", params[0])
+	if err != nil {
+		return nil, err
+	}
+	return &adx{period: period}, nil
+}
+
+func (a *adx) Update(k Kline) {
+	a.count++
+	if a.count == 1 {
+		a.prev = k
+		return
+	}
+
+	up := k.High - a.prev.High
+	down := a.prev.Low - k.Low
+	plusDM, minusDM := 0.0, 0.0
+	if up > down && up > 0 {
+		plusDM = up
+	}
+	if down > up && down > 0 {
+		minusDM = down
+	}
+	tr := trueRange(k, a.prev.Close)
+	a.prev = k
+
+	n := float64(a.period)
+	bars := a.count - 1
+	if bars <= a.period {
+		// 首个平滑值为前 period 个值之和
+		a.smTR += tr
+		a.smPlusDM += plusDM
+		a.smMinusDM += minusDM
+		if bars < a.period {
+			return
+		}
+	} else {
+		a.smTR = a.smTR - a.smTR/n + tr
+		a.smPlusDM = a.smPlusDM - a.smPlusDM/n + plusDM
+		a.smMinusDM = a.smMinusDM - a.smMinusDM/n + minusDM
+	}
+
+	a.plusDI, a.minusDI = 0, 0
+	if a.smTR > 0 {
+		a.plusDI = 100 * a.smPlusDM / a.smTR
+		a.minusDI = 100 * a.smMinusDM / a.smTR
+	}
+	dx := 0.0
+	if sum := a.plusDI + a.minusDI; sum > 0 {
+		dx = 100 * math.Abs(a.plusDI-a.minusDI) / sum
+	}
+
+	a.dxCount++
+	if a.dxCount <= a.period {
+		// 首个ADX为前 period 个DX的简单平均
+		a.adx += dx / n
+		return
+	}
+	a.adx = (a.adx*(n-1) + dx) / n
+}
+
+func (a *adx) Ready() bool       { return a.dxCount >= a.period }
+func (a *adx) Fields() []string  { return []string{"adx", "plus_di", "minus_di"} }
+func (a *adx) Values() []float64 { return []float64{a.adx, a.plusDI, a.minusDI} }
+
+// ===== 能量潮 obv =====
+
+type obv struct {
+	count     int
+	prevClose float64
+	obv       float64
+}
+
+func newOBV(_ []float64) (Indicator, error) { return &obv{}, nil }
+
+func (o *obv) Update(k Kline) {
+	o.count++
+	if o.count > 1 {
+		if k.Close > o.prevClose {
+			o.obv += k.Volume
+		} else if k.Close < o.prevClose {
+			o.obv -= k.Volume
+		}
+	}
+	o.prevClose = k.Close
+}
+
+func (o *obv) Ready() bool       { return o.count > 1 }
+func (o *obv) Fields() []string  { return []string{"obv"} }
+func (o *obv) Values() []float64 { return []float64{o.obv} }
+
+// ===== 超级趋势 supertrend(atrPeriod, multiplier) =====
+
+type supertrend struct {
+	multiplier             float64
+	atr                    wilderATR
+	started                bool
+	prevClose              float64
+	finalUpper, finalLower float64
+	direction              float64 // 1=上升趋势（价格在下轨之上），-1=下降趋势
+}
+
+func newSupertrend(params []float64) (Indicator, error) {
+	period, err := periodParam("supertrend ATR周期", params[0])
+	if err != nil {
+		return nil, err
+	}
+	return &supertrend{multiplier: params[1], atr: wilderATR{period: period}}, nil
+}
+
+func (s *supertrend) Update(k Kline) {
+	s.atr.update(k)
+	if !s.atr.ready() {
+		s.prevClose = k.Close
+		return
+	}
+
+	hl2 := (k.High + k.Low) / 2
+	basicUpper := hl2 + s.multiplier*s.atr.atr
+	basicLower := hl2 - s.multiplier*s.atr.atr
+
+	if !s.started {
+		// 首根有效K线：收盘价在中轴之上视为上升趋势
+		s.started = true
+		s.finalUpper, s.finalLower = basicUpper, basicLower
+		s.direction = -1
+		if k.Close >= hl2 {
+			s.direction = 1
+		}
+		s.prevClose = k.Close
+		return
+	}
+
+	// 轨道只朝趋势方向收紧，价格突破前一根轨道时重置
+	if basicUpper < s.finalUpper || s.prevClose > s.finalUpper {
+		s.finalUpper = basicUpper
+	}
+	if basicLower > s.finalLower || s.prevClose < s.finalLower {
+		s.finalLower = basicLower
+	}
+
+	if s.direction < 0 && k.Close > s.finalUpper {
+		s.direction = 1
+	} else if s.direction > 0 && k.Close < s.finalLower {
+		s.direction = -1
+	}
+	s.prevClose = k.Close
+}
+
+func (s *supertrend) Ready() bool      { return s.started }
+func (s *supertrend) Fields() []string { return []string{"value", "direction"} }
+
+func (s *supertrend) Values() []float64 {
+	if s.direction > 0 {
+		return []float64{s.finalLower, s.direction}
+	}
+	return []float64{s.finalUpper, s.direction}
+}
+
+// ===== 一目均衡表 ichimoku(tenkan, kijun, senkouB) =====
+// 先行带A/B以 kijun 周期向前平移，输出的是当前K线所处的云层
+
+type ichimoku struct {
+	tenkanPeriod, kijunPeriod, spanBPeriod int
+	highs, lows                            *rollingWindow
+	count                                  int
+	tenkan, kijun                          float64
+	spans                                  [][2]float64 // 最近 kijun+1 根K线计算出的先行带
+}
+
+func newIchimoku(params []float64) (Indicator, error) {
+	names := []string{"ichimoku 转换线周期", "ichimoku 基准线周期", "ichimoku 先行带B周期"}
+	periods := make([]int, len(params))
+	for i, p := range params {
+		v, err := periodParam(names[i], p)
+		if err != nil {
+			return nil, err
+		}
+		periods[i] = v
+	}
+	longest := periods[0]
+	for _, p := range periods[1:] {
+		if p > longest {
+			longest = p
+		}
+	}
+	return &ichimoku{
+		tenkanPeriod: periods[0],
+		kijunPeriod:  periods[1],
+		spanBPeriod:  periods[2],
+		highs:        newRollingWindow(longest),
+		lows:         newRollingWindow(longest),
+	}, nil
+}
+
+func (ic *ichimoku) midpoint(n int) float64 {
+	hi, _ := ic.highs.maxMinLast(n)
+	_, lo := ic.lows.maxMinLast(n)
+	return (hi + lo) / 2
+}
+
+func (ic *ichimoku) Update(k Kline) {
+	ic.count++
+	ic.highs.push(k.High)
+	ic.lows.push(k.Low)
+
+	if ic.count >= ic.tenkanPeriod {
+		ic.tenkan = ic.midpoint(ic.tenkanPeriod)
+	}
+	if ic.count >= ic.kijunPeriod {
+		ic.kijun = ic.midpoint(ic.kijunPeriod)
+	}
+	if ic.count >= ic.tenkanPeriod && ic.count >= ic.kijunPeriod && ic.count >= ic.spanBPeriod {
+		ic.spans = append(ic.spans, [2]float64{(ic.tenkan + ic.kijun) / 2, ic.midpoint(ic.spanBPeriod)})
+		if len(ic.spans) > ic.kijunPeriod+1 {
+			ic.spans = ic.spans[1:]
+		}
+	}
+}
+
+func (ic *ichimoku) Ready() bool      { return len(ic.spans) > ic.kijunPeriod }
+func (ic *ichimoku) Fields() []string { return []string{"tenkan", "kijun", "span_a", "span_b"} }
+
+func (ic *ichimoku) Values() []float64 {
+	cloud := ic.spans[0]
+	return []float64{ic.tenkan, ic.kijun, cloud[0], cloud[1]}
+}
+
+// ===== 唐奇安通道 donchian(period) =====
+
+type donchian struct {
+	highs, lows *rollingWindow
+}
+
+func newDonchian(params []float64) (Indicator, error) {
+	period, err := periodParam("donchian 周期", params[0])
+	if err != nil {
+		return nil, err
+	}
+	return &donchian{highs: newRollingWindow(period), lows: newRollingWindow(period)}, nil
+}
+
+func (d *donchian) Update(k Kline) {
+	d.highs.push(k.High)
+	d.lows.push(k.Low)
+}
+
+func (d *donchian) Ready() bool      { return d.highs.full() }
+func (d *donchian) Fields() []string { return []string{"upper", "middle", "lower"} }
+
+func (d *donchian) Values() []float64 {
+	n := len(d.highs.values)
+	upper, _ := d.highs.maxMinLast(n)
+	_, lower := d.lows.maxMinLast(n)
+	return []float64{upper, (upper + lower) / 2, lower}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,16 +14,38 @@ type WSMonitor struct {
 	wsClient       *WSClient
 	combinedClient *CombinedStreamsClient
 	symbols        []string
-	featuresMap    sync.Map
+	featuresMap    sync.Map // 每个交易对最近一根已收盘K线的特征（*SymbolFeatures）
 	alertsChan     chan Alert
-	klineDataMap3m sync.Map // 存储每个交易对的K线历史数据
-	klineDataMap4h sync.Map // 存储每个交易对的K线历史数据
+	alertCooldowns sync.Map // 交易对|警报类型 -> 上次触发时间
+	klineDataMaps  sync.Map // K线周期 -> *sync.Map（交易对 -> []Kline）
+	subscribed     sync.Map // 已注册的K线流，避免重复订阅
+	backfilling    sync.Map // 正在回填的 交易对|周期
+	depthMap       sync.Map // 存储每个交易对的订单簿（*OrderBook）
+	flowMap        sync.Map // 存储每个交易对的主动成交统计（*tradeFlow）
+	liquidationMap sync.Map // 存储每个交易对最近的强平单（*liquidationBook）
 	tickerDataMap  sync.Map // 存储每个交易对的ticker数据
 	batchSize      int
 	filterSymbols  sync.Map // 使用sync.Map来存储需要监控的币种和其状态
 	symbolStats    sync.Map // 存储币种统计信息
 	FilterSymbol   []string //经过筛选的币种
+
+	onDemandMu sync.Mutex
+	onDemand   map[string]*onDemandStream // 按需订阅的深度/归集成交流（见 acquireStream）
+
+	startMu sync.Mutex // 保护 ready，初始化完成前新增的周期由 Start 统一处理
+	ready   bool       // 历史数据与订阅是否已完成
+
+	listenersMu    sync.RWMutex
+	priceListeners map[int]PriceListener // 实时价格监听器
+	nextListenerID int
+
+	klineGaps        int64 // 检测到的K线缺口次数（原子操作）
+	backfills        int64 // REST回填成功次数
+	backfillFailures int64 // REST回填失败次数
 }
+
+// PriceListener 实时价格回调（在WebSocket处理goroutine中调用，实现方不应阻塞）
+type PriceListener func(symbol string, price float64)
 type SymbolStats struct {
 	LastActiveTime   time.Time
 	AlertCount       int
@@ -32,7 +55,43 @@ type SymbolStats struct {
 }
 
 var WSMonitorCli *WSMonitor
-var subKlineTime = []string{"3m", "4h"} // 管理订阅流的K线周期
+
+var (
+	subKlineMu   sync.RWMutex
+	subKlineTime = append([]string(nil), DefaultTimeframes...) // 管理订阅流的K线周期（默认周期 + 交易员登记的周期）
+)
+
+// klineIntervals 当前需要订阅的K线周期（副本）
+func klineIntervals() []string {
+	subKlineMu.RLock()
+	defer subKlineMu.RUnlock()
+	return append([]string(nil), subKlineTime...)
+}
+
+// RegisterTimeframes 登记交易员需要的K线周期
+// 监控器启动前登记的周期在初始化时一并回填和订阅；启动后登记的周期在后台立即回填并订阅
+func RegisterTimeframes(intervals []string) {
+	subKlineMu.Lock()
+	var added []string
+	for _, interval := range intervals {
+		exists := false
+		for _, st := range subKlineTime {
+			if st == interval {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			subKlineTime = append(subKlineTime, interval)
+			added = append(added, interval)
+		}
+	}
+	subKlineMu.Unlock()
+
+	if len(added) > 0 && WSMonitorCli != nil {
+		go WSMonitorCli.addTimeframes(added)
+	}
+}
 
 func NewWSMonitor(batchSize int) *WSMonitor {
 	WSMonitorCli = &WSMonitor{
@@ -41,6 +100,7 @@ func NewWSMonitor(batchSize int) *WSMonitor {
 		alertsChan:     make(chan Alert, 1000),
 		batchSize:      batchSize,
 	}
+	go WSMonitorCli.dispatchAlerts()
 	return WSMonitorCli
 }
 
@@ -57,7 +117,7 @@ func (m *WSMonitor) Initialize(coins []string) error {
 		// 筛选永续合约交易对 --仅测试时使用
 		//exchangeInfo.Symbols = exchangeInfo.Symbols[0:2]
 		for _, symbol := range exchangeInfo.Symbols {
-			if symbol.Status == "TRADING" && symbol.ContractType == "PERPETUAL" && strings.ToUpper(symbol.Symbol[len(symbol.Symbol)-4:]) == "USDT" {
+			if symbol.Status == "TRADING" && symbol.ContractType == ContractPerpetual && IsSupportedQuote(symbol.QuoteAsset) {
 				m.symbols = append(m.symbols, symbol.Symbol)
 				m.filterSymbols.Store(symbol.Symbol, true)
 			}
@@ -68,14 +128,14 @@ func (m *WSMonitor) Initialize(coins []string) error {
 
 	log.Printf("找到 %d 个交易对", len(m.symbols))
 	// 初始化历史数据
-	if err := m.initializeHistoricalData(); err != nil {
+	if err := m.initializeHistoricalData(klineIntervals()); err != nil {
 		log.Printf("初始化历史数据失败: %v", err)
 	}
 
 	return nil
 }
 
-func (m *WSMonitor) initializeHistoricalData() error {
+func (m *WSMonitor) initializeHistoricalData(intervals []string) error {
 	apiClient := NewAPIClient()
 
 	var wg sync.WaitGroup
@@ -89,25 +149,17 @@ func (m *WSMonitor) initializeHistoricalData() error {
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			// 获取历史K线数据
-			klines, err := apiClient.GetKlines(s, "3m", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-				return
-			}
-			if len(klines) > 0 {
-				m.klineDataMap3m.Store(s, klines)
-				log.Printf("已加载 %s 的历史K线数据-3m: %d 条", s, len(klines))
-			}
-			// 获取历史K线数据
-			klines4h, err := apiClient.GetKlines(s, "4h", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-				return
-			}
-			if len(klines4h) > 0 {
-				m.klineDataMap4h.Store(s, klines4h)
-				log.Printf("已加载 %s 的历史K线数据-4h: %d 条", s, len(klines4h))
+			// 获取每个周期的历史K线数据
+			for _, interval := range intervals {
+				klines, err := apiClient.GetKlines(s, interval, 100)
+				if err != nil {
+					log.Printf("获取 %s 历史数据失败: %v", s, err)
+					return
+				}
+				if len(klines) > 0 {
+					m.getKlineDataMap(interval).Store(s, klines)
+					log.Printf("已加载 %s 的历史K线数据-%s: %d 条", s, interval, len(klines))
+				}
 			}
 		}(symbol)
 	}
@@ -118,6 +170,9 @@ func (m *WSMonitor) initializeHistoricalData() error {
 
 func (m *WSMonitor) Start(coins []string) {
 	log.Printf("启动WebSocket实时监控...")
+	m.startMu.Lock()
+	defer m.startMu.Unlock()
+
 	// 初始化交易对
 	err := m.Initialize(coins)
 	if err != nil {
@@ -136,12 +191,39 @@ func (m *WSMonitor) Start(coins []string) {
 		log.Printf("❌ 订阅币种交易对失败: %v", err)
 		return
 	}
+	m.ready = true
 }
 
-// subscribeSymbol 注册监听
+// addTimeframes 为所有交易对回填并订阅新增的K线周期（监控器未就绪时由 Start 处理）
+func (m *WSMonitor) addTimeframes(intervals []string) {
+	m.startMu.Lock()
+	defer m.startMu.Unlock()
+	if !m.ready {
+		return
+	}
+
+	if err := m.initializeHistoricalData(intervals); err != nil {
+		log.Printf("回填新增K线周期失败: %v", err)
+	}
+	for _, st := range intervals {
+		for _, symbol := range m.symbols {
+			m.subscribeSymbol(symbol, st)
+		}
+		if err := m.combinedClient.BatchSubscribeKlines(m.symbols, st); err != nil {
+			log.Printf("❌ 订阅 %s K线失败: %v", st, err)
+			continue
+		}
+		log.Printf("✓ 已新增K线周期: %s", st)
+	}
+}
+
+// subscribeSymbol 注册监听（已注册的流返回空）
 func (m *WSMonitor) subscribeSymbol(symbol, st string) []string {
 	var streams []string
-	stream := fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), st)
+	stream := klineStream(symbol, st)
+	if _, loaded := m.subscribed.LoadOrStore(stream, true); loaded {
+		return streams
+	}
 	ch := m.combinedClient.AddSubscriber(stream, 100)
 	streams = append(streams, stream)
 	go m.handleKlineData(symbol, ch, st)
@@ -151,18 +233,23 @@ func (m *WSMonitor) subscribeSymbol(symbol, st string) []string {
 func (m *WSMonitor) subscribeAll() error {
 	// 执行批量订阅
 	log.Println("开始订阅所有交易对...")
+	intervals := klineIntervals()
 	for _, symbol := range m.symbols {
-		for _, st := range subKlineTime {
+		for _, st := range intervals {
 			m.subscribeSymbol(symbol, st)
 		}
 	}
-	for _, st := range subKlineTime {
+	for _, st := range intervals {
 		err := m.combinedClient.BatchSubscribeKlines(m.symbols, st)
 		if err != nil {
 			log.Printf("❌ 订阅 %s K线失败: %v", st, err)
 			return err
 		}
 	}
+	// 强平单是全市场单一流，订阅失败不影响K线
+	if err := m.subscribeForceOrders(); err != nil {
+		log.Printf("⚠️ 订阅强平单流失败: %v", err)
+	}
 	log.Println("所有交易对订阅完成")
 	return nil
 }
@@ -178,16 +265,10 @@ func (m *WSMonitor) handleKlineData(symbol string, ch <-chan []byte, _time strin
 	}
 }
 
+// getKlineDataMap 获取指定周期的K线缓存（不存在时创建）
 func (m *WSMonitor) getKlineDataMap(_time string) *sync.Map {
-	var klineDataMap *sync.Map
-	if _time == "3m" {
-		klineDataMap = &m.klineDataMap3m
-	} else if _time == "4h" {
-		klineDataMap = &m.klineDataMap4h
-	} else {
-		klineDataMap = &sync.Map{}
-	}
-	return klineDataMap
+	value, _ := m.klineDataMaps.LoadOrStore(_time, &sync.Map{})
+	return value.(*sync.Map)
 }
 func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time string) {
 	// 转换WebSocket数据为Kline结构
@@ -217,6 +298,15 @@ func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time
 			// 更新当前K线
 			klines[len(klines)-1] = kline
 		} else {
+			// 断线或推送丢失导致中间缺少K线时，后台通过REST回填
+			if len(klines) > 0 {
+				if missing := detectKlineGap(klines[len(klines)-1], kline, _time); missing > 0 {
+					atomic.AddInt64(&m.klineGaps, 1)
+					m.combinedClient.health.recordGap(klineStream(symbol, _time))
+					log.Printf("⚠️ %s %s K线缺少 %d 根，开始回填", symbol, _time, missing)
+					go m.backfillKlines(symbol, _time)
+				}
+			}
 			// 添加新K线
 			klines = append(klines, kline)
 
@@ -230,6 +320,44 @@ func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time
 	}
 
 	klineDataMap.Store(symbol, klines)
+
+	// 默认最短周期K线收盘时计算特征并检查警报
+	if wsData.Kline.IsFinal && _time == DefaultTimeframes[0] {
+		m.onKlineClosed(symbol, klines, _time)
+	}
+
+	// 默认最短周期的K线所有交易对都会订阅且推送频繁，用它的收盘价作为实时价格
+	if _time == DefaultTimeframes[0] && kline.Close > 0 {
+		m.notifyPriceListeners(symbol, kline.Close)
+	}
+}
+
+// AddPriceListener 注册实时价格监听器，返回取消注册的函数
+func (m *WSMonitor) AddPriceListener(listener PriceListener) func() {
+	m.listenersMu.Lock()
+	defer m.listenersMu.Unlock()
+
+	if m.priceListeners == nil {
+		m.priceListeners = make(map[int]PriceListener)
+	}
+	id := m.nextListenerID
+	m.nextListenerID++
+	m.priceListeners[id] = listener
+
+	return func() {
+		m.listenersMu.Lock()
+		defer m.listenersMu.Unlock()
+		delete(m.priceListeners, id)
+	}
+}
+
+func (m *WSMonitor) notifyPriceListeners(symbol string, price float64) {
+	m.listenersMu.RLock()
+	defer m.listenersMu.RUnlock()
+
+	for _, listener := range m.priceListeners {
+		listener(symbol, price)
+	}
 }
 
 func (m *WSMonitor) GetCurrentKlines(symbol string, _time string) ([]Kline, error) {
@@ -247,11 +375,12 @@ func (m *WSMonitor) GetCurrentKlines(symbol string, _time string) ([]Kline, erro
 		m.getKlineDataMap(_time).Store(strings.ToUpper(symbol), klines)
 
 		// 订阅 WebSocket 流
-		subStr := m.subscribeSymbol(symbol, _time)
-		subErr := m.combinedClient.subscribeStreams(subStr)
-		log.Printf("动态订阅流: %v", subStr)
-		if subErr != nil {
-			log.Printf("警告: 动态订阅%v分钟K线失败: %v (使用API数据)", _time, subErr)
+		if subStr := m.subscribeSymbol(symbol, _time); len(subStr) > 0 {
+			subErr := m.combinedClient.subscribeStreams(subStr)
+			log.Printf("动态订阅流: %v", subStr)
+			if subErr != nil {
+				log.Printf("警告: 动态订阅%vK线失败: %v (使用API数据)", _time, subErr)
+			}
 		}
 
 		// ✅ FIX: 返回深拷贝而非引用
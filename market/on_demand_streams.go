@@ -0,0 +1,160 @@
+package market
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxOnDemandStreams 按需订阅的深度和归集成交流数量上限（币安单连接最多200个流，K线流也占用名额）
+	maxOnDemandStreams = 100
+	// onDemandStreamGrace 新访问的流至少保留的时间（交易员在一个周期内先获取数据、后登记关注的币种）
+	onDemandStreamGrace = 10 * time.Minute
+)
+
+var (
+	retainedMu sync.Mutex
+	// retainedSymbols 交易员 → 当前持有或关注的币种
+	retainedSymbols = make(map[string]map[string]bool)
+)
+
+// onDemandStream 按需订阅的币种流（深度、归集成交）
+type onDemandStream struct {
+	symbol   string
+	lastUsed time.Time
+	release  func() // 退订后清理该币种的缓存数据
+}
+
+// RetainSymbols 登记交易员当前持有或关注的币种（symbols 为空表示交易员已停止）
+// 按需订阅的深度和归集成交流只为已登记的币种保留，其余的流在宽限期后退订
+func RetainSymbols(owner string, symbols []string) {
+	set := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		set[Normalize(symbol)] = true
+	}
+
+	retainedMu.Lock()
+	if len(set) == 0 {
+		delete(retainedSymbols, owner)
+	} else {
+		retainedSymbols[owner] = set
+	}
+	retainedMu.Unlock()
+
+	if WSMonitorCli != nil {
+		WSMonitorCli.evictOnDemandStreams(time.Now())
+	}
+}
+
+// isRetainedSymbol 是否有交易员持有或关注该币种
+func isRetainedSymbol(symbol string) bool {
+	retainedMu.Lock()
+	defer retainedMu.Unlock()
+	for _, set := range retainedSymbols {
+		if set[symbol] {
+			return true
+		}
+	}
+	return false
+}
+
+// acquireStream 按需订阅币种流并记录访问时间（已订阅时只刷新访问时间）
+// 达到数量上限时先退订最久未访问的流；handle 在独立goroutine中处理推送，release 在退订后清理缓存
+func (m *WSMonitor) acquireStream(symbol, stream string, bufferSize int, handle func(<-chan []byte), release func()) error {
+	now := time.Now()
+	m.onDemandMu.Lock()
+	if s, ok := m.onDemand[stream]; ok {
+		s.lastUsed = now
+		m.onDemandMu.Unlock()
+		return nil
+	}
+	if m.onDemand == nil {
+		m.onDemand = make(map[string]*onDemandStream)
+	}
+	var evicted []string
+	if over := len(m.onDemand) - maxOnDemandStreams + 1; over > 0 {
+		evicted = m.oldestOnDemandLocked(over)
+		log.Printf("⚠️  按需订阅的流达到上限 %d 个，退订最久未访问的 %d 个", maxOnDemandStreams, len(evicted))
+	}
+	releases := m.removeOnDemandLocked(evicted)
+	m.onDemand[stream] = &onDemandStream{symbol: symbol, lastUsed: now, release: release}
+	m.onDemandMu.Unlock()
+
+	m.releaseStreams(evicted, releases)
+
+	ch := m.combinedClient.AddSubscriber(stream, bufferSize)
+	go handle(ch)
+	if err := m.combinedClient.subscribeStreams([]string{stream}); err != nil {
+		// 订阅失败时移除登记，下次访问时重试
+		m.onDemandMu.Lock()
+		releases = m.removeOnDemandLocked([]string{stream})
+		m.onDemandMu.Unlock()
+		m.releaseStreams([]string{stream}, releases)
+		return err
+	}
+	return nil
+}
+
+// evictOnDemandStreams 退订没有交易员持有或关注、且超过宽限期未访问的按需流
+func (m *WSMonitor) evictOnDemandStreams(now time.Time) {
+	m.onDemandMu.Lock()
+	var evicted []string
+	for stream, s := range m.onDemand {
+		if now.Sub(s.lastUsed) > onDemandStreamGrace && !isRetainedSymbol(s.symbol) {
+			evicted = append(evicted, stream)
+		}
+	}
+	sort.Strings(evicted)
+	releases := m.removeOnDemandLocked(evicted)
+	m.onDemandMu.Unlock()
+
+	if len(evicted) > 0 {
+		log.Printf("🧹 退订 %d 个不再关注的按需流", len(evicted))
+		m.releaseStreams(evicted, releases)
+	}
+}
+
+// oldestOnDemandLocked 最久未访问的 n 个按需流（调用方持有 onDemandMu）
+func (m *WSMonitor) oldestOnDemandLocked(n int) []string {
+	streams := make([]string, 0, len(m.onDemand))
+	for stream := range m.onDemand {
+		streams = append(streams, stream)
+	}
+	sort.Slice(streams, func(i, j int) bool {
+		return m.onDemand[streams[i]].lastUsed.Before(m.onDemand[streams[j]].lastUsed)
+	})
+	if n > len(streams) {
+		n = len(streams)
+	}
+	return streams[:n]
+}
+
+// removeOnDemandLocked 移除按需流登记，返回对应的缓存清理函数（调用方持有 onDemandMu）
+func (m *WSMonitor) removeOnDemandLocked(streams []string) []func() {
+	var releases []func()
+	for _, stream := range streams {
+		if s, ok := m.onDemand[stream]; ok {
+			if s.release != nil {
+				releases = append(releases, s.release)
+			}
+			delete(m.onDemand, stream)
+		}
+	}
+	return releases
+}
+
+// releaseStreams 退订流并清理缓存
+func (m *WSMonitor) releaseStreams(streams []string, releases []func()) {
+	if len(streams) == 0 {
+		return
+	}
+	if err := m.combinedClient.unsubscribeStreams(streams); err != nil {
+		log.Printf("⚠️  退订流失败: %v (%s)", err, strings.Join(streams, ", "))
+	}
+	for _, release := range releases {
+		release()
+	}
+}
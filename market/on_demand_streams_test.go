@@ -0,0 +1,154 @@
+package market
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestCombinedClient 连接到本地WebSocket服务器的组合流客户端，返回服务器收到的订阅/退订请求
+func newTestCombinedClient(t *testing.T) (*CombinedStreamsClient, <-chan map[string]interface{}) {
+	requests := make(chan map[string]interface{}, 1000)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg map[string]interface{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			requests <- msg
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("连接测试服务器失败: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	client := NewCombinedStreamsClient(10)
+	client.conn = conn
+	return client, requests
+}
+
+// waitRequest 等待服务器收到指定方法的请求，返回请求的流
+func waitRequest(t *testing.T, requests <-chan map[string]interface{}, method string) []string {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-requests:
+			if msg["method"] != method {
+				continue
+			}
+			var streams []string
+			for _, p := range msg["params"].([]interface{}) {
+				streams = append(streams, p.(string))
+			}
+			return streams
+		case <-timeout:
+			t.Fatalf("未收到 %s 请求", method)
+			return nil
+		}
+	}
+}
+
+// TestOnDemandStreamEviction 测试不再关注的按需流在宽限期后退订，关注中的流保留
+func TestOnDemandStreamEviction(t *testing.T) {
+	client, requests := newTestCombinedClient(t)
+	m := &WSMonitor{combinedClient: client}
+
+	savedMonitor := WSMonitorCli
+	WSMonitorCli = nil
+	t.Cleanup(func() {
+		WSMonitorCli = savedMonitor
+		RetainSymbols("trader_a", nil)
+	})
+
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT"} {
+		if err := m.subscribeDepth(symbol); err != nil {
+			t.Fatalf("subscribeDepth(%s) error: %v", symbol, err)
+		}
+		waitRequest(t, requests, "SUBSCRIBE")
+	}
+	if err := m.subscribeAggTrade("ETHUSDT"); err != nil {
+		t.Fatalf("subscribeAggTrade error: %v", err)
+	}
+	waitRequest(t, requests, "SUBSCRIBE")
+	// 重复访问只刷新访问时间
+	if err := m.subscribeDepth("BTCUSDT"); err != nil || len(m.onDemand) != 3 {
+		t.Fatalf("重复订阅: err=%v, onDemand=%d", err, len(m.onDemand))
+	}
+	m.depthMap.Store("ETHUSDT", &OrderBook{})
+
+	RetainSymbols("trader_a", []string{"BTCUSDT"})
+
+	// 宽限期内不退订
+	m.evictOnDemandStreams(time.Now())
+	if len(m.onDemand) != 3 {
+		t.Fatalf("宽限期内不应退订, onDemand = %d", len(m.onDemand))
+	}
+
+	m.evictOnDemandStreams(time.Now().Add(onDemandStreamGrace + time.Minute))
+	unsubscribed := waitRequest(t, requests, "UNSUBSCRIBE")
+	want := []string{"ethusdt@aggTrade", "ethusdt@depth20@100ms"}
+	if fmt.Sprint(unsubscribed) != fmt.Sprint(want) {
+		t.Errorf("退订 = %v, want %v", unsubscribed, want)
+	}
+	if _, ok := m.onDemand["btcusdt@depth20@100ms"]; !ok || len(m.onDemand) != 1 {
+		t.Errorf("关注中的流应保留: %v", m.onDemand)
+	}
+	if _, ok := m.depthMap.Load("ETHUSDT"); ok {
+		t.Error("退订后应清理订单簿缓存")
+	}
+	client.mu.RLock()
+	_, subscribed := client.streams["ethusdt@depth20@100ms"]
+	_, hasSubscriber := client.subscribers["ethusdt@depth20@100ms"]
+	client.mu.RUnlock()
+	if subscribed || hasSubscriber {
+		t.Error("退订的流不应在重连后恢复")
+	}
+
+	// 交易员停止后，剩余的流也会退订
+	RetainSymbols("trader_a", nil)
+	m.evictOnDemandStreams(time.Now().Add(onDemandStreamGrace + time.Minute))
+	if unsubscribed := waitRequest(t, requests, "UNSUBSCRIBE"); len(unsubscribed) != 1 || len(m.onDemand) != 0 {
+		t.Errorf("退订 = %v, onDemand = %d", unsubscribed, len(m.onDemand))
+	}
+}
+
+// TestOnDemandStreamCap 测试按需流达到上限时退订最久未访问的流
+func TestOnDemandStreamCap(t *testing.T) {
+	client, requests := newTestCombinedClient(t)
+	m := &WSMonitor{combinedClient: client}
+
+	for i := 0; i < maxOnDemandStreams; i++ {
+		if err := m.subscribeDepth(fmt.Sprintf("COIN%dUSDT", i)); err != nil {
+			t.Fatalf("subscribeDepth error: %v", err)
+		}
+	}
+	// COIN0 最近被访问过，最久未访问的是 COIN1
+	m.onDemand["coin0usdt@depth20@100ms"].lastUsed = time.Now().Add(time.Second)
+
+	if err := m.subscribeDepth("NEWUSDT"); err != nil {
+		t.Fatalf("subscribeDepth error: %v", err)
+	}
+	unsubscribed := waitRequest(t, requests, "UNSUBSCRIBE")
+	if len(unsubscribed) != 1 || unsubscribed[0] != "coin1usdt@depth20@100ms" {
+		t.Errorf("退订 = %v, want 最久未访问的 coin1usdt", unsubscribed)
+	}
+	if len(m.onDemand) != maxOnDemandStreams {
+		t.Errorf("onDemand = %d, want %d", len(m.onDemand), maxOnDemandStreams)
+	}
+}
@@ -0,0 +1,145 @@
+package market
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MarketDataProvider 行情数据源
+// 交易员看到的K线、持仓量、资金费率和订单簿应来自实际下单的交易所（各交易所价格、资金费率和可交易币种都有差异）
+type MarketDataProvider interface {
+	Name() string
+	GetKlines(symbol, interval string) ([]Kline, error) // 最近的K线（按时间顺序，最后一根为未收盘的当前K线）
+	GetOpenInterest(symbol string) (*OIData, error)     // 持仓量（币本位数量）
+	GetFundingRate(symbol string) (float64, error)      // 资金费率（折算为8小时单期费率，与币安口径一致）
+	FundingIntervalHours(symbol string) float64         // 资金费实际结算间隔（小时），未知时为8
+	GetMarkPrice(symbol string) (float64, error)        // 交易所标记价格（止损止盈、强平按此价格触发）
+	GetOrderBook(symbol string) (*OrderBook, error)
+	// SubscribePrices 订阅实时价格推送（币安格式币种名），返回取消订阅的函数
+	// 不支持推送或推送未就绪时返回 ok=false，调用方应改为定时按标记价格评估
+	SubscribePrices(listener PriceListener) (unsubscribe func(), ok bool)
+}
+
+// flowSource 支持主动成交与强平数据的数据源（目前只有币安）
+type flowSource interface {
+	GetFlow(symbol string) *FlowData
+}
+
+// Binance 币安合约行情（WebSocket监控器缓存 + REST）
+var Binance MarketDataProvider = binanceProvider{}
+
+type binanceProvider struct{}
+
+func (binanceProvider) Name() string { return "binance" }
+
+func (binanceProvider) GetKlines(symbol, interval string) ([]Kline, error) {
+	if WSMonitorCli == nil {
+		return nil, fmt.Errorf("行情监控未启动")
+	}
+	klines, err := WSMonitorCli.GetCurrentKlines(symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+	// 推送中断时缓存不再更新，拒绝使用过期数据，同时后台回填以便下次恢复
+	if err := checkKlineFreshness(klines, interval, time.Now()); err != nil {
+		go WSMonitorCli.backfillKlines(symbol, interval)
+		return nil, fmt.Errorf("%s %w", symbol, err)
+	}
+	return klines, nil
+}
+
+func (binanceProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	return getOpenInterestData(symbol)
+}
+
+func (binanceProvider) GetFundingRate(symbol string) (float64, error) {
+	return getFundingRate(symbol)
+}
+
+func (binanceProvider) GetMarkPrice(symbol string) (float64, error) {
+	return NewAPIClient().GetMarkPrice(symbol)
+}
+
+func (binanceProvider) FundingIntervalHours(symbol string) float64 {
+	return binanceFundingIntervals.get(symbol)
+}
+
+func (binanceProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	if WSMonitorCli == nil {
+		return nil, fmt.Errorf("行情监控未启动")
+	}
+	return WSMonitorCli.GetOrderBook(symbol)
+}
+
+func (binanceProvider) SubscribePrices(listener PriceListener) (func(), bool) {
+	if WSMonitorCli == nil {
+		return nil, false
+	}
+	return WSMonitorCli.AddPriceListener(listener), true
+}
+
+func (binanceProvider) GetFlow(symbol string) *FlowData {
+	return getFlowData(symbol)
+}
+
+var (
+	providersMu sync.Mutex
+	providers   = make(map[string]MarketDataProvider)
+)
+
+// ProviderFor 按交易所选择行情数据源（同一交易所的交易员共享数据源和缓存，未知交易所使用币安）
+func ProviderFor(exchange string, testnet bool) MarketDataProvider {
+	key := exchange
+	switch exchange {
+	case "hyperliquid":
+		if testnet {
+			key += "-testnet"
+		}
+	case "aster":
+	default:
+		return Binance
+	}
+
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if p, ok := providers[key]; ok {
+		return p
+	}
+
+	var p MarketDataProvider
+	if exchange == "hyperliquid" {
+		p = newHyperliquidProvider(testnet)
+	} else {
+		p = newAsterProvider()
+	}
+	providers[key] = p
+	return p
+}
+
+// Available 数据源是否可用（币安依赖WebSocket监控器启动，其他交易所直接请求接口）
+func Available(p MarketDataProvider) bool {
+	if p == nil || p == Binance {
+		return WSMonitorCli != nil
+	}
+	return true
+}
+
+// FetchData 从指定数据源获取市场数据（p 为nil时使用币安，timeframes 为空时使用默认3m/4h）
+func FetchData(p MarketDataProvider, symbol string, timeframes []string) (*Data, error) {
+	if p == nil || p == Binance {
+		return GetWithTimeframes(symbol, timeframes)
+	}
+	if len(timeframes) == 0 || isDefaultTimeframes(timeframes) {
+		return fetchDefaultData(p, symbol)
+	}
+	return fetchTimeframeData(p, symbol, timeframes)
+}
+
+// getFlowFrom 获取数据源的主动成交与强平数据（不支持时返回nil）
+func getFlowFrom(p MarketDataProvider, symbol string) *FlowData {
+	if fs, ok := p.(flowSource); ok {
+		return fs.GetFlow(symbol)
+	}
+	return nil
+}
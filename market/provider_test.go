@@ -0,0 +1,214 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestHyperliquidServer 模拟 Hyperliquid info 接口
+func newTestHyperliquidServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Type string `json:"type"`
+			Coin string `json:"coin"`
+			Req  struct {
+				Coin     string `json:"coin"`
+				Interval string `json:"interval"`
+			} `json:"req"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("解析请求失败: %v", err)
+		}
+		switch req.Type {
+		case "metaAndAssetCtxs":
+			fmt.Fprint(w, `[{"universe":[{"name":"BTC"},{"name":"kPEPE"}]},`+
+				`[{"funding":"0.0000125","openInterest":"1500.5","markPx":"50000"},{"funding":"-0.00001","openInterest":"9000000","markPx":"0.01"}]]`)
+		case "candleSnapshot":
+			var candles []string
+			for i := 0; i < 120; i++ {
+				candles = append(candles, fmt.Sprintf(`{"t":%d,"T":%d,"s":"%s","i":"%s","o":"%d","c":"%d","h":"%d","l":"%d","v":"2.5","n":10}`,
+					i*180000, i*180000+179999, req.Req.Coin, req.Req.Interval, 100+i, 101+i, 102+i, 99+i))
+			}
+			fmt.Fprint(w, "["+strings.Join(candles, ",")+"]")
+		case "l2Book":
+			fmt.Fprint(w, `{"coin":"BTC","levels":[[{"px":"49990","sz":"1","n":1},{"px":"49980","sz":"2","n":1}],[{"px":"50010","sz":"1.5","n":2}]]}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+// TestHyperliquidProvider 测试 Hyperliquid 数据源的币种映射、K线、资金费率、持仓量和订单簿
+func TestHyperliquidProvider(t *testing.T) {
+	server := newTestHyperliquidServer(t)
+	defer server.Close()
+	p := &hyperliquidProvider{infoURL: server.URL, client: server.Client()}
+
+	klines, err := p.GetKlines("KPEPEUSDT", "3m")
+	if err != nil {
+		t.Fatalf("GetKlines error: %v", err)
+	}
+	if len(klines) != 100 || klines[99].Close != 220 || klines[99].Volume != 2.5 {
+		t.Errorf("klines = %d 根, 最后一根 %+v，want 最近100根", len(klines), klines[len(klines)-1])
+	}
+
+	rate, err := p.GetFundingRate("BTCUSDT")
+	if err != nil || math.Abs(rate-0.0001) > 1e-12 {
+		t.Errorf("GetFundingRate = %v, %v, want 每小时费率折算8小时 0.0001", rate, err)
+	}
+	if mark, err := p.GetMarkPrice("BTCUSDT"); err != nil || mark != 50000 {
+		t.Errorf("GetMarkPrice = %v, %v", mark, err)
+	}
+	oi, err := p.GetOpenInterest("BTCUSDT")
+	if err != nil || oi.Latest != 1500.5 {
+		t.Errorf("GetOpenInterest = %+v, %v", oi, err)
+	}
+
+	book, err := p.GetOrderBook("BTCUSDT")
+	if err != nil {
+		t.Fatalf("GetOrderBook error: %v", err)
+	}
+	if len(book.Bids) != 2 || len(book.Asks) != 1 || book.Bids[0].Price != 49990 || book.Asks[0].Quantity != 1.5 {
+		t.Errorf("book = %+v", book)
+	}
+
+	if _, err := p.GetKlines("DOGEUSDT", "3m"); err == nil {
+		t.Error("Hyperliquid 不存在的币种应返回错误")
+	}
+}
+
+// TestHyperliquidFetchData 测试按数据源生成市场数据
+func TestHyperliquidFetchData(t *testing.T) {
+	server := newTestHyperliquidServer(t)
+	defer server.Close()
+	p := &hyperliquidProvider{infoURL: server.URL, client: server.Client()}
+
+	data, err := FetchData(p, "BTC", nil)
+	if err != nil {
+		t.Fatalf("FetchData error: %v", err)
+	}
+	if data.Symbol != "BTCUSDT" || data.CurrentPrice != 220 || data.MarkPrice != 50000 || data.IntradaySeries == nil || data.LongerTermContext == nil {
+		t.Errorf("默认周期数据不完整: %+v", data)
+	}
+	if data.Depth == nil || data.Flow != nil {
+		t.Errorf("应包含订单簿、不包含币安成交流: depth=%v flow=%v", data.Depth, data.Flow)
+	}
+
+	data, err = FetchData(p, "BTCUSDT", []string{"1m", "1h"})
+	if err != nil {
+		t.Fatalf("FetchData(自定义周期) error: %v", err)
+	}
+	if len(data.Timeframes) != 2 || data.Timeframe("1h") == nil {
+		t.Errorf("Timeframes = %+v", data.Timeframes)
+	}
+}
+
+// TestHyperliquidCandleStream 测试K线推送合并到快照
+func TestHyperliquidCandleStream(t *testing.T) {
+	s := newHyperliquidCandleStream("")
+	s.handleMessage([]byte(`{"channel":"candle","data":{"t":0,"s":"BTC","i":"1m","c":"1"}}`))
+	if _, ok := s.get("BTC", "1m"); ok {
+		t.Fatal("没有快照时不应缓存推送")
+	}
+
+	s.klines.Store(candleKey("BTC", "1m"), []Kline{{OpenTime: 0, Close: 100}, {OpenTime: 60000, Close: 101}})
+	s.handleMessage([]byte(`{"channel":"candle","data":{"t":60000,"s":"BTC","i":"1m","o":"101","c":"102","h":"103","l":"100","v":"5"}}`))
+	s.handleMessage([]byte(`{"channel":"candle","data":{"t":120000,"s":"BTC","i":"1m","o":"102","c":"104","h":"104","l":"102","v":"1"}}`))
+	s.handleMessage([]byte(`{"channel":"subscriptionResponse","data":{}}`))
+
+	klines, ok := s.get("BTC", "1m")
+	if !ok || len(klines) != 3 {
+		t.Fatalf("klines = %+v", klines)
+	}
+	if klines[1].Close != 102 || klines[1].Volume != 5 || klines[2].Close != 104 {
+		t.Errorf("推送未正确合并: %+v", klines)
+	}
+}
+
+// TestHyperliquidMidsPush 测试中间价推送转换为币安格式后通知监听器
+func TestHyperliquidMidsPush(t *testing.T) {
+	s := newHyperliquidCandleStream("")
+	prices := make(map[string]float64)
+	s.priceListeners = map[int]PriceListener{0: func(symbol string, price float64) { prices[symbol] = price }}
+
+	s.handleMessage([]byte(`{"channel":"allMids","data":{"mids":{"BTC":"65000.5","ETH":"3200","@107":"12.3","BAD":"x"}}}`))
+
+	if len(prices) != 2 || prices["BTCUSDT"] != 65000.5 || prices["ETHUSDT"] != 3200 {
+		t.Errorf("prices = %+v", prices)
+	}
+}
+
+// TestAsterProvider 测试 Aster 数据源复用币安兼容接口、缓存K线并按结算间隔折算资金费率
+func TestAsterProvider(t *testing.T) {
+	var klineRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fapi/v1/klines":
+			atomic.AddInt32(&klineRequests, 1)
+			now := time.Now().UnixMilli()
+			fmt.Fprintf(w, `[[%d,"1","2","0.5","1.5","10",%d,"15",3,"6","9"]]`, now, now+179999)
+		case "/fapi/v1/premiumIndex":
+			fmt.Fprint(w, `{"symbol":"BTCUSDT","markPrice":"64000.5","lastFundingRate":"0.00025"}`)
+		case "/fapi/v1/openInterest":
+			fmt.Fprint(w, `{"symbol":"BTCUSDT","openInterest":"321.5"}`)
+		case "/fapi/v1/fundingInfo":
+			fmt.Fprint(w, `[{"symbol":"ETHUSDT","fundingIntervalHours":4}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	api := &APIClient{client: server.Client(), baseURL: server.URL}
+	p := &asterProvider{api: api, intervals: &fundingIntervalCache{fetch: api.GetFundingIntervals}}
+
+	for i := 0; i < 2; i++ {
+		klines, err := p.GetKlines("btcusdt", "3m")
+		if err != nil || len(klines) != 1 || klines[0].Close != 1.5 {
+			t.Fatalf("GetKlines = %+v, %v", klines, err)
+		}
+	}
+	if n := atomic.LoadInt32(&klineRequests); n != 1 {
+		t.Errorf("K线请求 %d 次，缓存期内应只请求1次", n)
+	}
+
+	if rate, err := p.GetFundingRate("BTCUSDT"); err != nil || rate != 0.00025 {
+		t.Errorf("GetFundingRate = %v, %v", rate, err)
+	}
+	// 每4小时结算的币种折算为8小时单期费率
+	if rate, err := p.GetFundingRate("ETHUSDT"); err != nil || rate != 0.0005 {
+		t.Errorf("GetFundingRate(4h) = %v, %v", rate, err)
+	}
+	if p.FundingIntervalHours("ETHUSDT") != 4 || p.FundingIntervalHours("BTCUSDT") != DefaultFundingIntervalHours {
+		t.Errorf("FundingIntervalHours = %v / %v", p.FundingIntervalHours("ETHUSDT"), p.FundingIntervalHours("BTCUSDT"))
+	}
+	if mark, err := p.GetMarkPrice("BTCUSDT"); err != nil || mark != 64000.5 {
+		t.Errorf("GetMarkPrice = %v, %v", mark, err)
+	}
+	if oi, err := p.GetOpenInterest("BTCUSDT"); err != nil || oi.Latest != 321.5 {
+		t.Errorf("GetOpenInterest = %+v, %v", oi, err)
+	}
+}
+
+// TestProviderFor 测试按交易所选择数据源
+func TestProviderFor(t *testing.T) {
+	if ProviderFor("binance", false) != Binance || ProviderFor("", false) != Binance {
+		t.Error("币安和未配置交易所应使用币安数据源")
+	}
+	hl := ProviderFor("hyperliquid", false)
+	if hl.Name() != "hyperliquid" || ProviderFor("hyperliquid", false) != hl {
+		t.Error("同一交易所应共享数据源")
+	}
+	if ProviderFor("hyperliquid", true) == hl {
+		t.Error("测试网应使用独立的数据源")
+	}
+	if ProviderFor("aster", false).Name() != "aster" {
+		t.Error("Aster 交易员应使用 Aster 数据源")
+	}
+}
@@ -0,0 +1,312 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	wsReadTimeout          = 2 * time.Minute  // 超过该时间未收到任何消息（含ping）视为连接中断，触发重连
+	streamRateWindow       = time.Minute      // 消息速率统计窗口
+	streamStaleAfter       = 2 * time.Minute  // K线/深度流超过该时间无消息视为过期
+	quietStreamStaleAfter  = 15 * time.Minute // 成交、强平等可能长时间无推送的流
+	staleKlineIntervals    = 3                // K线缓存最后一根收盘超过N个周期视为过期（推送中断且未回填）
+	maxReportedStaleStream = 50               // 健康报告中最多列出的过期流数量
+)
+
+// StreamHealth 单个流的健康状态
+type StreamHealth struct {
+	Stream        string    `json:"stream"`
+	LastMessage   time.Time `json:"last_message"`
+	AgeSeconds    float64   `json:"age_seconds"`  // 距上次消息（从未收到消息时为距订阅）的秒数
+	MessageRate   float64   `json:"message_rate"` // 最近一个统计窗口的消息速率（条/秒）
+	TotalMessages int64     `json:"total_messages"`
+	Gaps          int       `json:"gaps"` // 检测到的K线缺口次数
+	Stale         bool      `json:"stale"`
+}
+
+// ClientHealth WebSocket连接的健康状态
+type ClientHealth struct {
+	Connected     bool           `json:"connected"`
+	Reconnects    int            `json:"reconnects"`
+	LastConnected time.Time      `json:"last_connected"`
+	StreamCount   int            `json:"stream_count"`
+	StaleStreams  int            `json:"stale_streams"`
+	Streams       []StreamHealth `json:"streams,omitempty"` // 默认只列出过期的流，摘要中不列出
+}
+
+// MonitorHealth 行情监控器的健康报告
+type MonitorHealth struct {
+	Status           string       `json:"status"` // ok / degraded（连接断开或存在过期的流）
+	CombinedStreams  ClientHealth `json:"combined_streams"`
+	KlineGaps        int64        `json:"kline_gaps"`
+	Backfills        int64        `json:"backfills"`
+	BackfillFailures int64        `json:"backfill_failures"`
+}
+
+// streamStats 单个流的统计
+type streamStats struct {
+	subscribedAt time.Time
+	lastMessage  time.Time
+	total        int64
+	windowStart  time.Time
+	windowCount  int64
+	rate         float64
+	gaps         int
+}
+
+// clientHealth WebSocket客户端的连接与各流统计
+type clientHealth struct {
+	mu            sync.Mutex
+	connected     bool
+	reconnects    int
+	lastConnected time.Time
+	streams       map[string]*streamStats
+}
+
+func newClientHealth() *clientHealth {
+	return &clientHealth{streams: make(map[string]*streamStats)}
+}
+
+// setConnected 记录连接状态（重连成功时累计重连次数）
+func (h *clientHealth) setConnected(connected bool, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if connected {
+		if !h.lastConnected.IsZero() {
+			h.reconnects++
+		}
+		h.lastConnected = now
+	}
+	h.connected = connected
+}
+
+// addStreams 登记已订阅的流（从未收到消息的流按订阅时间判断是否过期）
+func (h *clientHealth) addStreams(streams []string, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, stream := range streams {
+		if _, ok := h.streams[stream]; !ok {
+			h.streams[stream] = &streamStats{subscribedAt: now}
+		}
+	}
+}
+
+// removeStreams 移除已退订的流
+func (h *clientHealth) removeStreams(streams []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, stream := range streams {
+		delete(h.streams, stream)
+	}
+}
+
+// recordMessage 记录一条消息
+func (h *clientHealth) recordMessage(stream string, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.streams[stream]
+	if !ok {
+		s = &streamStats{subscribedAt: now}
+		h.streams[stream] = s
+	}
+	if s.windowStart.IsZero() {
+		s.windowStart = now
+	}
+	if elapsed := now.Sub(s.windowStart); elapsed >= streamRateWindow {
+		s.rate = float64(s.windowCount) / elapsed.Seconds()
+		s.windowStart = now
+		s.windowCount = 0
+	}
+	s.windowCount++
+	s.total++
+	s.lastMessage = now
+}
+
+// recordGap 记录流的K线缺口
+func (h *clientHealth) recordGap(stream string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.streams[stream]; ok {
+		s.gaps++
+	}
+}
+
+// snapshot 生成健康状态（verbose 为 false 时只列出过期的流）
+func (h *clientHealth) snapshot(now time.Time, verbose bool) ClientHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	report := ClientHealth{
+		Connected:     h.connected,
+		Reconnects:    h.reconnects,
+		LastConnected: h.lastConnected,
+		StreamCount:   len(h.streams),
+		Streams:       []StreamHealth{},
+	}
+	for name, s := range h.streams {
+		since := s.lastMessage
+		if since.IsZero() {
+			since = s.subscribedAt
+		}
+		age := now.Sub(since)
+		rate := s.rate
+		if now.Sub(s.windowStart) >= 2*streamRateWindow {
+			rate = 0 // 超过两个窗口没有消息
+		}
+		stream := StreamHealth{
+			Stream:        name,
+			LastMessage:   s.lastMessage,
+			AgeSeconds:    age.Seconds(),
+			MessageRate:   rate,
+			TotalMessages: s.total,
+			Gaps:          s.gaps,
+			Stale:         age > staleThreshold(name),
+		}
+		if stream.Stale {
+			report.StaleStreams++
+		}
+		if verbose || stream.Stale {
+			report.Streams = append(report.Streams, stream)
+		}
+	}
+
+	sort.Slice(report.Streams, func(i, j int) bool {
+		return report.Streams[i].AgeSeconds > report.Streams[j].AgeSeconds
+	})
+	if !verbose && len(report.Streams) > maxReportedStaleStream {
+		report.Streams = report.Streams[:maxReportedStaleStream]
+	}
+	return report
+}
+
+// staleThreshold 流的过期阈值（K线和深度持续推送，成交和强平可能长时间无消息）
+func staleThreshold(stream string) time.Duration {
+	if strings.Contains(stream, "@kline_") || strings.HasSuffix(stream, "@"+depthStreamSuffix) {
+		return streamStaleAfter
+	}
+	return quietStreamStaleAfter
+}
+
+// klineStream K线流名称
+func klineStream(symbol, interval string) string {
+	return fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), interval)
+}
+
+// checkKlineFreshness 检查K线缓存是否过期（最后一根K线收盘超过 staleKlineIntervals 个周期）
+func checkKlineFreshness(klines []Kline, interval string, now time.Time) error {
+	d, ok := intervalDurations[interval]
+	if !ok || len(klines) == 0 || klines[len(klines)-1].CloseTime == 0 {
+		return nil
+	}
+	age := now.Sub(time.UnixMilli(klines[len(klines)-1].CloseTime))
+	if age > staleKlineIntervals*d {
+		return fmt.Errorf("%s K线数据已过期（最后一根K线收盘于 %v 前，超过 %d 个周期）", interval, age.Round(time.Second), staleKlineIntervals)
+	}
+	return nil
+}
+
+// mergeKlines 合并REST回填的K线与缓存（回填覆盖已收盘K线，缓存中更新的K线保留），按时间排序并保留最近 limit 根
+func mergeKlines(cached, fetched []Kline, limit int) []Kline {
+	byOpenTime := make(map[int64]Kline, len(cached)+len(fetched))
+	for _, k := range fetched {
+		byOpenTime[k.OpenTime] = k
+	}
+	var lastFetched int64
+	if len(fetched) > 0 {
+		lastFetched = fetched[len(fetched)-1].OpenTime
+	}
+	for _, k := range cached {
+		if _, ok := byOpenTime[k.OpenTime]; !ok || k.OpenTime > lastFetched {
+			byOpenTime[k.OpenTime] = k
+		}
+	}
+
+	merged := make([]Kline, 0, len(byOpenTime))
+	for _, k := range byOpenTime {
+		merged = append(merged, k)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].OpenTime < merged[j].OpenTime })
+	if len(merged) > limit {
+		merged = merged[len(merged)-limit:]
+	}
+	return merged
+}
+
+// detectKlineGap 新K线与缓存最后一根之间缺失的K线数量
+func detectKlineGap(last, next Kline, interval string) int {
+	d, ok := intervalDurations[interval]
+	if !ok || last.OpenTime == 0 {
+		return 0
+	}
+	missing := int((next.OpenTime-last.OpenTime)/d.Milliseconds()) - 1
+	if missing < 0 {
+		return 0
+	}
+	return missing
+}
+
+// backfillKlines 通过REST回填K线缓存（同一交易对和周期同时只回填一次）
+func (m *WSMonitor) backfillKlines(symbol, interval string) {
+	key := symbol + "|" + interval
+	if _, loaded := m.backfilling.LoadOrStore(key, true); loaded {
+		return
+	}
+	defer m.backfilling.Delete(key)
+
+	fetched, err := NewAPIClient().GetKlines(symbol, interval, 100)
+	if err != nil {
+		atomic.AddInt64(&m.backfillFailures, 1)
+		log.Printf("⚠️ 回填 %s %s K线失败: %v", symbol, interval, err)
+		return
+	}
+
+	klineDataMap := m.getKlineDataMap(interval)
+	var cached []Kline
+	if value, ok := klineDataMap.Load(symbol); ok {
+		cached = value.([]Kline)
+	}
+	klineDataMap.Store(symbol, mergeKlines(cached, fetched, 100))
+	atomic.AddInt64(&m.backfills, 1)
+	log.Printf("✓ 已回填 %s %s K线: %d 条", symbol, interval, len(fetched))
+}
+
+// Health 行情监控器的健康报告（监控器未启动时返回nil）
+func Health(verbose bool) *MonitorHealth {
+	m := WSMonitorCli
+	if m == nil {
+		return nil
+	}
+	return m.Health(verbose)
+}
+
+// Health 生成健康报告（verbose 为 true 时列出所有流）
+func (m *WSMonitor) Health(verbose bool) *MonitorHealth {
+	report := &MonitorHealth{
+		Status:           "ok",
+		CombinedStreams:  m.combinedClient.health.snapshot(time.Now(), verbose),
+		KlineGaps:        atomic.LoadInt64(&m.klineGaps),
+		Backfills:        atomic.LoadInt64(&m.backfills),
+		BackfillFailures: atomic.LoadInt64(&m.backfillFailures),
+	}
+	if !report.CombinedStreams.Connected || report.CombinedStreams.StaleStreams > 0 {
+		report.Status = "degraded"
+	}
+	return report
+}
+
+// Summary 健康报告摘要（只保留状态与计数，不列出具体的流和币种，供无需认证的健康检查使用）
+func (h *MonitorHealth) Summary() *MonitorHealth {
+	if h == nil {
+		return nil
+	}
+	summary := *h
+	summary.CombinedStreams.Streams = nil
+	return &summary
+}
@@ -0,0 +1,145 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+// TestClientHealth 测试流的消息统计、过期判断和重连计数
+func TestClientHealth(t *testing.T) {
+	h := newClientHealth()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	h.setConnected(true, start)
+	h.addStreams([]string{"btcusdt@kline_3m", "!forceOrder@arr", "ethusdt@kline_3m"}, start)
+	for i := 0; i <= 120; i++ {
+		h.recordMessage("btcusdt@kline_3m", start.Add(time.Duration(i)*500*time.Millisecond))
+	}
+	h.recordGap("btcusdt@kline_3m")
+	h.setConnected(false, start.Add(time.Minute))
+	h.setConnected(true, start.Add(time.Minute+3*time.Second))
+
+	now := start.Add(150 * time.Second)
+	report := h.snapshot(now, true)
+	if !report.Connected || report.Reconnects != 1 || report.StreamCount != 3 {
+		t.Errorf("report = %+v", report)
+	}
+	streams := make(map[string]StreamHealth)
+	for _, s := range report.Streams {
+		streams[s.Stream] = s
+	}
+	btc := streams["btcusdt@kline_3m"]
+	if btc.TotalMessages != 121 || btc.Gaps != 1 || btc.Stale || btc.MessageRate != 2 {
+		t.Errorf("btc = %+v，want 121条、1个缺口、2条/秒、未过期", btc)
+	}
+	if !streams["ethusdt@kline_3m"].Stale {
+		t.Error("订阅后长时间无消息的K线流应过期")
+	}
+	if streams["!forceOrder@arr"].Stale {
+		t.Error("强平流使用更长的过期阈值")
+	}
+
+	// 默认只列出过期的流
+	report = h.snapshot(now, false)
+	if report.StaleStreams != 1 || len(report.Streams) != 1 || report.Streams[0].Stream != "ethusdt@kline_3m" {
+		t.Errorf("非verbose报告 = %+v", report)
+	}
+
+	// 摘要保留计数，不列出具体的流
+	full := &MonitorHealth{Status: "degraded", CombinedStreams: report}
+	summary := full.Summary()
+	if summary.Status != "degraded" || summary.CombinedStreams.StaleStreams != 1 || summary.CombinedStreams.StreamCount != 3 || len(summary.CombinedStreams.Streams) != 0 {
+		t.Errorf("摘要 = %+v", summary)
+	}
+	if len(full.CombinedStreams.Streams) != 1 {
+		t.Error("生成摘要不应修改原报告")
+	}
+	if (*MonitorHealth)(nil).Summary() != nil {
+		t.Error("监控器未启动时摘要应为nil")
+	}
+}
+
+// TestCheckKlineFreshness 测试过期K线判断
+func TestCheckKlineFreshness(t *testing.T) {
+	now := time.Now()
+	closedAt := func(ago time.Duration) []Kline {
+		return []Kline{{CloseTime: now.Add(-ago).UnixMilli()}}
+	}
+
+	if err := checkKlineFreshness(closedAt(-time.Minute), "3m", now); err != nil {
+		t.Errorf("未收盘的当前K线不应过期: %v", err)
+	}
+	if err := checkKlineFreshness(closedAt(8*time.Minute), "3m", now); err != nil {
+		t.Errorf("未超过3个周期不应过期: %v", err)
+	}
+	if err := checkKlineFreshness(closedAt(10*time.Minute), "3m", now); err == nil {
+		t.Error("超过3个周期应过期")
+	}
+	if err := checkKlineFreshness(closedAt(10*time.Minute), "4h", now); err != nil {
+		t.Errorf("4h周期不应过期: %v", err)
+	}
+	if err := checkKlineFreshness(nil, "3m", now); err != nil {
+		t.Errorf("空K线不检查: %v", err)
+	}
+}
+
+// TestMergeKlines 测试回填K线与缓存合并
+func TestMergeKlines(t *testing.T) {
+	bar := func(openTime int64, close float64) Kline { return Kline{OpenTime: openTime, Close: close} }
+
+	cached := []Kline{bar(1, 10), bar(2, 20), bar(5, 50), bar(6, 60)} // 缺少3、4
+	fetched := []Kline{bar(2, 21), bar(3, 30), bar(4, 40), bar(5, 51)}
+
+	merged := mergeKlines(cached, fetched, 5)
+	want := []float64{21, 30, 40, 51, 60}
+	if len(merged) != len(want) {
+		t.Fatalf("merged = %+v", merged)
+	}
+	for i, k := range merged {
+		if k.Close != want[i] {
+			t.Errorf("merged[%d].Close = %v, want %v", i, k.Close, want[i])
+		}
+	}
+}
+
+// TestDetectKlineGap 测试K线缺口检测
+func TestDetectKlineGap(t *testing.T) {
+	minute := time.Minute.Milliseconds()
+	last := Kline{OpenTime: 100 * minute}
+
+	if n := detectKlineGap(last, Kline{OpenTime: 103 * minute}, "3m"); n != 0 {
+		t.Errorf("连续K线 missing = %d", n)
+	}
+	if n := detectKlineGap(last, Kline{OpenTime: 112 * minute}, "3m"); n != 3 {
+		t.Errorf("missing = %d, want 3", n)
+	}
+	if n := detectKlineGap(last, Kline{OpenTime: 112 * minute}, "1M"); n != 0 {
+		t.Errorf("未知周期不检测, missing = %d", n)
+	}
+}
+
+// TestProcessKlineUpdateGap 测试推送K线出现缺口时记录并回填
+func TestProcessKlineUpdateGap(t *testing.T) {
+	m := &WSMonitor{combinedClient: NewCombinedStreamsClient(10)}
+	m.combinedClient.health.addStreams([]string{"btcusdt@kline_1h"}, time.Now())
+	m.backfilling.Store("BTCUSDT|1h", true) // 模拟回填进行中，避免测试请求网络
+
+	hour := time.Hour.Milliseconds()
+	m.getKlineDataMap("1h").Store("BTCUSDT", []Kline{{OpenTime: 10 * hour, Close: 1}})
+
+	var update KlineWSData
+	update.Kline.StartTime = 11 * hour
+	update.Kline.ClosePrice = "2"
+	m.processKlineUpdate("BTCUSDT", update, "1h")
+	update.Kline.StartTime = 14 * hour
+	m.processKlineUpdate("BTCUSDT", update, "1h")
+
+	health := m.Health(true)
+	if health.KlineGaps != 1 || health.CombinedStreams.Streams[0].Gaps != 1 {
+		t.Errorf("health = %+v", health)
+	}
+	value, _ := m.getKlineDataMap("1h").Load("BTCUSDT")
+	if klines := value.([]Kline); len(klines) != 3 {
+		t.Errorf("klines = %d 根, want 3", len(klines))
+	}
+}
@@ -0,0 +1,160 @@
+package market
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 交易所（决定交易对的书写格式）
+const (
+	VenueBinance     = "binance"     // BTCUSDT、BTCUSDC、1000PEPEUSDT
+	VenueAster       = "aster"       // 与币安格式相同
+	VenueHyperliquid = "hyperliquid" // 只有币种名：BTC、kPEPE（USDC保证金）
+)
+
+// ContractPerpetual 永续合约
+const ContractPerpetual = "PERPETUAL"
+
+// supportedQuotes 支持的计价（保证金）币种（按后缀识别）
+var supportedQuotes = []string{"USDT", "USDC"}
+
+// multiplierPrefixes 低价币合约的数量倍数前缀（币安 1000PEPE、1000000MOG，Hyperliquid kPEPE）
+var multiplierPrefixes = []struct {
+	prefix     string
+	multiplier int
+}{
+	{"1000000", 1000000},
+	{"100000", 100000},
+	{"10000", 10000},
+	{"1000", 1000},
+	{"1M", 1000000},
+}
+
+// Symbol 交易对模型（与交易所书写格式无关）
+type Symbol struct {
+	Base         string // 基础币种（不含倍数前缀），如 BTC、PEPE
+	Quote        string // 计价/保证金币种，如 USDT、USDC
+	Multiplier   int    // 合约数量倍数，1000PEPE 为 1000，普通合约为 1
+	Venue        string // 解析来源的交易所
+	ContractType string // 合约类型，目前只有永续合约
+
+	prefix string // 原始倍数前缀（1000、1M），Format 时原样保留
+}
+
+// ParseSymbol 解析交易所格式的交易对
+// 支持 BTCUSDT、btc/usdt、BTC-USDC、BTC/USDT:USDT、1000PEPEUSDT、kPEPE、BTC（按交易所补全默认计价币种）
+func ParseSymbol(raw, venue string) (Symbol, error) {
+	s := strings.TrimSpace(raw)
+	if i := strings.Index(s, ":"); i >= 0 {
+		s = s[:i] // 去掉 ccxt 格式的结算币种后缀
+	}
+	s = strings.NewReplacer("/", "", "-", "", "_", "", " ", "").Replace(s)
+
+	sym := Symbol{Venue: venue, ContractType: ContractPerpetual, Multiplier: 1}
+
+	// Hyperliquid 用小写 k 前缀表示1000倍合约（kPEPE），需在转大写前识别
+	if len(s) > 1 && s[0] == 'k' && s[1:] == strings.ToUpper(s[1:]) && strings.ToLower(s[1:]) != s[1:] {
+		sym.Multiplier = 1000
+		s = s[1:]
+	}
+	s = strings.ToUpper(s)
+
+	for _, quote := range supportedQuotes {
+		if len(s) > len(quote) && strings.HasSuffix(s, quote) {
+			sym.Quote = quote
+			s = strings.TrimSuffix(s, quote)
+			break
+		}
+	}
+	if sym.Quote == "" {
+		sym.Quote = defaultQuote(venue)
+	}
+
+	if sym.Multiplier == 1 {
+		for _, p := range multiplierPrefixes {
+			if len(s) > len(p.prefix) && strings.HasPrefix(s, p.prefix) && isLetter(s[len(p.prefix)]) {
+				sym.Multiplier = p.multiplier
+				sym.prefix = p.prefix
+				s = s[len(p.prefix):]
+				break
+			}
+		}
+	}
+
+	if s == "" {
+		return Symbol{}, fmt.Errorf("无效的交易对: %q", raw)
+	}
+	sym.Base = s
+	return sym, nil
+}
+
+// defaultQuote 只写币种名时的默认计价币种（Hyperliquid 合约以USDC结算）
+func defaultQuote(venue string) string {
+	if venue == VenueHyperliquid {
+		return "USDC"
+	}
+	return "USDT"
+}
+
+func isLetter(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+// Format 按交易所格式书写交易对
+func (s Symbol) Format(venue string) string {
+	if venue == VenueHyperliquid {
+		if s.Multiplier == 1000 {
+			return "k" + s.Base
+		}
+		return s.Base
+	}
+	return s.Ticker() + s.Quote
+}
+
+// Ticker 带倍数前缀的币种名（如 1000PEPE）
+func (s Symbol) Ticker() string {
+	if s.prefix != "" {
+		return s.prefix + s.Base
+	}
+	for _, p := range multiplierPrefixes {
+		if p.multiplier == s.Multiplier && p.prefix != "1M" {
+			return p.prefix + s.Base
+		}
+	}
+	return s.Base
+}
+
+// String 按解析来源的交易所格式书写
+func (s Symbol) String() string {
+	return s.Format(s.Venue)
+}
+
+// IsSupportedQuote 是否为支持的计价（保证金）币种
+func IsSupportedQuote(quote string) bool {
+	quote = strings.ToUpper(quote)
+	for _, q := range supportedQuotes {
+		if q == quote {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBTCETH 是否为 BTC 或 ETH 合约（按基础币种判断，不区分计价币种和交易所格式）
+func IsBTCETH(symbol string) bool {
+	sym, err := ParseSymbol(symbol, VenueBinance)
+	return err == nil && (sym.Base == "BTC" || sym.Base == "ETH")
+}
+
+// ConvertSymbol 在交易所格式之间转换交易对（无法解析时返回大写的原始输入）
+// 转换到 Hyperliquid 时丢弃计价币种；从 Hyperliquid 转出时使用目标交易所的默认计价币种
+func ConvertSymbol(symbol, from, to string) string {
+	sym, err := ParseSymbol(symbol, from)
+	if err != nil {
+		return strings.ToUpper(strings.TrimSpace(symbol))
+	}
+	if from == VenueHyperliquid && to != VenueHyperliquid {
+		sym.Quote = defaultQuote(to)
+	}
+	return sym.Format(to)
+}
@@ -0,0 +1,114 @@
+package market
+
+import "testing"
+
+// TestParseSymbol 测试各交易所格式的交易对解析
+func TestParseSymbol(t *testing.T) {
+	tests := []struct {
+		raw        string
+		venue      string
+		base       string
+		quote      string
+		multiplier int
+		wantErr    bool
+	}{
+		{raw: "BTCUSDT", venue: VenueBinance, base: "BTC", quote: "USDT", multiplier: 1},
+		{raw: " btcusdc ", venue: VenueBinance, base: "BTC", quote: "USDC", multiplier: 1},
+		{raw: "ETH/USDT:USDT", venue: VenueBinance, base: "ETH", quote: "USDT", multiplier: 1},
+		{raw: "sol-usdc", venue: VenueAster, base: "SOL", quote: "USDC", multiplier: 1},
+		{raw: "BTC", venue: VenueBinance, base: "BTC", quote: "USDT", multiplier: 1},
+		{raw: "BTC", venue: VenueHyperliquid, base: "BTC", quote: "USDC", multiplier: 1},
+		{raw: "1000PEPEUSDT", venue: VenueBinance, base: "PEPE", quote: "USDT", multiplier: 1000},
+		{raw: "1000000MOGUSDT", venue: VenueBinance, base: "MOG", quote: "USDT", multiplier: 1000000},
+		{raw: "1MBABYDOGEUSDT", venue: VenueBinance, base: "BABYDOGE", quote: "USDT", multiplier: 1000000},
+		{raw: "kPEPE", venue: VenueHyperliquid, base: "PEPE", quote: "USDC", multiplier: 1000},
+		{raw: "KAVA", venue: VenueHyperliquid, base: "KAVA", quote: "USDC", multiplier: 1},
+		{raw: "1INCHUSDT", venue: VenueBinance, base: "1INCH", quote: "USDT", multiplier: 1},
+		{raw: "USDT", venue: VenueBinance, base: "USDT", quote: "USDT", multiplier: 1},
+		{raw: " / ", venue: VenueBinance, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw+"@"+tt.venue, func(t *testing.T) {
+			sym, err := ParseSymbol(tt.raw, tt.venue)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSymbol(%q) 应返回错误, got %+v", tt.raw, sym)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSymbol(%q) error: %v", tt.raw, err)
+			}
+			if sym.Base != tt.base || sym.Quote != tt.quote || sym.Multiplier != tt.multiplier || sym.ContractType != ContractPerpetual {
+				t.Errorf("ParseSymbol(%q) = %+v, want %s/%s x%d", tt.raw, sym, tt.base, tt.quote, tt.multiplier)
+			}
+		})
+	}
+}
+
+// TestConvertSymbol 测试交易所格式之间的转换
+func TestConvertSymbol(t *testing.T) {
+	tests := []struct {
+		symbol string
+		from   string
+		to     string
+		want   string
+	}{
+		{"BTCUSDT", VenueBinance, VenueHyperliquid, "BTC"},
+		{"1000PEPEUSDT", VenueBinance, VenueHyperliquid, "kPEPE"},
+		{"kPEPE", VenueHyperliquid, VenueBinance, "1000PEPEUSDT"},
+		{"ETH", VenueHyperliquid, VenueBinance, "ETHUSDT"},
+		{"BTCUSDC", VenueBinance, VenueAster, "BTCUSDC"},
+		{"1MBABYDOGEUSDT", VenueBinance, VenueBinance, "1MBABYDOGEUSDT"},
+		{"btc/usdt", VenueBinance, VenueBinance, "BTCUSDT"},
+		{"", VenueBinance, VenueBinance, ""},
+	}
+
+	for _, tt := range tests {
+		if got := ConvertSymbol(tt.symbol, tt.from, tt.to); got != tt.want {
+			t.Errorf("ConvertSymbol(%q, %s → %s) = %q, want %q", tt.symbol, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// TestNormalize 测试标准化为币安格式
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"btc":          "BTCUSDT",
+		"ETHUSDT":      "ETHUSDT",
+		"solusdc":      "SOLUSDC",
+		"kPEPE":        "1000PEPEUSDT",
+		"1000BONKUSDT": "1000BONKUSDT",
+	}
+	for input, want := range tests {
+		if got := Normalize(input); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+// TestIsSupportedQuote 测试计价币种识别
+func TestIsSupportedQuote(t *testing.T) {
+	if !IsSupportedQuote("usdt") || !IsSupportedQuote("USDC") || IsSupportedQuote("BUSD") {
+		t.Error("IsSupportedQuote 结果不符")
+	}
+}
+
+// TestIsBTCETH 测试按基础币种识别 BTC/ETH（不区分计价币种）
+func TestIsBTCETH(t *testing.T) {
+	tests := map[string]bool{
+		"BTCUSDT":  true,
+		"BTCUSDC":  true,
+		"ETH":      true,
+		"eth/usdc": true,
+		"SOLUSDT":  false,
+		"WBTCUSDT": false,
+		"":         false,
+	}
+	for input, want := range tests {
+		if got := IsBTCETH(input); got != want {
+			t.Errorf("IsBTCETH(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package market
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultTimeframes 交易员未配置时使用的K线周期（3分钟日内序列 + 4小时长期背景）
+var DefaultTimeframes = []string{"3m", "4h"}
+
+// maxTimeframes 单个交易员最多配置的周期数量（每个周期都会在提示词中占一段）
+const maxTimeframes = 5
+
+// intervalDurations 币安合约支持的K线周期
+var intervalDurations = map[string]time.Duration{
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+	"3d":  72 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+// TimeframeData 单个K线周期的指标数据
+type TimeframeData struct {
+	Interval string
+	Series   *IntradayData   // 最近10根K线的价格与指标序列
+	Context  *LongerTermData // EMA20/50、ATR、成交量等汇总指标
+}
+
+// IntervalDuration 返回K线周期对应的时长
+func IntervalDuration(interval string) (time.Duration, bool) {
+	d, ok := intervalDurations[interval]
+	return d, ok
+}
+
+// ParseTimeframes 解析逗号分隔的K线周期配置（如 "1m,15m,1h,1d"），去重并按周期从短到长排序
+// 空字符串返回nil，表示使用默认周期
+func ParseTimeframes(raw string) ([]string, error) {
+	var timeframes []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		interval := strings.TrimSpace(part)
+		if interval == "" || seen[interval] {
+			continue
+		}
+		if _, ok := intervalDurations[interval]; !ok {
+			return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+		}
+		seen[interval] = true
+		timeframes = append(timeframes, interval)
+	}
+	if len(timeframes) > maxTimeframes {
+		return nil, fmt.Errorf("K线周期最多配置 %d 个，当前 %d 个", maxTimeframes, len(timeframes))
+	}
+
+	sort.Slice(timeframes, func(i, j int) bool {
+		return intervalDurations[timeframes[i]] < intervalDurations[timeframes[j]]
+	})
+	return timeframes, nil
+}
+
+// isDefaultTimeframes 是否与默认周期一致（一致时沿用原有的3m/4h输出格式）
+func isDefaultTimeframes(timeframes []string) bool {
+	if len(timeframes) != len(DefaultTimeframes) {
+		return false
+	}
+	for i, tf := range timeframes {
+		if tf != DefaultTimeframes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// GetWithTimeframes 按指定K线周期获取币安市场数据（周期需从短到长排列，见 ParseTimeframes）
+// 当前价格和即时指标取自最短周期，每个周期各生成一组序列与汇总指标
+func GetWithTimeframes(symbol string, timeframes []string) (*Data, error) {
+	if len(timeframes) == 0 || isDefaultTimeframes(timeframes) {
+		return Get(symbol)
+	}
+	return fetchTimeframeData(Binance, symbol, timeframes)
+}
+
+// fetchTimeframeData 从数据源按自定义周期获取市场数据
+func fetchTimeframeData(p MarketDataProvider, symbol string, timeframes []string) (*Data, error) {
+	symbol = Normalize(symbol)
+	klinesByInterval := make(map[string][]Kline, len(timeframes))
+	for _, tf := range timeframes {
+		klines, err := p.GetKlines(symbol, tf)
+		if err != nil {
+			return nil, fmt.Errorf("获取%s K线失败: %v", tf, err)
+		}
+		if len(klines) == 0 {
+			return nil, fmt.Errorf("%s K线数据为空", tf)
+		}
+		klinesByInterval[tf] = klines
+	}
+
+	primary := klinesByInterval[timeframes[0]]
+	currentPrice := primary[len(primary)-1].Close
+
+	oiData, err := p.GetOpenInterest(symbol)
+	if err != nil {
+		// OI失败不影响整体,使用默认值
+		oiData = &OIData{Latest: 0, Average: 0}
+	}
+	fundingRate, _ := p.GetFundingRate(symbol)
+
+	data := &Data{
+		Symbol:          symbol,
+		CurrentPrice:    currentPrice,
+		MarkPrice:       getMarkPrice(p, symbol),
+		PriceChange1h:   priceChangeOver(klinesByInterval, timeframes, time.Hour, currentPrice),
+		PriceChange4h:   priceChangeOver(klinesByInterval, timeframes, 4*time.Hour, currentPrice),
+		CurrentEMA20:    calculateEMA(primary, 20),
+		CurrentMACD:     calculateMACD(primary),
+		CurrentRSI7:     calculateRSI(primary, 7),
+		OpenInterest:    oiData,
+		FundingRate:     fundingRate,
+		FundingInterval: p.FundingIntervalHours(symbol),
+		Depth:           getDepthData(p, symbol),
+		Flow:            getFlowFrom(p, symbol),
+	}
+	for _, tf := range timeframes {
+		klines := klinesByInterval[tf]
+		data.Timeframes = append(data.Timeframes, &TimeframeData{
+			Interval: tf,
+			Series:   calculateIntradaySeries(klines),
+			Context:  calculateLongerTermData(klines),
+		})
+	}
+	return data, nil
+}
+
+// priceChangeOver 计算一段时间内的价格变化百分比
+// 使用能整除该时间窗口的最短周期，取窗口长度对应根数之前的收盘价；没有合适周期或K线不足时返回0
+func priceChangeOver(klinesByInterval map[string][]Kline, timeframes []string, window time.Duration, currentPrice float64) float64 {
+	for _, tf := range timeframes {
+		d := intervalDurations[tf]
+		if d <= 0 || d > window || window%d != 0 {
+			continue
+		}
+		bars := int(window / d)
+		klines := klinesByInterval[tf]
+		if len(klines) <= bars {
+			continue
+		}
+		past := klines[len(klines)-1-bars].Close
+		if past > 0 {
+			return (currentPrice - past) / past * 100
+		}
+	}
+	return 0
+}
+
+// Timeframe 获取指定周期的数据（未配置该周期时返回nil）
+func (d *Data) Timeframe(interval string) *TimeframeData {
+	for _, tf := range d.Timeframes {
+		if tf.Interval == interval {
+			return tf
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package market
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseTimeframes 测试K线周期配置解析
+func TestParseTimeframes(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "空配置", raw: "", want: nil},
+		{name: "按周期排序并去重", raw: "1d, 1m,15m,1h,1m", want: []string{"1m", "15m", "1h", "1d"}},
+		{name: "不支持的周期", raw: "1m,7m", wantErr: true},
+		{name: "超过数量上限", raw: "1m,3m,5m,15m,1h,4h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimeframes(tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTimeframes(%q) 应返回错误", tt.raw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTimeframes(%q) error: %v", tt.raw, err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ParseTimeframes(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPriceChangeOver 测试按时间窗口选择周期计算价格变化
+func TestPriceChangeOver(t *testing.T) {
+	closes := func(n int, start, step float64) []Kline {
+		klines := make([]Kline, n)
+		for i := range klines {
+			klines[i] = Kline{Close: start + float64(i)*step}
+		}
+		return klines
+	}
+	klinesByInterval := map[string][]Kline{
+		"15m": closes(10, 100, 1), // 最后一根 109，4根之前 105
+		"1d":  closes(5, 50, 10),
+	}
+	timeframes := []string{"15m", "1d"}
+
+	got := priceChangeOver(klinesByInterval, timeframes, time.Hour, 109)
+	if want := (109.0 - 105.0) / 105.0 * 100; math.Abs(got-want) > 1e-9 {
+		t.Errorf("1h change = %v, want %v", got, want)
+	}
+
+	// 15m K线不足16根，4h 无法计算
+	if got := priceChangeOver(klinesByInterval, timeframes, 4*time.Hour, 109); got != 0 {
+		t.Errorf("4h change = %v, want 0", got)
+	}
+
+	// 日线周期大于窗口，不参与
+	if got := priceChangeOver(map[string][]Kline{"1d": closes(5, 50, 10)}, []string{"1d"}, time.Hour, 90); got != 0 {
+		t.Errorf("1h change from 1d = %v, want 0", got)
+	}
+}
+
+// TestFormatTimeframes 测试自定义周期按周期分段输出
+func TestFormatTimeframes(t *testing.T) {
+	klines := generateTestKlines(60)
+	data := &Data{
+		Symbol:       "BTCUSDT",
+		CurrentPrice: klines[len(klines)-1].Close,
+	}
+	for _, tf := range []string{"1m", "1h"} {
+		data.Timeframes = append(data.Timeframes, &TimeframeData{
+			Interval: tf,
+			Series:   calculateIntradaySeries(klines),
+			Context:  calculateLongerTermData(klines),
+		})
+	}
+
+	output := Format(data)
+	for _, want := range []string{"Timeframe 1m (oldest → latest)", "Timeframe 1h (oldest → latest)", "1h ATR (14‑period)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("输出缺少 %q", want)
+		}
+	}
+	if strings.Contains(output, "3‑minute intervals") || strings.Contains(output, "4‑hour timeframe") {
+		t.Error("未设置3m/4h数据时不应输出默认分段")
+	}
+	if strings.Index(output, "Timeframe 1m") > strings.Index(output, "Timeframe 1h") {
+		t.Error("周期应按配置顺序输出")
+	}
+	if data.Timeframe("1h") == nil || data.Timeframe("4h") != nil {
+		t.Error("Timeframe 查找结果错误")
+	}
+}
+
+// TestKlineCacheAnyInterval 测试任意周期的K线都能缓存和读取
+func TestKlineCacheAnyInterval(t *testing.T) {
+	m := &WSMonitor{combinedClient: NewCombinedStreamsClient(10)}
+
+	var update KlineWSData
+	update.Kline.StartTime = 1000
+	update.Kline.ClosePrice = "42.5"
+	m.processKlineUpdate("BTCUSDT", update, "15m")
+
+	klines, err := m.GetCurrentKlines("BTCUSDT", "15m")
+	if err != nil {
+		t.Fatalf("GetCurrentKlines error: %v", err)
+	}
+	if len(klines) != 1 || klines[0].Close != 42.5 {
+		t.Errorf("klines = %+v, want one kline closing at 42.5", klines)
+	}
+
+	if streams := m.subscribeSymbol("BTCUSDT", "15m"); len(streams) != 1 {
+		t.Errorf("首次订阅应返回1个流，got %v", streams)
+	}
+	if streams := m.subscribeSymbol("BTCUSDT", "15m"); len(streams) != 0 {
+		t.Errorf("重复订阅不应返回流，got %v", streams)
+	}
+}
@@ -5,16 +5,22 @@ import "time"
 // Data 市场数据结构
 type Data struct {
 	Symbol            string
-	CurrentPrice      float64
+	CurrentPrice      float64 // 最新K线收盘价
+	MarkPrice         float64 // 交易所标记价格（获取失败时为0）
 	PriceChange1h     float64 // 1小时价格变化百分比
 	PriceChange4h     float64 // 4小时价格变化百分比
 	CurrentEMA20      float64
 	CurrentMACD       float64
 	CurrentRSI7       float64
 	OpenInterest      *OIData
-	FundingRate       float64
+	FundingRate       float64 // 折算为8小时单期的资金费率
+	FundingInterval   float64 // 资金费实际结算间隔（小时）
 	IntradaySeries    *IntradayData
 	LongerTermContext *LongerTermData
+	Timeframes        []*TimeframeData // 交易员自定义周期的数据（从短到长），为空时使用上面的3m/4h数据
+	Indicators        []IndicatorValue // 提示词模板声明的技术指标
+	Depth             *DepthData       // 订单簿微观结构（获取失败时为nil）
+	Flow              *FlowData        // 主动成交CVD与强平（数据积累前为nil）
 }
 
 // OIData Open Interest数据
@@ -14,8 +14,10 @@ type WSClient struct {
 	conn        *websocket.Conn
 	mu          sync.RWMutex
 	subscribers map[string]chan []byte
+	streams     map[string]bool // 已订阅的流，重连后全部恢复
 	reconnect   bool
 	done        chan struct{}
+	health      *clientHealth
 }
 
 type WSMessage struct {
@@ -71,11 +73,25 @@ type TickerWSData struct {
 func NewWSClient() *WSClient {
 	return &WSClient{
 		subscribers: make(map[string]chan []byte),
+		streams:     make(map[string]bool),
 		reconnect:   true,
 		done:        make(chan struct{}),
+		health:      newClientHealth(),
 	}
 }
 
+// keepAlive 收到服务端ping时回复pong并延长读超时（超时未收到任何消息视为连接中断）
+func keepAlive(conn *websocket.Conn) {
+	conn.SetPingHandler(func(appData string) error {
+		conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+		err := conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(10*time.Second))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+}
+
 func (w *WSClient) Connect() error {
 	dialer := websocket.Dialer{
 		HandshakeTimeout: 10 * time.Second,
@@ -85,10 +101,12 @@ func (w *WSClient) Connect() error {
 	if err != nil {
 		return fmt.Errorf("WebSocket连接失败: %v", err)
 	}
+	keepAlive(conn)
 
 	w.mu.Lock()
 	w.conn = conn
 	w.mu.Unlock()
+	w.health.setConnected(true, time.Now())
 
 	log.Println("WebSocket连接成功")
 
@@ -120,8 +138,9 @@ func (w *WSClient) subscribe(stream string) error {
 		"id":     time.Now().Unix(),
 	}
 
-	w.mu.RLock()
-	defer w.mu.RUnlock()
+	// 写锁：gorilla/websocket 不支持并发写
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
 	if w.conn == nil {
 		return fmt.Errorf("WebSocket未连接")
@@ -131,11 +150,30 @@ func (w *WSClient) subscribe(stream string) error {
 	if err != nil {
 		return err
 	}
+	w.streams[stream] = true
+	w.health.addStreams([]string{stream}, time.Now())
 
 	log.Printf("订阅流: %s", stream)
 	return nil
 }
 
+// resubscribe 重连后恢复所有已订阅的流
+func (w *WSClient) resubscribe() {
+	w.mu.RLock()
+	streams := make([]string, 0, len(w.streams))
+	for stream := range w.streams {
+		streams = append(streams, stream)
+	}
+	w.mu.RUnlock()
+
+	for _, stream := range streams {
+		if err := w.subscribe(stream); err != nil {
+			log.Printf("恢复订阅 %s 失败: %v", stream, err)
+			return
+		}
+	}
+}
+
 func (w *WSClient) readMessages() {
 	for {
 		select {
@@ -151,9 +189,12 @@ func (w *WSClient) readMessages() {
 				continue
 			}
 
+			conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
 			_, message, err := conn.ReadMessage()
 			if err != nil {
 				log.Printf("读取WebSocket消息失败: %v", err)
+				w.health.setConnected(false, time.Now())
+				conn.Close()
 				w.handleReconnect()
 				return
 			}
@@ -165,10 +206,11 @@ func (w *WSClient) readMessages() {
 
 func (w *WSClient) handleMessage(message []byte) {
 	var wsMsg WSMessage
-	if err := json.Unmarshal(message, &wsMsg); err != nil {
+	if err := json.Unmarshal(message, &wsMsg); err != nil || wsMsg.Stream == "" {
 		// 可能是其他格式的消息
 		return
 	}
+	w.health.recordMessage(wsMsg.Stream, time.Now())
 
 	w.mu.RLock()
 	ch, exists := w.subscribers[wsMsg.Stream]
@@ -194,7 +236,9 @@ func (w *WSClient) handleReconnect() {
 	if err := w.Connect(); err != nil {
 		log.Printf("重新连接失败: %v", err)
 		go w.handleReconnect()
+		return
 	}
+	w.resubscribe()
 }
 
 func (w *WSClient) AddSubscriber(stream string, bufferSize int) <-chan []byte {
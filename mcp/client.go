@@ -10,6 +10,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -31,6 +32,51 @@ type Client struct {
 	Timeout    time.Duration
 	UseFullURL bool // 是否使用完整URL（不添加/chat/completions）
 	MaxTokens  int  // AI响应的最大token数
+	// ContextWindow 模型上下文窗口（token），0 表示按模型名称取默认值，见 ContextTokens
+	ContextWindow int
+}
+
+// defaultContextWindow 未知模型的上下文窗口（保守取值）
+const defaultContextWindow = 32000
+
+var (
+	modelContextWindowsMu sync.RWMutex
+	// modelContextWindows 各模型的上下文窗口（按模型名称前缀匹配，最长前缀优先）
+	modelContextWindows = map[string]int{
+		"deepseek-chat":     64000,
+		"deepseek-reasoner": 64000,
+		"qwen3-max":         256000,
+		"qwen-max":          32000,
+		"qwen-plus":         128000,
+		"gpt-4o":            128000,
+		"gpt-4.1":           1000000,
+		"claude":            200000,
+		"gemini":            1000000,
+	}
+)
+
+// RegisterModelContextWindow 注册（或覆盖）模型的上下文窗口，modelPrefix 按前缀匹配模型名称
+func RegisterModelContextWindow(modelPrefix string, tokens int) {
+	modelContextWindowsMu.Lock()
+	defer modelContextWindowsMu.Unlock()
+	modelContextWindows[modelPrefix] = tokens
+}
+
+// ContextTokens 当前模型的上下文窗口（token）
+func (client *Client) ContextTokens() int {
+	if client.ContextWindow > 0 {
+		return client.ContextWindow
+	}
+	modelContextWindowsMu.RLock()
+	defer modelContextWindowsMu.RUnlock()
+
+	best, tokens := "", defaultContextWindow
+	for prefix, window := range modelContextWindows {
+		if strings.HasPrefix(client.Model, prefix) && len(prefix) > len(best) {
+			best, tokens = prefix, window
+		}
+	}
+	return tokens
 }
 
 func New() *Client {
@@ -45,13 +91,25 @@ func New() *Client {
 		}
 	}
 
+	// 从环境变量读取上下文窗口（覆盖按模型的默认值）
+	contextWindow := 0
+	if envContext := os.Getenv("AI_CONTEXT_TOKENS"); envContext != "" {
+		if parsed, err := strconv.Atoi(envContext); err == nil && parsed > 0 {
+			contextWindow = parsed
+			log.Printf("🔧 [MCP] 使用环境变量 AI_CONTEXT_TOKENS: %d", contextWindow)
+		} else {
+			log.Printf("⚠️  [MCP] 环境变量 AI_CONTEXT_TOKENS 无效 (%s)，按模型使用默认值", envContext)
+		}
+	}
+
 	// 默认配置
 	return &Client{
-		Provider:  ProviderDeepSeek,
-		BaseURL:   "https://api.deepseek.com/v1",
-		Model:     "deepseek-chat",
-		Timeout:   120 * time.Second, // 增加到120秒，因为AI需要分析大量数据
-		MaxTokens: maxTokens,
+		Provider:      ProviderDeepSeek,
+		BaseURL:       "https://api.deepseek.com/v1",
+		Model:         "deepseek-chat",
+		Timeout:       120 * time.Second, // 增加到120秒，因为AI需要分析大量数据
+		MaxTokens:     maxTokens,
+		ContextWindow: contextWindow,
 	}
 }
 
@@ -131,8 +189,19 @@ func (client *Client) SetClient(Client Client) {
 	client = &Client
 }
 
+// Message 多轮对话中的一条消息
+type Message struct {
+	Role    string // "user" 或 "assistant"
+	Content string
+}
+
 // CallWithMessages 使用 system + user prompt 调用AI API（推荐）
 func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	return client.CallWithConversation(systemPrompt, []Message{{Role: "user", Content: userPrompt}})
+}
+
+// CallWithConversation 使用 system prompt + 多轮对话调用AI API（如把模型上一次的输出和错误一起发回让其修正）
+func (client *Client) CallWithConversation(systemPrompt string, conversation []Message) (string, error) {
 	if client.APIKey == "" {
 		return "", fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey() 或 SetQwenAPIKey()")
 	}
@@ -146,7 +215,7 @@ func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string,
 			fmt.Printf("⚠️  AI API调用失败，正在重试 (%d/%d)...\n", attempt, maxRetries)
 		}
 
-		result, err := client.callOnce(systemPrompt, userPrompt)
+		result, err := client.callOnce(systemPrompt, conversation)
 		if err == nil {
 			if attempt > 1 {
 				fmt.Printf("✓ AI API重试成功\n")
@@ -172,7 +241,7 @@ func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string,
 }
 
 // callOnce 单次调用AI API（内部使用）
-func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
+func (client *Client) callOnce(systemPrompt string, conversation []Message) (string, error) {
 	// 打印当前 AI 配置
 	log.Printf("📡 [MCP] AI 请求配置:")
 	log.Printf("   Provider: %s", client.Provider)
@@ -194,11 +263,13 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 		})
 	}
 
-	// 添加 user message
-	messages = append(messages, map[string]string{
-		"role":    "user",
-		"content": userPrompt,
-	})
+	// 添加对话消息（user / assistant 交替）
+	for _, msg := range conversation {
+		messages = append(messages, map[string]string{
+			"role":    msg.Role,
+			"content": msg.Content,
+		})
+	}
 
 	// 构建请求体
 	requestBody := map[string]interface{}{
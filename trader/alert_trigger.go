@@ -0,0 +1,90 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/market"
+)
+
+// maxPendingAlerts 两次决策之间最多保留的警报数量
+const maxPendingAlerts = 20
+
+// watchAlerts 订阅市场警报，返回取消订阅的函数
+func (at *AutoTrader) watchAlerts() func() {
+	return market.AddAlertListener(at.handleAlert)
+}
+
+// handleAlert 关注币种的警报都记录到下次决策的提示词中
+// 只有持仓币种的警报才触发额外决策，候选币种的警报等下个定时周期
+func (at *AutoTrader) handleAlert(alert market.Alert) {
+	if !at.isWatchedSymbol(alert.Symbol) {
+		return
+	}
+	at.addPendingAlert(alert)
+	if at.isHeldSymbol(alert.Symbol) {
+		at.fireTrigger(cycleTrigger{Type: TriggerMarketAlert, Detail: alert.Message})
+	}
+}
+
+// isWatchedSymbol 是否为当前持仓或上个周期的候选币种
+func (at *AutoTrader) isWatchedSymbol(symbol string) bool {
+	at.eventMutex.Lock()
+	defer at.eventMutex.Unlock()
+	return at.watchedSymbols[symbol]
+}
+
+// isHeldSymbol 是否为上个周期的持仓币种
+func (at *AutoTrader) isHeldSymbol(symbol string) bool {
+	at.eventMutex.Lock()
+	defer at.eventMutex.Unlock()
+	return at.heldSymbols[symbol]
+}
+
+// IsWatchingSymbol 运行中的交易员是否持有或关注该币种（用于过滤警报通知）
+func (at *AutoTrader) IsWatchingSymbol(symbol string) bool {
+	return at.isRunning && at.isWatchedSymbol(symbol)
+}
+
+// setWatchedSymbols 记录本周期关注的币种（持仓 + 获取了市场数据的候选币种）
+// 同时登记到行情模块，不再关注的币种的按需深度和成交流会被退订
+func (at *AutoTrader) setWatchedSymbols(ctx *decision.Context) {
+	watched := make(map[string]bool, len(ctx.MarketDataMap)+len(ctx.Positions))
+	held := make(map[string]bool, len(ctx.Positions))
+	for symbol := range ctx.MarketDataMap {
+		watched[symbol] = true
+	}
+	for _, pos := range ctx.Positions {
+		watched[pos.Symbol] = true
+		held[pos.Symbol] = true
+	}
+	symbols := make([]string, 0, len(watched))
+	for symbol := range watched {
+		symbols = append(symbols, symbol)
+	}
+
+	at.eventMutex.Lock()
+	at.watchedSymbols = watched
+	at.heldSymbols = held
+	at.eventMutex.Unlock()
+	market.RetainSymbols(at.id, symbols)
+}
+
+// addPendingAlert 记录待写入下次决策提示词的警报
+func (at *AutoTrader) addPendingAlert(alert market.Alert) {
+	at.eventMutex.Lock()
+	defer at.eventMutex.Unlock()
+
+	at.pendingAlerts = append(at.pendingAlerts, alert)
+	if over := len(at.pendingAlerts) - maxPendingAlerts; over > 0 {
+		at.pendingAlerts = at.pendingAlerts[over:]
+	}
+}
+
+// takePendingAlerts 取出上次决策以来的警报
+func (at *AutoTrader) takePendingAlerts() []market.Alert {
+	at.eventMutex.Lock()
+	defer at.eventMutex.Unlock()
+
+	alerts := at.pendingAlerts
+	at.pendingAlerts = nil
+	return alerts
+}
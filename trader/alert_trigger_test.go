@@ -0,0 +1,44 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/market"
+)
+
+// TestAlertTriggers 测试警报按关注币种过滤、只有持仓币种触发额外决策以及待处理警报上限
+func (s *AutoTraderTestSuite) TestAlertTriggers() {
+	s.autoTrader.setWatchedSymbols(&decision.Context{
+		Positions:     []decision.PositionInfo{{Symbol: "BTCUSDT"}},
+		MarketDataMap: map[string]*market.Data{"ETHUSDT": {}},
+	})
+	s.True(s.autoTrader.isWatchedSymbol("BTCUSDT"))
+	s.True(s.autoTrader.isWatchedSymbol("ETHUSDT"))
+	s.False(s.autoTrader.isWatchedSymbol("SOLUSDT"))
+
+	// 只有运行中的交易员关注的币种才推送通知
+	s.False(s.autoTrader.IsWatchingSymbol("BTCUSDT"))
+	s.autoTrader.isRunning = true
+	s.True(s.autoTrader.IsWatchingSymbol("BTCUSDT"))
+	s.False(s.autoTrader.IsWatchingSymbol("SOLUSDT"))
+	s.autoTrader.isRunning = false
+
+	// 持仓币种的警报触发额外决策，候选币种的警报只记录到下次决策
+	s.autoTrader.triggerCh = make(chan cycleTrigger, 4)
+	s.autoTrader.handleAlert(market.Alert{Symbol: "ETHUSDT", Message: "ETH 放量"})
+	s.autoTrader.handleAlert(market.Alert{Symbol: "SOLUSDT", Message: "SOL 放量"})
+	s.Empty(s.autoTrader.triggerCh)
+	s.autoTrader.handleAlert(market.Alert{Symbol: "BTCUSDT", Message: "BTC 放量"})
+	s.Require().Len(s.autoTrader.triggerCh, 1)
+	s.Equal(cycleTrigger{Type: TriggerMarketAlert, Detail: "BTC 放量"}, <-s.autoTrader.triggerCh)
+	s.Len(s.autoTrader.takePendingAlerts(), 2)
+	s.autoTrader.triggerCh = nil
+
+	s.autoTrader.addPendingAlert(market.Alert{Symbol: "BTCUSDT", Type: market.AlertVolumeSpike})
+	s.Len(s.autoTrader.takePendingAlerts(), 1)
+	s.Empty(s.autoTrader.takePendingAlerts())
+
+	for i := 0; i < maxPendingAlerts+5; i++ {
+		s.autoTrader.addPendingAlert(market.Alert{Symbol: "BTCUSDT"})
+	}
+	s.Len(s.autoTrader.takePendingAlerts(), maxPendingAlerts)
+}
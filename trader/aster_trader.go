@@ -919,6 +919,23 @@ func (t *AsterTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
 	return nil
 }
 
+// AddIsolatedMargin 为逐仓持仓追加保证金
+func (t *AsterTrader) AddIsolatedMargin(symbol string, positionSide string, amount float64) error {
+	params := map[string]interface{}{
+		"symbol":       symbol,
+		"positionSide": "BOTH",
+		"amount":       fmt.Sprintf("%.2f", amount),
+		"type":         1, // 1=追加保证金, 2=减少保证金
+	}
+
+	if _, err := t.request("POST", "/fapi/v3/positionMargin", params); err != nil {
+		return fmt.Errorf("追加保证金失败: %w", err)
+	}
+
+	log.Printf("  ✓ %s %s 已追加保证金 %.2f USDT", symbol, positionSide, amount)
+	return nil
+}
+
 // SetLeverage 设置杠杆倍数
 func (t *AsterTrader) SetLeverage(symbol string, leverage int) error {
 	params := map[string]interface{}{
@@ -1098,6 +1115,90 @@ func (t *AsterTrader) CancelStopLossOrders(symbol string) error {
 	return nil
 }
 
+// GetFundingPayments 获取资金费流水（收益历史中 incomeType=FUNDING_FEE 的记录）
+func (t *AsterTrader) GetFundingPayments(startTime int64) ([]map[string]interface{}, error) {
+	params := map[string]interface{}{
+		"incomeType": "FUNDING_FEE",
+		"startTime":  startTime,
+		"limit":      1000,
+	}
+
+	body, err := t.request("GET", "/fapi/v3/income", params)
+	if err != nil {
+		return nil, fmt.Errorf("获取资金费流水失败: %w", err)
+	}
+
+	var incomes []struct {
+		Symbol string `json:"symbol"`
+		Income string `json:"income"`
+		Time   int64  `json:"time"`
+	}
+	if err := json.Unmarshal(body, &incomes); err != nil {
+		return nil, fmt.Errorf("解析资金费流水失败: %w", err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(incomes))
+	for _, income := range incomes {
+		amount, _ := strconv.ParseFloat(income.Income, 64)
+		result = append(result, map[string]interface{}{
+			"symbol": income.Symbol,
+			"amount": amount,
+			"time":   income.Time,
+		})
+	}
+
+	return result, nil
+}
+
+// GetStopLossOrders 获取该币种当前挂着的止损单
+func (t *AsterTrader) GetStopLossOrders(symbol string) ([]map[string]interface{}, error) {
+	params := map[string]interface{}{
+		"symbol": symbol,
+	}
+
+	body, err := t.request("GET", "/fapi/v3/openOrders", params)
+	if err != nil {
+		return nil, fmt.Errorf("获取未完成订单失败: %w", err)
+	}
+
+	var orders []map[string]interface{}
+	if err := json.Unmarshal(body, &orders); err != nil {
+		return nil, fmt.Errorf("解析订单数据失败: %w", err)
+	}
+
+	var result []map[string]interface{}
+	for _, order := range orders {
+		orderType, _ := order["type"].(string)
+		if orderType != "STOP_MARKET" && orderType != "STOP" {
+			continue
+		}
+
+		// Aster 使用单向持仓（BOTH），根据下单方向推断保护的持仓方向
+		positionSide, _ := order["positionSide"].(string)
+		if positionSide != "LONG" && positionSide != "SHORT" {
+			if side, _ := order["side"].(string); side == "SELL" {
+				positionSide = "LONG"
+			} else {
+				positionSide = "SHORT"
+			}
+		}
+
+		orderID, _ := order["orderId"].(float64)
+		stopPriceStr, _ := order["stopPrice"].(string)
+		quantityStr, _ := order["origQty"].(string)
+		stopPrice, _ := strconv.ParseFloat(stopPriceStr, 64)
+		quantity, _ := strconv.ParseFloat(quantityStr, 64)
+		result = append(result, map[string]interface{}{
+			"orderId":      int64(orderID),
+			"positionSide": positionSide,
+			"stopPrice":    stopPrice,
+			"quantity":     quantity,
+		})
+	}
+
+	return result, nil
+}
+
 // CancelTakeProfitOrders 仅取消止盈单（不影响止损单）
 func (t *AsterTrader) CancelTakeProfitOrders(symbol string) error {
 	// 获取该币种的所有未完成订单
@@ -170,6 +170,18 @@ func NewAsterTraderTestSuite(t *testing.T) *AsterTraderTestSuite {
 				"symbol":   "BTCUSDT",
 			}
 
+		// Mock GetIncomeHistory - /fapi/v3/income
+		case path == "/fapi/v3/income":
+			respBody = []map[string]interface{}{
+				{
+					"symbol":     "BTCUSDT",
+					"incomeType": "FUNDING_FEE",
+					"income":     "-0.25",
+					"asset":      "USDT",
+					"time":       1700000100000,
+				},
+			}
+
 		// Mock SetMarginMode - /fapi/v1/marginType
 		case path == "/fapi/v1/marginType":
 			respBody = map[string]interface{}{
@@ -75,6 +75,53 @@ type AutoTraderConfig struct {
 
 	// 系统提示词模板
 	SystemPromptTemplate string // 系统提示词模板名称（如 "default", "aggressive"）
+
+	// 利润保护策略（为空时使用默认的回撤平仓策略）
+	ExitPolicies []ExitPolicy
+
+	// 强平距离保护（nil 使用默认配置）
+	LiquidationGuard *LiquidationGuardConfig
+
+	// 开仓资金费成本检查（nil 使用默认配置）
+	FundingGuard *FundingGuardConfig
+
+	// 开仓金额占对手盘±1%深度的上限百分比（0 表示不检查）
+	MaxDepthPct float64
+
+	// 市场数据K线周期（从短到长，为空使用默认的3m/4h）
+	Timeframes []string
+
+	// 关注币种（持仓和候选币种）出现市场警报时立即触发额外决策
+	AlertTriggers bool
+
+	// 持仓事件触发额外决策（nil 使用默认配置，默认不启用）
+	EventTriggers *EventTriggerConfig
+
+	// 系统提示词模板自定义变量（模板中以 {{.Vars.name}} 引用）
+	PromptVariables map[string]string
+
+	// User Prompt 布局（name 或 name@vN，为空使用默认布局）
+	UserPromptLayout string
+
+	// 多阶段决策流水线（nil 或未启用时单次调用AI）
+	Pipeline *decision.PipelineConfig
+	// 初筛/复核阶段使用的AI模型（nil 沿用主模型）
+	PipelineScreener *PipelineModel
+	PipelineReviewer *PipelineModel
+
+	// 交易反思（nil 使用默认配置，默认不启用）
+	Reflection *ReflectionConfig
+
+	// 决策校验规则（nil 使用默认规则）
+	ValidationProfile *decision.ValidationProfile
+}
+
+// PipelineModel 决策流水线某个阶段使用的AI模型
+type PipelineModel struct {
+	Provider        string // "deepseek", "qwen" 或 "custom"
+	APIKey          string
+	CustomAPIURL    string
+	CustomModelName string
 }
 
 // AutoTrader 自动交易器
@@ -86,6 +133,7 @@ type AutoTrader struct {
 	config                AutoTraderConfig
 	trader                Trader // 使用Trader接口（支持多平台）
 	mcpClient             *mcp.Client
+	pipeline              *decision.Pipeline     // 多阶段决策流水线（nil 表示未启用）
 	decisionLogger        *logger.DecisionLogger // 决策日志记录器
 	initialBalance        float64
 	dailyPnL              float64
@@ -97,16 +145,40 @@ type AutoTrader struct {
 	lastResetTime         time.Time
 	stopUntil             time.Time
 	isRunning             bool
-	startTime             time.Time          // 系统启动时间
-	callCount             int                // AI调用次数
-	positionFirstSeenTime map[string]int64   // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
-	stopMonitorCh         chan struct{}      // 用于停止监控goroutine
-	monitorWg             sync.WaitGroup     // 用于等待监控goroutine结束
-	peakPnLCache          map[string]float64 // 最高收益缓存 (symbol -> 峰值盈亏百分比)
-	peakPnLCacheMutex     sync.RWMutex       // 缓存读写锁
-	lastBalanceSyncTime   time.Time          // 上次余额同步时间
-	database              interface{}        // 数据库引用（用于自动更新余额）
-	userID                string             // 用户ID
+	startTime             time.Time                     // 系统启动时间
+	callCount             int                           // AI调用次数
+	positionFirstSeenTime map[string]int64              // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
+	stopMonitorCh         chan struct{}                 // 用于停止监控goroutine
+	monitorWg             sync.WaitGroup                // 用于等待监控goroutine结束
+	peakPnLCache          map[string]float64            // 最高收益缓存 (symbol -> 峰值盈亏百分比)
+	peakPnLCacheMutex     sync.RWMutex                  // 缓存读写锁
+	protectionLevels      map[string]*protectionLevel   // 最近已知的止损止盈价格 (symbol_side -> 价格)
+	stopLossFailures      map[string]int                // 止损重建连续失败次数 (symbol_side -> 次数)
+	unknownStopAlerted    map[string]bool               // 已告警的无止损记录持仓（手动开仓等），每个持仓只告警一次
+	protectionMutex       sync.RWMutex                  // 止损守护状态读写锁
+	exitStates            map[string]*exitPositionState // 利润保护策略跟踪的持仓状态 (symbol_side -> 状态)
+	exitMutex             sync.Mutex                    // 利润保护状态锁
+	positionFunding       map[string]float64            // 持仓期间已结算的资金费 (symbol_side -> USDT)
+	lastFundingSync       int64                         // 已同步资金费流水的最新时间戳（毫秒）
+	fundingMutex          sync.Mutex                    // 资金费状态锁
+	lastBalanceSyncTime   time.Time                     // 上次余额同步时间
+	marketProvider        market.MarketDataProvider     // 行情数据源（与执行交易所一致，nil 使用币安）
+	triggerCh             chan cycleTrigger             // 事件触发通知（Run 中创建）
+	pendingTriggers       []cycleTrigger                // 下次决策前累积的触发事件
+	pendingAlerts         []market.Alert                // 上次决策以来的市场警报
+	watchedSymbols        map[string]bool               // 警报关注的币种（持仓 + 上个周期的候选币种）
+	heldSymbols           map[string]bool               // 上个周期的持仓币种（警报只对这些币种触发额外决策）
+	eventBaselines        map[string]*eventBaseline     // 上次决策时的持仓参考状态 (symbol_side -> 状态)
+	eventPositions        map[string]bool               // 事件监控上次检查到的持仓 (symbol_side)
+	lastCycleTime         time.Time                     // 上次决策时间
+	eventMutex            sync.Mutex                    // 事件触发状态锁
+	lastReflection        time.Time                     // 上次交易反思时间
+	reflectedUntil        time.Time                     // 已反思的最后平仓时间
+	reflecting            bool                          // 反思是否正在进行
+	reflectionMutex       sync.Mutex                    // 交易反思状态锁
+	lastRejections        []string                      // 上个周期未通过校验的决策及原因（下个周期反馈给AI）
+	database              interface{}                   // 数据库引用（用于自动更新余额）
+	userID                string                        // 用户ID
 }
 
 // NewAutoTrader 创建自动交易器
@@ -151,6 +223,19 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		}
 	}
 
+	// 初始化多阶段决策流水线
+	var pipeline *decision.Pipeline
+	if config.Pipeline != nil && config.Pipeline.Enabled {
+		pipeline = &decision.Pipeline{
+			Screener:      newPipelineClient(config.PipelineScreener, mcpClient),
+			Analyst:       mcpClient,
+			Reviewer:      newPipelineClient(config.PipelineReviewer, mcpClient),
+			ShortlistSize: config.Pipeline.ShortlistSize,
+		}
+		log.Printf("🧭 [%s] 启用多阶段决策: 初筛(%s) → 分析(%s) → 风控复核(%s)",
+			config.Name, pipeline.Screener.Model, pipeline.Analyst.Model, pipeline.Reviewer.Model)
+	}
+
 	// 初始化币种池API
 	if config.CoinPoolAPIURL != "" {
 		pool.SetCoinPoolAPI(config.CoinPoolAPIURL)
@@ -216,6 +301,7 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		config:                config,
 		trader:                trader,
 		mcpClient:             mcpClient,
+		pipeline:              pipeline,
 		decisionLogger:        decisionLogger,
 		initialBalance:        config.InitialBalance,
 		systemPromptTemplate:  systemPromptTemplate,
@@ -230,12 +316,41 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		monitorWg:             sync.WaitGroup{},
 		peakPnLCache:          make(map[string]float64),
 		peakPnLCacheMutex:     sync.RWMutex{},
+		protectionLevels:      make(map[string]*protectionLevel),
+		stopLossFailures:      make(map[string]int),
+		exitStates:            make(map[string]*exitPositionState),
 		lastBalanceSyncTime:   time.Now(), // 初始化为当前时间
+		marketProvider:        market.ProviderFor(config.Exchange, config.HyperliquidTestnet),
 		database:              database,
 		userID:                userID,
 	}, nil
 }
 
+// requestDecision 请求AI决策（启用流水线时依次经过初筛、分析和风控复核）
+func (at *AutoTrader) requestDecision(ctx *decision.Context) (*decision.FullDecision, error) {
+	if at.pipeline != nil {
+		return decision.GetPipelineDecision(ctx, at.pipeline, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+	}
+	return decision.GetFullDecisionWithCustomPrompt(ctx, at.mcpClient, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+}
+
+// newPipelineClient 创建流水线阶段使用的AI客户端（未指定模型时沿用主模型）
+func newPipelineClient(model *PipelineModel, fallback *mcp.Client) *mcp.Client {
+	if model == nil {
+		return fallback
+	}
+	client := mcp.New()
+	switch model.Provider {
+	case "custom":
+		client.SetCustomAPI(model.CustomAPIURL, model.APIKey, model.CustomModelName)
+	case "qwen":
+		client.SetQwenAPIKey(model.APIKey, model.CustomAPIURL, model.CustomModelName)
+	default:
+		client.SetDeepSeekAPIKey(model.APIKey, model.CustomAPIURL, model.CustomModelName)
+	}
+	return client
+}
+
 // Run 运行自动交易主循环
 func (at *AutoTrader) Run() error {
 	at.isRunning = true
@@ -245,6 +360,11 @@ func (at *AutoTrader) Run() error {
 	log.Println("🚀 AI驱动自动交易系统启动")
 	log.Printf("💰 初始余额: %.2f USDT", at.initialBalance)
 	log.Printf("⚙️  扫描间隔: %v", at.config.ScanInterval)
+	if len(at.config.Timeframes) > 0 {
+		// 登记自定义周期，行情监控会为所有交易对回填并订阅
+		log.Printf("📊 K线周期: %s", strings.Join(at.config.Timeframes, "/"))
+		market.RegisterTimeframes(at.config.Timeframes)
+	}
 	log.Println("🤖 AI将全权决定杠杆、仓位大小、止损止盈等参数")
 	at.monitorWg.Add(1)
 	defer at.monitorWg.Done()
@@ -252,9 +372,29 @@ func (at *AutoTrader) Run() error {
 	// 启动回撤监控
 	at.startDrawdownMonitor()
 
+	// 启动止损守护
+	at.startStopLossGuardian()
+
 	ticker := time.NewTicker(at.config.ScanInterval)
 	defer ticker.Stop()
 
+	// 事件触发：市场警报和持仓事件在去抖与最短间隔限制下立即触发额外决策
+	at.triggerCh = make(chan cycleTrigger, 32)
+	if at.config.AlertTriggers {
+		unsubscribe := at.watchAlerts()
+		defer unsubscribe()
+	}
+	if at.eventTriggerConfig().Enabled {
+		at.startEventMonitor()
+	}
+	var eventTimer *time.Timer
+	var eventTimerC <-chan time.Time
+	defer func() {
+		if eventTimer != nil {
+			eventTimer.Stop()
+		}
+	}()
+
 	// 首次立即执行
 	if err := at.runCycle(); err != nil {
 		log.Printf("❌ 执行失败: %v", err)
@@ -266,6 +406,24 @@ func (at *AutoTrader) Run() error {
 			if err := at.runCycle(); err != nil {
 				log.Printf("❌ 执行失败: %v", err)
 			}
+		case trigger := <-at.triggerCh:
+			delay := at.queueTrigger(trigger)
+			if eventTimerC != nil {
+				log.Printf("⚡ %s（并入即将执行的额外决策）", trigger)
+				continue
+			}
+			log.Printf("⚡ %s，%v 后执行额外决策", trigger, delay.Round(time.Second))
+			eventTimer = time.NewTimer(delay)
+			eventTimerC = eventTimer.C
+		case <-eventTimerC:
+			eventTimerC = nil
+			// 事件已并入期间执行的定时周期
+			if !at.hasPendingTriggers() {
+				continue
+			}
+			if err := at.runCycle(); err != nil {
+				log.Printf("❌ 执行失败: %v", err)
+			}
 		case <-at.stopMonitorCh:
 			log.Printf("[%s] ⏹ 收到停止信号，退出自动交易主循环", at.name)
 			return nil
@@ -283,6 +441,7 @@ func (at *AutoTrader) Stop() {
 	at.isRunning = false
 	close(at.stopMonitorCh) // 通知监控goroutine停止
 	at.monitorWg.Wait()     // 等待监控goroutine结束
+	market.RetainSymbols(at.id, nil)
 	log.Println("⏹ 自动交易系统停止")
 }
 
@@ -387,14 +546,22 @@ func (at *AutoTrader) runCycle() error {
 
 	log.Print("\n" + strings.Repeat("=", 70) + "\n")
 	log.Printf("⏰ %s - AI决策周期 #%d", time.Now().Format("2006-01-02 15:04:05"), at.callCount)
+	triggers := at.takeCycleTriggers()
+	if len(triggers) > 0 {
+		log.Printf("⚡ 事件触发: %s", formatTriggers(triggers))
+	}
 	log.Println(strings.Repeat("=", 70))
 
 	// 创建决策记录
 	record := &logger.DecisionRecord{
+		Trigger:      formatTriggers(triggers),
 		ExecutionLog: []string{},
 		Success:      true,
 	}
 
+	// 同步上个周期以来结算的资金费（用于持仓展示和交易表现归属）
+	record.FundingPayments = at.syncFundingPayments()
+
 	// 1. 检查是否需要停止交易
 	if time.Now().Before(at.stopUntil) {
 		remaining := at.stopUntil.Sub(time.Now())
@@ -423,6 +590,9 @@ func (at *AutoTrader) runCycle() error {
 		at.decisionLogger.LogDecision(record)
 		return fmt.Errorf("构建交易上下文失败: %w", err)
 	}
+	if len(triggers) > 0 {
+		ctx.Trigger = record.Trigger
+	}
 
 	// 保存账户状态快照
 	record.AccountState = logger.AccountSnapshot{
@@ -457,7 +627,22 @@ func (at *AutoTrader) runCycle() error {
 
 	// 5. 调用AI获取完整决策
 	log.Printf("🤖 正在请求AI分析并决策... [模板: %s]", at.systemPromptTemplate)
-	decision, err := decision.GetFullDecisionWithCustomPrompt(ctx, at.mcpClient, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+	decision, err := at.requestDecision(ctx)
+	at.lastRejections = nil
+	if decision != nil {
+		at.lastRejections = decision.Rejections
+	}
+	at.setWatchedSymbols(ctx)
+	at.resetEventBaselines(ctx)
+	record.MarketData = ctx.MarketDataMap // 保存AI看到的结构化市场数据（写入压缩快照，用于审计和回放）
+	if decision != nil && decision.MarketData != nil {
+		// 提示词超出预算被裁剪时，快照和候选币种记录保存裁剪后AI实际看到的数据
+		record.MarketData = decision.MarketData
+		record.CandidateCoins = record.CandidateCoins[:0]
+		for _, coin := range decision.CandidateCoins {
+			record.CandidateCoins = append(record.CandidateCoins, coin.Symbol)
+		}
+	}
 
 	if decision != nil && decision.AIRequestDurationMs > 0 {
 		record.AIRequestDurationMs = decision.AIRequestDurationMs
@@ -470,6 +655,24 @@ func (at *AutoTrader) runCycle() error {
 	if decision != nil {
 		record.SystemPrompt = decision.SystemPrompt // 保存系统提示词
 		record.InputPrompt = decision.UserPrompt
+		record.UserPromptLayout = decision.UserPromptLayout
+		record.PromptTemplate = decision.PromptTemplate
+		record.PromptTemplateHash = decision.PromptTemplateHash
+		record.CustomPromptHash = decision.CustomPromptHash
+		record.PromptTokens = decision.PromptTokens
+		record.PromptTokenBudget = decision.PromptTokenBudget
+		record.PromptCuts = decision.PromptCuts
+		for _, stage := range decision.Stages {
+			record.PipelineStages = append(record.PipelineStages, logger.PipelineStage(stage))
+		}
+		for _, repair := range decision.Repairs {
+			record.RepairAttempts = append(record.RepairAttempts, logger.RepairAttempt(repair))
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🔧 自我修正 #%d: %s", repair.Attempt, repair.Outcome))
+		}
+		record.Rejections = decision.Rejections
+		for _, reason := range decision.Rejections {
+			record.ExecutionLog = append(record.ExecutionLog, "⚠️ 未通过校验，已跳过: "+reason)
+		}
 		record.CoTTrace = decision.CoTTrace
 		if len(decision.Decisions) > 0 {
 			decisionJSON, _ := json.MarshalIndent(decision.Decisions, "", "  ")
@@ -558,6 +761,9 @@ func (at *AutoTrader) runCycle() error {
 		} else {
 			actionRecord.Success = true
 			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", d.Symbol, d.Action))
+			if actionRecord.Warning != "" {
+				record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⚠️ %s %s: %s", d.Symbol, d.Action, actionRecord.Warning))
+			}
 			// 成功执行后短暂延迟
 			time.Sleep(1 * time.Second)
 		}
@@ -630,11 +836,11 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		liquidationPrice := pos["liquidationPrice"].(float64)
 
 		// 计算占用保证金（估算）
-		leverage := 10 // 默认值，实际应该从持仓信息获取
-		if lev, ok := pos["leverage"].(float64); ok {
-			leverage = int(lev)
+		leverage := at.positionLeverage(pos)
+		marginUsed := 0.0
+		if leverage > 0 {
+			marginUsed = (quantity * markPrice) / float64(leverage)
 		}
-		marginUsed := (quantity * markPrice) / float64(leverage)
 		totalMarginUsed += marginUsed
 
 		// 计算盈亏百分比（基于保证金，考虑杠杆）
@@ -666,6 +872,7 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 			PeakPnLPct:       peakPnlPct,
 			LiquidationPrice: liquidationPrice,
 			MarginUsed:       marginUsed,
+			FundingFee:       at.positionFundingFee(symbol, side),
 			UpdateTime:       updateTime,
 		})
 	}
@@ -676,6 +883,7 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 			delete(at.positionFirstSeenTime, key)
 		}
 	}
+	at.pruneFundingFees(currentPositionKeys)
 
 	// 3. 获取交易员的候选币种池
 	candidateCoins, err := at.getCandidateCoins()
@@ -704,13 +912,25 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		performance = nil
 	}
 
-	// 6. 构建上下文
+	// 6. 读取交易教训，必要时在后台反思新平仓的交易
+	lessons := at.loadLessons()
+	at.maybeReflect(performance, lessons)
+
+	// 7. 构建上下文
 	ctx := &decision.Context{
-		CurrentTime:     time.Now().Format("2006-01-02 15:04:05"),
-		RuntimeMinutes:  int(time.Since(at.startTime).Minutes()),
-		CallCount:       at.callCount,
-		BTCETHLeverage:  at.config.BTCETHLeverage,  // 使用配置的杠杆倍数
-		AltcoinLeverage: at.config.AltcoinLeverage, // 使用配置的杠杆倍数
+		CurrentTime:      time.Now().Format("2006-01-02 15:04:05"),
+		RuntimeMinutes:   int(time.Since(at.startTime).Minutes()),
+		CallCount:        at.callCount,
+		BTCETHLeverage:   at.config.BTCETHLeverage,  // 使用配置的杠杆倍数
+		AltcoinLeverage:  at.config.AltcoinLeverage, // 使用配置的杠杆倍数
+		Timeframes:       at.config.Timeframes,
+		MarketData:       at.marketProvider,
+		Alerts:           at.takePendingAlerts(),
+		TraderName:       at.name,
+		PromptVars:       at.config.PromptVariables,
+		UserPromptLayout: at.config.UserPromptLayout,
+		Validation:       at.config.ValidationProfile,
+		Rejections:       at.lastRejections,
 		Account: decision.AccountInfo{
 			TotalEquity:      totalEquity,
 			AvailableBalance: availableBalance,
@@ -724,6 +944,8 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		CandidateCoins: candidateCoins,
 		Performance:    performance, // 添加历史表现分析
 	}
+	ctx.Lessons = at.relevantLessons(lessons, ctx)
+	ctx.News = at.loadNews(ctx)
 
 	return ctx, nil
 }
@@ -745,6 +967,12 @@ func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, act
 		return at.executeUpdateTakeProfitWithRecord(decision, actionRecord)
 	case "partial_close":
 		return at.executePartialCloseWithRecord(decision, actionRecord)
+	case "add_to_position":
+		return at.executeAddToPositionWithRecord(decision, actionRecord)
+	case "reverse":
+		return at.executeReverseWithRecord(decision, actionRecord)
+	case "update_sl_tp":
+		return at.executeUpdateSLTPWithRecord(decision, actionRecord)
 	case "hold", "wait":
 		// 无需执行，仅记录
 		return nil
@@ -762,75 +990,40 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	if err == nil {
 		for _, pos := range positions {
 			if pos["symbol"] == decision.Symbol && pos["side"] == "long" {
-				return fmt.Errorf("❌ %s 已有多仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_long 决策", decision.Symbol)
+				return fmt.Errorf("❌ %s 已有多仓，拒绝开仓以防止仓位叠加超限。如需加仓请使用 add_to_position，如需换仓请先给出 close_long 决策", decision.Symbol)
 			}
 		}
 	}
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.FetchData(at.marketProvider, decision.Symbol, nil)
 	if err != nil {
 		return err
 	}
 
-	// 计算数量
-	quantity := decision.PositionSizeUSD / marketData.CurrentPrice
-	actionRecord.Quantity = quantity
-	actionRecord.Price = marketData.CurrentPrice
-
-	// ⚠️ 保证金验证：防止保证金不足错误（code=-2019）
-	requiredMargin := decision.PositionSizeUSD / float64(decision.Leverage)
-
-	balance, err := at.trader.GetBalance()
-	if err != nil {
-		return fmt.Errorf("获取账户余额失败: %w", err)
-	}
-	availableBalance := 0.0
-	if avail, ok := balance["availableBalance"].(float64); ok {
-		availableBalance = avail
-	}
-
-	// 手续费估算（Taker费率 0.04%）
-	estimatedFee := decision.PositionSizeUSD * 0.0004
-	totalRequired := requiredMargin + estimatedFee
-
-	if totalRequired > availableBalance {
-		return fmt.Errorf("❌ 保证金不足: 需要 %.2f USDT（保证金 %.2f + 手续费 %.2f），可用 %.2f USDT",
-			totalRequired, requiredMargin, estimatedFee, availableBalance)
-	}
-
-	// 设置仓位模式
-	if err := at.trader.SetMarginMode(decision.Symbol, at.config.IsCrossMargin); err != nil {
-		log.Printf("  ⚠️ 设置仓位模式失败: %v", err)
-		// 继续执行，不影响交易
-	}
-
-	// 开仓
-	order, err := at.trader.OpenLong(decision.Symbol, quantity, decision.Leverage)
+	// 资金费率检查：预计持仓期间的资金费成本过高时警告或拒绝
+	warning, err := at.checkFundingCost(decision, "long", marketData.FundingRate, marketData.FundingInterval)
 	if err != nil {
 		return err
 	}
+	actionRecord.Warning = warning
 
-	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
+	// 深度检查：订单金额占对手盘可见深度过大时拒绝（滑点风险）
+	if err := at.checkOrderBookDepth(decision.Symbol, "long", decision.PositionSizeUSD, marketData.Depth); err != nil {
+		return err
 	}
 
-	log.Printf("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
-
-	// 记录开仓时间
-	posKey := decision.Symbol + "_long"
-	at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
+	// 计算数量
+	quantity := decision.PositionSizeUSD / marketData.CurrentPrice
+	actionRecord.Quantity = quantity
+	actionRecord.Price = marketData.CurrentPrice
 
-	// 设置止损止盈
-	if err := at.trader.SetStopLoss(decision.Symbol, "LONG", quantity, decision.StopLoss); err != nil {
-		log.Printf("  ⚠ 设置止损失败: %v", err)
-	}
-	if err := at.trader.SetTakeProfit(decision.Symbol, "LONG", quantity, decision.TakeProfit); err != nil {
-		log.Printf("  ⚠ 设置止盈失败: %v", err)
+	// ⚠️ 保证金验证：防止保证金不足错误（code=-2019）
+	if err := at.checkMargin(decision.PositionSizeUSD, decision.Leverage, 0); err != nil {
+		return err
 	}
 
-	return nil
+	return at.placeOpenOrder(decision, "long", quantity, actionRecord)
 }
 
 // executeOpenShortWithRecord 执行开空仓并记录详细信息
@@ -842,16 +1035,28 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	if err == nil {
 		for _, pos := range positions {
 			if pos["symbol"] == decision.Symbol && pos["side"] == "short" {
-				return fmt.Errorf("❌ %s 已有空仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_short 决策", decision.Symbol)
+				return fmt.Errorf("❌ %s 已有空仓，拒绝开仓以防止仓位叠加超限。如需加仓请使用 add_to_position，如需换仓请先给出 close_short 决策", decision.Symbol)
 			}
 		}
 	}
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.FetchData(at.marketProvider, decision.Symbol, nil)
+	if err != nil {
+		return err
+	}
+
+	// 资金费率检查：预计持仓期间的资金费成本过高时警告或拒绝
+	warning, err := at.checkFundingCost(decision, "short", marketData.FundingRate, marketData.FundingInterval)
 	if err != nil {
 		return err
 	}
+	actionRecord.Warning = warning
+
+	// 深度检查：订单金额占对手盘可见深度过大时拒绝（滑点风险）
+	if err := at.checkOrderBookDepth(decision.Symbol, "short", decision.PositionSizeUSD, marketData.Depth); err != nil {
+		return err
+	}
 
 	// 计算数量
 	quantity := decision.PositionSizeUSD / marketData.CurrentPrice
@@ -859,26 +1064,15 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	actionRecord.Price = marketData.CurrentPrice
 
 	// ⚠️ 保证金验证：防止保证金不足错误（code=-2019）
-	requiredMargin := decision.PositionSizeUSD / float64(decision.Leverage)
-
-	balance, err := at.trader.GetBalance()
-	if err != nil {
-		return fmt.Errorf("获取账户余额失败: %w", err)
-	}
-	availableBalance := 0.0
-	if avail, ok := balance["availableBalance"].(float64); ok {
-		availableBalance = avail
+	if err := at.checkMargin(decision.PositionSizeUSD, decision.Leverage, 0); err != nil {
+		return err
 	}
 
-	// 手续费估算（Taker费率 0.04%）
-	estimatedFee := decision.PositionSizeUSD * 0.0004
-	totalRequired := requiredMargin + estimatedFee
-
-	if totalRequired > availableBalance {
-		return fmt.Errorf("❌ 保证金不足: 需要 %.2f USDT（保证金 %.2f + 手续费 %.2f），可用 %.2f USDT",
-			totalRequired, requiredMargin, estimatedFee, availableBalance)
-	}
+	return at.placeOpenOrder(decision, "short", quantity, actionRecord)
+}
 
+// placeOpenOrder 下开仓单并设置止损止盈（开仓前的持仓、资金费、深度和保证金检查由调用方完成）
+func (at *AutoTrader) placeOpenOrder(decision *decision.Decision, side string, quantity float64, actionRecord *logger.DecisionAction) error {
 	// 设置仓位模式
 	if err := at.trader.SetMarginMode(decision.Symbol, at.config.IsCrossMargin); err != nil {
 		log.Printf("  ⚠️ 设置仓位模式失败: %v", err)
@@ -886,7 +1080,14 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	}
 
 	// 开仓
-	order, err := at.trader.OpenShort(decision.Symbol, quantity, decision.Leverage)
+	positionSide := strings.ToUpper(side)
+	var order map[string]interface{}
+	var err error
+	if positionSide == "LONG" {
+		order, err = at.trader.OpenLong(decision.Symbol, quantity, decision.Leverage)
+	} else {
+		order, err = at.trader.OpenShort(decision.Symbol, quantity, decision.Leverage)
+	}
 	if err != nil {
 		return err
 	}
@@ -899,17 +1100,20 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	log.Printf("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
 
 	// 记录开仓时间
-	posKey := decision.Symbol + "_short"
+	posKey := decision.Symbol + "_" + side
 	at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
 
 	// 设置止损止盈
-	if err := at.trader.SetStopLoss(decision.Symbol, "SHORT", quantity, decision.StopLoss); err != nil {
+	if err := at.trader.SetStopLoss(decision.Symbol, positionSide, quantity, decision.StopLoss); err != nil {
 		log.Printf("  ⚠ 设置止损失败: %v", err)
 	}
-	if err := at.trader.SetTakeProfit(decision.Symbol, "SHORT", quantity, decision.TakeProfit); err != nil {
+	if err := at.trader.SetTakeProfit(decision.Symbol, positionSide, quantity, decision.TakeProfit); err != nil {
 		log.Printf("  ⚠ 设置止盈失败: %v", err)
 	}
 
+	// 记录止损止盈价格，止损单缺失时由止损守护按此重建
+	at.recordProtectionLevels(decision.Symbol, side, decision.StopLoss, decision.TakeProfit)
+
 	return nil
 }
 
@@ -918,7 +1122,7 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 	log.Printf("  🔄 平多仓: %s", decision.Symbol)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.FetchData(at.marketProvider, decision.Symbol, nil)
 	if err != nil {
 		return err
 	}
@@ -935,6 +1139,8 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 		actionRecord.OrderID = orderID
 	}
 
+	at.clearProtectionState(decision.Symbol, "long")
+
 	log.Printf("  ✓ 平仓成功")
 	return nil
 }
@@ -944,7 +1150,7 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 	log.Printf("  🔄 平空仓: %s", decision.Symbol)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.FetchData(at.marketProvider, decision.Symbol, nil)
 	if err != nil {
 		return err
 	}
@@ -961,6 +1167,8 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 		actionRecord.OrderID = orderID
 	}
 
+	at.clearProtectionState(decision.Symbol, "short")
+
 	log.Printf("  ✓ 平仓成功")
 	return nil
 }
@@ -970,7 +1178,7 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 	log.Printf("  🎯 调整止损: %s → %.2f", decision.Symbol, decision.NewStopLoss)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.FetchData(at.marketProvider, decision.Symbol, nil)
 	if err != nil {
 		return err
 	}
@@ -1001,6 +1209,7 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 	side, _ := targetPosition["side"].(string)
 	positionSide := strings.ToUpper(side)
 	positionAmt, _ := targetPosition["positionAmt"].(float64)
+	actionRecord.Side = side
 
 	// 验证新止损价格合理性
 	if positionSide == "LONG" && decision.NewStopLoss >= marketData.CurrentPrice {
@@ -1045,6 +1254,8 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 		return fmt.Errorf("修改止损失败: %w", err)
 	}
 
+	at.recordProtectionLevels(decision.Symbol, side, decision.NewStopLoss, 0)
+
 	log.Printf("  ✓ 止损已调整: %.2f (当前价格: %.2f)", decision.NewStopLoss, marketData.CurrentPrice)
 	return nil
 }
@@ -1054,7 +1265,7 @@ func (at *AutoTrader) executeUpdateTakeProfitWithRecord(decision *decision.Decis
 	log.Printf("  🎯 调整止盈: %s → %.2f", decision.Symbol, decision.NewTakeProfit)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.FetchData(at.marketProvider, decision.Symbol, nil)
 	if err != nil {
 		return err
 	}
@@ -1129,6 +1340,8 @@ func (at *AutoTrader) executeUpdateTakeProfitWithRecord(decision *decision.Decis
 		return fmt.Errorf("修改止盈失败: %w", err)
 	}
 
+	at.recordProtectionLevels(decision.Symbol, side, 0, decision.NewTakeProfit)
+
 	log.Printf("  ✓ 止盈已调整: %.2f (当前价格: %.2f)", decision.NewTakeProfit, marketData.CurrentPrice)
 	return nil
 }
@@ -1143,7 +1356,7 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 	}
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.FetchData(at.marketProvider, decision.Symbol, nil)
 	if err != nil {
 		return err
 	}
@@ -1204,6 +1417,248 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 	return nil
 }
 
+// checkMargin 保证金验证：新开仓位所需保证金 + 手续费不能超过可用余额
+// releasedMargin 为开仓前会释放的保证金（反手时平掉的原仓位）
+func (at *AutoTrader) checkMargin(positionSizeUSD float64, leverage int, releasedMargin float64) error {
+	requiredMargin := positionSizeUSD / float64(leverage)
+
+	balance, err := at.trader.GetBalance()
+	if err != nil {
+		return fmt.Errorf("获取账户余额失败: %w", err)
+	}
+	availableBalance := 0.0
+	if avail, ok := balance["availableBalance"].(float64); ok {
+		availableBalance = avail
+	}
+	availableBalance += releasedMargin
+
+	// 手续费估算（Taker费率 0.04%）
+	estimatedFee := positionSizeUSD * 0.0004
+	totalRequired := requiredMargin + estimatedFee
+
+	if totalRequired > availableBalance {
+		return fmt.Errorf("❌ 保证金不足: 需要 %.2f USDT（保证金 %.2f + 手续费 %.2f），可用 %.2f USDT",
+			totalRequired, requiredMargin, estimatedFee, availableBalance)
+	}
+	return nil
+}
+
+// findPosition 查找币种的当前持仓（返回第一个非零持仓）
+func (at *AutoTrader) findPosition(symbol string) (map[string]interface{}, error) {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("获取持仓失败: %w", err)
+	}
+	for _, pos := range positions {
+		posSymbol, _ := pos["symbol"].(string)
+		posAmt, _ := pos["positionAmt"].(float64)
+		if posSymbol == symbol && posAmt != 0 {
+			return pos, nil
+		}
+	}
+	return nil, fmt.Errorf("持仓不存在: %s", symbol)
+}
+
+// executeAddToPositionWithRecord 按原方向加仓，并按加仓后的总数量重设止损止盈
+func (at *AutoTrader) executeAddToPositionWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+	log.Printf("  ➕ 加仓: %s", decision.Symbol)
+
+	pos, err := at.findPosition(decision.Symbol)
+	if err != nil {
+		return err
+	}
+	side, _ := pos["side"].(string)
+	positionSide := strings.ToUpper(side)
+	positionAmt, _ := pos["positionAmt"].(float64)
+	actionRecord.Side = side
+
+	// 未指定杠杆时沿用持仓杠杆
+	if decision.Leverage <= 0 {
+		if leverage, ok := pos["leverage"].(float64); ok && leverage > 0 {
+			decision.Leverage = int(leverage)
+		}
+	}
+	if decision.Leverage <= 0 {
+		return fmt.Errorf("无法确定 %s 的加仓杠杆", decision.Symbol)
+	}
+	actionRecord.Leverage = decision.Leverage
+
+	// 获取当前价格
+	marketData, err := market.FetchData(at.marketProvider, decision.Symbol, nil)
+	if err != nil {
+		return err
+	}
+
+	// 加仓同样需要通过资金费率、深度和保证金检查
+	warning, err := at.checkFundingCost(decision, side, marketData.FundingRate, marketData.FundingInterval)
+	if err != nil {
+		return err
+	}
+	actionRecord.Warning = warning
+	if err := at.checkOrderBookDepth(decision.Symbol, side, decision.PositionSizeUSD, marketData.Depth); err != nil {
+		return err
+	}
+
+	quantity := decision.PositionSizeUSD / marketData.CurrentPrice
+	actionRecord.Quantity = quantity
+	actionRecord.Price = marketData.CurrentPrice
+
+	if err := at.checkMargin(decision.PositionSizeUSD, decision.Leverage, 0); err != nil {
+		return err
+	}
+
+	// 开仓接口会先撤销该币种的全部委托（含旧的止损止盈），成交后按总数量重新设置
+	var order map[string]interface{}
+	if positionSide == "LONG" {
+		order, err = at.trader.OpenLong(decision.Symbol, quantity, decision.Leverage)
+	} else {
+		order, err = at.trader.OpenShort(decision.Symbol, quantity, decision.Leverage)
+	}
+	if err != nil {
+		return fmt.Errorf("加仓失败: %w", err)
+	}
+
+	if orderID, ok := order["orderId"].(int64); ok {
+		actionRecord.OrderID = orderID
+	}
+
+	totalQuantity := math.Abs(positionAmt) + quantity
+	log.Printf("  ✓ 加仓成功，订单ID: %v, 加仓数量: %.4f, 总数量: %.4f", order["orderId"], quantity, totalQuantity)
+
+	if err := at.trader.SetStopLoss(decision.Symbol, positionSide, totalQuantity, decision.StopLoss); err != nil {
+		log.Printf("  ⚠ 设置止损失败: %v", err)
+	}
+	if err := at.trader.SetTakeProfit(decision.Symbol, positionSide, totalQuantity, decision.TakeProfit); err != nil {
+		log.Printf("  ⚠ 设置止盈失败: %v", err)
+	}
+
+	// 记录止损止盈价格，止损单缺失时由止损守护按此重建
+	at.recordProtectionLevels(decision.Symbol, side, decision.StopLoss, decision.TakeProfit)
+
+	return nil
+}
+
+// executeReverseWithRecord 反手：平掉当前持仓后按决策开反方向仓位
+// 平仓前先按释放的保证金检查新仓位，避免平仓后才发现无法开仓
+func (at *AutoTrader) executeReverseWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+	pos, err := at.findPosition(decision.Symbol)
+	if err != nil {
+		return err
+	}
+	side, _ := pos["side"].(string)
+	newSide := "short"
+	if side == "short" {
+		newSide = "long"
+	}
+	log.Printf("  🔁 反手: %s %s → %s", decision.Symbol, side, newSide)
+	actionRecord.Side = newSide
+
+	marketData, err := market.FetchData(at.marketProvider, decision.Symbol, nil)
+	if err != nil {
+		return err
+	}
+	warning, err := at.checkFundingCost(decision, newSide, marketData.FundingRate, marketData.FundingInterval)
+	if err != nil {
+		return err
+	}
+	actionRecord.Warning = warning
+	if err := at.checkOrderBookDepth(decision.Symbol, newSide, decision.PositionSizeUSD, marketData.Depth); err != nil {
+		return err
+	}
+
+	// 原仓位平仓后释放的保证金（按当前价估算）
+	positionAmt, _ := pos["positionAmt"].(float64)
+	releasedMargin := 0.0
+	if leverage, ok := pos["leverage"].(float64); ok && leverage > 0 {
+		releasedMargin = math.Abs(positionAmt) * marketData.CurrentPrice / leverage
+	}
+	if err := at.checkMargin(decision.PositionSizeUSD, decision.Leverage, releasedMargin); err != nil {
+		return err
+	}
+
+	var closeOrder map[string]interface{}
+	if side == "long" {
+		closeOrder, err = at.trader.CloseLong(decision.Symbol, 0) // 0 = 全部平仓
+	} else {
+		closeOrder, err = at.trader.CloseShort(decision.Symbol, 0)
+	}
+	if err != nil {
+		return fmt.Errorf("反手平仓失败: %w", err)
+	}
+	at.clearProtectionState(decision.Symbol, side)
+	log.Printf("  ✓ 已平%s仓，订单ID: %v", side, closeOrder["orderId"])
+
+	// 开仓前的检查已按平仓释放的保证金完成，平仓后直接下单（不再等待余额结算）
+	quantity := decision.PositionSizeUSD / marketData.CurrentPrice
+	actionRecord.Quantity = quantity
+	actionRecord.Price = marketData.CurrentPrice
+	if err := at.placeOpenOrder(decision, newSide, quantity, actionRecord); err != nil {
+		// 原仓位已平：单独记录平仓，保证交易表现分析能正确配对
+		closeAction := logger.DecisionAction{
+			Action:    "close_" + side,
+			Symbol:    decision.Symbol,
+			Price:     marketData.CurrentPrice,
+			Timestamp: time.Now(),
+			Success:   true,
+		}
+		if orderID, ok := closeOrder["orderId"].(int64); ok {
+			closeAction.OrderID = orderID
+		}
+		at.recordAutoAction(closeAction, fmt.Sprintf("🔁 反手 %s: 已平%s仓，但反向开仓失败", decision.Symbol, side))
+		return fmt.Errorf("反手开仓失败（原%s仓已平仓）: %w", side, err)
+	}
+	return nil
+}
+
+// executeUpdateSLTPWithRecord 同时调整止损和止盈（先撤销旧的止损止盈单，再设置新的）
+func (at *AutoTrader) executeUpdateSLTPWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+	log.Printf("  🎯 调整止损止盈: %s → 止损 %.2f / 止盈 %.2f", decision.Symbol, decision.NewStopLoss, decision.NewTakeProfit)
+
+	marketData, err := market.FetchData(at.marketProvider, decision.Symbol, nil)
+	if err != nil {
+		return err
+	}
+	actionRecord.Price = marketData.CurrentPrice
+
+	pos, err := at.findPosition(decision.Symbol)
+	if err != nil {
+		return err
+	}
+	side, _ := pos["side"].(string)
+	positionSide := strings.ToUpper(side)
+	positionAmt, _ := pos["positionAmt"].(float64)
+	actionRecord.Side = side
+
+	// 两个价格都要先通过校验，避免只改了一半
+	price := marketData.CurrentPrice
+	if positionSide == "LONG" && (decision.NewStopLoss >= price || decision.NewTakeProfit <= price) {
+		return fmt.Errorf("多单止损必须低于、止盈必须高于当前价格 (当前: %.2f, 新止损: %.2f, 新止盈: %.2f)", price, decision.NewStopLoss, decision.NewTakeProfit)
+	}
+	if positionSide == "SHORT" && (decision.NewStopLoss <= price || decision.NewTakeProfit >= price) {
+		return fmt.Errorf("空单止损必须高于、止盈必须低于当前价格 (当前: %.2f, 新止损: %.2f, 新止盈: %.2f)", price, decision.NewStopLoss, decision.NewTakeProfit)
+	}
+
+	if err := at.trader.CancelStopOrders(decision.Symbol); err != nil {
+		log.Printf("  ⚠ 取消旧止损止盈单失败: %v", err)
+		// 不中断执行，继续设置新的止损止盈
+	}
+
+	quantity := math.Abs(positionAmt)
+	if err := at.trader.SetStopLoss(decision.Symbol, positionSide, quantity, decision.NewStopLoss); err != nil {
+		// 旧止损单已撤销：止损守护会按之前记录的止损价重建
+		return fmt.Errorf("修改止损失败: %w", err)
+	}
+	if err := at.trader.SetTakeProfit(decision.Symbol, positionSide, quantity, decision.NewTakeProfit); err != nil {
+		at.recordProtectionLevels(decision.Symbol, side, decision.NewStopLoss, 0)
+		return fmt.Errorf("修改止盈失败（止损已更新）: %w", err)
+	}
+
+	at.recordProtectionLevels(decision.Symbol, side, decision.NewStopLoss, decision.NewTakeProfit)
+
+	log.Printf("  ✓ 止损止盈已调整: %.2f / %.2f (当前价格: %.2f)", decision.NewStopLoss, decision.NewTakeProfit, price)
+	return nil
+}
+
 // GetID 获取trader ID
 func (at *AutoTrader) GetID() string {
 	return at.id
@@ -1397,12 +1852,69 @@ func (at *AutoTrader) GetPositions() ([]map[string]interface{}, error) {
 			"unrealized_pnl_pct": pnlPct,
 			"liquidation_price":  liquidationPrice,
 			"margin_used":        marginUsed,
+			"funding_fee":        at.positionFundingFee(symbol, side),
 		})
 	}
 
 	return result, nil
 }
 
+// GetExposure 获取组合敞口与相关性分析（用于API）
+func (at *AutoTrader) GetExposure() (*decision.ExposureReport, error) {
+	balance, err := at.trader.GetBalance()
+	if err != nil {
+		return nil, fmt.Errorf("获取余额失败: %w", err)
+	}
+	totalWalletBalance, _ := balance["totalWalletBalance"].(float64)
+	totalUnrealizedProfit, _ := balance["totalUnrealizedProfit"].(float64)
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("获取持仓失败: %w", err)
+	}
+
+	var positionInfos []decision.PositionInfo
+	heldSymbols := make(map[string]bool)
+	for _, pos := range positions {
+		quantity, _ := pos["positionAmt"].(float64)
+		if quantity < 0 {
+			quantity = -quantity
+		}
+		if quantity == 0 {
+			continue
+		}
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		markPrice, _ := pos["markPrice"].(float64)
+		if symbol == "" || side == "" || markPrice <= 0 {
+			log.Printf("⚠️  持仓数据不完整，敞口计算跳过: %v", pos)
+			continue
+		}
+		entryPrice, _ := pos["entryPrice"].(float64)
+		heldSymbols[symbol] = true
+		positionInfos = append(positionInfos, decision.PositionInfo{
+			Symbol:     symbol,
+			Side:       side,
+			EntryPrice: entryPrice,
+			MarkPrice:  markPrice,
+			Quantity:   quantity,
+			Leverage:   at.positionLeverage(pos),
+		})
+	}
+
+	// 候选币种获取失败不影响持仓敞口的计算
+	var candidates []string
+	if coins, err := at.getCandidateCoins(); err == nil {
+		for _, coin := range coins {
+			if !heldSymbols[coin.Symbol] {
+				candidates = append(candidates, coin.Symbol)
+			}
+		}
+	}
+
+	return decision.BuildExposureReport(at.marketProvider, positionInfos, candidates, totalWalletBalance+totalUnrealizedProfit), nil
+}
+
 // calculatePnLPercentage 计算盈亏百分比（基于保证金，自动考虑杠杆）
 // 收益率 = 未实现盈亏 / 保证金 × 100%
 func calculatePnLPercentage(unrealizedPnl, marginUsed float64) float64 {
@@ -1424,10 +1936,10 @@ func sortDecisionsByPriority(decisions []decision.Decision) []decision.Decision
 		switch action {
 		case "close_long", "close_short", "partial_close":
 			return 1 // 最高优先级：先平仓（包括部分平仓）
-		case "update_stop_loss", "update_take_profit":
+		case "update_stop_loss", "update_take_profit", "update_sl_tp":
 			return 2 // 调整持仓止盈止损
-		case "open_long", "open_short":
-			return 3 // 次优先级：后开仓
+		case "open_long", "open_short", "add_to_position", "reverse":
+			return 3 // 次优先级：后开仓（加仓、反手会增加保证金占用，同样放在平仓之后）
 		case "hold", "wait":
 			return 4 // 最低优先级：观望
 		default:
@@ -1509,34 +2021,48 @@ func (at *AutoTrader) getCandidateCoins() ([]decision.CandidateCoin, error) {
 	}
 }
 
-// normalizeSymbol 标准化币种符号（确保以USDT结尾）
+// normalizeSymbol 标准化币种符号（币安格式，只有币种名时补全USDT）
 func normalizeSymbol(symbol string) string {
-	// 转为大写
-	symbol = strings.ToUpper(strings.TrimSpace(symbol))
-
-	// 确保以USDT结尾
-	if !strings.HasSuffix(symbol, "USDT") {
-		symbol = symbol + "USDT"
-	}
-
-	return symbol
+	return market.Normalize(symbol)
 }
 
-// 启动回撤监控
+// 启动回撤监控（利润保护策略）
+// 行情数据源的实时价格推送会立即触发持仓币种的策略评估，定时器只负责同步持仓信息
 func (at *AutoTrader) startDrawdownMonitor() {
 	at.monitorWg.Add(1)
 	go func() {
 		defer at.monitorWg.Done()
 
-		ticker := time.NewTicker(1 * time.Minute) // 每分钟检查一次
+		ticker := time.NewTicker(exitPolicyRefreshInterval)
 		defer ticker.Stop()
 
-		log.Println("📊 启动持仓回撤监控（每分钟检查一次）")
+		priceCh := make(chan priceTick, 256)
+		provider := at.marketDataProvider()
+		unsubscribe, ok := provider.SubscribePrices(func(symbol string, price float64) {
+			if !at.hasExitState(symbol) {
+				return
+			}
+			// 非阻塞投递，处理不过来时丢弃旧价格（下一次推送会带来最新价格）
+			select {
+			case priceCh <- priceTick{symbol: symbol, price: price}:
+			default:
+			}
+		})
+		if ok {
+			defer unsubscribe()
+			log.Printf("📊 启动持仓利润保护监控（%s 实时价格评估，每 %v 同步持仓）", provider.Name(), exitPolicyRefreshInterval)
+		} else {
+			log.Printf("📊 启动持仓利润保护监控（%s 无实时价格推送，每 %v 按标记价格评估）", provider.Name(), exitPolicyRefreshInterval)
+		}
+
+		at.checkPositionDrawdown()
 
 		for {
 			select {
 			case <-ticker.C:
 				at.checkPositionDrawdown()
+			case tick := <-priceCh:
+				at.onPriceTick(tick.symbol, tick.price)
 			case <-at.stopMonitorCh:
 				log.Println("⏹ 停止持仓回撤监控")
 				return
@@ -1545,7 +2071,7 @@ func (at *AutoTrader) startDrawdownMonitor() {
 	}()
 }
 
-// 检查持仓回撤情况
+// 检查持仓回撤情况：同步持仓状态并按标记价格评估利润保护策略
 func (at *AutoTrader) checkPositionDrawdown() {
 	// 获取当前持仓
 	positions, err := at.trader.GetPositions()
@@ -1554,71 +2080,28 @@ func (at *AutoTrader) checkPositionDrawdown() {
 		return
 	}
 
+	activeKeys := make(map[string]bool)
 	for _, pos := range positions {
-		symbol := pos["symbol"].(string)
-		side := pos["side"].(string)
-		entryPrice := pos["entryPrice"].(float64)
-		markPrice := pos["markPrice"].(float64)
-		quantity := pos["positionAmt"].(float64)
-		if quantity < 0 {
-			quantity = -quantity // 空仓数量为负，转为正数
-		}
-
-		// 计算当前盈亏百分比
-		leverage := 10 // 默认值
-		if lev, ok := pos["leverage"].(float64); ok {
-			leverage = int(lev)
-		}
-
-		var currentPnLPct float64
-		if side == "long" {
-			currentPnLPct = ((markPrice - entryPrice) / entryPrice) * float64(leverage) * 100
-		} else {
-			currentPnLPct = ((entryPrice - markPrice) / entryPrice) * float64(leverage) * 100
-		}
-
-		// 构造持仓唯一标识（区分多空）
-		posKey := symbol + "_" + side
-
-		// 获取该持仓的历史最高收益
-		at.peakPnLCacheMutex.RLock()
-		peakPnLPct, exists := at.peakPnLCache[posKey]
-		at.peakPnLCacheMutex.RUnlock()
-
-		if !exists {
-			// 如果没有历史最高记录，使用当前盈亏作为初始值
-			peakPnLPct = currentPnLPct
-			at.UpdatePeakPnL(symbol, side, currentPnLPct)
-		} else {
-			// 更新峰值缓存
-			at.UpdatePeakPnL(symbol, side, currentPnLPct)
+		state := at.syncExitState(pos)
+		if state == nil {
+			continue
 		}
+		activeKeys[state.Symbol+"_"+state.Side] = true
 
-		// 计算回撤（从最高点下跌的幅度）
-		var drawdownPct float64
-		if peakPnLPct > 0 && currentPnLPct < peakPnLPct {
-			drawdownPct = ((peakPnLPct - currentPnLPct) / peakPnLPct) * 100
+		markPrice, _ := pos["markPrice"].(float64)
+		if markPrice > 0 {
+			at.evaluateExitState(state, markPrice)
 		}
+	}
 
-		// 检查平仓条件：收益大于5%且回撤超过40%
-		if currentPnLPct > 5.0 && drawdownPct >= 40.0 {
-			log.Printf("🚨 触发回撤平仓条件: %s %s | 当前收益: %.2f%% | 最高收益: %.2f%% | 回撤: %.2f%%",
-				symbol, side, currentPnLPct, peakPnLPct, drawdownPct)
-
-			// 执行平仓
-			if err := at.emergencyClosePosition(symbol, side); err != nil {
-				log.Printf("❌ 回撤平仓失败 (%s %s): %v", symbol, side, err)
-			} else {
-				log.Printf("✅ 回撤平仓成功: %s %s", symbol, side)
-				// 平仓后清理该持仓的缓存
-				at.ClearPeakPnLCache(symbol, side)
-			}
-		} else if currentPnLPct > 5.0 {
-			// 记录接近平仓条件的情况（用于调试）
-			log.Printf("📊 回撤监控: %s %s | 收益: %.2f%% | 最高: %.2f%% | 回撤: %.2f%%",
-				symbol, side, currentPnLPct, peakPnLPct, drawdownPct)
+	// 清理已平仓的持仓状态
+	at.exitMutex.Lock()
+	for key := range at.exitStates {
+		if !activeKeys[key] {
+			delete(at.exitStates, key)
 		}
 	}
+	at.exitMutex.Unlock()
 }
 
 // 紧急平仓函数
@@ -1643,6 +2126,29 @@ func (at *AutoTrader) emergencyClosePosition(symbol, side string) error {
 	return nil
 }
 
+// recordAutoAction 将监控模块自动执行的操作（auto_*）写入决策日志
+func (at *AutoTrader) recordAutoAction(action logger.DecisionAction, summary string) {
+	if at.decisionLogger == nil {
+		return
+	}
+
+	record := &logger.DecisionRecord{
+		ExecutionLog: []string{summary},
+		Decisions:    []logger.DecisionAction{action},
+		Success:      action.Success,
+		ErrorMessage: action.Error,
+	}
+	if action.Success {
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", action.Symbol, action.Action))
+	} else {
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 失败: %s", action.Symbol, action.Action, action.Error))
+	}
+
+	if err := at.decisionLogger.LogDecision(record); err != nil {
+		log.Printf("⚠ 保存决策记录失败: %v", err)
+	}
+}
+
 // GetPeakPnLCache 获取最高收益缓存
 func (at *AutoTrader) GetPeakPnLCache() map[string]float64 {
 	at.peakPnLCacheMutex.RLock()
@@ -182,6 +182,8 @@ func (s *AutoTraderTestSuite) TestNormalizeSymbol() {
 		{"小写转大写", "btcusdt", "BTCUSDT"},
 		{"只有币种名称_添加USDT", "BTC", "BTCUSDT"},
 		{"带空格_去除空格", " BTC ", "BTCUSDT"},
+		{"USDC计价_保持不变", "ethusdc", "ETHUSDC"},
+		{"Hyperliquid倍数币种", "kPEPE", "1000PEPEUSDT"},
 	}
 
 	for _, tt := range tests {
@@ -325,6 +327,33 @@ func (s *AutoTraderTestSuite) TestGetPositions() {
 	})
 }
 
+// TestGetExposure 持仓字段缺失或类型不符时跳过该持仓而不是 panic
+func (s *AutoTraderTestSuite) TestGetExposure() {
+	var got []decision.PositionInfo
+	s.patches.ApplyFunc(decision.BuildExposureReport, func(p market.MarketDataProvider, positions []decision.PositionInfo, candidates []string, equity float64) *decision.ExposureReport {
+		got = positions
+		return &decision.ExposureReport{Equity: equity}
+	})
+	s.autoTrader.defaultCoins = []string{"BTCUSDT"}
+	s.mockTrader.positions = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "entryPrice": 50000.0, "markPrice": 51000.0, "positionAmt": 0.1, "leverage": 10.0},
+		{"symbol": "ETHUSDT", "side": "short", "entryPrice": "3000", "markPrice": 2900.0, "positionAmt": -1.0},
+		{"symbol": "SOLUSDT", "side": "long", "positionAmt": 2.0},
+		{"side": "long", "markPrice": 100.0, "positionAmt": 1.0},
+		{"symbol": "XRPUSDT", "side": "long", "markPrice": 1.0, "positionAmt": "10"},
+	}
+
+	report, err := s.autoTrader.GetExposure()
+
+	s.Require().NoError(err)
+	s.Equal(10100.0, report.Equity)
+	s.Require().Len(got, 2)
+	s.Equal(decision.PositionInfo{Symbol: "BTCUSDT", Side: "long", EntryPrice: 50000, MarkPrice: 51000, Quantity: 0.1, Leverage: 10}, got[0])
+	s.Equal("ETHUSDT", got[1].Symbol)
+	s.Equal(1.0, got[1].Quantity)
+	s.Equal(0.0, got[1].EntryPrice)
+}
+
 // ============================================================
 // 层次 7: getCandidateCoins 测试
 // ============================================================
@@ -772,6 +801,135 @@ func (s *AutoTraderTestSuite) TestExecutePartialCloseWithRecord() {
 	})
 }
 
+// TestExecutePositionActions 测试加仓、反手和同时调整止损止盈
+func (s *AutoTraderTestSuite) TestExecutePositionActions() {
+	s.patches.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 50000.0}, nil
+	})
+	longPosition := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1, "leverage": 5.0, "markPrice": 50000.0},
+		}
+	}
+	reset := func() {
+		s.mockTrader.positions = []map[string]interface{}{}
+		s.mockTrader.balance["availableBalance"] = 8000.0
+		s.mockTrader.stopLossQuantities = nil
+		s.mockTrader.closeQuantities = nil
+		s.mockTrader.shouldFailOpenShort = false
+	}
+
+	s.Run("加仓沿用持仓杠杆并按总数量重设止损", func() {
+		defer reset()
+		s.mockTrader.positions = longPosition()
+
+		d := &decision.Decision{Action: "add_to_position", Symbol: "BTCUSDT", PositionSizeUSD: 5000.0, StopLoss: 48000, TakeProfit: 56000}
+		actionRecord := &logger.DecisionAction{Action: "add_to_position", Symbol: "BTCUSDT"}
+
+		s.NoError(s.autoTrader.executeAddToPositionWithRecord(d, actionRecord))
+		s.Equal("long", actionRecord.Side)
+		s.Equal(5, actionRecord.Leverage)
+		s.Equal(int64(123456), actionRecord.OrderID)
+		s.InDelta(0.1, actionRecord.Quantity, 1e-9)
+		s.Require().Len(s.mockTrader.stopLossQuantities, 1)
+		s.InDelta(0.2, s.mockTrader.stopLossQuantities[0], 1e-9)
+
+		level, ok := s.autoTrader.getProtectionLevel("BTCUSDT", "long")
+		s.True(ok)
+		s.Equal(48000.0, level.StopLoss)
+	})
+
+	s.Run("加仓_持仓不存在", func() {
+		defer reset()
+		d := &decision.Decision{Action: "add_to_position", Symbol: "BTCUSDT", PositionSizeUSD: 1000.0, Leverage: 5}
+		err := s.autoTrader.executeAddToPositionWithRecord(d, &logger.DecisionAction{})
+		s.Error(err)
+		s.Contains(err.Error(), "持仓不存在")
+	})
+
+	s.Run("加仓_保证金不足", func() {
+		defer reset()
+		s.mockTrader.positions = longPosition()
+		s.mockTrader.balance["availableBalance"] = 100.0
+		d := &decision.Decision{Action: "add_to_position", Symbol: "BTCUSDT", PositionSizeUSD: 5000.0, StopLoss: 48000, TakeProfit: 56000}
+		err := s.autoTrader.executeAddToPositionWithRecord(d, &logger.DecisionAction{})
+		s.Error(err)
+		s.Contains(err.Error(), "保证金不足")
+	})
+
+	s.Run("反手平多开空", func() {
+		defer reset()
+		s.mockTrader.positions = longPosition()
+		s.autoTrader.recordProtectionLevels("BTCUSDT", "long", 48000, 56000)
+
+		d := &decision.Decision{Action: "reverse", Symbol: "BTCUSDT", Leverage: 10, PositionSizeUSD: 1000.0, StopLoss: 52000, TakeProfit: 44000}
+		actionRecord := &logger.DecisionAction{Action: "reverse", Symbol: "BTCUSDT"}
+
+		s.NoError(s.autoTrader.executeReverseWithRecord(d, actionRecord))
+		s.Equal("short", actionRecord.Side)
+		s.Equal(int64(123457), actionRecord.OrderID)
+		s.Equal([]float64{0}, s.mockTrader.closeQuantities)
+		_, ok := s.autoTrader.getProtectionLevel("BTCUSDT", "long")
+		s.False(ok)
+	})
+
+	s.Run("反手_计入释放的保证金", func() {
+		defer reset()
+		// 原仓位释放 0.1 × 50000 / 5 = 1000，加上可用 50 足够开 1000/10 的新仓位
+		s.mockTrader.positions = longPosition()
+		s.mockTrader.balance["availableBalance"] = 50.0
+		d := &decision.Decision{Action: "reverse", Symbol: "BTCUSDT", Leverage: 10, PositionSizeUSD: 1000.0, StopLoss: 52000, TakeProfit: 44000}
+		s.NoError(s.autoTrader.executeReverseWithRecord(d, &logger.DecisionAction{}))
+	})
+
+	s.Run("反手_保证金不足时不平仓", func() {
+		defer reset()
+		s.mockTrader.positions = []map[string]interface{}{
+			{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.001, "leverage": 5.0},
+		}
+		s.mockTrader.balance["availableBalance"] = 0.0
+		d := &decision.Decision{Action: "reverse", Symbol: "BTCUSDT", Leverage: 10, PositionSizeUSD: 5000.0, StopLoss: 52000, TakeProfit: 44000}
+		err := s.autoTrader.executeReverseWithRecord(d, &logger.DecisionAction{})
+		s.Error(err)
+		s.Contains(err.Error(), "保证金不足")
+		s.Empty(s.mockTrader.closeQuantities)
+	})
+
+	s.Run("反手_开仓失败时说明已平仓", func() {
+		defer reset()
+		s.mockTrader.positions = longPosition()
+		s.mockTrader.shouldFailOpenShort = true
+		d := &decision.Decision{Action: "reverse", Symbol: "BTCUSDT", Leverage: 10, PositionSizeUSD: 1000.0, StopLoss: 52000, TakeProfit: 44000}
+		err := s.autoTrader.executeReverseWithRecord(d, &logger.DecisionAction{})
+		s.Error(err)
+		s.Contains(err.Error(), "原long仓已平仓")
+	})
+
+	s.Run("同时调整止损止盈", func() {
+		defer reset()
+		s.mockTrader.positions = longPosition()
+		d := &decision.Decision{Action: "update_sl_tp", Symbol: "BTCUSDT", NewStopLoss: 49000, NewTakeProfit: 55000}
+		actionRecord := &logger.DecisionAction{Action: "update_sl_tp", Symbol: "BTCUSDT"}
+
+		s.NoError(s.autoTrader.executeUpdateSLTPWithRecord(d, actionRecord))
+		s.Equal(50000.0, actionRecord.Price)
+		level, ok := s.autoTrader.getProtectionLevel("BTCUSDT", "long")
+		s.True(ok)
+		s.Equal(49000.0, level.StopLoss)
+		s.Equal(55000.0, level.TakeProfit)
+	})
+
+	s.Run("同时调整_止盈低于当前价", func() {
+		defer reset()
+		s.mockTrader.positions = longPosition()
+		d := &decision.Decision{Action: "update_sl_tp", Symbol: "BTCUSDT", NewStopLoss: 49000, NewTakeProfit: 49500}
+		err := s.autoTrader.executeUpdateSLTPWithRecord(d, &logger.DecisionAction{})
+		s.Error(err)
+		s.Contains(err.Error(), "多单止损必须低于、止盈必须高于当前价格")
+		s.Empty(s.mockTrader.stopLossQuantities)
+	})
+}
+
 // ============================================================
 // 层次 10: executeDecisionWithRecord 路由测试
 // ============================================================
@@ -981,8 +1139,17 @@ type MockTrader struct {
 	shouldFailBalance    bool
 	shouldFailPositions  bool
 	shouldFailOpenLong   bool
+	shouldFailOpenShort  bool
 	shouldFailCloseLong  bool
 	shouldFailCloseShort bool
+	shouldFailStopLoss   bool
+	stopLossOrders       []map[string]interface{}
+	setStopLossCalls     int
+	stopLossQuantities   []float64
+	shouldFailAddMargin  bool
+	addMarginCalls       int
+	closeQuantities      []float64
+	fundingPayments      []map[string]interface{}
 }
 
 func (m *MockTrader) GetBalance() (map[string]interface{}, error) {
@@ -1020,6 +1187,9 @@ func (m *MockTrader) OpenLong(symbol string, quantity float64, leverage int) (ma
 }
 
 func (m *MockTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	if m.shouldFailOpenShort {
+		return nil, errors.New("failed to open short")
+	}
 	return map[string]interface{}{
 		"orderId": int64(123457),
 		"symbol":  symbol,
@@ -1030,6 +1200,7 @@ func (m *MockTrader) CloseLong(symbol string, quantity float64) (map[string]inte
 	if m.shouldFailCloseLong {
 		return nil, errors.New("failed to close long")
 	}
+	m.closeQuantities = append(m.closeQuantities, quantity)
 	return map[string]interface{}{
 		"orderId": int64(123458),
 		"symbol":  symbol,
@@ -1040,6 +1211,7 @@ func (m *MockTrader) CloseShort(symbol string, quantity float64) (map[string]int
 	if m.shouldFailCloseShort {
 		return nil, errors.New("failed to close short")
 	}
+	m.closeQuantities = append(m.closeQuantities, quantity)
 	return map[string]interface{}{
 		"orderId": int64(123459),
 		"symbol":  symbol,
@@ -1054,11 +1226,24 @@ func (m *MockTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
 	return nil
 }
 
+func (m *MockTrader) AddIsolatedMargin(symbol string, positionSide string, amount float64) error {
+	m.addMarginCalls++
+	if m.shouldFailAddMargin {
+		return errors.New("failed to add margin")
+	}
+	return nil
+}
+
 func (m *MockTrader) GetMarketPrice(symbol string) (float64, error) {
 	return 50000.0, nil
 }
 
 func (m *MockTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	m.setStopLossCalls++
+	m.stopLossQuantities = append(m.stopLossQuantities, quantity)
+	if m.shouldFailStopLoss {
+		return errors.New("failed to set stop loss")
+	}
 	return nil
 }
 
@@ -1082,6 +1267,26 @@ func (m *MockTrader) CancelStopOrders(symbol string) error {
 	return nil
 }
 
+func (m *MockTrader) GetStopLossOrders(symbol string) ([]map[string]interface{}, error) {
+	var orders []map[string]interface{}
+	for _, order := range m.stopLossOrders {
+		if order["symbol"] == symbol {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+func (m *MockTrader) GetFundingPayments(startTime int64) ([]map[string]interface{}, error) {
+	var payments []map[string]interface{}
+	for _, payment := range m.fundingPayments {
+		if payment["time"].(int64) >= startTime {
+			payments = append(payments, payment)
+		}
+	}
+	return payments, nil
+}
+
 func (m *MockTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
 	return fmt.Sprintf("%.4f", quantity), nil
 }
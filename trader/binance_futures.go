@@ -266,6 +266,32 @@ func (t *FuturesTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
 	return nil
 }
 
+// AddIsolatedMargin 为逐仓持仓追加保证金
+func (t *FuturesTrader) AddIsolatedMargin(symbol string, positionSide string, amount float64) error {
+	posSide := futures.PositionSideTypeLong
+	if positionSide == "SHORT" {
+		posSide = futures.PositionSideTypeShort
+	}
+
+	err := t.client.NewUpdatePositionMarginService().
+		Symbol(symbol).
+		PositionSide(posSide).
+		Amount(fmt.Sprintf("%.2f", amount)).
+		Type(1). // 1=追加保证金, 2=减少保证金
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("追加保证金失败: %w", err)
+	}
+
+	// 保证金变化后强平价也会变化，清除持仓缓存
+	t.positionsCacheMutex.Lock()
+	t.cachedPositions = nil
+	t.positionsCacheMutex.Unlock()
+
+	log.Printf("  ✓ %s %s 已追加保证金 %.2f USDT", symbol, positionSide, amount)
+	return nil
+}
+
 // SetLeverage 设置杠杆（智能判断+冷却期）
 func (t *FuturesTrader) SetLeverage(symbol string, leverage int) error {
 	// 先尝试获取当前杠杆（从持仓信息）
@@ -582,6 +608,69 @@ func (t *FuturesTrader) CancelStopLossOrders(symbol string) error {
 	return nil
 }
 
+// GetStopLossOrders 获取该币种当前挂着的止损单
+func (t *FuturesTrader) GetStopLossOrders(symbol string) ([]map[string]interface{}, error) {
+	orders, err := t.client.NewListOpenOrdersService().
+		Symbol(symbol).
+		Do(context.Background())
+
+	if err != nil {
+		return nil, fmt.Errorf("获取未完成订单失败: %w", err)
+	}
+
+	var result []map[string]interface{}
+	for _, order := range orders {
+		if order.Type != futures.OrderTypeStopMarket && order.Type != futures.OrderTypeStop {
+			continue
+		}
+
+		// 单向持仓模式下 positionSide 为 BOTH，根据下单方向推断保护的持仓方向
+		positionSide := string(order.PositionSide)
+		if positionSide != "LONG" && positionSide != "SHORT" {
+			if order.Side == futures.SideTypeSell {
+				positionSide = "LONG"
+			} else {
+				positionSide = "SHORT"
+			}
+		}
+
+		stopPrice, _ := strconv.ParseFloat(order.StopPrice, 64)
+		quantity, _ := strconv.ParseFloat(order.OrigQuantity, 64)
+		result = append(result, map[string]interface{}{
+			"orderId":      order.OrderID,
+			"positionSide": positionSide,
+			"stopPrice":    stopPrice,
+			"quantity":     quantity,
+		})
+	}
+
+	return result, nil
+}
+
+// GetFundingPayments 获取资金费流水（收益历史中 incomeType=FUNDING_FEE 的记录）
+func (t *FuturesTrader) GetFundingPayments(startTime int64) ([]map[string]interface{}, error) {
+	incomes, err := t.client.NewGetIncomeHistoryService().
+		IncomeType("FUNDING_FEE").
+		StartTime(startTime).
+		Limit(1000).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取资金费流水失败: %w", err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(incomes))
+	for _, income := range incomes {
+		amount, _ := strconv.ParseFloat(income.Income, 64)
+		result = append(result, map[string]interface{}{
+			"symbol": income.Symbol,
+			"amount": amount,
+			"time":   income.Time,
+		})
+	}
+
+	return result, nil
+}
+
 // CancelTakeProfitOrders 仅取消止盈单（不影响止损单）
 func (t *FuturesTrader) CancelTakeProfitOrders(symbol string) error {
 	// 获取该币种的所有未完成订单
@@ -248,6 +248,18 @@ func NewBinanceFuturesTestSuite(t *testing.T) *BinanceFuturesTestSuite {
 				"msg":  "success",
 			}
 
+		// Mock GetIncomeHistory - /fapi/v1/income
+		case path == "/fapi/v1/income":
+			respBody = []map[string]interface{}{
+				{
+					"symbol":     "BTCUSDT",
+					"incomeType": "FUNDING_FEE",
+					"income":     "-0.25",
+					"asset":      "USDT",
+					"time":       1700000100000,
+				},
+			}
+
 		// Mock ServerTime - /fapi/v1/time
 		case path == "/fapi/v1/time":
 			respBody = map[string]interface{}{
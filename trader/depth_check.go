@@ -0,0 +1,46 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"nofx/market"
+)
+
+// DefaultMaxDepthPct 默认不检查深度（开启时一般设为10，即开仓金额不超过对手盘±1%深度的10%）
+const DefaultMaxDepthPct = 0.0
+
+// ValidateMaxDepthPct 校验深度检查上限（0 表示关闭）
+func ValidateMaxDepthPct(pct float64) error {
+	if pct < 0 || pct > 100 {
+		return fmt.Errorf("深度检查上限必须在0-100之间，当前: %.2f", pct)
+	}
+	return nil
+}
+
+// checkOrderBookDepth 开仓前检查订单金额占对手盘可见深度的比例（开多吃卖盘，开空吃买盘）
+// 没有深度数据时不拦截，只记录日志
+func (at *AutoTrader) checkOrderBookDepth(symbol, side string, positionSizeUSD float64, depth *market.DepthData) error {
+	limit := at.config.MaxDepthPct
+	if limit <= 0 {
+		return nil
+	}
+	if depth == nil {
+		log.Printf("  ⚠️ %s 无订单簿数据，跳过深度检查", symbol)
+		return nil
+	}
+
+	available, book := depth.AskDepth1Pct, "卖盘"
+	if side == "short" {
+		available, book = depth.BidDepth1Pct, "买盘"
+	}
+	if available <= 0 {
+		return fmt.Errorf("❌ %s %s在中间价±1%%内没有挂单，流动性不足，拒绝开仓", symbol, book)
+	}
+
+	pct := positionSizeUSD / available * 100
+	if pct > limit {
+		return fmt.Errorf("❌ %s 开仓金额 %.2f USDT 占%s±1%%深度(%.0f USDT)的 %.1f%%，超过上限 %.1f%%，拒绝开仓",
+			symbol, positionSizeUSD, book, available, pct, limit)
+	}
+	return nil
+}
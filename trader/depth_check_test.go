@@ -0,0 +1,53 @@
+package trader
+
+import (
+	"nofx/market"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateMaxDepthPct 测试深度检查上限校验
+func TestValidateMaxDepthPct(t *testing.T) {
+	assert.NoError(t, ValidateMaxDepthPct(0))
+	assert.NoError(t, ValidateMaxDepthPct(10))
+	assert.NoError(t, ValidateMaxDepthPct(100))
+	assert.Error(t, ValidateMaxDepthPct(-1))
+	assert.Error(t, ValidateMaxDepthPct(100.5))
+}
+
+// TestCheckOrderBookDepth 测试开仓前的订单簿深度检查
+func (s *AutoTraderTestSuite) TestCheckOrderBookDepth() {
+	// 卖盘±1%深度 100000U，买盘 20000U
+	depth := &market.DepthData{MidPrice: 50000, AskDepth1Pct: 100000, BidDepth1Pct: 20000}
+
+	tests := []struct {
+		name    string
+		limit   float64
+		side    string
+		size    float64
+		depth   *market.DepthData
+		wantErr bool
+	}{
+		{name: "开多_未超过上限", limit: 10, side: "long", size: 5000, depth: depth},
+		{name: "开多_刚好等于上限", limit: 10, side: "long", size: 10000, depth: depth},
+		{name: "开空_超过买盘深度上限", limit: 10, side: "short", size: 5000, depth: depth, wantErr: true},
+		{name: "对手盘无挂单", limit: 10, side: "long", size: 100, depth: &market.DepthData{BidDepth1Pct: 1000}, wantErr: true},
+		{name: "无深度数据_跳过", limit: 10, side: "long", size: 1e9},
+		{name: "关闭检查", limit: 0, side: "short", size: 1e9, depth: depth},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.autoTrader.config.MaxDepthPct = tt.limit
+			defer func() { s.autoTrader.config.MaxDepthPct = 0 }()
+
+			err := s.autoTrader.checkOrderBookDepth("BTCUSDT", tt.side, tt.size, tt.depth)
+			if tt.wantErr {
+				s.Error(err)
+				return
+			}
+			s.NoError(err)
+		})
+	}
+}
@@ -0,0 +1,363 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/decision"
+	"nofx/market"
+	"strings"
+	"time"
+)
+
+// 决策触发类型
+const (
+	TriggerScheduled     = "scheduled"      // 定时周期
+	TriggerPriceMove     = "price_move"     // 持仓价格相对上次决策变化超过百分比
+	TriggerATRMove       = "atr_move"       // 持仓价格相对上次决策变化超过ATR倍数
+	TriggerStopFilled    = "stop_filled"    // 持仓被交易所平仓（止损/止盈/强平）
+	TriggerMarketAlert   = "market_alert"   // 持仓币种的市场警报
+	TriggerFundingChange = "funding_change" // 持仓币种资金费率大幅变化
+)
+
+const (
+	// eventCheckInterval 持仓事件（价格、止损成交、资金费率）检查间隔
+	eventCheckInterval = 15 * time.Second
+	// maxPendingTriggers 两次决策之间最多保留的触发事件数量
+	maxPendingTriggers = 20
+)
+
+// EventTriggerConfig 事件触发决策配置（按交易员配置，存储在 traders.event_triggers）
+// 事件发生后等待 debounce_seconds 合并随后的事件，再立即执行一次决策；两次决策间隔不少于 min_interval_seconds
+type EventTriggerConfig struct {
+	Enabled          bool    `json:"enabled"`
+	PriceMovePct     float64 `json:"price_move_pct"`     // 持仓价格相对上次决策变化超过该百分比时触发（0=不检查）
+	ATRMultiple      float64 `json:"atr_multiple"`       // 持仓价格相对上次决策变化超过N倍ATR14（长周期）时触发（0=不检查）
+	StopFilled       bool    `json:"stop_filled"`        // 持仓被止损/止盈/强平时触发
+	FundingChangeBps float64 `json:"funding_change_bps"` // 持仓币种资金费率相对上次决策变化超过N个基点时触发（0=不检查）
+
+	DebounceSeconds    int `json:"debounce_seconds"`     // 首个事件后等待合并后续事件的秒数
+	MinIntervalSeconds int `json:"min_interval_seconds"` // 两次决策的最短间隔秒数（限制最大决策频率）
+}
+
+// DefaultEventTriggers 默认配置：不启用持仓事件触发（市场警报触发仍由 alert_triggers 控制）
+func DefaultEventTriggers() EventTriggerConfig {
+	return EventTriggerConfig{
+		Enabled:            false,
+		PriceMovePct:       2.0,
+		StopFilled:         true,
+		DebounceSeconds:    5,
+		MinIntervalSeconds: 60,
+	}
+}
+
+// ParseEventTriggers 解析数据库中存储的配置（空字符串使用默认配置）
+func ParseEventTriggers(raw string) (EventTriggerConfig, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return DefaultEventTriggers(), nil
+	}
+
+	var cfg EventTriggerConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return EventTriggerConfig{}, fmt.Errorf("解析事件触发配置失败: %w", err)
+	}
+	if err := ValidateEventTriggers(&cfg); err != nil {
+		return EventTriggerConfig{}, err
+	}
+	return cfg, nil
+}
+
+// ValidateEventTriggers 校验配置并补全默认参数
+func ValidateEventTriggers(cfg *EventTriggerConfig) error {
+	if cfg.PriceMovePct < 0 || cfg.ATRMultiple < 0 || cfg.FundingChangeBps < 0 {
+		return fmt.Errorf("事件触发参数无效: price_move_pct、atr_multiple、funding_change_bps 不能为负")
+	}
+	if cfg.DebounceSeconds < 0 || cfg.MinIntervalSeconds < 0 {
+		return fmt.Errorf("事件触发参数无效: debounce_seconds、min_interval_seconds 不能为负")
+	}
+	if cfg.DebounceSeconds == 0 {
+		cfg.DebounceSeconds = 5
+	}
+	if cfg.MinIntervalSeconds == 0 {
+		cfg.MinIntervalSeconds = 60
+	}
+	if cfg.Enabled && cfg.PriceMovePct == 0 && cfg.ATRMultiple == 0 && !cfg.StopFilled && cfg.FundingChangeBps == 0 {
+		return fmt.Errorf("事件触发已启用但未配置任何触发条件")
+	}
+	return nil
+}
+
+// cycleTrigger 触发决策的事件
+type cycleTrigger struct {
+	Type   string // 见 Trigger* 常量
+	Detail string
+}
+
+func (t cycleTrigger) String() string {
+	return fmt.Sprintf("[%s] %s", t.Type, t.Detail)
+}
+
+// formatTriggers 决策记录中的触发说明（无事件时为定时周期）
+func formatTriggers(triggers []cycleTrigger) string {
+	if len(triggers) == 0 {
+		return TriggerScheduled
+	}
+	parts := make([]string, len(triggers))
+	for i, t := range triggers {
+		parts[i] = t.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// eventBaseline 上次决策时持仓的参考状态，同一事件在下次决策前只触发一次
+type eventBaseline struct {
+	Price        float64
+	ATR          float64
+	FundingRate  float64
+	priceFired   bool
+	fundingFired bool
+}
+
+// eventTriggerConfig 当前生效的事件触发配置
+func (at *AutoTrader) eventTriggerConfig() EventTriggerConfig {
+	if at.config.EventTriggers != nil {
+		return *at.config.EventTriggers
+	}
+	return DefaultEventTriggers()
+}
+
+// fireTrigger 通知主循环有事件发生（非阻塞，主循环未启动时忽略）
+func (at *AutoTrader) fireTrigger(trigger cycleTrigger) {
+	if at.triggerCh == nil {
+		return
+	}
+	select {
+	case at.triggerCh <- trigger:
+	default:
+		log.Printf("⚠️ 事件触发通道已满，丢弃 %s", trigger)
+	}
+}
+
+// queueTrigger 记录触发事件，返回距离执行额外决策还需等待的时间（去抖 + 最短决策间隔）
+func (at *AutoTrader) queueTrigger(trigger cycleTrigger) time.Duration {
+	cfg := at.eventTriggerConfig()
+
+	at.eventMutex.Lock()
+	defer at.eventMutex.Unlock()
+
+	duplicate := false
+	for _, t := range at.pendingTriggers {
+		if t == trigger {
+			duplicate = true
+			break
+		}
+	}
+	if !duplicate {
+		at.pendingTriggers = append(at.pendingTriggers, trigger)
+		if over := len(at.pendingTriggers) - maxPendingTriggers; over > 0 {
+			at.pendingTriggers = at.pendingTriggers[over:]
+		}
+	}
+
+	delay := time.Duration(cfg.DebounceSeconds) * time.Second
+	if wait := time.Until(at.lastCycleTime.Add(time.Duration(cfg.MinIntervalSeconds) * time.Second)); wait > delay {
+		delay = wait
+	}
+	return delay
+}
+
+// hasPendingTriggers 是否有尚未处理的触发事件（可能已并入定时周期）
+func (at *AutoTrader) hasPendingTriggers() bool {
+	at.eventMutex.Lock()
+	defer at.eventMutex.Unlock()
+	return len(at.pendingTriggers) > 0
+}
+
+// takeCycleTriggers 取出本次决策的触发事件，并记录决策时间
+func (at *AutoTrader) takeCycleTriggers() []cycleTrigger {
+	at.eventMutex.Lock()
+	defer at.eventMutex.Unlock()
+
+	triggers := at.pendingTriggers
+	at.pendingTriggers = nil
+	at.lastCycleTime = time.Now()
+	return triggers
+}
+
+// resetEventBaselines 以本次决策时的持仓价格、ATR和资金费率作为下次事件检查的参考
+func (at *AutoTrader) resetEventBaselines(ctx *decision.Context) {
+	baselines := make(map[string]*eventBaseline, len(ctx.Positions))
+	for _, pos := range ctx.Positions {
+		baseline := &eventBaseline{Price: pos.MarkPrice}
+		if data, ok := ctx.MarketDataMap[pos.Symbol]; ok && data != nil {
+			baseline.FundingRate = data.FundingRate
+			if data.LongerTermContext != nil && data.LongerTermContext.ATR14 > 0 {
+				baseline.ATR = data.LongerTermContext.ATR14
+			} else if data.IntradaySeries != nil {
+				baseline.ATR = data.IntradaySeries.ATR14
+			}
+		}
+		baselines[pos.Symbol+"_"+pos.Side] = baseline
+	}
+
+	at.eventMutex.Lock()
+	at.eventBaselines = baselines
+	at.eventMutex.Unlock()
+}
+
+// forgetEventPosition 持仓由本交易员平仓时移除事件状态，避免误判为止损成交
+func (at *AutoTrader) forgetEventPosition(posKey string) {
+	at.eventMutex.Lock()
+	defer at.eventMutex.Unlock()
+	delete(at.eventPositions, posKey)
+	delete(at.eventBaselines, posKey)
+}
+
+// startEventMonitor 启动持仓事件监控（价格变化、止损成交、资金费率变化）
+func (at *AutoTrader) startEventMonitor() {
+	at.monitorWg.Add(1)
+	go func() {
+		defer at.monitorWg.Done()
+
+		ticker := time.NewTicker(eventCheckInterval)
+		defer ticker.Stop()
+
+		log.Printf("⚡ 启动事件触发监控（每 %v 检查持仓）", eventCheckInterval)
+
+		for {
+			select {
+			case <-ticker.C:
+				at.checkEventTriggers()
+			case <-at.stopMonitorCh:
+				log.Println("⏹ 停止事件触发监控")
+				return
+			}
+		}
+	}()
+}
+
+// checkEventTriggers 检查持仓事件，满足条件时通知主循环
+func (at *AutoTrader) checkEventTriggers() {
+	cfg := at.eventTriggerConfig()
+	if !cfg.Enabled {
+		return
+	}
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("❌ 事件监控：获取持仓失败: %v", err)
+		return
+	}
+
+	type positionMark struct {
+		symbol    string
+		markPrice float64
+		baseline  *eventBaseline
+	}
+
+	var triggers []cycleTrigger
+	var marks []positionMark
+	current := make(map[string]bool)
+
+	at.eventMutex.Lock()
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		quantity, _ := pos["positionAmt"].(float64)
+		markPrice, _ := pos["markPrice"].(float64)
+		if symbol == "" || quantity == 0 {
+			continue
+		}
+		posKey := symbol + "_" + side
+		current[posKey] = true
+
+		baseline := at.eventBaselines[posKey]
+		if baseline == nil {
+			continue
+		}
+		if trigger, ok := checkPriceEvent(cfg, baseline, symbol, side, markPrice); ok {
+			baseline.priceFired = true
+			triggers = append(triggers, trigger)
+		}
+		if cfg.FundingChangeBps > 0 && !baseline.fundingFired {
+			marks = append(marks, positionMark{symbol: symbol, markPrice: markPrice, baseline: baseline})
+		}
+	}
+
+	// 上次检查时存在、现在消失且不是本交易员平仓的持仓，视为止损/止盈/强平成交
+	if cfg.StopFilled {
+		for posKey := range at.eventPositions {
+			if !current[posKey] {
+				triggers = append(triggers, cycleTrigger{
+					Type:   TriggerStopFilled,
+					Detail: fmt.Sprintf("%s 持仓已被交易所平仓（止损/止盈/强平）", strings.Replace(posKey, "_", " ", 1)),
+				})
+			}
+		}
+	}
+	at.eventPositions = current
+	at.eventMutex.Unlock()
+
+	// 资金费率需要请求数据源，不在锁内进行
+	for _, mark := range marks {
+		rate, err := at.marketDataProvider().GetFundingRate(mark.symbol)
+		if err != nil {
+			continue
+		}
+		if trigger, ok := checkFundingEvent(cfg, mark.baseline, mark.symbol, rate); ok {
+			at.eventMutex.Lock()
+			mark.baseline.fundingFired = true
+			at.eventMutex.Unlock()
+			triggers = append(triggers, trigger)
+		}
+	}
+
+	for _, trigger := range triggers {
+		at.fireTrigger(trigger)
+	}
+}
+
+// marketDataProvider 交易员使用的行情数据源（未设置时使用币安）
+func (at *AutoTrader) marketDataProvider() market.MarketDataProvider {
+	if at.marketProvider == nil {
+		return market.Binance
+	}
+	return at.marketProvider
+}
+
+// checkPriceEvent 持仓价格相对上次决策的变化是否达到百分比或ATR倍数阈值
+func checkPriceEvent(cfg EventTriggerConfig, baseline *eventBaseline, symbol, side string, markPrice float64) (cycleTrigger, bool) {
+	if baseline.priceFired || baseline.Price <= 0 || markPrice <= 0 {
+		return cycleTrigger{}, false
+	}
+	move := markPrice - baseline.Price
+	movePct := move / baseline.Price * 100
+
+	if cfg.PriceMovePct > 0 && math.Abs(movePct) >= cfg.PriceMovePct {
+		return cycleTrigger{
+			Type:   TriggerPriceMove,
+			Detail: fmt.Sprintf("%s %s 价格 %.4f → %.4f (%+.2f%%，阈值 ±%.2f%%)", symbol, side, baseline.Price, markPrice, movePct, cfg.PriceMovePct),
+		}, true
+	}
+	if cfg.ATRMultiple > 0 && baseline.ATR > 0 && math.Abs(move) >= cfg.ATRMultiple*baseline.ATR {
+		return cycleTrigger{
+			Type:   TriggerATRMove,
+			Detail: fmt.Sprintf("%s %s 价格 %.4f → %.4f (%+.2f ATR，阈值 %.2f ATR)", symbol, side, baseline.Price, markPrice, move/baseline.ATR, cfg.ATRMultiple),
+		}, true
+	}
+	return cycleTrigger{}, false
+}
+
+// checkFundingEvent 资金费率相对上次决策的变化是否达到阈值（基点）
+func checkFundingEvent(cfg EventTriggerConfig, baseline *eventBaseline, symbol string, rate float64) (cycleTrigger, bool) {
+	changeBps := (rate - baseline.FundingRate) * 10000
+	if cfg.FundingChangeBps <= 0 || math.Abs(changeBps) < cfg.FundingChangeBps {
+		return cycleTrigger{}, false
+	}
+	return cycleTrigger{
+		Type:   TriggerFundingChange,
+		Detail: fmt.Sprintf("%s 资金费率 %.4f%% → %.4f%% (%+.1f bps，阈值 %.1f bps)", symbol, baseline.FundingRate*100, rate*100, changeBps, cfg.FundingChangeBps),
+	}, true
+}
@@ -0,0 +1,147 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseEventTriggers 测试事件触发配置解析与校验
+func TestParseEventTriggers(t *testing.T) {
+	cfg, err := ParseEventTriggers("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultEventTriggers(), cfg)
+
+	cfg, err = ParseEventTriggers(`{"enabled":true,"atr_multiple":1.5}`)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, cfg.ATRMultiple)
+	assert.Equal(t, 5, cfg.DebounceSeconds)
+	assert.Equal(t, 60, cfg.MinIntervalSeconds)
+
+	_, err = ParseEventTriggers(`{"enabled":true}`)
+	assert.Error(t, err, "启用但未配置触发条件")
+	_, err = ParseEventTriggers(`{"price_move_pct":-1}`)
+	assert.Error(t, err)
+	_, err = ParseEventTriggers(`{"min_interval_seconds":-10}`)
+	assert.Error(t, err)
+	_, err = ParseEventTriggers(`{bad json`)
+	assert.Error(t, err)
+}
+
+// TestCheckPriceEvent 测试持仓价格事件
+func TestCheckPriceEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      EventTriggerConfig
+		baseline eventBaseline
+		price    float64
+		want     string
+	}{
+		{name: "未达百分比阈值", cfg: EventTriggerConfig{PriceMovePct: 2}, baseline: eventBaseline{Price: 100}, price: 101.5},
+		{name: "下跌达到百分比阈值", cfg: EventTriggerConfig{PriceMovePct: 2}, baseline: eventBaseline{Price: 100}, price: 97.9, want: TriggerPriceMove},
+		{name: "达到ATR倍数", cfg: EventTriggerConfig{ATRMultiple: 1.5}, baseline: eventBaseline{Price: 100, ATR: 1}, price: 101.6, want: TriggerATRMove},
+		{name: "无ATR数据", cfg: EventTriggerConfig{ATRMultiple: 1.5}, baseline: eventBaseline{Price: 100}, price: 110},
+		{name: "已触发过", cfg: EventTriggerConfig{PriceMovePct: 2}, baseline: eventBaseline{Price: 100, priceFired: true}, price: 110},
+		{name: "无标记价格", cfg: EventTriggerConfig{PriceMovePct: 2}, baseline: eventBaseline{Price: 100}, price: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger, ok := checkPriceEvent(tt.cfg, &tt.baseline, "BTCUSDT", "long", tt.price)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, trigger.Type)
+		})
+	}
+}
+
+// TestCheckFundingEvent 测试资金费率变化事件
+func TestCheckFundingEvent(t *testing.T) {
+	cfg := EventTriggerConfig{FundingChangeBps: 5}
+	baseline := &eventBaseline{FundingRate: 0.0001}
+
+	_, ok := checkFundingEvent(cfg, baseline, "BTCUSDT", 0.0004)
+	assert.False(t, ok)
+	trigger, ok := checkFundingEvent(cfg, baseline, "BTCUSDT", -0.0005)
+	assert.True(t, ok)
+	assert.Equal(t, TriggerFundingChange, trigger.Type)
+	_, ok = checkFundingEvent(EventTriggerConfig{}, baseline, "BTCUSDT", 0.01)
+	assert.False(t, ok)
+}
+
+// TestFormatTriggers 测试决策记录中的触发说明
+func TestFormatTriggers(t *testing.T) {
+	assert.Equal(t, TriggerScheduled, formatTriggers(nil))
+	assert.Equal(t, "[price_move] a; [stop_filled] b", formatTriggers([]cycleTrigger{
+		{Type: TriggerPriceMove, Detail: "a"},
+		{Type: TriggerStopFilled, Detail: "b"},
+	}))
+}
+
+// TestEventTriggerScheduling 测试事件去抖、去重与最短决策间隔
+func (s *AutoTraderTestSuite) TestEventTriggerScheduling() {
+	s.autoTrader.config.EventTriggers = &EventTriggerConfig{Enabled: true, PriceMovePct: 2, DebounceSeconds: 5, MinIntervalSeconds: 60}
+	defer func() { s.autoTrader.config.EventTriggers = nil }()
+
+	trigger := cycleTrigger{Type: TriggerPriceMove, Detail: "BTCUSDT"}
+
+	// 距上次决策已超过最短间隔：只等待去抖时间
+	s.autoTrader.lastCycleTime = time.Now().Add(-10 * time.Minute)
+	s.Equal(5*time.Second, s.autoTrader.queueTrigger(trigger))
+	s.autoTrader.queueTrigger(trigger)
+	s.True(s.autoTrader.hasPendingTriggers())
+
+	triggers := s.autoTrader.takeCycleTriggers()
+	s.Len(triggers, 1, "相同事件只记录一次")
+	s.False(s.autoTrader.hasPendingTriggers())
+
+	// 刚执行过决策：等待到最短间隔结束
+	delay := s.autoTrader.queueTrigger(trigger)
+	s.Greater(delay, 55*time.Second)
+	s.LessOrEqual(delay, 60*time.Second)
+	s.autoTrader.takeCycleTriggers()
+}
+
+// TestCheckEventTriggers 测试持仓价格变化和止损成交的检测
+func (s *AutoTraderTestSuite) TestCheckEventTriggers() {
+	s.autoTrader.config.EventTriggers = &EventTriggerConfig{Enabled: true, PriceMovePct: 2, StopFilled: true}
+	s.autoTrader.triggerCh = make(chan cycleTrigger, 10)
+	defer func() {
+		s.autoTrader.config.EventTriggers = nil
+		s.autoTrader.triggerCh = nil
+		s.autoTrader.eventBaselines = nil
+		s.autoTrader.eventPositions = nil
+		s.mockTrader.positions = []map[string]interface{}{}
+	}()
+
+	position := func(symbol, side string, markPrice float64) map[string]interface{} {
+		return map[string]interface{}{"symbol": symbol, "side": side, "positionAmt": 1.0, "markPrice": markPrice}
+	}
+	s.autoTrader.eventBaselines = map[string]*eventBaseline{
+		"BTCUSDT_long":  {Price: 50000},
+		"ETHUSDT_short": {Price: 3000},
+	}
+	s.mockTrader.positions = []map[string]interface{}{
+		position("BTCUSDT", "long", 50500),
+		position("ETHUSDT", "short", 3000),
+		position("SOLUSDT", "long", 100),
+	}
+
+	s.autoTrader.checkEventTriggers()
+	s.Len(s.autoTrader.triggerCh, 0, "价格变化未达阈值")
+
+	// BTC 上涨 3%，ETH 持仓被交易所平仓，SOL 由交易员自己平仓
+	s.mockTrader.positions = []map[string]interface{}{position("BTCUSDT", "long", 51500)}
+	s.autoTrader.clearProtectionState("SOLUSDT", "long")
+	s.autoTrader.checkEventTriggers()
+
+	var types []string
+	for len(s.autoTrader.triggerCh) > 0 {
+		types = append(types, (<-s.autoTrader.triggerCh).Type)
+	}
+	s.ElementsMatch([]string{TriggerPriceMove, TriggerStopFilled}, types)
+
+	// 同一价格事件在下次决策前不重复触发
+	s.autoTrader.checkEventTriggers()
+	s.Len(s.autoTrader.triggerCh, 0)
+}
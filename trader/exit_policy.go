@@ -0,0 +1,579 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/logger"
+	"nofx/market"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 利润保护策略类型
+const (
+	ExitPolicyDrawdown        = "drawdown"         // 盈利回撤平仓（收益超过阈值后，从峰值回撤一定比例即平仓）
+	ExitPolicyBreakEven       = "break_even"       // 保本止损（浮盈达到X倍风险R后，止损移至开仓价）
+	ExitPolicySteppedTrailing = "stepped_trailing" // 阶梯移动止损（价格每达到一个台阶，锁定对应利润）
+	ExitPolicyTimeExit        = "time_exit"        // 时间止盈/止损（持仓超过N小时平仓）
+	ExitPolicyATRChandelier   = "atr_chandelier"   // ATR吊灯止损（持仓期间极值回撤N倍ATR即平仓）
+)
+
+// ExitPolicy 持仓利润保护策略（按交易员配置，存储在 traders.exit_policies）
+type ExitPolicy struct {
+	Type string `json:"type"` // 策略类型，见 ExitPolicy* 常量
+
+	// drawdown: 收益率（含杠杆）超过 ActivationPnLPct 后，从峰值回撤 GivebackPct% 即平仓
+	ActivationPnLPct float64 `json:"activation_pnl_pct,omitempty"`
+	GivebackPct      float64 `json:"giveback_pct,omitempty"`
+
+	// break_even: 浮盈达到 TriggerR 倍初始风险后，止损移至开仓价（OffsetPct 为覆盖手续费的额外偏移，%）
+	TriggerR  float64 `json:"trigger_r,omitempty"`
+	OffsetPct float64 `json:"offset_pct,omitempty"`
+
+	// stepped_trailing: 价格相对开仓价的涨跌幅（%，不含杠杆）达到 ProfitPct 时，止损移至锁定 LockPct 利润的位置
+	Steps []TrailingStep `json:"steps,omitempty"`
+
+	// time_exit: 持仓超过 MaxHoldHours 小时平仓
+	MaxHoldHours float64 `json:"max_hold_hours,omitempty"`
+
+	// atr_chandelier: 多单价格跌破持仓期间最高价 - ATRMultiplier×ATR 即平仓（空单对称）
+	ATRPeriod     int     `json:"atr_period,omitempty"`
+	ATRMultiplier float64 `json:"atr_multiplier,omitempty"`
+	Timeframe     string  `json:"timeframe,omitempty"` // 计算ATR使用的K线周期，默认 3m
+}
+
+// TrailingStep 阶梯移动止损的一个台阶
+type TrailingStep struct {
+	ProfitPct float64 `json:"profit_pct"` // 触发台阶的价格涨跌幅（%，不含杠杆）
+	LockPct   float64 `json:"lock_pct"`   // 锁定的价格利润（%，不含杠杆，0=保本）
+}
+
+// DefaultExitPolicies 默认利润保护策略：收益超过5%后回撤40%平仓
+func DefaultExitPolicies() []ExitPolicy {
+	return []ExitPolicy{
+		{Type: ExitPolicyDrawdown, ActivationPnLPct: 5.0, GivebackPct: 40.0},
+	}
+}
+
+// ParseExitPolicies 解析数据库中存储的策略配置（空字符串使用默认策略）
+func ParseExitPolicies(raw string) ([]ExitPolicy, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return DefaultExitPolicies(), nil
+	}
+
+	var policies []ExitPolicy
+	if err := json.Unmarshal([]byte(raw), &policies); err != nil {
+		return nil, fmt.Errorf("解析利润保护策略失败: %w", err)
+	}
+	if err := ValidateExitPolicies(policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// ValidateExitPolicies 校验策略配置并补全默认参数
+func ValidateExitPolicies(policies []ExitPolicy) error {
+	for i := range policies {
+		p := &policies[i]
+		switch p.Type {
+		case ExitPolicyDrawdown:
+			if p.ActivationPnLPct <= 0 || p.GivebackPct <= 0 || p.GivebackPct > 100 {
+				return fmt.Errorf("drawdown 策略参数无效: activation_pnl_pct 必须>0，giveback_pct 必须在 (0, 100] 之间")
+			}
+		case ExitPolicyBreakEven:
+			if p.TriggerR <= 0 {
+				return fmt.Errorf("break_even 策略参数无效: trigger_r 必须>0")
+			}
+			if p.OffsetPct < 0 {
+				return fmt.Errorf("break_even 策略参数无效: offset_pct 不能为负")
+			}
+		case ExitPolicySteppedTrailing:
+			if len(p.Steps) == 0 {
+				return fmt.Errorf("stepped_trailing 策略至少需要一个台阶")
+			}
+			for _, step := range p.Steps {
+				if step.ProfitPct <= 0 || step.LockPct < 0 || step.LockPct >= step.ProfitPct {
+					return fmt.Errorf("stepped_trailing 台阶无效: profit_pct 必须>0，且 0 <= lock_pct < profit_pct")
+				}
+			}
+			sort.Slice(p.Steps, func(a, b int) bool { return p.Steps[a].ProfitPct < p.Steps[b].ProfitPct })
+		case ExitPolicyTimeExit:
+			if p.MaxHoldHours <= 0 {
+				return fmt.Errorf("time_exit 策略参数无效: max_hold_hours 必须>0")
+			}
+		case ExitPolicyATRChandelier:
+			if p.ATRMultiplier <= 0 {
+				return fmt.Errorf("atr_chandelier 策略参数无效: atr_multiplier 必须>0")
+			}
+			if p.ATRPeriod <= 0 {
+				p.ATRPeriod = 14
+			}
+			if p.Timeframe == "" {
+				p.Timeframe = "3m"
+			}
+			if _, ok := market.IntervalDuration(p.Timeframe); !ok {
+				return fmt.Errorf("atr_chandelier 策略参数无效: 不支持的K线周期 %s", p.Timeframe)
+			}
+		default:
+			return fmt.Errorf("未知的利润保护策略类型: %s", p.Type)
+		}
+	}
+	return nil
+}
+
+// exitPositionState 持仓监控（利润保护、强平保护）跟踪的持仓状态
+type exitPositionState struct {
+	Symbol               string
+	Side                 string
+	EntryPrice           float64
+	Quantity             float64
+	Leverage             int                // 0 表示未知，依赖杠杆的策略会被跳过
+	InitialStop          float64            // 初始止损价（用于计算风险R）
+	CurrentStop          float64            // 当前止损价
+	OpenedAt             time.Time          // 开仓时间（取自决策日志，找不到时为首次观察到持仓的时间）
+	ExtremePx            float64            // 持仓期间最有利价格（多单最高价/空单最低价）
+	ATR                  map[string]float64 // 吊灯止损、强平保护使用的ATR（key 见 atrCacheKey，定期刷新）
+	LiquidationPrice     float64            // 交易所返回的强平价（0 表示未知）
+	LastDeleverageAt     time.Time          // 最近一次强平保护自动降风险的时间
+	DeleverageCount      int                // 强平保护已自动降风险的次数
+	DeleverageCapAlerted bool               // 降风险次数用完后是否已告警（只告警一次）
+}
+
+// atrCacheKey ATR缓存的key
+func atrCacheKey(timeframe string, period int) string {
+	return fmt.Sprintf("%s:%d", timeframe, period)
+}
+
+// atrKey 吊灯止损ATR缓存的key
+func (p ExitPolicy) atrKey() string {
+	return atrCacheKey(p.Timeframe, p.ATRPeriod)
+}
+
+// exitAction 策略评估结果
+type exitAction struct {
+	Close   bool    // 是否平仓
+	NewStop float64 // 新止损价（0=不调整）
+	Policy  string  // 触发的策略
+	Reason  string  // 触发原因
+}
+
+// movePct 价格相对开仓价的有利变动百分比（不含杠杆）
+func (s *exitPositionState) movePct(price float64) float64 {
+	if s.EntryPrice <= 0 {
+		return 0
+	}
+	if s.Side == "long" {
+		return (price - s.EntryPrice) / s.EntryPrice * 100
+	}
+	return (s.EntryPrice - price) / s.EntryPrice * 100
+}
+
+// isTighter 判断新止损是否比当前止损更紧（更靠近/越过开仓价）
+func (s *exitPositionState) isTighter(stop float64) bool {
+	if s.CurrentStop <= 0 {
+		return true
+	}
+	if s.Side == "long" {
+		return stop > s.CurrentStop
+	}
+	return stop < s.CurrentStop
+}
+
+// lockStop 计算锁定 lockPct% 价格利润的止损价
+func (s *exitPositionState) lockStop(lockPct float64) float64 {
+	if s.Side == "long" {
+		return s.EntryPrice * (1 + lockPct/100)
+	}
+	return s.EntryPrice * (1 - lockPct/100)
+}
+
+// evaluateExitPolicies 根据最新价格评估所有策略
+// peakPnLPct 为该持仓的历史最高收益率（含杠杆，drawdown 策略使用）
+func evaluateExitPolicies(policies []ExitPolicy, state *exitPositionState, price, peakPnLPct float64, now time.Time) exitAction {
+	var result exitAction
+	movePct := state.movePct(price)
+
+	proposeStop := func(stop float64, policy, reason string) {
+		// 止损必须位于当前价格的保护侧
+		if (state.Side == "long" && stop >= price) || (state.Side == "short" && stop <= price) {
+			return
+		}
+		if !state.isTighter(stop) {
+			return
+		}
+		if result.NewStop > 0 {
+			if (state.Side == "long" && stop <= result.NewStop) || (state.Side == "short" && stop >= result.NewStop) {
+				return
+			}
+		}
+		result.NewStop = stop
+		result.Policy = policy
+		result.Reason = reason
+	}
+
+	for _, p := range policies {
+		switch p.Type {
+		case ExitPolicyDrawdown:
+			if state.Leverage <= 0 {
+				continue
+			}
+			currentPnLPct := movePct * float64(state.Leverage)
+			var drawdownPct float64
+			if peakPnLPct > 0 && currentPnLPct < peakPnLPct {
+				drawdownPct = (peakPnLPct - currentPnLPct) / peakPnLPct * 100
+			}
+			if currentPnLPct > p.ActivationPnLPct && drawdownPct >= p.GivebackPct {
+				return exitAction{Close: true, Policy: p.Type,
+					Reason: fmt.Sprintf("当前收益 %.2f%% | 最高收益 %.2f%% | 回撤 %.2f%%", currentPnLPct, peakPnLPct, drawdownPct)}
+			}
+
+		case ExitPolicyTimeExit:
+			if !state.OpenedAt.IsZero() && now.Sub(state.OpenedAt) >= time.Duration(p.MaxHoldHours*float64(time.Hour)) {
+				return exitAction{Close: true, Policy: p.Type,
+					Reason: fmt.Sprintf("持仓时长 %.1f 小时，超过上限 %.1f 小时", now.Sub(state.OpenedAt).Hours(), p.MaxHoldHours)}
+			}
+
+		case ExitPolicyATRChandelier:
+			atr := state.ATR[p.atrKey()]
+			if atr <= 0 || state.ExtremePx <= 0 {
+				continue
+			}
+			if state.Side == "long" {
+				exitPx := state.ExtremePx - p.ATRMultiplier*atr
+				if price <= exitPx {
+					return exitAction{Close: true, Policy: p.Type,
+						Reason: fmt.Sprintf("价格 %.4f 跌破吊灯止损 %.4f（最高 %.4f - %.1f×ATR %.4f）", price, exitPx, state.ExtremePx, p.ATRMultiplier, atr)}
+				}
+			} else {
+				exitPx := state.ExtremePx + p.ATRMultiplier*atr
+				if price >= exitPx {
+					return exitAction{Close: true, Policy: p.Type,
+						Reason: fmt.Sprintf("价格 %.4f 突破吊灯止损 %.4f（最低 %.4f + %.1f×ATR %.4f）", price, exitPx, state.ExtremePx, p.ATRMultiplier, atr)}
+				}
+			}
+
+		case ExitPolicyBreakEven:
+			if state.InitialStop <= 0 {
+				continue
+			}
+			risk := state.EntryPrice - state.InitialStop
+			if state.Side == "short" {
+				risk = state.InitialStop - state.EntryPrice
+			}
+			if risk <= 0 {
+				continue
+			}
+			reward := movePct / 100 * state.EntryPrice
+			if reward >= p.TriggerR*risk {
+				proposeStop(state.lockStop(p.OffsetPct), p.Type,
+					fmt.Sprintf("浮盈达到 %.2fR，止损移至保本", reward/risk))
+			}
+
+		case ExitPolicySteppedTrailing:
+			// 从最高台阶开始查找，已锁定的台阶会因不比当前止损更紧而被忽略
+			for i := len(p.Steps) - 1; i >= 0; i-- {
+				step := p.Steps[i]
+				if movePct >= step.ProfitPct {
+					proposeStop(state.lockStop(step.LockPct), p.Type,
+						fmt.Sprintf("价格涨幅 %.2f%% 达到第%d级台阶，锁定 %.2f%% 利润", movePct, i+1, step.LockPct))
+					break
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// exitPolicyRefreshInterval 利润保护监控同步持仓的间隔
+const exitPolicyRefreshInterval = 30 * time.Second
+
+// priceTick WebSocket实时价格
+type priceTick struct {
+	symbol string
+	price  float64
+}
+
+// exitPolicies 当前交易员生效的利润保护策略
+func (at *AutoTrader) exitPolicies() []ExitPolicy {
+	if len(at.config.ExitPolicies) == 0 {
+		return DefaultExitPolicies()
+	}
+	return at.config.ExitPolicies
+}
+
+// positionLeverage 获取持仓杠杆；交易所未返回时使用交易员配置的杠杆，均缺失时返回0
+func (at *AutoTrader) positionLeverage(pos map[string]interface{}) int {
+	if lev, ok := pos["leverage"].(float64); ok && lev > 0 {
+		return int(lev)
+	}
+	symbol, _ := pos["symbol"].(string)
+	if market.IsBTCETH(symbol) {
+		return at.config.BTCETHLeverage
+	}
+	return at.config.AltcoinLeverage
+}
+
+// syncExitState 根据交易所持仓同步利润保护状态，返回nil表示持仓无效
+func (at *AutoTrader) syncExitState(pos map[string]interface{}) *exitPositionState {
+	symbol, _ := pos["symbol"].(string)
+	side, _ := pos["side"].(string)
+	entryPrice, _ := pos["entryPrice"].(float64)
+	quantity, _ := pos["positionAmt"].(float64)
+	quantity = math.Abs(quantity)
+	if symbol == "" || quantity == 0 || entryPrice <= 0 {
+		return nil
+	}
+
+	posKey := symbol + "_" + side
+
+	at.exitMutex.Lock()
+	if at.exitStates == nil {
+		at.exitStates = make(map[string]*exitPositionState)
+	}
+	state, exists := at.exitStates[posKey]
+	if !exists {
+		state = &exitPositionState{
+			Symbol:    symbol,
+			Side:      side,
+			ExtremePx: entryPrice,
+			ATR:       make(map[string]float64),
+		}
+		at.exitStates[posKey] = state
+	}
+	at.exitMutex.Unlock()
+
+	if !exists {
+		// 重启后或监控启动前已有的持仓，持仓时长从决策日志中的开仓时间起算
+		if openedAt, ok := at.lastOpenTime(symbol, side); ok {
+			state.OpenedAt = openedAt
+		} else {
+			state.OpenedAt = time.Now()
+			log.Printf("⚠ 利润保护：决策日志中找不到 %s %s 的开仓记录，持仓时长从现在起算", symbol, side)
+		}
+	}
+
+	state.EntryPrice = entryPrice
+	state.Quantity = quantity
+	state.Leverage = at.positionLeverage(pos)
+	state.LiquidationPrice, _ = pos["liquidationPrice"].(float64)
+	if !exists && state.Leverage <= 0 {
+		log.Printf("⚠ 利润保护：%s %s 无法获取杠杆，依赖收益率的策略将被跳过", symbol, side)
+	}
+
+	// 止损价以最近一次决策（或策略调整）为准
+	if stopLoss, ok := at.lastKnownStopLoss(symbol, side); ok {
+		if state.InitialStop <= 0 {
+			state.InitialStop = stopLoss
+		}
+		state.CurrentStop = stopLoss
+	}
+
+	at.refreshStateATR(state)
+
+	return state
+}
+
+// lastOpenTime 从决策日志回溯持仓的开仓时间：最近一次成功的开仓、加仓或反手到该方向的操作
+// 更早的平仓或反手离开该方向说明那是已平掉的旧持仓
+func (at *AutoTrader) lastOpenTime(symbol, side string) (time.Time, bool) {
+	if at.decisionLogger == nil {
+		return time.Time{}, false
+	}
+	records, err := at.decisionLogger.GetLatestRecords(stopLossLookbackRecords)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// 记录按时间正序排列，从最新的开始回溯
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		for j := len(record.Decisions) - 1; j >= 0; j-- {
+			action := record.Decisions[j]
+			if action.Symbol != symbol || !action.Success {
+				continue
+			}
+			openedAt := action.Timestamp
+			if openedAt.IsZero() {
+				openedAt = record.Timestamp
+			}
+			switch action.Action {
+			case "open_" + side:
+				return openedAt, true
+			case "add_to_position":
+				if action.Side == "" || action.Side == side {
+					return openedAt, true
+				}
+			case "reverse":
+				if action.Side == side {
+					return openedAt, true
+				}
+				return time.Time{}, false
+			case "close_" + side:
+				return time.Time{}, false
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// refreshStateATR 刷新吊灯止损和强平保护需要的ATR
+func (at *AutoTrader) refreshStateATR(state *exitPositionState) {
+	if !market.Available(at.marketProvider) {
+		return
+	}
+
+	type atrParam struct {
+		timeframe string
+		period    int
+	}
+	var params []atrParam
+	for _, p := range at.exitPolicies() {
+		if p.Type == ExitPolicyATRChandelier {
+			params = append(params, atrParam{p.Timeframe, p.ATRPeriod})
+		}
+	}
+	if guard := at.liquidationGuard(); guard.Enabled && guard.Mode == LiquidationModeATR {
+		params = append(params, atrParam{guard.Timeframe, guard.ATRPeriod})
+	}
+
+	refreshed := make(map[string]bool)
+	for _, p := range params {
+		key := atrCacheKey(p.timeframe, p.period)
+		if refreshed[key] {
+			continue
+		}
+		refreshed[key] = true
+		atr, err := market.GetATR(at.marketProvider, state.Symbol, p.timeframe, p.period)
+		if err != nil {
+			log.Printf("⚠ 持仓监控：获取 %s ATR失败: %v", state.Symbol, err)
+			continue
+		}
+		state.ATR[key] = atr
+	}
+}
+
+// hasExitState 是否存在该币种的持仓状态（用于过滤实时价格推送）
+func (at *AutoTrader) hasExitState(symbol string) bool {
+	at.exitMutex.Lock()
+	defer at.exitMutex.Unlock()
+
+	_, long := at.exitStates[symbol+"_long"]
+	_, short := at.exitStates[symbol+"_short"]
+	return long || short
+}
+
+// onPriceTick 处理实时价格推送
+func (at *AutoTrader) onPriceTick(symbol string, price float64) {
+	for _, side := range []string{"long", "short"} {
+		at.exitMutex.Lock()
+		state, exists := at.exitStates[symbol+"_"+side]
+		at.exitMutex.Unlock()
+		if exists {
+			at.evaluateExitState(state, price)
+		}
+	}
+}
+
+// evaluateExitState 按最新价格评估持仓的利润保护策略并执行
+func (at *AutoTrader) evaluateExitState(state *exitPositionState, price float64) {
+	if (state.Side == "long" && price > state.ExtremePx) || (state.Side == "short" && (price < state.ExtremePx || state.ExtremePx == 0)) {
+		state.ExtremePx = price
+	}
+
+	// 更新峰值收益（含杠杆），drawdown 策略使用更新前的峰值
+	peakPnLPct := 0.0
+	if state.Leverage > 0 {
+		currentPnLPct := state.movePct(price) * float64(state.Leverage)
+		posKey := state.Symbol + "_" + state.Side
+		at.peakPnLCacheMutex.RLock()
+		peak, exists := at.peakPnLCache[posKey]
+		at.peakPnLCacheMutex.RUnlock()
+		if !exists {
+			peak = currentPnLPct
+		}
+		peakPnLPct = peak
+		at.UpdatePeakPnL(state.Symbol, state.Side, currentPnLPct)
+	}
+
+	action := evaluateExitPolicies(at.exitPolicies(), state, price, peakPnLPct, time.Now())
+	switch {
+	case action.Close:
+		at.executeExitClose(state, price, action)
+		return
+	case action.NewStop > 0:
+		at.executeExitStopMove(state, price, action)
+	}
+
+	at.checkLiquidationDistance(state, price)
+}
+
+// executeExitClose 执行策略触发的平仓
+func (at *AutoTrader) executeExitClose(state *exitPositionState, price float64, action exitAction) {
+	log.Printf("🚨 触发利润保护平仓 [%s]: %s %s | %s", action.Policy, state.Symbol, state.Side, action.Reason)
+
+	actionRecord := logger.DecisionAction{
+		Action:    "auto_close_" + state.Side,
+		Symbol:    state.Symbol,
+		Quantity:  state.Quantity,
+		Leverage:  state.Leverage,
+		Price:     price,
+		Timestamp: time.Now(),
+	}
+
+	if err := at.emergencyClosePosition(state.Symbol, state.Side); err != nil {
+		log.Printf("❌ 利润保护平仓失败 (%s %s): %v", state.Symbol, state.Side, err)
+		actionRecord.Error = err.Error()
+	} else {
+		log.Printf("✅ 利润保护平仓成功: %s %s", state.Symbol, state.Side)
+		actionRecord.Success = true
+		// 平仓后清理该持仓的缓存
+		at.ClearPeakPnLCache(state.Symbol, state.Side)
+		at.clearProtectionState(state.Symbol, state.Side)
+	}
+
+	at.recordAutoAction(actionRecord, fmt.Sprintf("利润保护 [%s]: %s", action.Policy, action.Reason))
+}
+
+// executeExitStopMove 执行策略触发的止损调整（利润保护、强平保护共用）
+func (at *AutoTrader) executeExitStopMove(state *exitPositionState, price float64, action exitAction) {
+	log.Printf("🎯 自动调整止损 [%s]: %s %s → %.4f | %s", action.Policy, state.Symbol, state.Side, action.NewStop, action.Reason)
+
+	actionRecord := logger.DecisionAction{
+		Action:    "auto_update_stop_loss",
+		Symbol:    state.Symbol,
+		Quantity:  state.Quantity,
+		Price:     price,
+		Timestamp: time.Now(),
+	}
+
+	if err := at.trader.CancelStopLossOrders(state.Symbol); err != nil {
+		log.Printf("  ⚠ 取消旧止损单失败: %v", err)
+	}
+
+	if err := at.trader.SetStopLoss(state.Symbol, strings.ToUpper(state.Side), state.Quantity, action.NewStop); err != nil {
+		log.Printf("❌ 自动调整止损失败 (%s %s): %v", state.Symbol, state.Side, err)
+		actionRecord.Error = err.Error()
+		at.recordAutoAction(actionRecord, fmt.Sprintf("自动调整止损 [%s]: %s", action.Policy, action.Reason))
+		return
+	}
+
+	state.CurrentStop = action.NewStop
+	at.recordProtectionLevels(state.Symbol, state.Side, action.NewStop, 0)
+
+	// Hyperliquid 无法单独取消止损单，取消时止盈单也被撤掉，需要补挂
+	if at.exchange == "hyperliquid" {
+		if level, ok := at.getProtectionLevel(state.Symbol, state.Side); ok && level.TakeProfit > 0 {
+			if err := at.trader.SetTakeProfit(state.Symbol, strings.ToUpper(state.Side), state.Quantity, level.TakeProfit); err != nil {
+				log.Printf("  ⚠ 补挂止盈单失败: %v", err)
+			}
+		}
+	}
+
+	actionRecord.Success = true
+	at.recordAutoAction(actionRecord, fmt.Sprintf("自动调整止损 [%s]: %s，止损 → %.4f", action.Policy, action.Reason, action.NewStop))
+}
@@ -0,0 +1,296 @@
+package trader
+
+import (
+	"nofx/logger"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseExitPolicies 测试利润保护策略解析与校验
+func TestParseExitPolicies(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		wantErr   bool
+		wantTypes []string
+	}{
+		{name: "空配置_使用默认策略", raw: "", wantTypes: []string{ExitPolicyDrawdown}},
+		{name: "非法JSON", raw: "{", wantErr: true},
+		{name: "未知策略类型", raw: `[{"type":"moon"}]`, wantErr: true},
+		{name: "保本策略缺少触发R", raw: `[{"type":"break_even"}]`, wantErr: true},
+		{name: "台阶锁定利润不小于触发涨幅", raw: `[{"type":"stepped_trailing","steps":[{"profit_pct":2,"lock_pct":2}]}]`, wantErr: true},
+		{name: "吊灯止损不支持的周期", raw: `[{"type":"atr_chandelier","atr_multiplier":3,"timeframe":"7m"}]`, wantErr: true},
+		{name: "吊灯止损使用1小时周期", raw: `[{"type":"atr_chandelier","atr_multiplier":3,"timeframe":"1h"}]`, wantTypes: []string{ExitPolicyATRChandelier}},
+		{
+			name:      "组合策略",
+			raw:       `[{"type":"break_even","trigger_r":1},{"type":"time_exit","max_hold_hours":24},{"type":"atr_chandelier","atr_multiplier":3}]`,
+			wantTypes: []string{ExitPolicyBreakEven, ExitPolicyTimeExit, ExitPolicyATRChandelier},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies, err := ParseExitPolicies(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			var types []string
+			for _, p := range policies {
+				types = append(types, p.Type)
+			}
+			assert.Equal(t, tt.wantTypes, types)
+		})
+	}
+
+	t.Run("默认值与台阶排序", func(t *testing.T) {
+		policies, err := ParseExitPolicies(`[{"type":"atr_chandelier","atr_multiplier":3},{"type":"stepped_trailing","steps":[{"profit_pct":4,"lock_pct":2},{"profit_pct":2,"lock_pct":0}]}]`)
+		assert.NoError(t, err)
+		assert.Equal(t, 14, policies[0].ATRPeriod)
+		assert.Equal(t, "3m", policies[0].Timeframe)
+		assert.Equal(t, 2.0, policies[1].Steps[0].ProfitPct)
+		assert.Equal(t, 4.0, policies[1].Steps[1].ProfitPct)
+	})
+}
+
+// TestEvaluateExitPolicies 测试各类利润保护策略的评估结果
+func TestEvaluateExitPolicies(t *testing.T) {
+	now := time.Now()
+	newLong := func() *exitPositionState {
+		return &exitPositionState{
+			Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, Quantity: 1, Leverage: 10,
+			InitialStop: 95, CurrentStop: 95, OpenedAt: now.Add(-time.Hour), ExtremePx: 100,
+			ATR: map[string]float64{},
+		}
+	}
+	newShort := func() *exitPositionState {
+		return &exitPositionState{
+			Symbol: "ETHUSDT", Side: "short", EntryPrice: 100, Quantity: 1, Leverage: 10,
+			InitialStop: 105, CurrentStop: 105, OpenedAt: now.Add(-time.Hour), ExtremePx: 100,
+			ATR: map[string]float64{},
+		}
+	}
+	steps := []TrailingStep{{ProfitPct: 2, LockPct: 0.5}, {ProfitPct: 4, LockPct: 2}}
+
+	tests := []struct {
+		name       string
+		policies   []ExitPolicy
+		state      func() *exitPositionState
+		price      float64
+		peakPnLPct float64
+		wantClose  bool
+		wantStop   float64
+		wantPolicy string
+	}{
+		{
+			name:     "保本_未达到触发R",
+			policies: []ExitPolicy{{Type: ExitPolicyBreakEven, TriggerR: 1}},
+			state:    newLong, price: 104,
+		},
+		{
+			name:     "保本_多单达到1R",
+			policies: []ExitPolicy{{Type: ExitPolicyBreakEven, TriggerR: 1, OffsetPct: 0.1}},
+			state:    newLong, price: 105,
+			wantStop: 100.1, wantPolicy: ExitPolicyBreakEven,
+		},
+		{
+			name:     "保本_空单达到1R",
+			policies: []ExitPolicy{{Type: ExitPolicyBreakEven, TriggerR: 1}},
+			state:    newShort, price: 95,
+			wantStop: 100, wantPolicy: ExitPolicyBreakEven,
+		},
+		{
+			name:     "保本_止损已更紧_不调整",
+			policies: []ExitPolicy{{Type: ExitPolicyBreakEven, TriggerR: 1}},
+			state: func() *exitPositionState {
+				s := newLong()
+				s.CurrentStop = 101
+				return s
+			},
+			price: 106,
+		},
+		{
+			name:     "阶梯追踪_取最高已达台阶",
+			policies: []ExitPolicy{{Type: ExitPolicySteppedTrailing, Steps: steps}},
+			state:    newLong, price: 104.5,
+			wantStop: 102, wantPolicy: ExitPolicySteppedTrailing,
+		},
+		{
+			name:     "组合策略_取最紧止损",
+			policies: []ExitPolicy{{Type: ExitPolicyBreakEven, TriggerR: 1}, {Type: ExitPolicySteppedTrailing, Steps: steps}},
+			state:    newLong, price: 105,
+			wantStop: 102, wantPolicy: ExitPolicySteppedTrailing,
+		},
+		{
+			name:     "时间退出_超过持仓上限",
+			policies: []ExitPolicy{{Type: ExitPolicyTimeExit, MaxHoldHours: 0.5}},
+			state:    newLong, price: 101,
+			wantClose: true, wantPolicy: ExitPolicyTimeExit,
+		},
+		{
+			name:     "时间退出_未到期",
+			policies: []ExitPolicy{{Type: ExitPolicyTimeExit, MaxHoldHours: 2}},
+			state:    newLong, price: 101,
+		},
+		{
+			name:     "吊灯止损_多单跌破",
+			policies: []ExitPolicy{{Type: ExitPolicyATRChandelier, ATRMultiplier: 3, ATRPeriod: 14, Timeframe: "3m"}},
+			state: func() *exitPositionState {
+				s := newLong()
+				s.ExtremePx = 110
+				s.ATR["3m:14"] = 2
+				return s
+			},
+			price:     104,
+			wantClose: true, wantPolicy: ExitPolicyATRChandelier,
+		},
+		{
+			name:     "吊灯止损_空单未突破",
+			policies: []ExitPolicy{{Type: ExitPolicyATRChandelier, ATRMultiplier: 3, ATRPeriod: 14, Timeframe: "3m"}},
+			state: func() *exitPositionState {
+				s := newShort()
+				s.ExtremePx = 90
+				s.ATR["3m:14"] = 2
+				return s
+			},
+			price: 95,
+		},
+		{
+			name:     "吊灯止损_无ATR_跳过",
+			policies: []ExitPolicy{{Type: ExitPolicyATRChandelier, ATRMultiplier: 3, ATRPeriod: 14, Timeframe: "3m"}},
+			state: func() *exitPositionState {
+				s := newLong()
+				s.ExtremePx = 110
+				return s
+			},
+			price: 100,
+		},
+		{
+			name:     "回撤平仓_触发",
+			policies: DefaultExitPolicies(),
+			state:    newLong, price: 100.6, peakPnLPct: 10,
+			wantClose: true, wantPolicy: ExitPolicyDrawdown,
+		},
+		{
+			name:     "回撤平仓_杠杆未知_跳过",
+			policies: DefaultExitPolicies(),
+			state: func() *exitPositionState {
+				s := newLong()
+				s.Leverage = 0
+				return s
+			},
+			price: 100.6, peakPnLPct: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := evaluateExitPolicies(tt.policies, tt.state(), tt.price, tt.peakPnLPct, now)
+			assert.Equal(t, tt.wantClose, action.Close)
+			assert.InDelta(t, tt.wantStop, action.NewStop, 1e-9)
+			assert.Equal(t, tt.wantPolicy, action.Policy)
+		})
+	}
+}
+
+// TestEvaluateExitStateMovesStop 测试策略触发止损调整并记录 auto_update_stop_loss
+func (s *AutoTraderTestSuite) TestEvaluateExitStateMovesStop() {
+	s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+	s.autoTrader.config.ExitPolicies = []ExitPolicy{{Type: ExitPolicyBreakEven, TriggerR: 1}}
+	defer func() { s.autoTrader.config.ExitPolicies = nil }()
+
+	s.mockTrader.setStopLossCalls = 0
+	s.autoTrader.recordProtectionLevels("BTCUSDT", "long", 49000.0, 0)
+	defer s.autoTrader.clearProtectionState("BTCUSDT", "long")
+
+	state := s.autoTrader.syncExitState(map[string]interface{}{
+		"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1, "entryPrice": 50000.0, "markPrice": 50000.0, "leverage": 10.0,
+	})
+	s.Require().NotNil(state)
+	s.Equal(49000.0, state.InitialStop)
+
+	// 浮盈不足1R，不调整
+	s.autoTrader.evaluateExitState(state, 50500.0)
+	s.Equal(0, s.mockTrader.setStopLossCalls)
+
+	// 浮盈达到1R，止损移至保本
+	s.autoTrader.evaluateExitState(state, 51000.0)
+	s.Equal(1, s.mockTrader.setStopLossCalls)
+	s.Equal(50000.0, state.CurrentStop)
+
+	level, ok := s.autoTrader.getProtectionLevel("BTCUSDT", "long")
+	s.True(ok)
+	s.Equal(50000.0, level.StopLoss)
+
+	records, err := s.autoTrader.decisionLogger.GetLatestRecords(1)
+	s.NoError(err)
+	s.Require().Len(records, 1)
+	s.Require().Len(records[0].Decisions, 1)
+	s.Equal("auto_update_stop_loss", records[0].Decisions[0].Action)
+	s.True(records[0].Decisions[0].Success)
+
+	// 已保本，再次评估不重复调整
+	s.autoTrader.evaluateExitState(state, 51200.0)
+	s.Equal(1, s.mockTrader.setStopLossCalls)
+}
+
+// TestSyncExitStateOpenedAtAfterRestart 测试重启后持仓时长从决策日志中的开仓时间起算
+func (s *AutoTraderTestSuite) TestSyncExitStateOpenedAtAfterRestart() {
+	openedAt := time.Now().Add(-5 * time.Hour).Truncate(time.Second)
+	pos := map[string]interface{}{
+		"symbol": "SOLUSDT", "side": "long", "positionAmt": 10.0, "entryPrice": 100.0, "markPrice": 101.0, "leverage": 5.0,
+	}
+	timeExit := []ExitPolicy{{Type: ExitPolicyTimeExit, MaxHoldHours: 4}}
+
+	tests := []struct {
+		name       string
+		actions    []logger.DecisionAction
+		wantOpened bool // 是否采用日志中的开仓时间
+	}{
+		{
+			name:       "开仓记录",
+			actions:    []logger.DecisionAction{{Symbol: "SOLUSDT", Action: "open_long", Timestamp: openedAt, Success: true}},
+			wantOpened: true,
+		},
+		{
+			name:       "反手到当前方向",
+			actions:    []logger.DecisionAction{{Symbol: "SOLUSDT", Action: "reverse", Side: "long", Timestamp: openedAt, Success: true}},
+			wantOpened: true,
+		},
+		{
+			name: "开仓后已平仓",
+			actions: []logger.DecisionAction{
+				{Symbol: "SOLUSDT", Action: "open_long", Timestamp: openedAt, Success: true},
+				{Symbol: "SOLUSDT", Action: "close_long", Timestamp: openedAt.Add(time.Hour), Success: true},
+			},
+		},
+		{
+			name:    "开仓失败",
+			actions: []logger.DecisionAction{{Symbol: "SOLUSDT", Action: "open_long", Timestamp: openedAt, Success: false}},
+		},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+			s.Require().NoError(s.autoTrader.decisionLogger.LogDecision(&logger.DecisionRecord{Decisions: tt.actions, Success: true}))
+			// 模拟重启：内存中的持仓状态丢失
+			s.autoTrader.exitStates = nil
+			defer func() { s.autoTrader.exitStates = nil }()
+
+			state := s.autoTrader.syncExitState(pos)
+			s.Require().NotNil(state)
+			now := time.Now()
+			action := evaluateExitPolicies(timeExit, state, 101, 0, now)
+			if tt.wantOpened {
+				s.True(state.OpenedAt.Equal(openedAt), "OpenedAt = %v, want %v", state.OpenedAt, openedAt)
+				s.True(action.Close, "持仓超过4小时应触发时间平仓")
+			} else {
+				s.WithinDuration(now, state.OpenedAt, time.Minute)
+				s.False(action.Close)
+			}
+		})
+	}
+}
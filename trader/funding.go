@@ -0,0 +1,256 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+	"strings"
+	"time"
+)
+
+// 资金费率检查的处理方式
+const (
+	FundingGuardOff    = "off"    // 不检查
+	FundingGuardWarn   = "warn"   // 仅记录警告，仍然开仓
+	FundingGuardReject = "reject" // 拒绝开仓
+)
+
+// FundingGuardConfig 开仓资金费成本检查配置（按交易员配置，存储在 traders.funding_guard）
+type FundingGuardConfig struct {
+	Mode             string  `json:"mode"`               // 见 FundingGuard* 常量
+	MaxCostPct       float64 `json:"max_cost_pct"`       // 预计资金费占保证金的百分比上限，超过即视为不利
+	DefaultHoldHours float64 `json:"default_hold_hours"` // 决策未给出 hold_hours 时假定的持仓时长
+}
+
+// DefaultFundingGuard 默认配置：预计持仓24小时的资金费超过保证金5%时警告
+func DefaultFundingGuard() FundingGuardConfig {
+	return FundingGuardConfig{
+		Mode:             FundingGuardWarn,
+		MaxCostPct:       5.0,
+		DefaultHoldHours: 24.0,
+	}
+}
+
+// ParseFundingGuard 解析数据库中存储的配置（空字符串使用默认配置）
+func ParseFundingGuard(raw string) (FundingGuardConfig, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return DefaultFundingGuard(), nil
+	}
+
+	var cfg FundingGuardConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return FundingGuardConfig{}, fmt.Errorf("解析资金费检查配置失败: %w", err)
+	}
+	if err := ValidateFundingGuard(&cfg); err != nil {
+		return FundingGuardConfig{}, err
+	}
+	return cfg, nil
+}
+
+// ValidateFundingGuard 校验配置并补全默认参数
+func ValidateFundingGuard(cfg *FundingGuardConfig) error {
+	switch cfg.Mode {
+	case FundingGuardOff:
+		return nil
+	case "", FundingGuardWarn, FundingGuardReject:
+		if cfg.Mode == "" {
+			cfg.Mode = FundingGuardWarn
+		}
+	default:
+		return fmt.Errorf("未知的资金费检查方式: %s", cfg.Mode)
+	}
+
+	if cfg.MaxCostPct == 0 {
+		cfg.MaxCostPct = 5.0
+	}
+	if cfg.MaxCostPct < 0 {
+		return fmt.Errorf("资金费检查参数无效: max_cost_pct 不能为负")
+	}
+	if cfg.DefaultHoldHours == 0 {
+		cfg.DefaultHoldHours = 24.0
+	}
+	if cfg.DefaultHoldHours < 0 {
+		return fmt.Errorf("资金费检查参数无效: default_hold_hours 不能为负")
+	}
+	return nil
+}
+
+// expectedFundingCost 按当前费率估算持仓期间需支付的资金费（USDT，负数表示预计收取）
+// fundingRate 为折算后的8小时单期费率，intervalHours 为交易所实际结算间隔（0 表示8小时）；
+// 持仓期间经过的结算次数向上取整，按最坏情况估算。费率为正时多头支付、空头收取
+func expectedFundingCost(side string, fundingRate, intervalHours, notional, holdHours float64) float64 {
+	if intervalHours <= 0 {
+		intervalHours = market.DefaultFundingIntervalHours
+	}
+	settlements := math.Ceil(holdHours / intervalHours)
+	cost := notional * fundingRate * intervalHours / market.DefaultFundingIntervalHours * settlements
+	if side == "short" {
+		return -cost
+	}
+	return cost
+}
+
+// fundingGuard 当前交易员生效的资金费检查配置
+func (at *AutoTrader) fundingGuard() FundingGuardConfig {
+	if at.config.FundingGuard == nil {
+		return DefaultFundingGuard()
+	}
+	return *at.config.FundingGuard
+}
+
+// checkFundingCost 开仓前检查预计资金费成本，返回警告信息（reject 模式下返回错误）
+func (at *AutoTrader) checkFundingCost(d *decision.Decision, side string, fundingRate, intervalHours float64) (string, error) {
+	cfg := at.fundingGuard()
+	if cfg.Mode == FundingGuardOff || d.Leverage <= 0 || d.PositionSizeUSD <= 0 {
+		return "", nil
+	}
+
+	holdHours := d.HoldHours
+	if holdHours <= 0 {
+		holdHours = cfg.DefaultHoldHours
+	}
+	cost := expectedFundingCost(side, fundingRate, intervalHours, d.PositionSizeUSD, holdHours)
+	margin := d.PositionSizeUSD / float64(d.Leverage)
+	costPct := cost / margin * 100
+	if costPct <= cfg.MaxCostPct {
+		return "", nil
+	}
+
+	msg := fmt.Sprintf("资金费率不利: 当前费率 %.4f%%，预计持仓 %.0f 小时需支付资金费 %.2f USDT（占保证金 %.1f%%，上限 %.1f%%）",
+		fundingRate*100, holdHours, cost, costPct, cfg.MaxCostPct)
+	if cfg.Mode == FundingGuardReject {
+		return "", fmt.Errorf("❌ %s，拒绝开仓", msg)
+	}
+
+	log.Printf("  ⚠️ %s", msg)
+	return msg, nil
+}
+
+// syncFundingPayments 拉取上次同步以来结算的资金费，累计到对应持仓（symbol_side），并返回用于写入决策记录
+// 资金费流水不区分方向：只持有一个方向时全部归属该方向，双向持仓时按名义价值拆分
+func (at *AutoTrader) syncFundingPayments() []logger.FundingPayment {
+	at.fundingMutex.Lock()
+	defer at.fundingMutex.Unlock()
+
+	if at.lastFundingSync == 0 {
+		at.lastFundingSync = at.startTime.UnixMilli()
+	}
+	if at.positionFunding == nil {
+		at.positionFunding = make(map[string]float64)
+	}
+
+	payments, err := at.trader.GetFundingPayments(at.lastFundingSync + 1)
+	if err != nil {
+		log.Printf("⚠️ 获取资金费流水失败: %v", err)
+		return nil
+	}
+
+	var result []logger.FundingPayment
+	var notionals map[string]map[string]float64
+	latest := at.lastFundingSync
+	total := 0.0
+	for _, payment := range payments {
+		symbol, _ := payment["symbol"].(string)
+		amount, _ := payment["amount"].(float64)
+		paidAt, _ := payment["time"].(int64)
+		// 交易所按起始时间过滤，这里再去重一次，避免重复累计
+		if symbol == "" || paidAt <= at.lastFundingSync {
+			continue
+		}
+		if notionals == nil {
+			notionals = at.positionNotionals()
+		}
+
+		total += amount
+		if paidAt > latest {
+			latest = paidAt
+		}
+		for side, share := range splitFundingPayment(amount, notionals[symbol]) {
+			at.positionFunding[symbol+"_"+side] += share
+			result = append(result, logger.FundingPayment{
+				Symbol: symbol,
+				Side:   side,
+				Amount: share,
+				Time:   time.UnixMilli(paidAt),
+			})
+		}
+		if len(notionals[symbol]) == 0 {
+			// 已平仓，无法确定方向，由交易表现分析按当时的持仓归属
+			result = append(result, logger.FundingPayment{
+				Symbol: symbol,
+				Amount: amount,
+				Time:   time.UnixMilli(paidAt),
+			})
+		}
+	}
+	at.lastFundingSync = latest
+
+	if len(result) > 0 {
+		log.Printf("💸 同步资金费 %d 笔，合计 %+.4f USDT", len(result), total)
+	}
+	return result
+}
+
+// positionNotionals 当前持仓的名义价值（symbol -> side -> USDT），获取失败时返回空
+func (at *AutoTrader) positionNotionals() map[string]map[string]float64 {
+	notionals := make(map[string]map[string]float64)
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("⚠️ 资金费归属：获取持仓失败: %v", err)
+		return notionals
+	}
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		quantity, _ := pos["positionAmt"].(float64)
+		markPrice, _ := pos["markPrice"].(float64)
+		if symbol == "" || side == "" || quantity == 0 {
+			continue
+		}
+		if notionals[symbol] == nil {
+			notionals[symbol] = make(map[string]float64)
+		}
+		notionals[symbol][side] = math.Abs(quantity) * markPrice
+	}
+	return notionals
+}
+
+// splitFundingPayment 按各方向的名义价值拆分一笔资金费（名义价值未知时平均拆分）
+func splitFundingPayment(amount float64, sides map[string]float64) map[string]float64 {
+	shares := make(map[string]float64, len(sides))
+	total := 0.0
+	for _, notional := range sides {
+		total += notional
+	}
+	for side, notional := range sides {
+		if total > 0 {
+			shares[side] = amount * notional / total
+		} else {
+			shares[side] = amount / float64(len(sides))
+		}
+	}
+	return shares
+}
+
+// positionFundingFee 持仓期间已结算的资金费（正数为收取，负数为支付）
+func (at *AutoTrader) positionFundingFee(symbol, side string) float64 {
+	at.fundingMutex.Lock()
+	defer at.fundingMutex.Unlock()
+	return at.positionFunding[symbol+"_"+side]
+}
+
+// pruneFundingFees 清理已平仓持仓（symbol_side）的资金费累计
+func (at *AutoTrader) pruneFundingFees(heldPositions map[string]bool) {
+	at.fundingMutex.Lock()
+	defer at.fundingMutex.Unlock()
+	for posKey := range at.positionFunding {
+		if !heldPositions[posKey] {
+			delete(at.positionFunding, posKey)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package trader
+
+import (
+	"nofx/decision"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseFundingGuard 测试资金费检查配置解析与校验
+func TestParseFundingGuard(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+		want    FundingGuardConfig
+	}{
+		{name: "空配置_使用默认配置", raw: "", want: DefaultFundingGuard()},
+		{name: "非法JSON", raw: "{", wantErr: true},
+		{name: "未知检查方式", raw: `{"mode":"panic"}`, wantErr: true},
+		{name: "上限为负", raw: `{"mode":"reject","max_cost_pct":-1}`, wantErr: true},
+		{name: "关闭时不补全参数", raw: `{"mode":"off"}`, want: FundingGuardConfig{Mode: FundingGuardOff}},
+		{
+			name: "补全默认参数",
+			raw:  `{"mode":"reject","max_cost_pct":2}`,
+			want: FundingGuardConfig{Mode: FundingGuardReject, MaxCostPct: 2, DefaultHoldHours: 24},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseFundingGuard(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, cfg)
+		})
+	}
+}
+
+// TestExpectedFundingCost 测试持仓期间资金费成本估算
+func TestExpectedFundingCost(t *testing.T) {
+	tests := []struct {
+		name      string
+		side      string
+		rate      float64
+		interval  float64
+		notional  float64
+		holdHours float64
+		want      float64
+	}{
+		{name: "正费率_多头支付", side: "long", rate: 0.001, interval: 8, notional: 1000, holdHours: 24, want: 3},
+		{name: "正费率_空头收取", side: "short", rate: 0.001, interval: 8, notional: 1000, holdHours: 24, want: -3},
+		{name: "负费率_空头支付", side: "short", rate: -0.0005, interval: 8, notional: 2000, holdHours: 8, want: 1},
+		{name: "不足一个结算周期按一次结算估算", side: "long", rate: 0.001, interval: 8, notional: 1000, holdHours: 4, want: 1},
+		{name: "未知结算间隔按8小时", side: "long", rate: 0.001, notional: 1000, holdHours: 4, want: 1},
+		{name: "每小时结算按实际次数估算", side: "long", rate: 0.001, interval: 1, notional: 1000, holdHours: 4, want: 0.5},
+		{name: "每4小时结算", side: "long", rate: 0.001, interval: 4, notional: 1000, holdHours: 10, want: 1.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, expectedFundingCost(tt.side, tt.rate, tt.interval, tt.notional, tt.holdHours), 1e-9)
+		})
+	}
+}
+
+// TestCheckFundingCost 测试开仓前的资金费检查
+func (s *AutoTraderTestSuite) TestCheckFundingCost() {
+	// 1000U 仓位 10x 杠杆，保证金 100U；费率 0.1% 持仓24小时预计支付 3U（保证金的3%）
+	openLong := &decision.Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 10, PositionSizeUSD: 1000}
+
+	tests := []struct {
+		name        string
+		guard       *FundingGuardConfig
+		side        string
+		holdHours   float64
+		wantWarning bool
+		wantErr     bool
+	}{
+		{name: "默认配置_未超过上限", side: "long"},
+		{name: "持仓时间更长_超过上限警告", side: "long", holdHours: 48, wantWarning: true},
+		{name: "拒绝模式", guard: &FundingGuardConfig{Mode: FundingGuardReject, MaxCostPct: 2, DefaultHoldHours: 24}, side: "long", wantErr: true},
+		{name: "空头收取资金费_不检查", guard: &FundingGuardConfig{Mode: FundingGuardReject, MaxCostPct: 2, DefaultHoldHours: 24}, side: "short"},
+		{name: "关闭检查", guard: &FundingGuardConfig{Mode: FundingGuardOff}, side: "long", holdHours: 480},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.autoTrader.config.FundingGuard = tt.guard
+			defer func() { s.autoTrader.config.FundingGuard = nil }()
+
+			d := *openLong
+			d.HoldHours = tt.holdHours
+			warning, err := s.autoTrader.checkFundingCost(&d, tt.side, 0.001, 8)
+			if tt.wantErr {
+				s.Error(err)
+				return
+			}
+			s.NoError(err)
+			s.Equal(tt.wantWarning, warning != "")
+		})
+	}
+}
+
+// TestSyncFundingPayments 测试资金费流水同步、去重与按持仓方向累计
+func (s *AutoTraderTestSuite) TestSyncFundingPayments() {
+	base := time.Now().Add(time.Hour).UnixMilli()
+	s.autoTrader.lastFundingSync = 0
+	s.autoTrader.positionFunding = nil
+	s.mockTrader.positions = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1, "markPrice": 60000.0},
+		{"symbol": "BTCUSDT", "side": "short", "positionAmt": -0.05, "markPrice": 60000.0},
+		{"symbol": "ETHUSDT", "side": "short", "positionAmt": -1.0, "markPrice": 3000.0},
+	}
+	defer func() { s.mockTrader.positions = []map[string]interface{}{} }()
+	s.mockTrader.fundingPayments = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "amount": -0.6, "time": base},
+		{"symbol": "ETHUSDT", "amount": 0.2, "time": base + 1000},
+		{"symbol": "SOLUSDT", "amount": -0.1, "time": base + 1000},                            // 已平仓
+		{"symbol": "BTCUSDT", "amount": -0.1, "time": time.Now().Add(-time.Hour).UnixMilli()}, // 启动之前
+	}
+
+	payments := s.autoTrader.syncFundingPayments()
+	s.Len(payments, 4) // BTC 双向各一笔 + ETH + SOL（无方向）
+	// 双向持仓按名义价值 2:1 拆分
+	s.InDelta(-0.4, s.autoTrader.positionFundingFee("BTCUSDT", "long"), 1e-9)
+	s.InDelta(-0.2, s.autoTrader.positionFundingFee("BTCUSDT", "short"), 1e-9)
+	s.InDelta(0.2, s.autoTrader.positionFundingFee("ETHUSDT", "short"), 1e-9)
+	s.InDelta(0, s.autoTrader.positionFundingFee("ETHUSDT", "long"), 1e-9)
+	for _, payment := range payments {
+		if payment.Symbol == "SOLUSDT" {
+			s.Empty(payment.Side)
+		} else {
+			s.NotEmpty(payment.Side)
+		}
+	}
+	s.Equal(base+1000, s.autoTrader.lastFundingSync)
+
+	// 再次同步不会重复累计
+	s.mockTrader.positions = s.mockTrader.positions[:1]
+	s.mockTrader.fundingPayments = append(s.mockTrader.fundingPayments,
+		map[string]interface{}{"symbol": "BTCUSDT", "amount": -0.3, "time": base + 2000})
+	payments = s.autoTrader.syncFundingPayments()
+	s.Len(payments, 1)
+	s.InDelta(-0.7, s.autoTrader.positionFundingFee("BTCUSDT", "long"), 1e-9)
+	s.InDelta(-0.2, s.autoTrader.positionFundingFee("BTCUSDT", "short"), 1e-9)
+
+	// 平仓后清理累计
+	s.autoTrader.pruneFundingFees(map[string]bool{"BTCUSDT_long": true})
+	s.InDelta(0, s.autoTrader.positionFundingFee("ETHUSDT", "short"), 1e-9)
+	s.InDelta(0, s.autoTrader.positionFundingFee("BTCUSDT", "short"), 1e-9)
+	s.InDelta(-0.7, s.autoTrader.positionFundingFee("BTCUSDT", "long"), 1e-9)
+}
@@ -1,11 +1,15 @@
 package trader
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"nofx/market"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +22,7 @@ import (
 type HyperliquidTrader struct {
 	exchange      *hyperliquid.Exchange
 	ctx           context.Context
+	apiURL        string // Info 接口地址（SDK 未覆盖的查询直接请求）
 	walletAddr    string
 	meta          *hyperliquid.Meta // 缓存meta信息（包含精度等）
 	metaMutex     sync.RWMutex      // 保护meta字段的并发访问
@@ -123,6 +128,7 @@ func NewHyperliquidTrader(privateKeyHex string, walletAddr string, testnet bool)
 	return &HyperliquidTrader{
 		exchange:      exchange,
 		ctx:           ctx,
+		apiURL:        apiURL,
 		walletAddr:    walletAddr,
 		meta:          meta,
 		isCrossMargin: true, // 默认使用全仓模式
@@ -265,8 +271,8 @@ func (t *HyperliquidTrader) GetPositions() ([]map[string]interface{}, error) {
 
 		posMap := make(map[string]interface{})
 
-		// 标准化symbol格式（Hyperliquid使用如"BTC"，我们转换为"BTCUSDT"）
-		symbol := position.Coin + "USDT"
+		// 标准化symbol格式（Hyperliquid使用如"BTC"、"kPEPE"，我们转换为"BTCUSDT"、"1000PEPEUSDT"）
+		symbol := market.ConvertSymbol(position.Coin, market.VenueHyperliquid, market.VenueBinance)
 		posMap["symbol"] = symbol
 
 		// 持仓数量和方向
@@ -320,6 +326,19 @@ func (t *HyperliquidTrader) SetMarginMode(symbol string, isCrossMargin bool) err
 	return nil
 }
 
+// AddIsolatedMargin 为逐仓持仓追加保证金
+func (t *HyperliquidTrader) AddIsolatedMargin(symbol string, positionSide string, amount float64) error {
+	coin := convertSymbolToHyperliquid(symbol)
+
+	// Hyperliquid 为单向持仓，正数表示追加保证金
+	if _, err := t.exchange.UpdateIsolatedMargin(t.ctx, amount, coin); err != nil {
+		return fmt.Errorf("追加保证金失败: %w", err)
+	}
+
+	log.Printf("  ✓ %s %s 已追加保证金 %.2f USDC", symbol, positionSide, amount)
+	return nil
+}
+
 // SetLeverage 设置杠杆
 func (t *HyperliquidTrader) SetLeverage(symbol string, leverage int) error {
 	// Hyperliquid symbol格式（去掉USDT后缀）
@@ -649,6 +668,99 @@ func (t *HyperliquidTrader) CancelTakeProfitOrders(symbol string) error {
 	return t.CancelStopOrders(symbol)
 }
 
+// GetStopLossOrders 获取该币种当前挂着的止损单
+// 使用 frontendOpenOrders 接口，它会返回触发单类型，可区分止损和止盈
+func (t *HyperliquidTrader) GetStopLossOrders(symbol string) ([]map[string]interface{}, error) {
+	coin := convertSymbolToHyperliquid(symbol)
+
+	openOrders, err := t.exchange.Info().FrontendOpenOrders(t.ctx, t.walletAddr)
+	if err != nil {
+		return nil, fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	var result []map[string]interface{}
+	for _, order := range openOrders {
+		if order.Coin != coin || !order.IsTrigger || !strings.HasPrefix(order.OrderType, "Stop") {
+			continue
+		}
+
+		// 卖出止损保护多仓，买入止损保护空仓
+		positionSide := "LONG"
+		if order.Side == hyperliquid.OrderSideBid {
+			positionSide = "SHORT"
+		}
+
+		result = append(result, map[string]interface{}{
+			"orderId":      order.Oid,
+			"positionSide": positionSide,
+			"stopPrice":    order.TriggerPx,
+			"quantity":     order.Sz,
+		})
+	}
+
+	return result, nil
+}
+
+// GetFundingPayments 获取资金费流水
+// SDK 的 UserFundingHistory 结构缺少 delta 字段，这里直接请求 userFunding 接口
+func (t *HyperliquidTrader) GetFundingPayments(startTime int64) ([]map[string]interface{}, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"type":      "userFunding",
+		"user":      t.walletAddr,
+		"startTime": startTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(t.ctx, "POST", t.apiURL+"/info", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("获取资金费流水失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("获取资金费流水失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取资金费流水失败: HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	var entries []struct {
+		Time  int64 `json:"time"`
+		Delta struct {
+			Type string `json:"type"`
+			Coin string `json:"coin"`
+			USDC string `json:"usdc"`
+		} `json:"delta"`
+	}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("解析资金费流水失败: %w", err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Delta.Type != "funding" {
+			continue
+		}
+		amount, _ := strconv.ParseFloat(entry.Delta.USDC, 64)
+		result = append(result, map[string]interface{}{
+			"symbol": market.ConvertSymbol(entry.Delta.Coin, market.VenueHyperliquid, market.VenueBinance),
+			"amount": amount,
+			"time":   entry.Time,
+		})
+	}
+
+	return result, nil
+}
+
 // CancelAllOrders 取消该币种的所有挂单
 func (t *HyperliquidTrader) CancelAllOrders(symbol string) error {
 	coin := convertSymbolToHyperliquid(symbol)
@@ -888,13 +1000,9 @@ func (t *HyperliquidTrader) roundPriceToSigfigs(price float64) float64 {
 }
 
 // convertSymbolToHyperliquid 将标准symbol转换为Hyperliquid格式
-// 例如: "BTCUSDT" -> "BTC"
+// 例如: "BTCUSDT" -> "BTC"，"1000PEPEUSDT" -> "kPEPE"
 func convertSymbolToHyperliquid(symbol string) string {
-	// 去掉USDT后缀
-	if len(symbol) > 4 && symbol[len(symbol)-4:] == "USDT" {
-		return symbol[:len(symbol)-4]
-	}
-	return symbol
+	return market.ConvertSymbol(symbol, market.VenueBinance, market.VenueHyperliquid)
 }
 
 // absFloat 返回浮点数的绝对值
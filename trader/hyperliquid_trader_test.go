@@ -150,6 +150,57 @@ func NewHyperliquidTestSuite(t *testing.T) *HyperliquidTestSuite {
 		case "openOrders":
 			respBody = []interface{}{}
 
+		// Mock UserFunding - 获取资金费流水
+		case "userFunding":
+			respBody = []map[string]interface{}{
+				{
+					"time": 1700000100000,
+					"hash": "0x0000000000000000000000000000000000000000000000000000000000000000",
+					"delta": map[string]interface{}{
+						"type":        "funding",
+						"coin":        "BTC",
+						"usdc":        "-0.25",
+						"szi":         "0.5",
+						"fundingRate": "0.00001",
+					},
+				},
+			}
+
+		// Mock FrontendOpenOrders - 获取带触发信息的挂单列表
+		case "frontendOpenOrders":
+			respBody = []map[string]interface{}{
+				{
+					"coin":             "BTC",
+					"isPositionTpsl":   false,
+					"isTrigger":        true,
+					"limitPx":          "48000.0",
+					"oid":              1001,
+					"orderType":        "Stop Market",
+					"origSz":           "0.5",
+					"reduceOnly":       true,
+					"side":             "A",
+					"sz":               "0.5",
+					"timestamp":        1700000000000,
+					"triggerCondition": "Price below 48000",
+					"triggerPx":        "48000.0",
+				},
+				{
+					"coin":             "BTC",
+					"isPositionTpsl":   false,
+					"isTrigger":        true,
+					"limitPx":          "55000.0",
+					"oid":              1002,
+					"orderType":        "Take Profit Market",
+					"origSz":           "0.5",
+					"reduceOnly":       true,
+					"side":             "A",
+					"sz":               "0.5",
+					"timestamp":        1700000000000,
+					"triggerCondition": "Price above 55000",
+					"triggerPx":        "55000.0",
+				},
+			}
+
 		// Mock Order - 创建订单（开仓、平仓、止损、止盈）
 		case "order":
 			respBody = map[string]interface{}{
@@ -219,6 +270,7 @@ func NewHyperliquidTestSuite(t *testing.T) *HyperliquidTestSuite {
 	trader := &HyperliquidTrader{
 		exchange:      exchange,
 		ctx:           ctx,
+		apiURL:        mockServer.URL,
 		walletAddr:    walletAddr,
 		meta:          meta,
 		isCrossMargin: true,
@@ -377,6 +429,24 @@ func TestNewHyperliquidTrader_Success(t *testing.T) {
 	t.Skip("跳过此测试：hyperliquid SDK 在构造时会调用真实 API，无法注入 mock URL")
 }
 
+// TestHyperliquidTrader_GetStopLossOrders 测试只返回止损触发单（过滤止盈单）
+func TestHyperliquidTrader_GetStopLossOrders(t *testing.T) {
+	suite := NewHyperliquidTestSuite(t)
+	defer suite.Cleanup()
+
+	orders, err := suite.Trader.GetStopLossOrders("BTCUSDT")
+	assert.NoError(t, err)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, int64(1001), orders[0]["orderId"])
+	assert.Equal(t, "LONG", orders[0]["positionSide"])
+	assert.Equal(t, 48000.0, orders[0]["stopPrice"])
+
+	// 其他币种没有止损单
+	orders, err = suite.Trader.GetStopLossOrders("ETHUSDT")
+	assert.NoError(t, err)
+	assert.Empty(t, orders)
+}
+
 // ============================================================
 // 四、工具函数单元测试（Hyperliquid 特有）
 // ============================================================
@@ -403,6 +473,11 @@ func TestConvertSymbolToHyperliquid(t *testing.T) {
 			symbol:   "BTC",
 			expected: "BTC",
 		},
+		{
+			name:     "1000倍合约转换",
+			symbol:   "1000PEPEUSDT",
+			expected: "kPEPE",
+		},
 	}
 
 	for _, tt := range tests {
@@ -27,6 +27,9 @@ type Trader interface {
 	// SetMarginMode 设置仓位模式 (true=全仓, false=逐仓)
 	SetMarginMode(symbol string, isCrossMargin bool) error
 
+	// AddIsolatedMargin 为逐仓持仓追加保证金（amount 单位为 USDT）
+	AddIsolatedMargin(symbol string, positionSide string, amount float64) error
+
 	// GetMarketPrice 获取市场价格
 	GetMarketPrice(symbol string) (float64, error)
 
@@ -48,6 +51,14 @@ type Trader interface {
 	// CancelStopOrders 取消该币种的止盈/止损单（用于调整止盈止损位置）
 	CancelStopOrders(symbol string) error
 
+	// GetStopLossOrders 获取该币种当前挂着的止损单（用于止损守护检查）
+	// 每个订单包含: orderId, positionSide ("LONG"/"SHORT"), stopPrice, quantity
+	GetStopLossOrders(symbol string) ([]map[string]interface{}, error)
+
+	// GetFundingPayments 获取 startTime（毫秒时间戳）之后账户的资金费流水
+	// 每条记录包含: symbol, amount (USDT，正数为收取，负数为支付), time (毫秒时间戳)
+	GetFundingPayments(startTime int64) ([]map[string]interface{}, error)
+
 	// FormatQuantity 格式化数量到正确的精度
 	FormatQuantity(symbol string, quantity float64) (string, error)
 }
@@ -0,0 +1,350 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/logger"
+	"nofx/market"
+	"strings"
+	"time"
+)
+
+// 强平距离保护的处理方式
+const (
+	LiquidationActionReduce      = "reduce"       // 部分平仓降低仓位
+	LiquidationActionAddMargin   = "add_margin"   // 逐仓追加保证金（全仓或追加失败时改为部分平仓）
+	LiquidationActionTightenStop = "tighten_stop" // 止损收紧到当前价与强平价的中点
+)
+
+// 强平距离的度量方式
+const (
+	LiquidationModePrice = "price" // 距离占当前价格的百分比
+	LiquidationModeATR   = "atr"   // 距离为ATR的倍数
+)
+
+// liquidationGuardCooldown 同一持仓两次自动降风险之间的最小间隔（等待交易所更新强平价）
+const liquidationGuardCooldown = 2 * time.Minute
+
+// LiquidationGuardConfig 强平距离监控配置（按交易员配置，存储在 traders.liquidation_guard）
+type LiquidationGuardConfig struct {
+	Enabled   bool    `json:"enabled"`
+	Mode      string  `json:"mode"`      // 距离度量方式，见 LiquidationMode* 常量
+	Threshold float64 `json:"threshold"` // price 模式为百分比，atr 模式为ATR倍数；距离低于该值触发
+	ATRPeriod int     `json:"atr_period,omitempty"`
+	Timeframe string  `json:"timeframe,omitempty"` // atr 模式使用的K线周期，默认 3m
+	Action    string  `json:"action"`              // 触发后的处理方式，见 LiquidationAction* 常量
+
+	ReducePct    float64 `json:"reduce_pct,omitempty"`     // reduce: 每次平掉的仓位比例（%）
+	AddMarginPct float64 `json:"add_margin_pct,omitempty"` // add_margin: 每次追加的保证金占当前保证金比例（%）
+	MaxActions   int     `json:"max_actions,omitempty"`    // 每个持仓最多自动降风险的次数，用完后只告警
+}
+
+// DefaultLiquidationGuard 默认配置：不启用（启用后默认距离强平价不足3%时平掉30%仓位，每个持仓最多3次）
+func DefaultLiquidationGuard() LiquidationGuardConfig {
+	return LiquidationGuardConfig{
+		Enabled:    false,
+		Mode:       LiquidationModePrice,
+		Threshold:  3.0,
+		Action:     LiquidationActionReduce,
+		ReducePct:  30.0,
+		MaxActions: 3,
+	}
+}
+
+// ParseLiquidationGuard 解析数据库中存储的配置（空字符串使用默认配置）
+func ParseLiquidationGuard(raw string) (LiquidationGuardConfig, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return DefaultLiquidationGuard(), nil
+	}
+
+	var cfg LiquidationGuardConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return LiquidationGuardConfig{}, fmt.Errorf("解析强平保护配置失败: %w", err)
+	}
+	if err := ValidateLiquidationGuard(&cfg); err != nil {
+		return LiquidationGuardConfig{}, err
+	}
+	return cfg, nil
+}
+
+// ValidateLiquidationGuard 校验配置并补全默认参数
+func ValidateLiquidationGuard(cfg *LiquidationGuardConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	switch cfg.Mode {
+	case "", LiquidationModePrice:
+		cfg.Mode = LiquidationModePrice
+		if cfg.Threshold <= 0 || cfg.Threshold >= 100 {
+			return fmt.Errorf("强平保护参数无效: price 模式 threshold 必须在 (0, 100) 之间")
+		}
+	case LiquidationModeATR:
+		if cfg.Threshold <= 0 {
+			return fmt.Errorf("强平保护参数无效: atr 模式 threshold 必须>0")
+		}
+		if cfg.ATRPeriod <= 0 {
+			cfg.ATRPeriod = 14
+		}
+		if cfg.Timeframe == "" {
+			cfg.Timeframe = "3m"
+		}
+		if _, ok := market.IntervalDuration(cfg.Timeframe); !ok {
+			return fmt.Errorf("强平保护参数无效: 不支持的K线周期 %s", cfg.Timeframe)
+		}
+	default:
+		return fmt.Errorf("未知的强平距离度量方式: %s", cfg.Mode)
+	}
+
+	switch cfg.Action {
+	case "", LiquidationActionReduce, LiquidationActionAddMargin, LiquidationActionTightenStop:
+		if cfg.Action == "" {
+			cfg.Action = LiquidationActionReduce
+		}
+	default:
+		return fmt.Errorf("未知的强平保护处理方式: %s", cfg.Action)
+	}
+
+	// 追加保证金在全仓或失败时会退化为部分平仓，因此始终需要减仓比例
+	if cfg.ReducePct == 0 {
+		cfg.ReducePct = 30.0
+	}
+	if cfg.ReducePct < 0 || cfg.ReducePct >= 100 {
+		return fmt.Errorf("强平保护参数无效: reduce_pct 必须在 (0, 100) 之间")
+	}
+	if cfg.MaxActions == 0 {
+		cfg.MaxActions = 3
+	}
+	if cfg.MaxActions < 0 {
+		return fmt.Errorf("强平保护参数无效: max_actions 不能为负")
+	}
+	if cfg.Action == LiquidationActionAddMargin {
+		if cfg.AddMarginPct == 0 {
+			cfg.AddMarginPct = 20.0
+		}
+		if cfg.AddMarginPct < 0 {
+			return fmt.Errorf("强平保护参数无效: add_margin_pct 不能为负")
+		}
+	}
+	return nil
+}
+
+// liquidationDistance 计算当前价格到强平价的距离（价格单位，越小越危险，<=0 表示已越过）
+func liquidationDistance(side string, price, liquidationPrice float64) float64 {
+	if side == "long" {
+		return price - liquidationPrice
+	}
+	return liquidationPrice - price
+}
+
+// evaluateLiquidationGuard 判断持仓是否过于接近强平价，返回是否触发及描述
+func evaluateLiquidationGuard(cfg LiquidationGuardConfig, state *exitPositionState, price float64) (bool, string) {
+	if !cfg.Enabled || state.LiquidationPrice <= 0 || price <= 0 {
+		return false, ""
+	}
+
+	distance := liquidationDistance(state.Side, price, state.LiquidationPrice)
+
+	switch cfg.Mode {
+	case LiquidationModeATR:
+		atr := state.ATR[atrCacheKey(cfg.Timeframe, cfg.ATRPeriod)]
+		if atr <= 0 {
+			return false, ""
+		}
+		if multiple := distance / atr; multiple < cfg.Threshold {
+			return true, fmt.Sprintf("价格 %.4f 距强平价 %.4f 仅 %.2f 倍ATR（阈值 %.2f）",
+				price, state.LiquidationPrice, multiple, cfg.Threshold)
+		}
+	default:
+		if distancePct := distance / price * 100; distancePct < cfg.Threshold {
+			return true, fmt.Sprintf("价格 %.4f 距强平价 %.4f 仅 %.2f%%（阈值 %.2f%%）",
+				price, state.LiquidationPrice, distancePct, cfg.Threshold)
+		}
+	}
+	return false, ""
+}
+
+// liquidationGuard 当前交易员生效的强平保护配置
+func (at *AutoTrader) liquidationGuard() LiquidationGuardConfig {
+	if at.config.LiquidationGuard == nil {
+		return DefaultLiquidationGuard()
+	}
+	return *at.config.LiquidationGuard
+}
+
+// checkLiquidationDistance 按最新价格检查持仓的强平距离，过近时自动降低风险
+// 处理前从交易所重新读取强平价（上次处理后强平价可能已变化），每个持仓最多处理 MaxActions 次
+func (at *AutoTrader) checkLiquidationDistance(state *exitPositionState, price float64) {
+	cfg := at.liquidationGuard()
+	breached, _ := evaluateLiquidationGuard(cfg, state, price)
+	if !breached || time.Since(state.LastDeleverageAt) < liquidationGuardCooldown {
+		return
+	}
+	state.LastDeleverageAt = time.Now()
+
+	if !at.refreshLiquidationState(state) {
+		return
+	}
+	breached, reason := evaluateLiquidationGuard(cfg, state, price)
+	if !breached {
+		return
+	}
+
+	log.Printf("🚨 强平预警: %s %s | %s", state.Symbol, state.Side, reason)
+
+	if state.DeleverageCount >= cfg.MaxActions {
+		if !state.DeleverageCapAlerted {
+			state.DeleverageCapAlerted = true
+			logger.Alertf("[%s] %s %s 接近强平，自动降风险已达上限 %d 次，请人工处理！%s",
+				at.name, state.Symbol, state.Side, cfg.MaxActions, reason)
+		}
+		return
+	}
+	state.DeleverageCount++
+
+	switch cfg.Action {
+	case LiquidationActionAddMargin:
+		if at.config.IsCrossMargin {
+			log.Printf("  ⚠ 全仓模式无法单独追加保证金，改为部分平仓")
+		} else if at.addPositionMargin(state, price, cfg.AddMarginPct, reason) {
+			return
+		}
+
+	case LiquidationActionTightenStop:
+		// 止损移到当前价与强平价的中点，确保先于强平触发
+		newStop := (price + state.LiquidationPrice) / 2
+		if !state.isTighter(newStop) {
+			log.Printf("  ✓ 当前止损 %.4f 已先于强平价触发，无需调整", state.CurrentStop)
+			return
+		}
+		at.executeExitStopMove(state, price, exitAction{
+			NewStop: newStop,
+			Policy:  "liquidation_guard",
+			Reason:  reason,
+		})
+		return
+	}
+
+	at.reducePosition(state, price, cfg.ReducePct, reason)
+}
+
+// refreshLiquidationState 从交易所重新读取持仓数量和强平价，持仓已不存在或读取失败时返回 false
+func (at *AutoTrader) refreshLiquidationState(state *exitPositionState) bool {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("⚠ 强平保护：获取持仓失败，跳过本次处理: %v", err)
+		return false
+	}
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		quantity, _ := pos["positionAmt"].(float64)
+		if symbol != state.Symbol || side != state.Side || quantity == 0 {
+			continue
+		}
+		state.Quantity = math.Abs(quantity)
+		state.LiquidationPrice, _ = pos["liquidationPrice"].(float64)
+		return true
+	}
+	return false
+}
+
+// addPositionMargin 为逐仓持仓追加保证金，返回是否成功
+func (at *AutoTrader) addPositionMargin(state *exitPositionState, price, addMarginPct float64, reason string) bool {
+	if state.Leverage <= 0 {
+		log.Printf("  ⚠ 无法获取杠杆，无法计算追加保证金金额，改为部分平仓")
+		return false
+	}
+	margin := state.Quantity * state.EntryPrice / float64(state.Leverage)
+	amount := margin * addMarginPct / 100
+
+	actionRecord := logger.DecisionAction{
+		Action:    "auto_add_margin",
+		Symbol:    state.Symbol,
+		Quantity:  state.Quantity,
+		Leverage:  state.Leverage,
+		Price:     price,
+		Timestamp: time.Now(),
+	}
+
+	err := at.trader.AddIsolatedMargin(state.Symbol, strings.ToUpper(state.Side), amount)
+	if err != nil {
+		log.Printf("❌ 追加保证金失败 (%s %s): %v，改为部分平仓", state.Symbol, state.Side, err)
+		actionRecord.Error = err.Error()
+	} else {
+		actionRecord.Success = true
+	}
+
+	at.recordAutoAction(actionRecord, fmt.Sprintf("强平保护: %s，追加保证金 %.2f USDT", reason, amount))
+	return err == nil
+}
+
+// reducePosition 部分平仓以拉开与强平价的距离
+func (at *AutoTrader) reducePosition(state *exitPositionState, price, reducePct float64, reason string) {
+	closeQuantity := state.Quantity * reducePct / 100
+
+	actionRecord := logger.DecisionAction{
+		Action:    "auto_reduce_" + state.Side,
+		Symbol:    state.Symbol,
+		Quantity:  closeQuantity,
+		Leverage:  state.Leverage,
+		Price:     price,
+		Timestamp: time.Now(),
+	}
+
+	var order map[string]interface{}
+	var err error
+	if state.Side == "long" {
+		order, err = at.trader.CloseLong(state.Symbol, closeQuantity)
+	} else {
+		order, err = at.trader.CloseShort(state.Symbol, closeQuantity)
+	}
+
+	if err != nil {
+		log.Printf("❌ 强平保护减仓失败 (%s %s): %v", state.Symbol, state.Side, err)
+		actionRecord.Error = err.Error()
+		logger.Alertf("[%s] %s %s 接近强平且自动减仓失败，请人工处理！%s, 错误: %v",
+			at.name, state.Symbol, state.Side, reason, err)
+	} else {
+		if orderID, ok := order["orderId"].(int64); ok {
+			actionRecord.OrderID = orderID
+		}
+		actionRecord.Success = true
+		state.Quantity -= closeQuantity
+		log.Printf("  ✓ 强平保护减仓成功: 平仓 %.4f (%.1f%%), 剩余 %.4f", closeQuantity, reducePct, state.Quantity)
+		at.resizeProtectionOrders(state)
+	}
+
+	at.recordAutoAction(actionRecord, fmt.Sprintf("强平保护: %s，减仓 %.1f%%", reason, reducePct))
+}
+
+// resizeProtectionOrders 减仓后按剩余数量重新挂止损止盈单（价格沿用最近一次记录的止损止盈）
+func (at *AutoTrader) resizeProtectionOrders(state *exitPositionState) {
+	level, _ := at.getProtectionLevel(state.Symbol, state.Side)
+	stopLoss := level.StopLoss
+	if stopLoss <= 0 {
+		stopLoss = state.CurrentStop
+	}
+	if stopLoss <= 0 && level.TakeProfit <= 0 {
+		return
+	}
+
+	positionSide := strings.ToUpper(state.Side)
+	if err := at.trader.CancelStopOrders(state.Symbol); err != nil {
+		log.Printf("  ⚠ 取消旧止损止盈单失败: %v", err)
+	}
+	if stopLoss > 0 {
+		if err := at.trader.SetStopLoss(state.Symbol, positionSide, state.Quantity, stopLoss); err != nil {
+			// 止损守护会按记录的止损价重建
+			log.Printf("  ⚠ 按剩余数量重设止损失败: %v", err)
+		}
+	}
+	if level.TakeProfit > 0 {
+		if err := at.trader.SetTakeProfit(state.Symbol, positionSide, state.Quantity, level.TakeProfit); err != nil {
+			log.Printf("  ⚠ 按剩余数量重设止盈失败: %v", err)
+		}
+	}
+}
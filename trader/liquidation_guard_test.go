@@ -0,0 +1,260 @@
+package trader
+
+import (
+	"nofx/logger"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseLiquidationGuard 测试强平保护配置解析与校验
+func TestParseLiquidationGuard(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+		want    LiquidationGuardConfig
+	}{
+		{name: "空配置_使用默认配置_不启用", raw: "", want: DefaultLiquidationGuard()},
+		{name: "非法JSON", raw: "{", wantErr: true},
+		{name: "关闭时不校验参数", raw: `{"enabled":false,"mode":"moon"}`, want: LiquidationGuardConfig{Mode: "moon"}},
+		{name: "未知度量方式", raw: `{"enabled":true,"mode":"moon","threshold":3}`, wantErr: true},
+		{name: "未知处理方式", raw: `{"enabled":true,"threshold":3,"action":"pray"}`, wantErr: true},
+		{name: "价格模式阈值越界", raw: `{"enabled":true,"mode":"price","threshold":120}`, wantErr: true},
+		{name: "ATR模式不支持的周期", raw: `{"enabled":true,"mode":"atr","threshold":2,"timeframe":"7m"}`, wantErr: true},
+		{
+			name: "ATR模式补全默认参数",
+			raw:  `{"enabled":true,"mode":"atr","threshold":2,"action":"add_margin"}`,
+			want: LiquidationGuardConfig{
+				Enabled: true, Mode: LiquidationModeATR, Threshold: 2, ATRPeriod: 14, Timeframe: "3m",
+				Action: LiquidationActionAddMargin, ReducePct: 30, AddMarginPct: 20, MaxActions: 3,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseLiquidationGuard(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, cfg)
+		})
+	}
+}
+
+// TestEvaluateLiquidationGuard 测试强平距离判断
+func TestEvaluateLiquidationGuard(t *testing.T) {
+	priceGuard := DefaultLiquidationGuard()
+	priceGuard.Enabled = true
+	atrGuard := LiquidationGuardConfig{Enabled: true, Mode: LiquidationModeATR, Threshold: 3, ATRPeriod: 14, Timeframe: "3m"}
+
+	tests := []struct {
+		name         string
+		cfg          LiquidationGuardConfig
+		side         string
+		liquidation  float64
+		atr          float64
+		price        float64
+		wantBreached bool
+	}{
+		{name: "多单_距离充足", cfg: priceGuard, side: "long", liquidation: 90, price: 100},
+		{name: "多单_距离不足3%", cfg: priceGuard, side: "long", liquidation: 98, price: 100, wantBreached: true},
+		{name: "空单_距离不足3%", cfg: priceGuard, side: "short", liquidation: 102, price: 100, wantBreached: true},
+		{name: "空单_距离充足", cfg: priceGuard, side: "short", liquidation: 110, price: 100},
+		{name: "已越过强平价", cfg: priceGuard, side: "long", liquidation: 101, price: 100, wantBreached: true},
+		{name: "强平价未知_跳过", cfg: priceGuard, side: "long", liquidation: 0, price: 100},
+		{name: "ATR模式_不足3倍ATR", cfg: atrGuard, side: "long", liquidation: 95, atr: 2, price: 100, wantBreached: true},
+		{name: "ATR模式_超过3倍ATR", cfg: atrGuard, side: "long", liquidation: 90, atr: 2, price: 100},
+		{name: "ATR模式_无ATR_跳过", cfg: atrGuard, side: "long", liquidation: 99, price: 100},
+		{name: "已关闭", cfg: LiquidationGuardConfig{}, side: "long", liquidation: 99, price: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &exitPositionState{Side: tt.side, LiquidationPrice: tt.liquidation, ATR: map[string]float64{}}
+			if tt.atr > 0 {
+				state.ATR[atrCacheKey("3m", 14)] = tt.atr
+			}
+			breached, reason := evaluateLiquidationGuard(tt.cfg, state, tt.price)
+			assert.Equal(t, tt.wantBreached, breached)
+			assert.Equal(t, tt.wantBreached, reason != "")
+		})
+	}
+}
+
+// TestCheckLiquidationDistance 测试强平保护的自动处理
+func (s *AutoTraderTestSuite) TestCheckLiquidationDistance() {
+	tests := []struct {
+		name             string
+		action           string
+		isCrossMargin    bool
+		failAddMargin    bool
+		currentStop      float64
+		exchangeLiq      float64 // 交易所返回的最新强平价（0 表示与缓存一致）
+		actionsTaken     int
+		checks           int
+		wantAction       string
+		wantCloseQty     []float64
+		wantAddMargin    int
+		wantSetStopCalls int
+	}{
+		{
+			name:         "部分平仓",
+			action:       LiquidationActionReduce,
+			checks:       1,
+			wantAction:   "auto_reduce_long",
+			wantCloseQty: []float64{0.03},
+		},
+		{
+			name:         "冷却期内不重复处理",
+			action:       LiquidationActionReduce,
+			checks:       2,
+			wantAction:   "auto_reduce_long",
+			wantCloseQty: []float64{0.03},
+		},
+		{
+			name:          "逐仓追加保证金",
+			action:        LiquidationActionAddMargin,
+			checks:        1,
+			wantAction:    "auto_add_margin",
+			wantAddMargin: 1,
+		},
+		{
+			name:          "全仓无法追加保证金_改为部分平仓",
+			action:        LiquidationActionAddMargin,
+			isCrossMargin: true,
+			checks:        1,
+			wantAction:    "auto_reduce_long",
+			wantCloseQty:  []float64{0.03},
+		},
+		{
+			name:          "追加保证金失败_改为部分平仓",
+			action:        LiquidationActionAddMargin,
+			failAddMargin: true,
+			checks:        1,
+			wantAction:    "auto_reduce_long",
+			wantCloseQty:  []float64{0.03},
+			wantAddMargin: 1,
+		},
+		{
+			name:             "收紧止损",
+			action:           LiquidationActionTightenStop,
+			currentStop:      47000,
+			checks:           1,
+			wantAction:       "auto_update_stop_loss",
+			wantSetStopCalls: 1,
+		},
+		{
+			name:        "止损已先于强平触发_不处理",
+			action:      LiquidationActionTightenStop,
+			currentStop: 49500,
+			checks:      1,
+		},
+		{
+			name:        "交易所强平价已远离_不处理",
+			action:      LiquidationActionReduce,
+			exchangeLiq: 45000,
+			checks:      1,
+		},
+		{
+			name:         "达到次数上限_只告警",
+			action:       LiquidationActionReduce,
+			actionsTaken: 3,
+			checks:       1,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+			guard := LiquidationGuardConfig{Enabled: true, Threshold: 3, Action: tt.action}
+			s.Require().NoError(ValidateLiquidationGuard(&guard))
+			s.autoTrader.config.LiquidationGuard = &guard
+			s.autoTrader.config.IsCrossMargin = tt.isCrossMargin
+			defer func() {
+				s.autoTrader.config.LiquidationGuard = nil
+				s.autoTrader.config.IsCrossMargin = true
+			}()
+
+			s.mockTrader.closeQuantities = nil
+			s.mockTrader.addMarginCalls = 0
+			s.mockTrader.setStopLossCalls = 0
+			s.mockTrader.shouldFailAddMargin = tt.failAddMargin
+			defer func() { s.mockTrader.shouldFailAddMargin = false }()
+
+			exchangeLiq := tt.exchangeLiq
+			if exchangeLiq == 0 {
+				exchangeLiq = 48500
+			}
+			s.mockTrader.positions = []map[string]interface{}{
+				{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1, "liquidationPrice": exchangeLiq},
+			}
+			defer func() { s.mockTrader.positions = []map[string]interface{}{} }()
+
+			state := &exitPositionState{
+				Symbol: "BTCUSDT", Side: "long", EntryPrice: 50000, Quantity: 0.1, Leverage: 20,
+				CurrentStop: tt.currentStop, LiquidationPrice: 48500, ATR: map[string]float64{},
+				DeleverageCount: tt.actionsTaken,
+			}
+			defer s.autoTrader.clearProtectionState("BTCUSDT", "long")
+
+			for i := 0; i < tt.checks; i++ {
+				s.autoTrader.checkLiquidationDistance(state, 49800)
+			}
+
+			s.InDeltaSlice(tt.wantCloseQty, s.mockTrader.closeQuantities, 1e-9)
+			s.Equal(tt.wantAddMargin, s.mockTrader.addMarginCalls)
+			s.Equal(tt.wantSetStopCalls, s.mockTrader.setStopLossCalls)
+
+			records, err := s.autoTrader.decisionLogger.GetLatestRecords(1)
+			s.NoError(err)
+			if tt.wantAction == "" {
+				s.Empty(records)
+				return
+			}
+			s.Require().Len(records, 1)
+			s.Require().Len(records[0].Decisions, 1)
+			s.Equal(tt.wantAction, records[0].Decisions[0].Action)
+			s.True(records[0].Decisions[0].Success)
+		})
+	}
+
+	s.Run("冷却期过后再次处理并按剩余数量重设止损", func() {
+		guard := LiquidationGuardConfig{Enabled: true, Threshold: 3}
+		s.Require().NoError(ValidateLiquidationGuard(&guard))
+		s.autoTrader.config.LiquidationGuard = &guard
+		s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+		s.mockTrader.closeQuantities = nil
+		s.mockTrader.stopLossQuantities = nil
+		s.mockTrader.positions = []map[string]interface{}{
+			{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1, "liquidationPrice": 48500.0},
+		}
+		s.autoTrader.recordProtectionLevels("BTCUSDT", "long", 48800, 52000)
+		defer func() {
+			s.autoTrader.config.LiquidationGuard = nil
+			s.mockTrader.positions = []map[string]interface{}{}
+			s.autoTrader.clearProtectionState("BTCUSDT", "long")
+		}()
+
+		state := &exitPositionState{
+			Symbol: "BTCUSDT", Side: "long", EntryPrice: 50000, Quantity: 0.1, Leverage: 20,
+			LiquidationPrice: 48500, LastDeleverageAt: time.Now().Add(-liquidationGuardCooldown), DeleverageCount: 1,
+		}
+		s.autoTrader.checkLiquidationDistance(state, 49800)
+		s.Len(s.mockTrader.closeQuantities, 1)
+		s.InDelta(0.07, state.Quantity, 1e-9)
+		s.Equal(2, state.DeleverageCount)
+		s.InDeltaSlice([]float64{0.07}, s.mockTrader.stopLossQuantities, 1e-9)
+	})
+
+	s.Run("默认不启用", func() {
+		s.mockTrader.closeQuantities = nil
+		state := &exitPositionState{Symbol: "BTCUSDT", Side: "long", EntryPrice: 50000, Quantity: 0.1, LiquidationPrice: 48500}
+		s.autoTrader.checkLiquidationDistance(state, 49800)
+		s.Empty(s.mockTrader.closeQuantities)
+	})
+}
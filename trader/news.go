@@ -0,0 +1,42 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/hook"
+)
+
+// maxNewsItems 写入提示词的新闻条数上限
+const maxNewsItems = 10
+
+// loadNews 通过 GET_NEWS 扩展点获取持仓和候选币种相关的新闻
+// 布局不包含 news 区块时不获取；扩展点未注册或出错时返回空，不影响决策
+func (at *AutoTrader) loadNews(ctx *decision.Context) []string {
+	if !decision.LayoutHasSection(ctx.UserPromptLayout, decision.SectionNews) {
+		return nil
+	}
+
+	symbols := make([]string, 0, len(ctx.Positions)+len(ctx.CandidateCoins))
+	seen := make(map[string]bool, cap(symbols))
+	for _, pos := range ctx.Positions {
+		if !seen[pos.Symbol] {
+			seen[pos.Symbol] = true
+			symbols = append(symbols, pos.Symbol)
+		}
+	}
+	for _, coin := range ctx.CandidateCoins {
+		if !seen[coin.Symbol] {
+			seen[coin.Symbol] = true
+			symbols = append(symbols, coin.Symbol)
+		}
+	}
+
+	result := hook.HookExec[hook.NewsResult](hook.GET_NEWS, at.id, symbols)
+	if result == nil || result.Error() != nil {
+		return nil
+	}
+	news := result.GetResult()
+	if len(news) > maxNewsItems {
+		news = news[:maxNewsItems]
+	}
+	return news
+}
@@ -0,0 +1,66 @@
+package trader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"nofx/decision"
+	"nofx/hook"
+)
+
+// TestLoadNews 测试通过 GET_NEWS 扩展点获取新闻（只在布局包含 news 区块时获取）
+func (s *AutoTraderTestSuite) TestLoadNews() {
+	dir := s.T().TempDir()
+	layout := `{"name":"with_news","version":1,"language":"zh","sections":["account","news","instruction"]}`
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "with_news.json"), []byte(layout), 0644))
+	s.Require().NoError(decision.LoadUserPromptLayouts(dir))
+	defer decision.LoadUserPromptLayouts(s.T().TempDir())
+
+	saved, registered := hook.Hooks[hook.GET_NEWS]
+	defer func() {
+		if registered {
+			hook.Hooks[hook.GET_NEWS] = saved
+		} else {
+			delete(hook.Hooks, hook.GET_NEWS)
+		}
+	}()
+
+	var gotTrader string
+	var gotSymbols []string
+	hook.RegisterHook(hook.GET_NEWS, func(args ...any) any {
+		gotTrader, _ = args[0].(string)
+		gotSymbols, _ = args[1].([]string)
+		news := make([]string, maxNewsItems+5)
+		for i := range news {
+			news[i] = fmt.Sprintf("新闻%d", i)
+		}
+		return &hook.NewsResult{News: news}
+	})
+
+	ctx := &decision.Context{
+		UserPromptLayout: "with_news",
+		Positions:        []decision.PositionInfo{{Symbol: "BTCUSDT"}},
+		CandidateCoins:   []decision.CandidateCoin{{Symbol: "BTCUSDT"}, {Symbol: "SOLUSDT"}},
+	}
+	news := s.autoTrader.loadNews(ctx)
+	s.Len(news, maxNewsItems)
+	s.Equal(s.autoTrader.id, gotTrader)
+	s.Equal([]string{"BTCUSDT", "SOLUSDT"}, gotSymbols)
+
+	// 扩展点出错时不写入新闻
+	hook.RegisterHook(hook.GET_NEWS, func(args ...any) any {
+		return &hook.NewsResult{Err: fmt.Errorf("新闻源不可用")}
+	})
+	s.Empty(s.autoTrader.loadNews(ctx))
+
+	// 布局不包含 news 区块时不调用扩展点
+	gotTrader = ""
+	hook.RegisterHook(hook.GET_NEWS, func(args ...any) any {
+		gotTrader = "called"
+		return &hook.NewsResult{}
+	})
+	ctx.UserPromptLayout = ""
+	s.Empty(s.autoTrader.loadNews(ctx))
+	s.Empty(gotTrader)
+}
@@ -0,0 +1,196 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/config"
+	"nofx/decision"
+	"nofx/logger"
+	"strings"
+	"time"
+)
+
+// ReflectionConfig 交易反思配置（按交易员配置，存储在 traders.reflection）
+// 每隔 interval_minutes 把新平仓的交易交给AI总结成简短教训，决策时注入与当前币种相关的教训
+type ReflectionConfig struct {
+	Enabled         bool `json:"enabled"`
+	IntervalMinutes int  `json:"interval_minutes"` // 两次反思的最短间隔（分钟）
+	MinTrades       int  `json:"min_trades"`       // 至少有N笔新平仓交易才反思
+	MaxLessons      int  `json:"max_lessons"`      // 每个交易员最多保存的教训数（超出删除最旧的）
+	PromptTokens    int  `json:"prompt_tokens"`    // 注入 User Prompt 的教训 token 上限
+}
+
+// DefaultReflection 默认配置：不启用反思
+func DefaultReflection() ReflectionConfig {
+	return ReflectionConfig{
+		Enabled:         false,
+		IntervalMinutes: 360,
+		MinTrades:       3,
+		MaxLessons:      30,
+		PromptTokens:    300,
+	}
+}
+
+// ParseReflection 解析数据库中存储的配置（空字符串使用默认配置）
+func ParseReflection(raw string) (ReflectionConfig, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return DefaultReflection(), nil
+	}
+
+	var cfg ReflectionConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return ReflectionConfig{}, fmt.Errorf("解析反思配置失败: %w", err)
+	}
+	if err := ValidateReflection(&cfg); err != nil {
+		return ReflectionConfig{}, err
+	}
+	return cfg, nil
+}
+
+// ValidateReflection 校验配置并补全默认参数
+func ValidateReflection(cfg *ReflectionConfig) error {
+	if cfg.IntervalMinutes < 0 || cfg.MinTrades < 0 || cfg.MaxLessons < 0 || cfg.PromptTokens < 0 {
+		return fmt.Errorf("反思参数无效: interval_minutes、min_trades、max_lessons、prompt_tokens 不能为负")
+	}
+	if cfg.PromptTokens > 2000 {
+		return fmt.Errorf("反思参数无效: prompt_tokens 不能超过 2000")
+	}
+	defaults := DefaultReflection()
+	if cfg.IntervalMinutes == 0 {
+		cfg.IntervalMinutes = defaults.IntervalMinutes
+	}
+	if cfg.MinTrades == 0 {
+		cfg.MinTrades = defaults.MinTrades
+	}
+	if cfg.MaxLessons == 0 {
+		cfg.MaxLessons = defaults.MaxLessons
+	}
+	if cfg.PromptTokens == 0 {
+		cfg.PromptTokens = defaults.PromptTokens
+	}
+	return nil
+}
+
+// lessonStore 交易教训存储（config.Database 实现）
+type lessonStore interface {
+	GetTraderLessons(userID, traderID string) ([]*config.TraderLesson, error)
+	AddTraderLessons(userID, traderID string, lessons []*config.TraderLesson, maxLessons int) error
+}
+
+func (at *AutoTrader) lessonStore() lessonStore {
+	store, _ := at.database.(lessonStore)
+	return store
+}
+
+// loadLessons 读取交易员保存的教训（未启用反思或读取失败时为空）
+func (at *AutoTrader) loadLessons() []decision.Lesson {
+	store := at.lessonStore()
+	if at.config.Reflection == nil || !at.config.Reflection.Enabled || store == nil {
+		return nil
+	}
+	records, err := store.GetTraderLessons(at.userID, at.id)
+	if err != nil {
+		log.Printf("⚠️  [%s] 读取交易教训失败: %v", at.name, err)
+		return nil
+	}
+	lessons := make([]decision.Lesson, len(records))
+	at.reflectionMutex.Lock()
+	defer at.reflectionMutex.Unlock()
+	for i, r := range records {
+		lessons[i] = decision.Lesson{Symbol: r.Symbol, Content: r.Content, CreatedAt: r.CreatedAt}
+		// 重启后从已保存的教训恢复反思进度，避免重复总结同一批交易
+		if r.TradesUntil != nil && r.TradesUntil.After(at.reflectedUntil) {
+			at.reflectedUntil = *r.TradesUntil
+		}
+	}
+	return lessons
+}
+
+// relevantLessons 与持仓和候选币种相关的教训（受 prompt_tokens 上限约束）
+func (at *AutoTrader) relevantLessons(lessons []decision.Lesson, ctx *decision.Context) []decision.Lesson {
+	if len(lessons) == 0 {
+		return nil
+	}
+	var symbols []string
+	for _, pos := range ctx.Positions {
+		symbols = append(symbols, pos.Symbol)
+	}
+	for _, coin := range ctx.CandidateCoins {
+		symbols = append(symbols, coin.Symbol)
+	}
+	return decision.SelectLessons(lessons, symbols, at.config.Reflection.PromptTokens)
+}
+
+// maybeReflect 到达反思间隔且有足够的新平仓交易时，在后台总结教训（不阻塞决策周期）
+func (at *AutoTrader) maybeReflect(performance *logger.PerformanceAnalysis, existing []decision.Lesson) {
+	cfg := at.config.Reflection
+	store := at.lessonStore()
+	if cfg == nil || !cfg.Enabled || store == nil || performance == nil {
+		return
+	}
+
+	at.reflectionMutex.Lock()
+	defer at.reflectionMutex.Unlock()
+	if at.reflecting || time.Since(at.lastReflection) < time.Duration(cfg.IntervalMinutes)*time.Minute {
+		return
+	}
+	trades := newClosedTrades(performance.RecentTrades, at.reflectedUntil)
+	if len(trades) < cfg.MinTrades {
+		return
+	}
+	at.reflecting = true
+
+	go func() {
+		until := trades[0].CloseTime
+		for _, t := range trades {
+			if t.CloseTime.After(until) {
+				until = t.CloseTime
+			}
+		}
+
+		lessons, err := decision.Reflect(at.mcpClient, trades, existing)
+		if err == nil && len(lessons) > 0 {
+			records := make([]*config.TraderLesson, len(lessons))
+			for i, lesson := range lessons {
+				records[i] = &config.TraderLesson{Symbol: lesson.Symbol, Content: lesson.Content, TradesUntil: &until}
+			}
+			err = store.AddTraderLessons(at.userID, at.id, records, cfg.MaxLessons)
+		}
+
+		at.reflectionMutex.Lock()
+		defer at.reflectionMutex.Unlock()
+		at.reflecting = false
+		at.lastReflection = time.Now()
+		if err != nil {
+			log.Printf("⚠️  [%s] 交易反思失败: %v", at.name, err)
+			return
+		}
+		at.reflectedUntil = until
+		log.Printf("📝 [%s] 交易反思完成: %d 笔交易 → %d 条新教训", at.name, len(trades), len(lessons))
+	}()
+}
+
+// newClosedTrades 平仓时间晚于 since 的交易
+func newClosedTrades(outcomes []logger.TradeOutcome, since time.Time) []decision.ClosedTrade {
+	var trades []decision.ClosedTrade
+	for _, o := range outcomes {
+		if !o.CloseTime.After(since) {
+			continue
+		}
+		trades = append(trades, decision.ClosedTrade{
+			Symbol:      o.Symbol,
+			Side:        o.Side,
+			Leverage:    o.Leverage,
+			OpenPrice:   o.OpenPrice,
+			ClosePrice:  o.ClosePrice,
+			PnL:         o.PnL,
+			PnLPct:      o.PnLPct,
+			Duration:    o.Duration,
+			WasStopLoss: o.WasStopLoss,
+			CloseTime:   o.CloseTime,
+		})
+	}
+	return trades
+}
@@ -0,0 +1,47 @@
+package trader
+
+import (
+	"nofx/logger"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseReflection 测试交易反思配置解析与校验
+func TestParseReflection(t *testing.T) {
+	cfg, err := ParseReflection("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultReflection(), cfg)
+
+	cfg, err = ParseReflection(`{"enabled":true,"min_trades":5}`)
+	assert.NoError(t, err)
+	assert.True(t, cfg.Enabled)
+	assert.Equal(t, 5, cfg.MinTrades)
+	assert.Equal(t, 360, cfg.IntervalMinutes)
+	assert.Equal(t, 300, cfg.PromptTokens)
+
+	_, err = ParseReflection(`{"prompt_tokens":-1}`)
+	assert.Error(t, err)
+	_, err = ParseReflection(`{"prompt_tokens":5000}`)
+	assert.Error(t, err, "注入上限过大")
+	_, err = ParseReflection(`{bad json`)
+	assert.Error(t, err)
+}
+
+// TestNewClosedTrades 测试只反思上次反思之后平仓的交易
+func TestNewClosedTrades(t *testing.T) {
+	since := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	outcomes := []logger.TradeOutcome{
+		{Symbol: "SOLUSDT", Side: "long", PnL: -5, CloseTime: since.Add(time.Hour)},
+		{Symbol: "ETHUSDT", Side: "short", PnL: 8, CloseTime: since},
+		{Symbol: "BTCUSDT", Side: "long", PnL: 3, CloseTime: since.Add(-time.Hour)},
+	}
+
+	trades := newClosedTrades(outcomes, since)
+	assert.Len(t, trades, 1)
+	assert.Equal(t, "SOLUSDT", trades[0].Symbol)
+	assert.Equal(t, -5.0, trades[0].PnL)
+
+	assert.Len(t, newClosedTrades(outcomes, time.Time{}), 3)
+}
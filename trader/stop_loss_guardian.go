@@ -0,0 +1,370 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/decision"
+	"nofx/logger"
+	"strings"
+	"time"
+)
+
+const (
+	// stopLossGuardianInterval 止损守护检查间隔
+	stopLossGuardianInterval = 1 * time.Minute
+	// stopLossGuardianGracePeriod 开仓/调整止损后的宽限期，避免与正在执行的决策竞争
+	stopLossGuardianGracePeriod = 30 * time.Second
+	// maxStopLossRecreateAttempts 连续重建止损失败次数上限，超过后强制平仓（仅限有止损决策记录的持仓）
+	maxStopLossRecreateAttempts = 3
+	// stopLossLookbackRecords 从决策日志中回溯止损价时最多读取的记录数
+	stopLossLookbackRecords = 200
+)
+
+// protectionLevel 持仓最近一次已知的止损止盈价格（来自AI决策）
+type protectionLevel struct {
+	StopLoss   float64
+	TakeProfit float64
+	UpdatedAt  time.Time
+}
+
+// 启动止损守护
+func (at *AutoTrader) startStopLossGuardian() {
+	at.monitorWg.Add(1)
+	go func() {
+		defer at.monitorWg.Done()
+
+		ticker := time.NewTicker(stopLossGuardianInterval)
+		defer ticker.Stop()
+
+		log.Println("🛡️ 启动止损守护（每分钟检查持仓止损单）")
+
+		for {
+			select {
+			case <-ticker.C:
+				at.checkStopLossProtection()
+			case <-at.stopMonitorCh:
+				log.Println("⏹ 停止止损守护")
+				return
+			}
+		}
+	}()
+}
+
+// checkStopLossProtection 检查每个持仓在交易所是否有对应的止损单
+// 缺失时按最近一次决策的止损价重建，重建持续失败则平仓并告警；
+// 找不到止损决策的持仓（手动开仓或超出回溯范围）无法确定止损价，只告警不平仓
+func (at *AutoTrader) checkStopLossProtection() {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("❌ 止损守护：获取持仓失败: %v", err)
+		return
+	}
+
+	activeKeys := make(map[string]bool)
+	stopOrdersBySymbol := make(map[string][]map[string]interface{})
+
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		quantity, _ := pos["positionAmt"].(float64)
+		markPrice, _ := pos["markPrice"].(float64)
+		quantity = math.Abs(quantity)
+		if symbol == "" || quantity == 0 {
+			continue
+		}
+
+		posKey := symbol + "_" + side
+		activeKeys[posKey] = true
+
+		// 刚开仓或刚调整止损的持仓，交给执行流程自己完成挂单
+		if level, ok := at.getProtectionLevel(symbol, side); ok && time.Since(level.UpdatedAt) < stopLossGuardianGracePeriod {
+			continue
+		}
+
+		orders, fetched := stopOrdersBySymbol[symbol]
+		if !fetched {
+			orders, err = at.trader.GetStopLossOrders(symbol)
+			if err != nil {
+				log.Printf("⚠ 止损守护：获取 %s 止损单失败: %v", symbol, err)
+				continue
+			}
+			stopOrdersBySymbol[symbol] = orders
+		}
+
+		if hasStopLossForSide(orders, side) {
+			at.resetStopLossFailures(posKey)
+			continue
+		}
+
+		log.Printf("🛡️ 止损守护: %s %s 未检测到止损单，尝试重建", symbol, side)
+
+		stopLoss, ok := at.lastKnownStopLoss(symbol, side)
+		if !ok {
+			at.alertUnknownStopLoss(symbol, side)
+			continue
+		}
+
+		// 止损价已被穿越：交易所会拒绝该止损单，直接按止损逻辑平仓
+		if (side == "long" && markPrice > 0 && markPrice <= stopLoss) ||
+			(side == "short" && markPrice > 0 && markPrice >= stopLoss) {
+			at.escalateUnprotectedPosition(symbol, side, quantity, markPrice,
+				fmt.Sprintf("止损价 %.4f 已被穿越（当前价格 %.4f）", stopLoss, markPrice))
+			continue
+		}
+
+		if err := at.trader.SetStopLoss(symbol, strings.ToUpper(side), quantity, stopLoss); err != nil {
+			at.handleStopLossRecreateFailure(symbol, side, quantity, markPrice, err)
+			continue
+		}
+
+		at.resetStopLossFailures(posKey)
+		log.Printf("  ✓ 止损守护：已重建 %s %s 止损单 @ %.4f", symbol, side, stopLoss)
+	}
+
+	// 清理已不存在持仓的状态
+	at.protectionMutex.Lock()
+	for key := range at.stopLossFailures {
+		if !activeKeys[key] {
+			delete(at.stopLossFailures, key)
+		}
+	}
+	for key := range at.unknownStopAlerted {
+		if !activeKeys[key] {
+			delete(at.unknownStopAlerted, key)
+		}
+	}
+	for key, level := range at.protectionLevels {
+		if !activeKeys[key] && time.Since(level.UpdatedAt) >= stopLossGuardianGracePeriod {
+			delete(at.protectionLevels, key)
+		}
+	}
+	at.protectionMutex.Unlock()
+}
+
+// handleStopLossRecreateFailure 记录一次重建失败，连续失败达到上限后升级处理
+func (at *AutoTrader) handleStopLossRecreateFailure(symbol, side string, quantity, markPrice float64, err error) {
+	posKey := symbol + "_" + side
+
+	at.protectionMutex.Lock()
+	if at.stopLossFailures == nil {
+		at.stopLossFailures = make(map[string]int)
+	}
+	at.stopLossFailures[posKey]++
+	failures := at.stopLossFailures[posKey]
+	at.protectionMutex.Unlock()
+
+	log.Printf("❌ 止损守护：%s %s 重建止损失败 (%d/%d): %v",
+		symbol, side, failures, maxStopLossRecreateAttempts, err)
+
+	if failures >= maxStopLossRecreateAttempts {
+		at.escalateUnprotectedPosition(symbol, side, quantity, markPrice,
+			fmt.Sprintf("连续 %d 次重建止损失败: %v", failures, err))
+	}
+}
+
+// alertUnknownStopLoss 持仓没有止损单也找不到止损决策时告警（每个持仓只告警一次），由用户自行处理
+func (at *AutoTrader) alertUnknownStopLoss(symbol, side string) {
+	posKey := symbol + "_" + side
+
+	at.protectionMutex.Lock()
+	if at.unknownStopAlerted == nil {
+		at.unknownStopAlerted = make(map[string]bool)
+	}
+	alerted := at.unknownStopAlerted[posKey]
+	at.unknownStopAlerted[posKey] = true
+	at.protectionMutex.Unlock()
+
+	log.Printf("⚠️ 止损守护：%s %s 没有止损单，且找不到该持仓的止损决策（可能为手动开仓），不自动平仓", symbol, side)
+	if !alerted {
+		logger.Alertf("[%s] %s %s 没有止损单，且不是本交易员开的仓位（或开仓记录已超出回溯范围），请人工设置止损", at.name, symbol, side)
+	}
+}
+
+// escalateUnprotectedPosition 平掉无止损保护的持仓，记录 auto_close 决策并发送告警
+func (at *AutoTrader) escalateUnprotectedPosition(symbol, side string, quantity, markPrice float64, reason string) {
+	log.Printf("🚨 止损守护：%s %s 无止损保护，执行强制平仓（%s）", symbol, side, reason)
+
+	actionRecord := logger.DecisionAction{
+		Action:    "auto_close_" + side,
+		Symbol:    symbol,
+		Quantity:  quantity,
+		Price:     markPrice,
+		Timestamp: time.Now(),
+	}
+
+	if err := at.emergencyClosePosition(symbol, side); err != nil {
+		actionRecord.Error = err.Error()
+		logger.Alertf("[%s] %s %s 无止损保护且强制平仓失败，请立即人工处理！原因: %s, 错误: %v",
+			at.name, symbol, side, reason, err)
+	} else {
+		actionRecord.Success = true
+		at.ClearPeakPnLCache(symbol, side)
+		at.clearProtectionState(symbol, side)
+		logger.Alertf("[%s] %s %s 无止损保护，已强制平仓。原因: %s", at.name, symbol, side, reason)
+	}
+
+	at.recordAutoAction(actionRecord, fmt.Sprintf("止损守护: %s %s 无止损保护（%s）", symbol, side, reason))
+}
+
+// hasStopLossForSide 判断止损单列表中是否存在保护指定方向持仓的订单
+func hasStopLossForSide(orders []map[string]interface{}, side string) bool {
+	positionSide := strings.ToUpper(side)
+	for _, order := range orders {
+		if ps, _ := order["positionSide"].(string); ps == positionSide {
+			return true
+		}
+	}
+	return false
+}
+
+// lastKnownStopLoss 获取持仓最近一次已知的止损价
+// 优先使用内存缓存，重启后缓存为空时从决策日志中回溯最近一次成功执行的开仓/调整止损决策
+func (at *AutoTrader) lastKnownStopLoss(symbol, side string) (float64, bool) {
+	if level, ok := at.getProtectionLevel(symbol, side); ok && level.StopLoss > 0 {
+		return level.StopLoss, true
+	}
+
+	if at.decisionLogger == nil {
+		return 0, false
+	}
+	records, err := at.decisionLogger.GetLatestRecords(stopLossLookbackRecords)
+	if err != nil {
+		return 0, false
+	}
+
+	// 记录按时间正序排列，从最新的开始回溯
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.DecisionJSON == "" {
+			continue
+		}
+
+		// 成功执行的操作 → 执行后的持仓方向（add_to_position、reverse、update_stop_loss、update_sl_tp 记录，旧记录可能为空）
+		executed := make(map[string]string)
+		for _, action := range record.Decisions {
+			if action.Success {
+				executed[action.Symbol+"_"+action.Action] = action.Side
+			}
+		}
+
+		var decisions []decision.Decision
+		if err := json.Unmarshal([]byte(record.DecisionJSON), &decisions); err != nil {
+			continue
+		}
+
+		for _, d := range decisions {
+			actionSide, ok := executed[d.Symbol+"_"+d.Action]
+			if d.Symbol != symbol || !ok {
+				continue
+			}
+			switch d.Action {
+			case "update_stop_loss", "update_sl_tp":
+				// 双向持仓时只采用调整本方向持仓的决策
+				if d.NewStopLoss > 0 && (actionSide == "" || actionSide == side) {
+					at.cacheStopLoss(symbol, side, d.NewStopLoss)
+					return d.NewStopLoss, true
+				}
+			case "add_to_position":
+				if d.StopLoss > 0 && (actionSide == "" || actionSide == side) {
+					at.cacheStopLoss(symbol, side, d.StopLoss)
+					return d.StopLoss, true
+				}
+			case "reverse":
+				// 反手到当前方向时止损来自该决策，反手离开当前方向时更早的决策属于已平掉的旧持仓
+				if actionSide == side && d.StopLoss > 0 {
+					at.cacheStopLoss(symbol, side, d.StopLoss)
+					return d.StopLoss, true
+				}
+				return 0, false
+			case "open_" + side:
+				if d.StopLoss > 0 {
+					at.cacheStopLoss(symbol, side, d.StopLoss)
+					return d.StopLoss, true
+				}
+			case "close_" + side:
+				// 更早的决策属于已平掉的旧持仓
+				return 0, false
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// cacheStopLoss 缓存从决策日志回溯到的止损价，避免重复读取日志
+// 不更新 UpdatedAt，因此不会触发止损守护的宽限期
+func (at *AutoTrader) cacheStopLoss(symbol, side string, stopLoss float64) {
+	at.protectionMutex.Lock()
+	defer at.protectionMutex.Unlock()
+
+	if at.protectionLevels == nil {
+		at.protectionLevels = make(map[string]*protectionLevel)
+	}
+	posKey := symbol + "_" + side
+	if _, exists := at.protectionLevels[posKey]; !exists {
+		at.protectionLevels[posKey] = &protectionLevel{StopLoss: stopLoss}
+	}
+}
+
+// recordProtectionLevels 记录持仓最新的止损止盈价格（0表示保持不变）
+func (at *AutoTrader) recordProtectionLevels(symbol, side string, stopLoss, takeProfit float64) {
+	at.protectionMutex.Lock()
+	defer at.protectionMutex.Unlock()
+
+	if at.protectionLevels == nil {
+		at.protectionLevels = make(map[string]*protectionLevel)
+	}
+
+	posKey := symbol + "_" + side
+	level, exists := at.protectionLevels[posKey]
+	if !exists {
+		level = &protectionLevel{}
+		at.protectionLevels[posKey] = level
+	}
+	if stopLoss > 0 {
+		level.StopLoss = stopLoss
+	}
+	if takeProfit > 0 {
+		level.TakeProfit = takeProfit
+	}
+	level.UpdatedAt = time.Now()
+}
+
+// getProtectionLevel 获取持仓最新的止损止盈价格
+func (at *AutoTrader) getProtectionLevel(symbol, side string) (protectionLevel, bool) {
+	at.protectionMutex.RLock()
+	defer at.protectionMutex.RUnlock()
+
+	level, exists := at.protectionLevels[symbol+"_"+side]
+	if !exists {
+		return protectionLevel{}, false
+	}
+	return *level, true
+}
+
+// clearProtectionState 清除持仓的止损缓存、失败计数、利润保护和事件触发状态（本交易员平仓时调用）
+func (at *AutoTrader) clearProtectionState(symbol, side string) {
+	at.protectionMutex.Lock()
+	defer at.protectionMutex.Unlock()
+
+	posKey := symbol + "_" + side
+	delete(at.protectionLevels, posKey)
+	delete(at.stopLossFailures, posKey)
+	delete(at.unknownStopAlerted, posKey)
+
+	at.exitMutex.Lock()
+	delete(at.exitStates, posKey)
+	at.exitMutex.Unlock()
+
+	at.forgetEventPosition(posKey)
+}
+
+// resetStopLossFailures 重置重建失败计数
+func (at *AutoTrader) resetStopLossFailures(posKey string) {
+	at.protectionMutex.Lock()
+	defer at.protectionMutex.Unlock()
+
+	delete(at.stopLossFailures, posKey)
+}
@@ -0,0 +1,305 @@
+package trader
+
+import (
+	"encoding/json"
+	"nofx/decision"
+	"nofx/logger"
+	"time"
+)
+
+// setStaleProtectionLevel 设置一个已过宽限期的止损缓存
+func (s *AutoTraderTestSuite) setStaleProtectionLevel(symbol, side string, stopLoss float64) {
+	s.autoTrader.recordProtectionLevels(symbol, side, stopLoss, 0)
+	s.autoTrader.protectionLevels[symbol+"_"+side].UpdatedAt = time.Now().Add(-time.Minute)
+}
+
+// TestCheckStopLossProtection 测试止损守护
+func (s *AutoTraderTestSuite) TestCheckStopLossProtection() {
+	longPosition := map[string]interface{}{
+		"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1, "entryPrice": 50000.0, "markPrice": 50500.0, "leverage": 10.0,
+	}
+
+	tests := []struct {
+		name              string
+		setup             func()
+		checks            int
+		expectedSetCalls  int
+		expectClosed      bool
+		expectedFailCount int
+		expectAlerted     bool
+	}{
+		{
+			name: "已有止损单_无需重建",
+			setup: func() {
+				s.mockTrader.stopLossOrders = []map[string]interface{}{
+					{"symbol": "BTCUSDT", "positionSide": "LONG", "stopPrice": 49000.0},
+				}
+				s.setStaleProtectionLevel("BTCUSDT", "long", 49000.0)
+			},
+			checks:           1,
+			expectedSetCalls: 0,
+		},
+		{
+			name: "缺少止损单_按缓存重建",
+			setup: func() {
+				s.setStaleProtectionLevel("BTCUSDT", "long", 49000.0)
+			},
+			checks:           1,
+			expectedSetCalls: 1,
+		},
+		{
+			name: "只有空单止损_多单仍需重建",
+			setup: func() {
+				s.mockTrader.stopLossOrders = []map[string]interface{}{
+					{"symbol": "BTCUSDT", "positionSide": "SHORT", "stopPrice": 52000.0},
+				}
+				s.setStaleProtectionLevel("BTCUSDT", "long", 49000.0)
+			},
+			checks:           1,
+			expectedSetCalls: 1,
+		},
+		{
+			name: "宽限期内_跳过检查",
+			setup: func() {
+				s.autoTrader.recordProtectionLevels("BTCUSDT", "long", 49000.0, 0)
+			},
+			checks:           1,
+			expectedSetCalls: 0,
+		},
+		{
+			name: "重建失败未达上限_不平仓",
+			setup: func() {
+				s.setStaleProtectionLevel("BTCUSDT", "long", 49000.0)
+				s.mockTrader.shouldFailStopLoss = true
+			},
+			checks:            maxStopLossRecreateAttempts - 1,
+			expectedSetCalls:  maxStopLossRecreateAttempts - 1,
+			expectedFailCount: maxStopLossRecreateAttempts - 1,
+		},
+		{
+			name: "重建持续失败_强制平仓",
+			setup: func() {
+				s.setStaleProtectionLevel("BTCUSDT", "long", 49000.0)
+				s.mockTrader.shouldFailStopLoss = true
+			},
+			checks:           maxStopLossRecreateAttempts,
+			expectedSetCalls: maxStopLossRecreateAttempts,
+			expectClosed:     true,
+		},
+		{
+			name:             "没有止损决策记录_只告警不平仓",
+			setup:            func() {},
+			checks:           maxStopLossRecreateAttempts + 1,
+			expectedSetCalls: 0,
+			expectAlerted:    true,
+		},
+		{
+			name: "止损价已被穿越_立即平仓",
+			setup: func() {
+				s.setStaleProtectionLevel("BTCUSDT", "long", 51000.0)
+			},
+			checks:           1,
+			expectedSetCalls: 0,
+			expectClosed:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+			s.mockTrader.positions = []map[string]interface{}{longPosition}
+			s.mockTrader.stopLossOrders = nil
+			s.mockTrader.shouldFailStopLoss = false
+			s.mockTrader.setStopLossCalls = 0
+			s.autoTrader.clearProtectionState("BTCUSDT", "long")
+			s.autoTrader.UpdatePeakPnL("BTCUSDT", "long", 10.0)
+
+			tt.setup()
+			for i := 0; i < tt.checks; i++ {
+				s.autoTrader.checkStopLossProtection()
+			}
+
+			s.Equal(tt.expectedSetCalls, s.mockTrader.setStopLossCalls)
+			s.Equal(tt.expectedFailCount, s.autoTrader.stopLossFailures["BTCUSDT_long"])
+			s.Equal(tt.expectAlerted, s.autoTrader.unknownStopAlerted["BTCUSDT_long"])
+
+			_, peakExists := s.autoTrader.GetPeakPnLCache()["BTCUSDT_long"]
+			s.Equal(!tt.expectClosed, peakExists, "平仓后应清理峰值缓存")
+
+			if tt.expectClosed {
+				records, err := s.autoTrader.decisionLogger.GetLatestRecords(1)
+				s.NoError(err)
+				s.Require().Len(records, 1)
+				s.Require().Len(records[0].Decisions, 1)
+				s.Equal("auto_close_long", records[0].Decisions[0].Action)
+				s.True(records[0].Decisions[0].Success)
+			}
+
+			s.mockTrader.positions = []map[string]interface{}{}
+			s.mockTrader.shouldFailStopLoss = false
+		})
+	}
+}
+
+// TestLastKnownStopLoss 测试从缓存和决策日志回溯止损价
+func (s *AutoTraderTestSuite) TestLastKnownStopLoss() {
+	logRecord := func(decisions []decision.Decision, actions []logger.DecisionAction) {
+		decisionJSON, _ := json.Marshal(decisions)
+		s.Require().NoError(s.autoTrader.decisionLogger.LogDecision(&logger.DecisionRecord{
+			DecisionJSON: string(decisionJSON),
+			Decisions:    actions,
+			Success:      true,
+		}))
+	}
+
+	s.Run("缓存优先", func() {
+		s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+		s.autoTrader.recordProtectionLevels("ETHUSDT", "short", 3200.0, 2800.0)
+		defer s.autoTrader.clearProtectionState("ETHUSDT", "short")
+
+		stopLoss, ok := s.autoTrader.lastKnownStopLoss("ETHUSDT", "short")
+		s.True(ok)
+		s.Equal(3200.0, stopLoss)
+	})
+
+	s.Run("从决策日志回溯", func() {
+		s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+		s.autoTrader.clearProtectionState("BTCUSDT", "long")
+		defer s.autoTrader.clearProtectionState("BTCUSDT", "long")
+
+		logRecord(
+			[]decision.Decision{{Symbol: "BTCUSDT", Action: "open_long", StopLoss: 48000.0, TakeProfit: 56000.0}},
+			[]logger.DecisionAction{{Symbol: "BTCUSDT", Action: "open_long", Success: true}},
+		)
+		logRecord(
+			[]decision.Decision{{Symbol: "BTCUSDT", Action: "update_stop_loss", NewStopLoss: 49500.0}},
+			[]logger.DecisionAction{{Symbol: "BTCUSDT", Action: "update_stop_loss", Success: true}},
+		)
+		// 执行失败的调整不应被采用
+		logRecord(
+			[]decision.Decision{{Symbol: "BTCUSDT", Action: "update_stop_loss", NewStopLoss: 60000.0}},
+			[]logger.DecisionAction{{Symbol: "BTCUSDT", Action: "update_stop_loss", Success: false}},
+		)
+
+		stopLoss, ok := s.autoTrader.lastKnownStopLoss("BTCUSDT", "long")
+		s.True(ok)
+		s.Equal(49500.0, stopLoss)
+
+		_, ok = s.autoTrader.lastKnownStopLoss("SOLUSDT", "long")
+		s.False(ok)
+	})
+
+	s.Run("平仓之前的决策不采用", func() {
+		s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+		s.autoTrader.clearProtectionState("BTCUSDT", "long")
+		defer s.autoTrader.clearProtectionState("BTCUSDT", "long")
+
+		logRecord(
+			[]decision.Decision{{Symbol: "BTCUSDT", Action: "open_long", StopLoss: 48000.0, TakeProfit: 56000.0}},
+			[]logger.DecisionAction{{Symbol: "BTCUSDT", Action: "open_long", Success: true}},
+		)
+		logRecord(
+			[]decision.Decision{{Symbol: "BTCUSDT", Action: "close_long"}},
+			[]logger.DecisionAction{{Symbol: "BTCUSDT", Action: "close_long", Success: true}},
+		)
+
+		_, ok := s.autoTrader.lastKnownStopLoss("BTCUSDT", "long")
+		s.False(ok)
+	})
+
+	s.Run("加仓和调整止损止盈的决策", func() {
+		tests := []struct {
+			name     string
+			decision decision.Decision
+			action   logger.DecisionAction
+			want     float64
+		}{
+			{
+				name:     "update_sl_tp",
+				decision: decision.Decision{Symbol: "BTCUSDT", Action: "update_sl_tp", NewStopLoss: 49000.0, NewTakeProfit: 58000.0},
+				action:   logger.DecisionAction{Symbol: "BTCUSDT", Action: "update_sl_tp", Side: "long", Success: true},
+				want:     49000.0,
+			},
+			{
+				name:     "add_to_position",
+				decision: decision.Decision{Symbol: "BTCUSDT", Action: "add_to_position", StopLoss: 48500.0, TakeProfit: 57000.0},
+				action:   logger.DecisionAction{Symbol: "BTCUSDT", Action: "add_to_position", Side: "long", Success: true},
+				want:     48500.0,
+			},
+		}
+		for _, tt := range tests {
+			s.Run(tt.name, func() {
+				s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+				s.autoTrader.clearProtectionState("BTCUSDT", "long")
+				defer s.autoTrader.clearProtectionState("BTCUSDT", "long")
+
+				logRecord(
+					[]decision.Decision{{Symbol: "BTCUSDT", Action: "open_long", StopLoss: 48000.0, TakeProfit: 56000.0}},
+					[]logger.DecisionAction{{Symbol: "BTCUSDT", Action: "open_long", Success: true}},
+				)
+				logRecord([]decision.Decision{tt.decision}, []logger.DecisionAction{tt.action})
+
+				stopLoss, ok := s.autoTrader.lastKnownStopLoss("BTCUSDT", "long")
+				s.True(ok)
+				s.Equal(tt.want, stopLoss)
+			})
+		}
+	})
+
+	s.Run("反手", func() {
+		s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+		s.autoTrader.clearProtectionState("BTCUSDT", "long")
+		s.autoTrader.clearProtectionState("BTCUSDT", "short")
+		defer s.autoTrader.clearProtectionState("BTCUSDT", "long")
+		defer s.autoTrader.clearProtectionState("BTCUSDT", "short")
+
+		logRecord(
+			[]decision.Decision{{Symbol: "BTCUSDT", Action: "open_long", StopLoss: 48000.0, TakeProfit: 56000.0}},
+			[]logger.DecisionAction{{Symbol: "BTCUSDT", Action: "open_long", Success: true}},
+		)
+		logRecord(
+			[]decision.Decision{{Symbol: "BTCUSDT", Action: "reverse", StopLoss: 52000.0, TakeProfit: 45000.0}},
+			[]logger.DecisionAction{{Symbol: "BTCUSDT", Action: "reverse", Side: "short", Success: true}},
+		)
+
+		stopLoss, ok := s.autoTrader.lastKnownStopLoss("BTCUSDT", "short")
+		s.True(ok)
+		s.Equal(52000.0, stopLoss)
+
+		// 反手前的多仓已平掉，其止损不再采用
+		_, ok = s.autoTrader.lastKnownStopLoss("BTCUSDT", "long")
+		s.False(ok)
+	})
+
+	s.Run("双向持仓只采用本方向的止损调整", func() {
+		s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+		s.autoTrader.clearProtectionState("BTCUSDT", "long")
+		s.autoTrader.clearProtectionState("BTCUSDT", "short")
+		defer s.autoTrader.clearProtectionState("BTCUSDT", "long")
+		defer s.autoTrader.clearProtectionState("BTCUSDT", "short")
+
+		logRecord(
+			[]decision.Decision{
+				{Symbol: "BTCUSDT", Action: "open_long", StopLoss: 48000.0, TakeProfit: 56000.0},
+				{Symbol: "BTCUSDT", Action: "open_short", StopLoss: 53000.0, TakeProfit: 45000.0},
+			},
+			[]logger.DecisionAction{
+				{Symbol: "BTCUSDT", Action: "open_long", Success: true},
+				{Symbol: "BTCUSDT", Action: "open_short", Success: true},
+			},
+		)
+		logRecord(
+			[]decision.Decision{{Symbol: "BTCUSDT", Action: "update_stop_loss", NewStopLoss: 52000.0}},
+			[]logger.DecisionAction{{Symbol: "BTCUSDT", Action: "update_stop_loss", Side: "short", Success: true}},
+		)
+
+		stopLoss, ok := s.autoTrader.lastKnownStopLoss("BTCUSDT", "short")
+		s.True(ok)
+		s.Equal(52000.0, stopLoss)
+
+		// 空仓的止损调整不属于多仓
+		stopLoss, ok = s.autoTrader.lastKnownStopLoss("BTCUSDT", "long")
+		s.True(ok)
+		s.Equal(48000.0, stopLoss)
+	})
+}
@@ -47,6 +47,7 @@ func (s *TraderTestSuite) RunAllTests() {
 	// 配置方法
 	s.T.Run("SetLeverage", func(t *testing.T) { s.TestSetLeverage() })
 	s.T.Run("SetMarginMode", func(t *testing.T) { s.TestSetMarginMode() })
+	s.T.Run("AddIsolatedMargin", func(t *testing.T) { s.TestAddIsolatedMargin() })
 	s.T.Run("FormatQuantity", func(t *testing.T) { s.TestFormatQuantity() })
 
 	// 核心交易方法
@@ -64,6 +65,10 @@ func (s *TraderTestSuite) RunAllTests() {
 	s.T.Run("CancelStopOrders", func(t *testing.T) { s.TestCancelStopOrders() })
 	s.T.Run("CancelStopLossOrders", func(t *testing.T) { s.TestCancelStopLossOrders() })
 	s.T.Run("CancelTakeProfitOrders", func(t *testing.T) { s.TestCancelTakeProfitOrders() })
+	s.T.Run("GetStopLossOrders", func(t *testing.T) { s.TestGetStopLossOrders() })
+
+	// 资金费
+	s.T.Run("GetFundingPayments", func(t *testing.T) { s.TestGetFundingPayments() })
 }
 
 // TestGetBalance 测试获取账户余额
@@ -243,6 +248,43 @@ func (s *TraderTestSuite) TestSetMarginMode() {
 	}
 }
 
+// TestAddIsolatedMargin 测试追加逐仓保证金
+func (s *TraderTestSuite) TestAddIsolatedMargin() {
+	tests := []struct {
+		name         string
+		symbol       string
+		positionSide string
+		amount       float64
+		wantError    bool
+	}{
+		{
+			name:         "多仓追加保证金",
+			symbol:       "BTCUSDT",
+			positionSide: "LONG",
+			amount:       50,
+			wantError:    false,
+		},
+		{
+			name:         "空仓追加保证金",
+			symbol:       "ETHUSDT",
+			positionSide: "SHORT",
+			amount:       20,
+			wantError:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		s.T.Run(tt.name, func(t *testing.T) {
+			err := s.Trader.AddIsolatedMargin(tt.symbol, tt.positionSide, tt.amount)
+			if tt.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 // TestFormatQuantity 测试数量格式化
 func (s *TraderTestSuite) TestFormatQuantity() {
 	tests := []struct {
@@ -662,3 +704,45 @@ func (s *TraderTestSuite) TestCancelTakeProfitOrders() {
 		})
 	}
 }
+
+// TestGetStopLossOrders 测试获取止损单
+func (s *TraderTestSuite) TestGetStopLossOrders() {
+	tests := []struct {
+		name      string
+		symbol    string
+		wantError bool
+	}{
+		{
+			name:      "获取BTC止损单",
+			symbol:    "BTCUSDT",
+			wantError: false,
+		},
+	}
+
+	for _, tt := range tests {
+		s.T.Run(tt.name, func(t *testing.T) {
+			orders, err := s.Trader.GetStopLossOrders(tt.symbol)
+			if tt.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			for _, order := range orders {
+				assert.Contains(t, []string{"LONG", "SHORT"}, order["positionSide"])
+				assert.Contains(t, order, "stopPrice")
+			}
+		})
+	}
+}
+
+// TestGetFundingPayments 测试获取资金费流水
+func (s *TraderTestSuite) TestGetFundingPayments() {
+	payments, err := s.Trader.GetFundingPayments(1700000000000)
+	assert.NoError(s.T, err)
+	assert.NotEmpty(s.T, payments)
+	for _, payment := range payments {
+		assert.Equal(s.T, "BTCUSDT", payment["symbol"])
+		assert.InDelta(s.T, -0.25, payment["amount"], 1e-9)
+		assert.Equal(s.T, int64(1700000100000), payment["time"])
+	}
+}